@@ -0,0 +1,148 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// valeurs du champ access
+const (
+	accessFull    = "full"
+	accessPartial = "partial"
+	accessBlocked = "blocked"
+)
+
+// valeurs du champ block_reason
+const (
+	reasonPaywall     = "paywall"
+	reasonConsentWall = "consent_wall"
+	reasonTruncated   = "truncated"
+	reasonTeaser      = "teaser"
+)
+
+// marqueurs de paywall (Piano, Tinypass, compteurs d'articles...)
+var paywallSelector = strings.Join([]string{
+	`[id^="piano-"]`, `[class*="piano-"]`,
+	".paywall", `[class*="paywall"]`, `[id*="paywall"]`,
+	".meteredContent", `[class*="metered"]`,
+	".tp-modal", ".tp-container-inner",
+	`[class*="subscriber-only"]`, `[class*="premium-content"]`,
+}, ", ")
+
+// conteneurs des gestionnaires de consentement (OneTrust, Didomi, Quantcast, Cookiebot, Sourcepoint, Funding Choices)
+var consentSelector = strings.Join([]string{
+	"#onetrust-consent-sdk", "#onetrust-banner-sdk",
+	"#didomi-host", ".didomi-popup",
+	"#qc-cmp2-container", ".qc-cmp2-container",
+	"#CybotCookiebotDialog",
+	`[id^="sp_message_container"]`,
+	".fc-consent-root",
+}, ", ")
+
+const (
+	// texte hors CMP en dessous duquel la page n'est qu'un mur de consentement
+	consentWallMaxWords = 150
+	// texte extrait en dessous duquel un paywall bloque l'article
+	paywallBlockedMaxWords = 100
+	// au-delà, un marqueur de paywall ne rend l'article partiel que si le
+	// texte paraît tronqué : moins de mots que ceci, ou une mention de coupure
+	paywallPartialMaxWords = 400
+	// un texte extrait vaut un simple chapeau s'il ne dépasse pas ce multiple de og:description
+	teaserRatio    = 2
+	teaserMaxWords = 100
+)
+
+// accessSignals : indices relevés sur le document avant nettoyage
+// (removeBoilerplate retire les bandeaux de consentement)
+type accessSignals struct {
+	paywall      bool // marqueurs DOM ou JSON-LD isAccessibleForFree=false
+	consent      bool // une CMP domine le corps de la page
+	bodyWords    int  // longueur annoncée par JSON-LD articleBody
+	summaryWords int  // longueur de la description
+	cutNotice    bool // invitation à lire la suite (abonnement, connexion)
+}
+
+func collectAccessSignals(doc *goquery.Document, meta Metadata) accessSignals {
+	var s accessSignals
+	s.paywall = doc.Find(paywallSelector).Length() > 0
+	s.summaryWords, _ = countWords(meta.Description)
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, script *goquery.Selection) {
+		block, err := parseJSONLD(script.Text())
+		if err != nil {
+			return
+		}
+		walkJSONLD(block, func(node map[string]any) {
+			switch free := node["isAccessibleForFree"].(type) {
+			case bool:
+				s.paywall = s.paywall || !free
+			case string:
+				s.paywall = s.paywall || strings.EqualFold(free, "false")
+			}
+			if body, ok := node["articleBody"].(string); ok {
+				if words, _ := countWords(body); words > s.bodyWords {
+					s.bodyWords = words
+				}
+			}
+		})
+	})
+
+	s.cutNotice = cutNotice.MatchString(doc.Find("body").Text())
+
+	if doc.Find(consentSelector).Length() > 0 {
+		body := doc.Find("body").Clone()
+		body.Find("script, style, noscript, template").Remove()
+		body.Find(consentSelector).Remove()
+		outside, _ := countWords(body.Text())
+		s.consent = outside < consentWallMaxWords
+	}
+	return s
+}
+
+// invitations à s'abonner pour lire la suite, souvent retirées du texte
+// extrait par le nettoyage : cherchées dans la page
+var cutNotice = regexp.MustCompile(`(?i)continue reading|keep reading|read the (full|rest)|subscribe to (read|continue|unlock)|for subscribers only|already a subscriber|lire la suite|réservé aux abonnés`)
+
+// points de suspension en fin de texte extrait
+var trailingEllipsis = regexp.MustCompile(`(\.\.\.|…|\[…\])\W*$`)
+
+// looksTruncated : texte court, invitation à lire la suite, ou texte
+// extrait terminé par des points de suspension
+func (s accessSignals) looksTruncated(text string, words int) bool {
+	return words < paywallPartialMaxWords || s.cutNotice || trailingEllipsis.MatchString(strings.TrimSpace(text))
+}
+
+// walkJSONLD appelle fn sur chaque objet du bloc, imbriqués compris (hasPart, @graph...)
+func walkJSONLD(v any, fn func(map[string]any)) {
+	switch t := v.(type) {
+	case []any:
+		for _, item := range t {
+			walkJSONLD(item, fn)
+		}
+	case map[string]any:
+		fn(t)
+		for _, child := range t {
+			walkJSONLD(child, fn)
+		}
+	}
+}
+
+// classify compare les indices au texte effectivement extrait ; un marqueur
+// de paywall sur un article long et complet (bandeau d'abonnement) ne compte pas
+func (s accessSignals) classify(text string, words int) (access, reason string) {
+	switch {
+	case s.consent:
+		return accessBlocked, reasonConsentWall
+	case s.paywall && words < paywallBlockedMaxWords:
+		return accessBlocked, reasonPaywall
+	case s.paywall && s.looksTruncated(text, words):
+		return accessPartial, reasonPaywall
+	case s.bodyWords > 0 && words*2 < s.bodyWords:
+		return accessPartial, reasonTruncated
+	case s.summaryWords > 0 && words < teaserMaxWords && words <= s.summaryWords*teaserRatio:
+		return accessPartial, reasonTeaser
+	}
+	return accessFull, ""
+}
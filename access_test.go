@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessClassification(t *testing.T) {
+	tests := []struct {
+		fixture, access, reason string
+	}{
+		{"hard-paywall.html", accessBlocked, reasonPaywall},
+		{"metered-teaser.html", accessPartial, reasonPaywall},
+		{"metered-long.html", accessPartial, reasonPaywall},
+		{"full-with-banner.html", accessFull, ""},
+		{"consent-wall.html", accessBlocked, reasonConsentWall},
+		{"../news.html", accessFull, ""},
+	}
+	e := newTestEngine(t, nil)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			article := extractFixture(t, e, "access/"+tt.fixture, extractOptions{})
+			if article.Access != tt.access || article.BlockReason != tt.reason {
+				t.Errorf("access=%q reason=%q (%d words), want %q %q", article.Access, article.BlockReason, article.WordCount, tt.access, tt.reason)
+			}
+		})
+	}
+}
+
+// bloqué, la réponse garde le texte trouvé
+func TestBlockedPageKeepsText(t *testing.T) {
+	e := newTestEngine(t, nil)
+	article := extractFixture(t, e, "access/hard-paywall.html", extractOptions{})
+	if !strings.Contains(article.CleanText, "harbour wall") {
+		t.Errorf("teaser text dropped: %q", article.CleanText)
+	}
+}
+
+func TestClassifySignals(t *testing.T) {
+	long := strings.Repeat("word ", 500)
+	tests := []struct {
+		name           string
+		signals        accessSignals
+		text           string
+		access, reason string
+	}{
+		{"nothing", accessSignals{}, long, accessFull, ""},
+		{"paywall, short text", accessSignals{paywall: true}, strings.Repeat("word ", 50), accessBlocked, reasonPaywall},
+		{"paywall, medium text", accessSignals{paywall: true}, strings.Repeat("word ", 250), accessPartial, reasonPaywall},
+		{"paywall, long complete text", accessSignals{paywall: true}, long, accessFull, ""},
+		{"paywall, subscribe notice", accessSignals{paywall: true, cutNotice: true}, long, accessPartial, reasonPaywall},
+		{"notice without paywall", accessSignals{cutNotice: true}, long, accessFull, ""},
+		{"paywall, ellipsis", accessSignals{paywall: true}, long + "and then…", accessPartial, reasonPaywall},
+		{"articleBody twice as long", accessSignals{bodyWords: 1200}, long, accessPartial, reasonTruncated},
+		{"description-sized text", accessSignals{summaryWords: 30}, strings.Repeat("word ", 40), accessPartial, reasonTeaser},
+		{"consent wins", accessSignals{consent: true, paywall: true}, long, accessBlocked, reasonConsentWall},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, _ := countWords(tt.text)
+			access, reason := tt.signals.classify(tt.text, words)
+			if access != tt.access || reason != tt.reason {
+				t.Errorf("got %q %q, want %q %q", access, reason, tt.access, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clés récentes listées par défaut et au plus par /cache/stats
+const (
+	defaultRecentKeys = 10
+	maxRecentKeys     = 100
+)
+
+// cacheStatsResponse : les clés sont des empreintes (hôte, URL, options),
+// aucune URL n'apparaît en clair
+type cacheStatsResponse struct {
+	Backend     string   `json:"backend"`
+	Entries     int      `json:"entries"`
+	MemoryBytes int64    `json:"memory_bytes"`
+	Hits        int64    `json:"hits"`
+	Misses      int64    `json:"misses"`
+	Errors      int64    `json:"errors"`
+	HitRatio    float64  `json:"hit_ratio"`
+	MissRatio   float64  `json:"miss_ratio"`
+	RecentKeys  []string `json:"recent_keys"`
+}
+
+// cachePurgeHandler évince une URL (toutes options) ou tout un hôte
+// (DELETE /cache?url=... ou ?host=...)
+func (s *server) cachePurgeHandler(c *gin.Context) {
+	pageURL, host := c.Query("url"), c.Query("host")
+	var prefix string
+	switch {
+	case pageURL != "" && host != "":
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "use either url or host, not both"))
+		return
+	case pageURL != "":
+		if u, err := url.Parse(pageURL); err != nil || u.Host == "" {
+			respondError(c, newAPIError(http.StatusBadRequest, codeInvalidURL, "invalid url"))
+			return
+		}
+		prefix = urlPrefix(normalizeURL(pageURL))
+	case host != "":
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		prefix = hostPrefix(strings.Trim(host, "[]"))
+	default:
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing url or host parameter"))
+		return
+	}
+	s.respondPurge(c, prefix)
+}
+
+// cachePurgeAllHandler vide le cache ; confirm=true est exigé (DELETE /cache/all)
+func (s *server) cachePurgeAllHandler(c *gin.Context) {
+	if c.Query("confirm") != "true" {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "purging the whole cache requires confirm=true"))
+		return
+	}
+	s.respondPurge(c, storePrefix)
+}
+
+func (s *server) respondPurge(c *gin.Context, prefix string) {
+	deleted, err := s.engine.cache.purge(c.Request.Context(), prefix)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusServiceUnavailable, codeInternal, "cache purge failed: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// cacheStatsHandler décrit le cache (GET /cache/stats?recent=N)
+func (s *server) cacheStatsHandler(c *gin.Context) {
+	recent := defaultRecentKeys
+	if v := c.Query("recent"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxRecentKeys {
+			respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "recent must be between 0 and "+strconv.Itoa(maxRecentKeys)))
+			return
+		}
+		recent = n
+	}
+
+	cache := s.engine.cache
+	stats, err := cache.store.Stats(c.Request.Context(), storePrefix, recent)
+	if err != nil {
+		respondError(c, newAPIError(http.StatusServiceUnavailable, codeInternal, "cache stats failed: "+err.Error()))
+		return
+	}
+	resp := cacheStatsResponse{
+		Backend:     "memory",
+		Entries:     stats.Entries,
+		MemoryBytes: stats.Bytes,
+		Hits:        cache.hits.Load(),
+		Misses:      cache.misses.Load(),
+		Errors:      cache.errors.Load(),
+		RecentKeys:  stats.Recent,
+	}
+	if _, ok := cache.store.(*redisStore); ok {
+		resp.Backend = "redis"
+	}
+	if resp.RecentKeys == nil {
+		resp.RecentKeys = []string{}
+	}
+	if total := resp.Hits + resp.Misses; total > 0 {
+		resp.HitRatio = float64(resp.Hits) / float64(total)
+		resp.MissRatio = float64(resp.Misses) / float64(total)
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// adminRequest envoie une requête authentifiée par testAdminKey
+func adminRequest(t *testing.T, ts *httptest.Server, method, path string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", testAdminKey)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+// purge appelle DELETE path et retourne le nombre d'entrées supprimées
+func purge(t *testing.T, ts *httptest.Server, path string) int {
+	t.Helper()
+	status, body := adminRequest(t, ts, http.MethodDelete, path)
+	var resp struct {
+		Deleted int `json:"deleted"`
+	}
+	if status != http.StatusOK || json.Unmarshal(body, &resp) != nil {
+		t.Fatalf("DELETE %s: %d %s", path, status, body)
+	}
+	return resp.Deleted
+}
+
+// extractIsCached extrait pageURL et indique si la réponse vient du cache
+func extractIsCached(t *testing.T, ts *httptest.Server, pageURL, extra string) bool {
+	t.Helper()
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(pageURL)+extra, &article); status != http.StatusOK {
+		t.Fatalf("extract %s: %d", pageURL, status)
+	}
+	return article.Cached
+}
+
+// la même origine sous deux noms d'hôte : 127.0.0.1 et localhost
+func twoHosts(origin *httptest.Server) (string, string) {
+	return origin.URL, strings.Replace(origin.URL, "127.0.0.1", "localhost", 1)
+}
+
+func TestPurgeByHostThenMiss(t *testing.T) {
+	counter, origin := newCountingOrigin(t, false)
+	s, ts := newTestServer(t, nil)
+	ip, local := twoHosts(origin)
+	for _, page := range []string{ip + "/a", ip + "/b", local + "/c"} {
+		extractIsCached(t, ts, page, "")
+	}
+	if !extractIsCached(t, ts, ip+"/a", "") {
+		t.Fatal("populated entry not served from the cache")
+	}
+
+	if n := purge(t, ts, "/cache?host=127.0.0.1"); n != 2 {
+		t.Errorf("purged %d entries for 127.0.0.1, want 2", n)
+	}
+	before := counter.hits.Load()
+	if extractIsCached(t, ts, ip+"/a", "") || extractIsCached(t, ts, ip+"/b", "") {
+		t.Error("purged host still served from the cache")
+	}
+	if counter.hits.Load() != before+2 {
+		t.Errorf("origin hits %d, want %d", counter.hits.Load(), before+2)
+	}
+	// l'autre hôte n'est pas touché
+	if !extractIsCached(t, ts, local+"/c", "") {
+		t.Error("localhost entry purged with 127.0.0.1")
+	}
+	if misses := s.engine.cache.misses.Load(); misses != 5 {
+		t.Errorf("%d misses, want 5", misses)
+	}
+
+	// hôte avec port et majuscules : même préfixe
+	if n := purge(t, ts, "/cache?host="+url.QueryEscape(strings.TrimPrefix(strings.ToUpper(local), "HTTP://"))); n != 1 {
+		t.Errorf("purged %d entries for LOCALHOST:port, want 1", n)
+	}
+}
+
+func TestPurgeByURL(t *testing.T) {
+	_, origin := newCountingOrigin(t, false)
+	_, ts := newTestServer(t, nil)
+	extractIsCached(t, ts, origin.URL+"/a?x=1&y=2", "")
+	extractIsCached(t, ts, origin.URL+"/a?x=1&y=2", "&format=markdown")
+	extractIsCached(t, ts, origin.URL+"/b", "")
+
+	// URL normalisée : fragment, ordre de la query ; toutes options confondues
+	if n := purge(t, ts, "/cache?url="+url.QueryEscape(origin.URL+"/a?y=2&x=1#top")); n != 2 {
+		t.Errorf("purged %d entries, want 2", n)
+	}
+	if extractIsCached(t, ts, origin.URL+"/a?x=1&y=2", "") {
+		t.Error("purged URL still served from the cache")
+	}
+	if !extractIsCached(t, ts, origin.URL+"/b", "") {
+		t.Error("other URL purged")
+	}
+}
+
+func TestPurgeAllNeedsConfirm(t *testing.T) {
+	_, origin := newCountingOrigin(t, false)
+	_, ts := newTestServer(t, nil)
+	extractIsCached(t, ts, origin.URL+"/a", "")
+	extractIsCached(t, ts, origin.URL+"/b", "")
+
+	for _, path := range []string{"/cache/all", "/cache/all?confirm=false"} {
+		if status, body := adminRequest(t, ts, http.MethodDelete, path); status != http.StatusBadRequest || !strings.Contains(string(body), "confirm") {
+			t.Errorf("DELETE %s: got %d %s, want 400", path, status, body)
+		}
+	}
+	if !extractIsCached(t, ts, origin.URL+"/a", "") {
+		t.Fatal("cache emptied without confirm")
+	}
+	if n := purge(t, ts, "/cache/all?confirm=true"); n != 2 {
+		t.Errorf("purged %d entries, want 2", n)
+	}
+	if extractIsCached(t, ts, origin.URL+"/b", "") {
+		t.Error("entry left after /cache/all")
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	_, origin := newCountingOrigin(t, false)
+	_, ts := newTestServer(t, nil)
+	for _, page := range []string{"/a", "/b", "/a", "/c", "/a"} {
+		extractIsCached(t, ts, origin.URL+page, "")
+	}
+
+	status, body := adminRequest(t, ts, http.MethodGet, "/cache/stats?recent=2")
+	var stats cacheStatsResponse
+	if status != http.StatusOK || json.Unmarshal(body, &stats) != nil {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if stats.Backend != "memory" || stats.Entries != 3 || stats.MemoryBytes <= 0 {
+		t.Errorf("backend=%s entries=%d memory=%d", stats.Backend, stats.Entries, stats.MemoryBytes)
+	}
+	if stats.Hits != 2 || stats.Misses != 3 || stats.HitRatio != 0.4 || stats.MissRatio != 0.6 {
+		t.Errorf("hits=%d misses=%d ratios %.2f/%.2f", stats.Hits, stats.Misses, stats.HitRatio, stats.MissRatio)
+	}
+	// la plus récente en tête ; des empreintes, jamais l'URL ni l'hôte
+	if len(stats.RecentKeys) != 2 || !strings.HasPrefix(stats.RecentKeys[0], urlPrefix(normalizeURL(origin.URL+"/a"))) {
+		t.Errorf("recent keys %v", stats.RecentKeys)
+	}
+	if strings.Contains(string(body), "127.0.0.1") || strings.Contains(string(body), "/a") {
+		t.Errorf("stats leak a URL: %s", body)
+	}
+
+	for _, recent := range []string{"-1", "101", "ten"} {
+		if status, _ := adminRequest(t, ts, http.MethodGet, "/cache/stats?recent="+recent); status != http.StatusBadRequest {
+			t.Errorf("recent=%s: got %d, want 400", recent, status)
+		}
+	}
+}
+
+func TestPurgeValidation(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	tests := []struct {
+		path, code string
+	}{
+		{"/cache", codeInvalidRequest},
+		{"/cache?url=https://example.com/a&host=example.com", codeInvalidRequest},
+		{"/cache?url=not-a-url", codeInvalidURL},
+	}
+	for _, tt := range tests {
+		status, body := adminRequest(t, ts, http.MethodDelete, tt.path)
+		if status != http.StatusBadRequest || errorCode(t, body) != tt.code {
+			t.Errorf("DELETE %s: got %d %s, want 400 %s", tt.path, status, body, tt.code)
+		}
+	}
+	// clé d'API ordinaire refusée
+	if status, _ := apiRequest(t, ts, http.MethodDelete, "/cache?host=example.com", "", ""); status != http.StatusUnauthorized {
+		t.Errorf("api key on DELETE /cache: got %d, want 401", status)
+	}
+}
+
+// purges et lectures concurrentes (go test -race)
+func TestPurgeDuringReads(t *testing.T) {
+	_, origin := newCountingOrigin(t, false)
+	_, ts := newTestServer(t, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				extractIsCached(t, ts, origin.URL+"/a", "")
+			}
+		}()
+	}
+	for j := 0; j < 10; j++ {
+		purge(t, ts, "/cache?host=127.0.0.1")
+	}
+	wg.Wait()
+	purge(t, ts, "/cache?host=127.0.0.1")
+	if extractIsCached(t, ts, origin.URL+"/a", "") {
+		t.Error("entry served from the cache right after a purge")
+	}
+}
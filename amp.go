@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// version de la page ayant servi à l'extraction
+const (
+	variantCanonical = "canonical"
+	variantAMP       = "amp"
+)
+
+// valeur de prefer : tenter la version AMP même si la page extrait bien
+const preferAMP = "amp"
+
+func validPrefer(prefer string) bool {
+	return prefer == "" || prefer == preferAMP
+}
+
+// useAMP indique si la version <link rel="amphtml"> doit être essayée :
+// prefer=amp, ou moins de AMP_FALLBACK_MIN_WORDS mots extraits
+func (e *engine) useAMP(article *Article, opts extractOptions) bool {
+	if article.ampURL == "" || opts.Raw {
+		return false
+	}
+	return opts.Prefer == preferAMP || article.WordCount < e.cfg.AMPFallbackMinWords
+}
+
+// extractAMP télécharge et extrait la version AMP de l'article. Elle n'est
+// retenue que si elle a plus de texte, ou si prefer=amp et qu'elle n'est pas
+// vide ; sinon l'article d'origine est conservé. Un seul saut : le lien
+// amphtml de la page AMP n'est pas suivi, et une page AMP qui renvoie vers
+// la page d'origine est ignorée.
+func (e *engine) extractAMP(ctx context.Context, article *Article, opts extractOptions) *Article {
+	ampURL := article.ampURL
+	if ampURL == article.FinalURL {
+		return article
+	}
+	// fetchPage applique la même validation (SSRF, redirections) que l'URL d'origine
+	resp, body, attempts, err := e.fetchPage(ctx, ampURL, opts.Fetch)
+	if err != nil {
+		log.Printf("amp fallback %s: %v", ampURL, err)
+		return article
+	}
+	finalURL := resp.Request.URL.String()
+	if finalURL == article.FinalURL {
+		return article
+	}
+	amp, err := e.extractHTML(ctx, body, resp.Header.Get("Content-Type"), finalURL, opts)
+	if err != nil {
+		log.Printf("amp fallback %s: %v", ampURL, err)
+		return article
+	}
+	if amp.WordCount == 0 || (opts.Prefer != preferAMP && amp.WordCount <= article.WordCount) {
+		return article
+	}
+
+	amp.SourceVariant = variantAMP
+	amp.FinalURL = finalURL
+	amp.RedirectChain = redirectChain(resp)
+	amp.Attempts = article.Attempts + attempts
+	// la revalidation porte sur la page d'origine
+	amp.etag, amp.lastModified = article.etag, article.lastModified
+	if amp.CanonicalURL == "" {
+		amp.CanonicalURL = article.FinalURL
+	}
+	return amp
+}
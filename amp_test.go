@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAMPFallbackForEmptyShell(t *testing.T) {
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(fixtures.URL+"/amp/shell.html"), &article); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if article.SourceVariant != variantAMP || article.FinalURL != fixtures.URL+"/amp/shell.amp.html" {
+		t.Errorf("variant %q from %q", article.SourceVariant, article.FinalURL)
+	}
+	if article.CanonicalURL != fixtures.URL+"/amp/shell.html" {
+		t.Errorf("canonical_url %q", article.CanonicalURL)
+	}
+	if article.WordCount < 150 || !strings.Contains(article.CleanText, "refurbished ship") || article.Attempts != 2 {
+		t.Errorf("%d words over %d attempts: %.80q", article.WordCount, article.Attempts, article.CleanText)
+	}
+}
+
+func TestAMPFallbackThreshold(t *testing.T) {
+	fixtures := fixtureServer(t)
+	e := newTestEngine(t, nil)
+	shell := extractFixture(t, e, "amp/shell.html", extractOptions{})
+	if shell.ampURL == "" || !e.useAMP(shell, extractOptions{}) {
+		t.Fatalf("empty shell does not try amp (%d words)", shell.WordCount)
+	}
+
+	// seuil abaissé : la page d'origine suffit
+	_, ts := newTestServer(t, map[string]string{"AMP_FALLBACK_MIN_WORDS": "10"})
+	var article Article
+	apiGet(t, ts, "/extract?url="+url.QueryEscape(fixtures.URL+"/amp/shell.html"), &article)
+	if article.SourceVariant != variantCanonical || article.FinalURL != fixtures.URL+"/amp/shell.html" {
+		t.Errorf("variant %q from %q, want the canonical page", article.SourceVariant, article.FinalURL)
+	}
+	// prefer=amp passe outre
+	article = Article{}
+	apiGet(t, ts, "/extract?prefer=amp&url="+url.QueryEscape(fixtures.URL+"/amp/shell.html"), &article)
+	if article.SourceVariant != variantAMP {
+		t.Errorf("prefer=amp: variant %q", article.SourceVariant)
+	}
+}
+
+func TestAMPLoopsAreIgnored(t *testing.T) {
+	fixtures := fixtureServer(t)
+	// la page AMP redirige vers la page d'origine
+	var ampHits atomic.Int64
+	back := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/story.amp" {
+			ampHits.Add(1)
+			http.Redirect(w, r, "/story", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><link rel="amphtml" href="/story.amp"></head><body><p>Short shell.</p></body></html>`))
+	}))
+	defer back.Close()
+	_, ts := newTestServer(t, nil)
+
+	for _, page := range []string{fixtures.URL + "/amp/self.html", back.URL + "/story"} {
+		var article Article
+		if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(page), &article); status != http.StatusOK {
+			t.Fatalf("%s: status %d", page, status)
+		}
+		if article.SourceVariant != variantCanonical || article.FinalURL != page {
+			t.Errorf("%s: variant %q from %q", page, article.SourceVariant, article.FinalURL)
+		}
+	}
+	if ampHits.Load() != 1 {
+		t.Errorf("amp page fetched %d times, want 1", ampHits.Load())
+	}
+}
+
+// le lien amphtml passe par la même validation SSRF que l'URL demandée
+func TestAMPLinkToPrivateAddressIsIgnored(t *testing.T) {
+	var reached atomic.Bool
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Store(true)
+		largePage(w, r)
+	}))
+	defer internal.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "93.184.215.14" {
+			reached.Store(true)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Shell</title><link rel="amphtml" href="` + internal.URL + `/amp"></head><body><p>Loading.</p></body></html>`))
+	}))
+	defer proxy.Close()
+	_, ts := newTestServer(t, map[string]string{"ALLOW_PRIVATE": "false", "OUTBOUND_PROXY": proxy.URL})
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape("http://93.184.215.14/story"), &article); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if article.SourceVariant != variantCanonical || reached.Load() {
+		t.Errorf("variant %q, private amp page reached: %v", article.SourceVariant, reached.Load())
+	}
+}
+
+func TestPreferIsValidated(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?prefer=print&url=https://example.com/", "", "")
+	if status != http.StatusBadRequest || errorCode(t, body) != codeInvalidRequest {
+		t.Errorf("got %d %s", status, body)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clé du contexte gin contenant la clé API validée
+const ctxAPIKey = "apiKey"
+
+// keyStore contient les clés actives, rechargeables à chaud
+type keyStore struct {
+	mu   sync.RWMutex
+	keys [][]byte
+}
+
+func (s *keyStore) set(keys []string) {
+	var b [][]byte
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			b = append(b, []byte(k))
+		}
+	}
+	s.mu.Lock()
+	s.keys = b
+	s.mu.Unlock()
+}
+
+// valid compare en temps constant avec toutes les clés (pas de sortie anticipée)
+func (s *keyStore) valid(key string) bool {
+	if key == "" {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ok := 0
+	for _, k := range s.keys {
+		ok |= subtle.ConstantTimeCompare([]byte(key), k)
+	}
+	return ok == 1
+}
+
+// loadAPIKeys charge API_KEYS et API_KEYS_FILE, ainsi que ADMIN_KEYS :
+// clés des routes d'administration, distinctes des clés API ; sans
+// ADMIN_KEYS ces routes sont fermées. Sans aucune clé API, le démarrage
+// échoue : pas de clé par défaut partagée
+func (s *server) loadAPIKeys() error {
+	keys := slices.Clone(s.cfg.APIKeys)
+	if path := s.cfg.APIKeysFile; path != "" {
+		fileKeys, err := readKeyFile(path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if !slices.ContainsFunc(keys, func(key string) bool { return strings.TrimSpace(key) != "" }) {
+		return errors.New("no API key configured: set API_KEYS or API_KEYS_FILE")
+	}
+	admin := s.cfg.AdminKeys
+	for _, k := range admin {
+		if slices.ContainsFunc(keys, func(key string) bool { return strings.TrimSpace(key) == k }) {
+			return errors.New("ADMIN_KEYS must not reuse an API key")
+		}
+	}
+	s.apiKeys.set(keys)
+	s.adminKeys.set(admin)
+	return nil
+}
+
+// readKeyFile accepte ["k1", "k2"] ou {"keys": ["k1", "k2"]}
+func readKeyFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+	var obj struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	return obj.Keys, nil
+}
+
+// reloadKeysOnSIGHUP recharge les clés à chaque SIGHUP
+func (s *server) reloadKeysOnSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := s.loadAPIKeys(); err != nil {
+				log.Printf("api keys reload failed, keeping previous keys: %v", err)
+				continue
+			}
+			log.Println("api keys reloaded")
+		}
+	}()
+}
+
+// requestAPIKey lit la clé : X-API-Key, puis Authorization: Bearer, puis ?key=
+func requestAPIKey(c *gin.Context) string {
+	if k := c.GetHeader("X-API-Key"); k != "" {
+		return k
+	}
+	if auth := c.GetHeader("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return c.Query("key")
+}
+
+// requireAPIKey vérifie la clé API avant d'appeler le handler
+func (s *server) requireAPIKey(c *gin.Context) {
+	key := requestAPIKey(c)
+	if !s.apiKeys.valid(key) {
+		respondError(c, newAPIError(http.StatusUnauthorized, codeUnauthorized, "invalid or missing API key"))
+		return
+	}
+	c.Set(ctxAPIKey, key)
+	c.Next()
+}
+
+// requireAdminKey protège les routes d'administration
+func (s *server) requireAdminKey(c *gin.Context) {
+	if !s.adminKeys.valid(requestAPIKey(c)) {
+		respondError(c, newAPIError(http.StatusUnauthorized, codeUnauthorized, "invalid or missing admin key"))
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// usageStatus appelle /usage avec les en-têtes et la query donnés
+func usageStatus(t *testing.T, ts *httptest.Server, query string, header map[string]string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/usage"+query, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAPIKeySources(t *testing.T) {
+	_, ts := newTestServer(t, map[string]string{"API_KEYS": "key-a, key-b"})
+	tests := []struct {
+		name   string
+		query  string
+		header map[string]string
+		want   int
+	}{
+		{"missing", "", nil, http.StatusUnauthorized},
+		{"unknown", "", map[string]string{"X-API-Key": "nope"}, http.StatusUnauthorized},
+		{"x-api-key", "", map[string]string{"X-API-Key": "key-a"}, http.StatusOK},
+		{"bearer", "", map[string]string{"Authorization": "Bearer key-b"}, http.StatusOK},
+		{"bearer lowercase", "", map[string]string{"Authorization": "bearer key-b"}, http.StatusOK},
+		{"query", "?key=key-a", nil, http.StatusOK},
+		{"former demo key rejected", "?key=demo_12345", nil, http.StatusUnauthorized},
+		// l'en-tête l'emporte sur la query, dans les deux sens
+		{"header wins over bad query", "?key=nope", map[string]string{"X-API-Key": "key-a"}, http.StatusOK},
+		{"bad header wins over query", "?key=key-a", map[string]string{"X-API-Key": "nope"}, http.StatusUnauthorized},
+		{"X-API-Key before Bearer", "", map[string]string{"X-API-Key": "nope", "Authorization": "Bearer key-a"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usageStatus(t, ts, tt.query, tt.header); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeKeyFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// attend que key donne want, le rechargement étant asynchrone
+func waitKeyStatus(t *testing.T, ts *httptest.Server, key string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := usageStatus(t, ts, "", map[string]string{"X-API-Key": key})
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("key %s: got %d, want %d", key, got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// rotation : nouvelle clé ajoutée, puis ancienne retirée, sans redémarrage
+func TestAPIKeyRotationOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, path, `["old-key"]`)
+	s, ts := newTestServer(t, map[string]string{"API_KEYS": "", "API_KEYS_FILE": path})
+	s.reloadKeysOnSIGHUP()
+	waitKeyStatus(t, ts, "old-key", http.StatusOK)
+	waitKeyStatus(t, ts, "new-key", http.StatusUnauthorized)
+
+	writeKeyFile(t, path, `{"keys": ["old-key", "new-key"]}`)
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	waitKeyStatus(t, ts, "new-key", http.StatusOK)
+	waitKeyStatus(t, ts, "old-key", http.StatusOK)
+
+	writeKeyFile(t, path, `["new-key"]`)
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	waitKeyStatus(t, ts, "old-key", http.StatusUnauthorized)
+	waitKeyStatus(t, ts, "new-key", http.StatusOK)
+
+	// un fichier vide ou illisible garde les clés précédentes
+	writeKeyFile(t, path, `[]`)
+	if err := s.loadAPIKeys(); err == nil {
+		t.Error("empty key file accepted")
+	}
+	waitKeyStatus(t, ts, "new-key", http.StatusOK)
+
+	writeKeyFile(t, path, `not json`)
+	if err := s.loadAPIKeys(); err == nil {
+		t.Error("invalid key file accepted")
+	}
+	waitKeyStatus(t, ts, "new-key", http.StatusOK)
+}
+
+func TestAdminKeysAreSeparate(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/cache/stats", nil)
+	req.Header.Set("X-API-Key", testKey)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("api key on an admin route: got %d", resp.StatusCode)
+	}
+	if got := usageStatus(t, ts, "", map[string]string{"X-API-Key": testAdminKey}); got != http.StatusUnauthorized {
+		t.Errorf("admin key on an api route: got %d", got)
+	}
+
+	s, err := newServer(testConfig(t, map[string]string{"API_KEYS": "shared", "ADMIN_KEYS": "shared"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.loadAPIKeys() == nil {
+		t.Error("an admin key reusing an api key was accepted")
+	}
+}
+
+// sans clé configurée, le démarrage échoue au lieu de servir une clé de démo
+func TestNoAPIKeysFailsStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, path, `{"keys": [" "]}`)
+	for name, env := range map[string]map[string]string{
+		"nothing set":    {"API_KEYS": ""},
+		"blank keys":     {"API_KEYS": " , "},
+		"empty key file": {"API_KEYS": "", "API_KEYS_FILE": path},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, err := newServer(testConfig(t, env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.loadAPIKeys(); err == nil || !strings.Contains(err.Error(), "API_KEYS") {
+				t.Errorf("got %v, want an error naming API_KEYS", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// nombre maximal d'URLs par lot
+const maxBatchURLs = 50
+
+type batchRequest struct {
+	URLs        []string `json:"urls"`
+	Raw         bool     `json:"raw"`
+	Format      string   `json:"format"`
+	NoCache     bool     `json:"nocache"`
+	Debug       bool     `json:"debug"`
+	Async       bool     `json:"async"`
+	CallbackURL string   `json:"callback_url"`
+	Fields      string   `json:"fields"`
+	Stream      bool     `json:"stream"` // NDJSON, comme Accept: application/x-ndjson
+
+	DataImages        bool   `json:"data_images"`
+	KeepSelectors     string `json:"keep_selectors"`
+	FollowPagination  bool   `json:"follow_pagination"`
+	Expand            bool   `json:"expand"`
+	IncludeStructured bool   `json:"include_structured"`
+	IncludeLinks      bool   `json:"include_links"`
+	IncludeTables     bool   `json:"include_tables"`
+	FlattenTables     bool   `json:"flatten_tables"`
+	IncludeOffsets    bool   `json:"include_offsets"`
+	Prefer            string `json:"prefer"`
+	VerifyFavicon     bool   `json:"verify_favicon"`
+
+	UserAgent string            `json:"user_agent"`
+	Headers   map[string]string `json:"headers"`
+	Cookies   string            `json:"cookies"`
+	Proxy     string            `json:"proxy"`
+
+	RespectRobots *bool `json:"respect_robots"` // défaut : RESPECT_ROBOTS
+}
+
+// batchResult contient soit l'article, soit l'erreur
+type batchResult struct {
+	URL    string    `json:"url"`
+	Result *Article  `json:"result,omitempty"`
+	Error  *apiError `json:"error,omitempty"`
+}
+
+func (s *server) batchHandler(c *gin.Context) {
+	var body batchRequest
+	if !s.bindJSON(c, &body) {
+		return
+	}
+	if len(body.URLs) == 0 {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing urls"))
+		return
+	}
+	if body.Format == "" {
+		body.Format = formatText
+	}
+	opts := extractOptions{
+		Raw:               body.Raw,
+		Format:            body.Format,
+		IncludeDataImages: body.DataImages,
+		FollowPagination:  body.FollowPagination,
+		ExpandFeed:        body.Expand,
+		IncludeStructured: body.IncludeStructured,
+		IncludeLinks:      body.IncludeLinks,
+		IncludeTables:     body.IncludeTables,
+		FlattenTables:     body.FlattenTables,
+		IncludeOffsets:    body.IncludeOffsets,
+		Prefer:            body.Prefer,
+		VerifyFavicon:     body.VerifyFavicon,
+		Fetch: fetchOptions{
+			UserAgent: body.UserAgent,
+			Headers:   body.Headers,
+			Cookies:   body.Cookies,
+			Proxy:     body.Proxy,
+		},
+	}
+	opts.Fetch.RespectRobots = s.cfg.RespectRobots
+	if body.RespectRobots != nil {
+		opts.Fetch.RespectRobots = *body.RespectRobots
+	}
+	var err error
+	if opts.KeepSelectors, err = parseKeepSelectors(body.KeepSelectors); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := opts.validate(s.cfg); err != nil {
+		respondError(c, err)
+		return
+	}
+	fields, err := parseFields(body.Fields)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if len(body.URLs) > maxBatchURLs {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("too many urls (max %d)", maxBatchURLs)))
+		return
+	}
+
+	batch := func(ctx context.Context) (any, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.cfg.BatchTimeout)
+		defer cancel()
+
+		results := s.engine.runBatch(ctx, body.URLs, opts, body.NoCache, s.cfg.BatchConcurrency)
+		if body.Debug {
+			for _, r := range results {
+				if r.Result != nil {
+					r.Result.Fetch = opts.Fetch.debug()
+				}
+			}
+		}
+		if fields != nil {
+			return gin.H{"results": projectResults(results, fields)}, nil
+		}
+		return gin.H{"results": results}, nil
+	}
+	stream := body.Stream || acceptsNDJSON(c.GetHeader("Accept"))
+	if stream && body.Async {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "stream and async cannot be combined"))
+		return
+	}
+	if stream {
+		s.streamBatch(c, body, opts, fields)
+		return
+	}
+	if body.Async {
+		s.startJob(c, body.CallbackURL, batch)
+		return
+	}
+
+	results, _ := batch(c.Request.Context())
+	c.JSON(http.StatusOK, results)
+}
+
+// projectedResult : batchResult dont l'article est restreint par fields
+type projectedResult struct {
+	URL    string    `json:"url"`
+	Result any       `json:"result,omitempty"`
+	Error  *apiError `json:"error,omitempty"`
+}
+
+func projectResults(results []batchResult, fields *fieldSelection) []projectedResult {
+	out := make([]projectedResult, len(results))
+	for i, r := range results {
+		out[i] = projectedResult{URL: r.URL, Error: r.Error}
+		if r.Result != nil {
+			result, err := fields.project(r.Result)
+			if err != nil {
+				out[i].Error = toAPIError(err)
+				continue
+			}
+			out[i].Result = result
+		}
+	}
+	return out
+}
+
+// runBatch extrait les URLs avec au plus workers requêtes simultanées.
+// Les résultats sont dans le même ordre que urls.
+func (e *engine) runBatch(ctx context.Context, urls []string, opts extractOptions, nocache bool, workers int) []batchResult {
+	results := make([]batchResult, len(urls))
+	e.eachBatchResult(ctx, urls, opts, nocache, workers, func(i int, r batchResult) {
+		results[i] = r
+	})
+	return results
+}
+
+// eachBatchResult extrait les URLs avec au plus workers requêtes simultanées
+// et passe chaque résultat à fn, depuis le worker, dès qu'il est prêt
+func (e *engine) eachBatchResult(ctx context.Context, urls []string, opts extractOptions, nocache bool, workers int, fn func(i int, r batchResult)) {
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(urls)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i, e.extractOne(ctx, urls[i], opts, nocache))
+			}
+		}()
+	}
+
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (e *engine) extractOne(ctx context.Context, pageURL string, opts extractOptions, nocache bool) batchResult {
+	res := batchResult{URL: pageURL}
+	if pageURL == "" {
+		res.Error = newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing url")
+		return res
+	}
+
+	article, _, err := e.extractCached(ctx, pageURL, opts, nocache)
+	if err != nil {
+		res.Error = toAPIError(err)
+		return res
+	}
+	res.Result = article
+	return res
+}
+
+// streamedResult est une ligne du flux NDJSON ; index est la position de
+// l'URL dans la requête, les lignes arrivant dans l'ordre de fin
+type streamedResult struct {
+	Index  int       `json:"index"`
+	URL    string    `json:"url"`
+	Result any       `json:"result,omitempty"`
+	Error  *apiError `json:"error,omitempty"`
+}
+
+// batchSummary est la dernière ligne du flux
+type batchSummary struct {
+	Succeeded  int   `json:"succeeded"`
+	Failed     int   `json:"failed"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+func acceptsNDJSON(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		if mediaType, _, _ := mime.ParseMediaType(part); mediaType == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+// streamBatch écrit une ligne JSON par URL dès que son extraction se
+// termine, puis {"summary": ...}. Les erreurs sont des lignes comme les
+// autres. Si le client ferme la connexion ou n'est plus joignable, les
+// extractions restantes sont annulées.
+func (s *server) streamBatch(c *gin.Context, body batchRequest, opts extractOptions, fields *fieldSelection) {
+	start := time.Now()
+	streamCtx, stop := context.WithCancel(c.Request.Context())
+	defer stop()
+	// le délai du lot annule les extractions, pas le flux : leurs erreurs sont encore écrites
+	ctx, cancel := context.WithTimeout(streamCtx, s.cfg.BatchTimeout)
+	defer cancel()
+
+	lines := make(chan streamedResult)
+	go func() {
+		defer close(lines)
+		s.engine.eachBatchResult(ctx, body.URLs, opts, body.NoCache, s.cfg.BatchConcurrency, func(i int, r batchResult) {
+			line := streamedResult{Index: i, URL: r.URL, Error: r.Error}
+			if r.Result != nil {
+				if body.Debug {
+					r.Result.Fetch = opts.Fetch.debug()
+				}
+				if result, err := fields.project(r.Result); err != nil {
+					line.Error = toAPIError(err)
+				} else {
+					line.Result = result
+				}
+			}
+			select {
+			case lines <- line:
+			case <-streamCtx.Done():
+			}
+		})
+	}()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // pas de mise en tampon par un proxy nginx
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	enc := json.NewEncoder(c.Writer)
+	var summary batchSummary
+	for line := range lines {
+		if line.Error != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		if streamCtx.Err() != nil {
+			continue
+		}
+		if err := enc.Encode(line); err != nil {
+			stop()
+			continue
+		}
+		c.Writer.Flush()
+	}
+	if streamCtx.Err() != nil {
+		return
+	}
+	summary.DurationMS = time.Since(start).Milliseconds()
+	enc.Encode(gin.H{"summary": summary})
+	c.Writer.Flush()
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// batchOrigin : /page/N répond après (5-N)*20 ms, pour que les réponses
+// arrivent dans le désordre ; /missing répond 404 ; /hang ne répond pas
+func batchOrigin(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/page/"), "%d", &n)
+		time.Sleep(time.Duration(5-n) * 20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>Page %d</title></head><body><p>Body of page %d, long enough to be kept by the extractor.</p></body></html>", n, n)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/hang", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func postBatch(t *testing.T, ts *httptest.Server, urls []string) (int, []byte) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"urls": urls})
+	return apiRequest(t, ts, http.MethodPost, "/extract/batch", "application/json", string(body))
+}
+
+func decodeBatch(t *testing.T, body []byte) []batchResult {
+	t.Helper()
+	var resp struct {
+		Results []batchResult `json:"results"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("%v in %s", err, body)
+	}
+	return resp.Results
+}
+
+func TestBatchMixedResultsKeepOrder(t *testing.T) {
+	origin := batchOrigin(t)
+	_, ts := newTestServer(t, map[string]string{"HOST_CONCURRENCY": "10"})
+	urls := []string{
+		origin.URL + "/page/1",
+		origin.URL + "/missing",
+		origin.URL + "/page/2",
+		"not a url",
+		origin.URL + "/page/3",
+		origin.URL + "/page/4",
+	}
+	status, body := postBatch(t, ts, urls)
+	if status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	results := decodeBatch(t, body)
+	if len(results) != len(urls) {
+		t.Fatalf("%d results for %d urls", len(results), len(urls))
+	}
+	wantErrors := map[int]string{1: codeUpstreamNotFound, 3: codeInvalidURL}
+	page := 0
+	for i, r := range results {
+		if r.URL != urls[i] {
+			t.Errorf("result %d is for %q, want %q", i, r.URL, urls[i])
+		}
+		if code, ok := wantErrors[i]; ok {
+			if r.Error == nil || r.Error.Code != code || r.Result != nil {
+				t.Errorf("result %d: error %+v, want %s", i, r.Error, code)
+			}
+			continue
+		}
+		page++
+		if r.Error != nil || r.Result == nil || r.Result.Title != fmt.Sprintf("Page %d", page) {
+			t.Errorf("result %d: %+v %+v", i, r.Result, r.Error)
+		}
+	}
+}
+
+func TestBatchRejectsTooManyURLs(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	urls := make([]string, maxBatchURLs+1)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://example.com/%d", i)
+	}
+	status, body := postBatch(t, ts, urls)
+	if status != http.StatusBadRequest || errorCode(t, body) != codeInvalidRequest {
+		t.Fatalf("got %d %s, want 400", status, body)
+	}
+	if status, _ := postBatch(t, ts, nil); status != http.StatusBadRequest {
+		t.Errorf("empty batch: got %d, want 400", status)
+	}
+}
+
+// corps limité à MAX_UPLOAD_BYTES, comme les documents envoyés
+func TestBatchBodyTooLarge(t *testing.T) {
+	_, ts := newTestServer(t, map[string]string{"MAX_UPLOAD_BYTES": "200"})
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://example.com/a/rather/long/path/to/article-%d", i)
+	}
+	status, body := postBatch(t, ts, urls)
+	if status != http.StatusRequestEntityTooLarge || errorCode(t, body) != codeBodyTooLarge {
+		t.Errorf("got %d %s, want 413 %s", status, body, codeBodyTooLarge)
+	}
+}
+
+// un hôte bloqué ne retient pas le lot au-delà de BATCH_TIMEOUT
+func TestBatchDeadline(t *testing.T) {
+	origin := batchOrigin(t)
+	_, ts := newTestServer(t, map[string]string{"BATCH_TIMEOUT": "300ms", "FETCH_TIMEOUT": "10s", "FETCH_MAX_ATTEMPTS": "1"})
+	start := time.Now()
+	status, body := postBatch(t, ts, []string{origin.URL + "/page/4", origin.URL + "/hang"})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("batch took %s", elapsed)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	results := decodeBatch(t, body)
+	if results[0].Result == nil {
+		t.Errorf("fast url failed: %+v", results[0].Error)
+	}
+	if results[1].Error == nil || results[1].Error.Code != codeUpstreamTimeout {
+		t.Errorf("stuck url: %+v, want %s", results[1].Error, codeUpstreamTimeout)
+	}
+}
+
+// slowOrigin : /page/N comme batchOrigin, /slow ne répond qu'à la fermeture
+// de release ; cancelled compte les requêtes /slow annulées par le client
+type slowOrigin struct {
+	*httptest.Server
+	release   chan struct{}
+	cancelled atomic.Int64
+}
+
+func newSlowOrigin(t *testing.T) *slowOrigin {
+	t.Helper()
+	o := &slowOrigin{release: make(chan struct{})}
+	fast := batchOrigin(t)
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/slow" {
+			http.Redirect(w, r, fast.URL+r.URL.Path, http.StatusFound)
+			return
+		}
+		select {
+		case <-o.release:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, "<html><head><title>Slow page</title></head><body><p>Body of the slow page, long enough to be kept by the extractor.</p></body></html>")
+		case <-r.Context().Done():
+			o.cancelled.Add(1)
+		}
+	}))
+	t.Cleanup(o.Close)
+	t.Cleanup(func() {
+		select {
+		case <-o.release:
+		default:
+			close(o.release)
+		}
+	})
+	return o
+}
+
+// streamLine : ligne du flux, résultat ou résumé final
+type streamLine struct {
+	Index   int             `json:"index"`
+	URL     string          `json:"url"`
+	Result  json.RawMessage `json:"result"`
+	Error   *apiError       `json:"error"`
+	Summary *batchSummary   `json:"summary"`
+}
+
+// openStream poste le lot et retourne la réponse, à lire ligne à ligne
+func openStream(t *testing.T, ts *httptest.Server, body, accept string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/extract/batch", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", testKey)
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp
+}
+
+// streamReader lit les lignes au fil de l'eau ; next échoue si aucune
+// ligne n'arrive à temps
+type streamReader struct {
+	lines chan streamLine
+	errs  chan error
+}
+
+func readStream(r io.Reader) *streamReader {
+	sr := &streamReader{lines: make(chan streamLine), errs: make(chan error, 1)}
+	go func() {
+		defer close(sr.lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var line streamLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				sr.errs <- fmt.Errorf("%v in %s", err, scanner.Bytes())
+				return
+			}
+			sr.lines <- line
+		}
+	}()
+	return sr
+}
+
+func (sr *streamReader) next(t *testing.T) (streamLine, bool) {
+	t.Helper()
+	select {
+	case line, ok := <-sr.lines:
+		return line, ok
+	case err := <-sr.errs:
+		t.Fatal(err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("no line within 3s")
+	}
+	return streamLine{}, false
+}
+
+func TestBatchStreamEarlyLines(t *testing.T) {
+	origin := newSlowOrigin(t)
+	_, ts := newTestServer(t, map[string]string{"HOST_CONCURRENCY": "10"})
+	urls := []string{origin.URL + "/slow", origin.URL + "/page/4", origin.URL + "/missing", origin.URL + "/page/3"}
+	body, _ := json.Marshal(map[string]any{"urls": urls, "nocache": true})
+
+	for _, tt := range []struct{ name, body, accept string }{
+		{"accept header", string(body), "application/json, application/x-ndjson"},
+		{"stream param", strings.Replace(string(body), "{", `{"stream":true,`, 1), ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := readStream(openStream(t, ts, tt.body, tt.accept).Body)
+
+			// les trois URL rapides arrivent pendant que /slow est bloquée
+			seen := map[int]bool{}
+			for len(seen) < 3 {
+				line, ok := stream.next(t)
+				if !ok {
+					t.Fatal("stream closed early")
+				}
+				if line.Index == 0 || line.URL != urls[line.Index] {
+					t.Fatalf("line %+v before the slow url was released", line)
+				}
+				// erreur en ligne, sans interrompre le flux
+				if line.Index == 2 && (line.Error == nil || line.Error.Code != codeUpstreamNotFound) {
+					t.Errorf("missing page: %+v", line.Error)
+				}
+				if line.Index != 2 && (line.Error != nil || len(line.Result) == 0) {
+					t.Errorf("line %d: %+v", line.Index, line.Error)
+				}
+				seen[line.Index] = true
+			}
+
+			origin.release <- struct{}{}
+			if line, _ := stream.next(t); line.Index != 0 || line.Error != nil || !strings.Contains(string(line.Result), "Slow page") {
+				t.Errorf("slow line %+v", line)
+			}
+			summary, _ := stream.next(t)
+			if summary.Summary == nil || summary.Summary.Succeeded != 3 || summary.Summary.Failed != 1 {
+				t.Errorf("summary %+v", summary.Summary)
+			}
+			if _, ok := stream.next(t); ok {
+				t.Error("line after the summary")
+			}
+		})
+	}
+}
+
+// le client qui ferme la connexion annule les fetchs en cours
+func TestBatchStreamClientGone(t *testing.T) {
+	origin := newSlowOrigin(t)
+	_, ts := newTestServer(t, map[string]string{"HOST_CONCURRENCY": "10"})
+	body, _ := json.Marshal(map[string]any{"urls": []string{origin.URL + "/page/4", origin.URL + "/slow", origin.URL + "/slow?b"}})
+	resp := openStream(t, ts, string(body), "application/x-ndjson")
+	stream := readStream(resp.Body)
+	if line, _ := stream.next(t); line.Index != 0 {
+		t.Fatalf("first line %+v", line)
+	}
+	resp.Body.Close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for origin.cancelled.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of 2 slow fetches cancelled", origin.cancelled.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBatchStreamNotAsync(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	status, body := apiRequest(t, ts, http.MethodPost, "/extract/batch", "application/json", `{"urls":["https://example.com/"],"stream":true,"async":true}`)
+	if status != http.StatusBadRequest || !strings.Contains(string(body), "stream and async") {
+		t.Errorf("got %d %s", status, body)
+	}
+}
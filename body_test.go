@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x10\x00\x00\x00\x10\x08\x06\x00\x00\x00")
+
+func TestContentTypeChecks(t *testing.T) {
+	html := "<html><head><title>Sniffed</title></head><body><p>An html page served without any content type header at all.</p></body></html>"
+	mux := http.NewServeMux()
+	// ment : se dit HTML mais envoie une image
+	mux.HandleFunc("/liar", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(pngHeader)
+	})
+	mux.HandleFunc("/video", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write([]byte("not read"))
+	})
+	// sans en-tête : les 512 premiers octets décident
+	noHeader := func(body []byte) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header()["Content-Type"] = nil // pas de détection par net/http
+			w.Write(body)
+		}
+	}
+	mux.HandleFunc("/sniff-html", noHeader([]byte(html)))
+	mux.HandleFunc("/sniff-png", noHeader(pngHeader))
+	mux.HandleFunc("/xhtml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xhtml+xml; charset=utf-8")
+		fmt.Fprint(w, html)
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, html)
+	})
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+
+	_, ts := newTestServer(t, nil)
+	_, allowPlain := newTestServer(t, map[string]string{"ALLOW_CONTENT_TYPES": "text/plain"})
+	tests := []struct {
+		name, path string
+		server     *httptest.Server
+		status     int
+	}{
+		{"lying header", "/liar", ts, http.StatusUnprocessableEntity},
+		{"video", "/video", ts, http.StatusUnprocessableEntity},
+		{"sniffed html", "/sniff-html", ts, http.StatusOK},
+		{"sniffed binary", "/sniff-png", ts, http.StatusUnprocessableEntity},
+		{"xhtml", "/xhtml", ts, http.StatusOK},
+		{"plain refused", "/plain", ts, http.StatusUnprocessableEntity},
+		{"plain allowed", "/plain", allowPlain, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := apiRequest(t, tt.server, http.MethodGet, "/extract?url="+origin.URL+tt.path, "", "")
+			if status != tt.status {
+				t.Fatalf("got %d %s, want %d", status, body, tt.status)
+			}
+			if status == http.StatusUnprocessableEntity && errorCode(t, body) != codeUnsupportedContentType {
+				t.Errorf("code %s", errorCode(t, body))
+			}
+		})
+	}
+}
+
+// flux sans Content-Length qui dépasse la limite : refusé, et la lecture
+// s'arrête peu après la limite au lieu de tout télécharger
+func TestStreamingPastTheLimit(t *testing.T) {
+	var written atomic.Int64
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		chunk := []byte("<p>" + strings.Repeat("x", 1020) + "</p>\n")
+		for i := 0; i < 10_000; i++ { // ~10 Mo
+			n, err := w.Write(chunk)
+			written.Add(int64(n))
+			if err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"MAX_BODY_BYTES": "65536"})
+
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+origin.URL, "", "")
+	if status != http.StatusUnprocessableEntity || errorCode(t, body) != codeBodyTooLarge {
+		t.Fatalf("got %d %s, want 422 %s", status, body, codeBodyTooLarge)
+	}
+	origin.Close() // attend la fin du handler
+	if n := written.Load(); n > 5<<20 {
+		t.Errorf("origin wrote %d bytes, the body was read past the limit", n)
+	}
+}
+
+// Content-Length annoncé au-delà de la limite : refusé sans lire le corps
+func TestDeclaredLengthPastTheLimit(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Length", "2000")
+		w.Write([]byte(strings.Repeat("a", 2000)))
+	}))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"MAX_BODY_BYTES": "1000"})
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+origin.URL, "", "")
+	if status != http.StatusUnprocessableEntity || errorCode(t, body) != codeBodyTooLarge {
+		t.Fatalf("got %d %s", status, body)
+	}
+}
+
+// exactement à la limite : accepté, pas tronqué
+func TestBodyAtTheLimit(t *testing.T) {
+	page := "<html><body><p>" + strings.Repeat("word ", 190) + "end.</p></body></html>"
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"MAX_BODY_BYTES": fmt.Sprint(len(page))})
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+origin.URL, &article); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if !strings.HasSuffix(article.CleanText, "end.") {
+		t.Errorf("text truncated: ...%s", article.CleanText[max(0, len(article.CleanText)-20):])
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// sélecteurs retirés avant l'extraction du texte
+var builtinJunkSelectors = []string{
+	"aside",
+	`[role="complementary"]`,
+	`[role="banner"]`,
+	`[role="dialog"]`,
+	`[aria-label*="share" i]`,
+	`[class*="share"]`,
+	`[class*="social"]`,
+	`[id*="cookie"]`,
+	`[class*="cookie"]`,
+	`[class*="consent"]`,
+	`[id*="consent"]`,
+	".newsletter",
+	`[class*="newsletter"]`,
+	`[class*="subscribe"]`,
+	`[class*="advert"]`,
+	`[id*="advert"]`,
+	`[class*="sponsored"]`,
+	`[class*="related"]`,
+	`[class*="promo"]`,
+	".ad", ".ads", ".adsbygoogle", `[id^="ad-"]`, `[class^="ad-"]`,
+	`[data-ad]`, `[data-ad-slot]`,
+}
+
+// éléments supprimés quand leur texte se résume à un marqueur publicitaire
+var junkMarkers = map[string]bool{
+	"advertisement":     true,
+	"advertisements":    true,
+	"sponsored":         true,
+	"sponsored content": true,
+	"publicité":         true,
+	"annonce":           true,
+	"ad":                true,
+}
+
+// cleaningRules : sélecteurs à retirer et à protéger
+type cleaningRules struct {
+	Remove []string `json:"remove"`
+	Keep   []string `json:"keep"`
+}
+
+// loadCleaningRules ajoute aux règles intégrées celles du fichier JSON
+// {"remove": [...], "keep": [...]} ; les sélecteurs invalides sont ignorés
+func loadCleaningRules(path string) cleaningRules {
+	rules := cleaningRules{Remove: builtinJunkSelectors}
+	if path == "" {
+		return rules
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("cleaning rules: %v", err)
+		return rules
+	}
+	var extra cleaningRules
+	if err := json.Unmarshal(data, &extra); err != nil {
+		log.Printf("cleaning rules: invalid json in %s: %v", path, err)
+		return rules
+	}
+	for _, sel := range extra.Remove {
+		if _, err := cascadia.Compile(sel); err != nil {
+			log.Printf("cleaning rules: ignoring invalid selector %q", sel)
+			continue
+		}
+		rules.Remove = append(rules.Remove, sel)
+	}
+	for _, sel := range extra.Keep {
+		if _, err := cascadia.Compile(sel); err != nil {
+			log.Printf("cleaning rules: ignoring invalid selector %q", sel)
+			continue
+		}
+		rules.Keep = append(rules.Keep, sel)
+	}
+	return rules
+}
+
+// parseKeepSelectors valide le paramètre keep_selectors (un groupe CSS, ex. ".a, .b")
+func parseKeepSelectors(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if _, err := cascadia.Compile(raw); err != nil {
+		return nil, newAPIError(http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("invalid keep_selectors %q", raw))
+	}
+	return []string{raw}, nil
+}
+
+// keepFilter indique si un élément est protégé : il correspond à keep,
+// en contient un ou se trouve à l'intérieur d'un
+func (e *engine) keepFilter(keep []string) func(*goquery.Selection) bool {
+	keep = append(append([]string{}, e.junk.Keep...), keep...)
+	if len(keep) == 0 {
+		return func(*goquery.Selection) bool { return false }
+	}
+	keepSel := strings.Join(keep, ", ")
+	return func(s *goquery.Selection) bool {
+		return s.Is(keepSel) || s.Find(keepSel).Length() > 0 || s.ParentsFiltered(keepSel).Length() > 0
+	}
+}
+
+// removeBoilerplate retire les éléments parasites (partage, cookies,
+// newsletter, publicité) sauf ceux protégés par keep ou qui en contiennent
+func (e *engine) removeBoilerplate(doc *goquery.Document, protected func(*goquery.Selection) bool) {
+	// ne jamais retirer la page entière
+	doc.Find(strings.Join(e.junk.Remove, ", ")).Not("html, body, main, article").Each(func(i int, s *goquery.Selection) {
+		if !protected(s) {
+			s.Remove()
+		}
+	})
+
+	doc.Find("div, span, p, section, li").Each(func(i int, s *goquery.Selection) {
+		if s.Children().Length() > 2 {
+			return
+		}
+		text := strings.ToLower(strings.Trim(strings.TrimSpace(s.Text()), ".:-–— "))
+		if junkMarkers[text] && !protected(s) {
+			s.Remove()
+		}
+	})
+}
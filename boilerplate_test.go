@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// avant (testdata/boilerplate/*.html) et après (testdata/golden/boilerplate-*.txt)
+func TestBoilerplateBeforeAfter(t *testing.T) {
+	e := newTestEngine(t, nil)
+	for _, name := range []string{"local-news", "recipe-blog", "tech-review"} {
+		t.Run(name, func(t *testing.T) {
+			article := extractFixture(t, e, "boilerplate/"+name+".html", extractOptions{})
+			checkGolden(t, "boilerplate-"+name+".txt", article.CleanText)
+			for _, junk := range []string{"Share on", "Advertisement", "Accept all cookies", "newsletter", "Sponsored", "Follow us", "You might also like", "Deal of the day", "consent", "Subscribe", "Read more", "Looking for more"} {
+				if strings.Contains(article.CleanText, junk) {
+					t.Errorf("%q left in the text", junk)
+				}
+			}
+		})
+	}
+}
+
+func TestKeepSelectorsProtectJunk(t *testing.T) {
+	e := newTestEngine(t, nil)
+	article := extractFixture(t, e, "boilerplate/recipe-blog.html", extractOptions{KeepSelectors: []string{".related-posts"}})
+	if !strings.Contains(article.CleanText, "You might also like") {
+		t.Errorf("kept element removed:\n%s", article.CleanText)
+	}
+	if strings.Contains(article.CleanText, "Follow us") {
+		t.Errorf("unprotected junk kept:\n%s", article.CleanText)
+	}
+}
+
+func TestCleaningRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `{"remove": [".verdict-line", "p:has(strong)", "[invalid"], "keep": ["[role=\"complementary\"]"]}`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEngine(t, map[string]string{"CLEANING_RULES_FILE": path})
+	if n := len(e.junk.Remove) - len(builtinJunkSelectors); n != 2 {
+		t.Errorf("%d extra selectors, want 2 (the invalid one ignored)", n)
+	}
+
+	review := extractFixture(t, e, "boilerplate/tech-review.html", extractOptions{})
+	if strings.Contains(review.CleanText, "Verdict") {
+		t.Errorf("extra selector not applied:\n%s", review.CleanText)
+	}
+	recipe := extractFixture(t, e, "boilerplate/recipe-blog.html", extractOptions{})
+	if !strings.Contains(recipe.CleanText, "Looking for more bakes") {
+		t.Errorf("keep rule from the file not applied:\n%s", recipe.CleanText)
+	}
+}
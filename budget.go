@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// fenêtre glissante découpée en tranches d'une minute
+const (
+	budgetWindow = time.Hour
+	budgetSlot   = time.Minute
+	budgetSlots  = int(budgetWindow / budgetSlot)
+)
+
+var outboundBytes = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "outbound_bytes_total",
+	Help: "Octets reçus des origines (corps lus, avant décompression).",
+})
+
+// budgetError : budget épuisé, de nouveau disponible à reset
+type budgetError struct {
+	scope string
+	reset time.Time
+}
+
+func (e *budgetError) Error() string {
+	return "outbound " + e.scope + " budget exceeded"
+}
+
+// usageWindow compte sur l'heure glissante, par tranches d'une minute
+type usageWindow struct {
+	counts [budgetSlots]int64
+	slots  [budgetSlots]int64 // numéro de la tranche (minutes Unix) de chaque case
+}
+
+func (w *usageWindow) add(now time.Time, n int64) {
+	slot := now.Unix() / int64(budgetSlot/time.Second)
+	i := int(slot % int64(budgetSlots))
+	if w.slots[i] != slot {
+		w.slots[i], w.counts[i] = slot, 0
+	}
+	w.counts[i] += n
+}
+
+func (w *usageWindow) total(now time.Time) int64 {
+	current := now.Unix() / int64(budgetSlot/time.Second)
+	var sum int64
+	for i, slot := range w.slots {
+		if current-slot < int64(budgetSlots) {
+			sum += w.counts[i]
+		}
+	}
+	return sum
+}
+
+// resetAt : instant où le total repassera sous limit, les plus anciennes
+// tranches sortant de la fenêtre
+func (w *usageWindow) resetAt(now time.Time, limit int64) time.Time {
+	current := now.Unix() / int64(budgetSlot/time.Second)
+	total := w.total(now)
+	for age := int64(budgetSlots) - 1; age >= 0; age-- {
+		slot := current - age
+		if i := int(slot % int64(budgetSlots)); w.slots[i] == slot {
+			total -= w.counts[i]
+		}
+		if total < limit {
+			return time.Unix((slot+int64(budgetSlots))*int64(budgetSlot/time.Second), 0)
+		}
+	}
+	return now.Add(budgetWindow)
+}
+
+type keyUsage struct {
+	bytes    usageWindow
+	fetches  usageWindow
+	lastSeen time.Time
+}
+
+// budget compte les requêtes sortantes et les octets reçus, par clé (key_id)
+// et au total. Les octets sont réservés avant chaque lecture du corps, ce qui
+// garde la limite exacte entre lectures concurrentes.
+type budget struct {
+	mu      sync.Mutex
+	keys    map[string]*keyUsage
+	bytes   usageWindow
+	fetches usageWindow
+	now     func() time.Time
+
+	// limites sur l'heure glissante ; 0 : pas de limite
+	maxBytesPerKey   int64 // octets reçus par clé API (MAX_BYTES_PER_KEY_PER_HOUR)
+	maxBytesGlobal   int64 // octets reçus, toutes clés confondues (MAX_BYTES_PER_HOUR)
+	maxFetchesGlobal int64 // requêtes sortantes, toutes clés confondues (MAX_FETCHES_PER_HOUR)
+}
+
+func newBudget(perKey, bytes, fetches int64) *budget {
+	return &budget{
+		keys:             make(map[string]*keyUsage),
+		now:              time.Now,
+		maxBytesPerKey:   perKey,
+		maxBytesGlobal:   bytes,
+		maxFetchesGlobal: fetches,
+	}
+}
+
+type budgetKeyCtx struct{}
+
+// withBudgetKey impute les requêtes sortantes faites avec ctx à la clé keyID
+func withBudgetKey(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, budgetKeyCtx{}, id)
+}
+
+func budgetKeyFrom(ctx context.Context) string {
+	id, _ := ctx.Value(budgetKeyCtx{}).(string)
+	return id
+}
+
+func (b *budget) usage(id string) *keyUsage {
+	u, ok := b.keys[id]
+	if !ok {
+		u = &keyUsage{}
+		b.keys[id] = u
+	}
+	u.lastSeen = b.now()
+	return u
+}
+
+// exceeded retourne l'erreur du premier budget épuisé, verrou tenu
+func (b *budget) exceeded(id string, now time.Time) *budgetError {
+	if err := b.bytesExceeded(id, now); err != nil {
+		return err
+	}
+	if b.maxFetchesGlobal > 0 && b.fetches.total(now) >= b.maxFetchesGlobal {
+		return &budgetError{scope: "fetches", reset: b.fetches.resetAt(now, b.maxFetchesGlobal)}
+	}
+	return nil
+}
+
+// bytesExceeded : seuls les budgets d'octets, pour la lecture d'un corps
+// dont la requête a déjà été comptée
+func (b *budget) bytesExceeded(id string, now time.Time) *budgetError {
+	if b.maxBytesPerKey > 0 && id != "" {
+		if u := b.usage(id); u.bytes.total(now) >= b.maxBytesPerKey {
+			return &budgetError{scope: "bytes per key", reset: u.bytes.resetAt(now, b.maxBytesPerKey)}
+		}
+	}
+	if b.maxBytesGlobal > 0 && b.bytes.total(now) >= b.maxBytesGlobal {
+		return &budgetError{scope: "bytes", reset: b.bytes.resetAt(now, b.maxBytesGlobal)}
+	}
+	return nil
+}
+
+// check refuse une requête dont un budget est déjà épuisé
+func (b *budget) check(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.exceeded(id, b.now()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// startFetch compte une requête sortante, si les budgets le permettent
+func (b *budget) startFetch(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if err := b.exceeded(id, now); err != nil {
+		return err
+	}
+	b.fetches.add(now, 1)
+	if id != "" {
+		b.usage(id).fetches.add(now, 1)
+	}
+	return nil
+}
+
+// reserve accorde au plus want octets de lecture et les compte d'avance
+func (b *budget) reserve(id string, want int) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if err := b.bytesExceeded(id, now); err != nil {
+		return 0, err
+	}
+	allowed := int64(want)
+	if b.maxBytesPerKey > 0 && id != "" {
+		allowed = min(allowed, b.maxBytesPerKey-b.usage(id).bytes.total(now))
+	}
+	if b.maxBytesGlobal > 0 {
+		allowed = min(allowed, b.maxBytesGlobal-b.bytes.total(now))
+	}
+	b.addBytes(id, now, allowed)
+	return int(allowed), nil
+}
+
+// record corrige la réservation : n octets lus au lieu de reserved
+func (b *budget) record(id string, reserved, n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.addBytes(id, b.now(), int64(n-reserved))
+	outboundBytes.Add(float64(n))
+}
+
+func (b *budget) addBytes(id string, now time.Time, n int64) {
+	b.bytes.add(now, n)
+	if id != "" {
+		b.usage(id).bytes.add(now, n)
+	}
+}
+
+// cleanup oublie les clés inactives depuis plus d'une fenêtre
+func (b *budget) cleanup() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for id, u := range b.keys {
+		if now.Sub(u.lastSeen) > budgetWindow {
+			delete(b.keys, id)
+		}
+	}
+}
+
+// startCleanup nettoie périodiquement les compteurs des clés inactives
+func (b *budget) startCleanup(every time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			b.cleanup()
+		}
+	}()
+}
+
+// budgetTransport compte chaque requête sortante et les octets réellement
+// lus de chaque corps, y compris les lectures interrompues (corps trop
+// gros, erreur) et les réponses écartées par les nouvelles tentatives
+type budgetTransport struct {
+	base   http.RoundTripper
+	budget *budget
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := budgetKeyFrom(req.Context())
+	if err := t.budget.startFetch(id); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &budgetBody{body: resp.Body, id: id, budget: t.budget}
+	return resp, nil
+}
+
+type budgetBody struct {
+	body   io.ReadCloser
+	id     string
+	budget *budget
+}
+
+func (r *budgetBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return r.body.Read(p)
+	}
+	allowed, err := r.budget.reserve(r.id, len(p))
+	if err != nil {
+		// budget atteint : un octet distingue la fin du corps d'un dépassement
+		var probe [1]byte
+		n, readErr := r.body.Read(probe[:])
+		r.budget.record(r.id, 0, n)
+		if n == 0 && readErr == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	n, err := r.body.Read(p[:allowed])
+	r.budget.record(r.id, allowed, n)
+	return n, err
+}
+
+func (r *budgetBody) Close() error {
+	return r.body.Close()
+}
+
+// budgetExceeded traduit un budget épuisé en 429 avec son heure de remise à zéro
+func budgetExceeded(e *budgetError) *apiError {
+	err := newAPIError(http.StatusTooManyRequests, codeBudgetExceeded, e.Error())
+	reset := e.reset.UTC()
+	err.ResetAt = &reset
+	return err
+}
+
+// checkBudget refuse d'emblée une requête dont la clé ou le service a épuisé
+// un budget ; les requêtes sortantes qu'elle fera sont imputées à sa clé.
+// Doit être placé après requireAPIKey.
+func (s *server) checkBudget(c *gin.Context) {
+	id := keyID(c.GetString(ctxAPIKey))
+	if err := s.engine.budget.check(id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Request = c.Request.WithContext(withBudgetKey(c.Request.Context(), id))
+	c.Next()
+}
+
+// usageLimit : consommation sur l'heure glissante ; limit et remaining
+// sont omis quand il n'y a pas de limite
+type usageLimit struct {
+	Used      int64  `json:"used"`
+	Limit     *int64 `json:"limit,omitempty"`
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+func newUsageLimit(used, limit int64) usageLimit {
+	u := usageLimit{Used: used}
+	if limit > 0 {
+		remaining := max(limit-used, 0)
+		u.Limit, u.Remaining = &limit, &remaining
+	}
+	return u
+}
+
+// usageHandler décrit la consommation de la clé appelante (GET /usage)
+func (s *server) usageHandler(c *gin.Context) {
+	id := keyID(c.GetString(ctxAPIKey))
+
+	b := s.engine.budget
+	b.mu.Lock()
+	now := b.now()
+	u := b.usage(id)
+	resp := gin.H{
+		"key_id":  id,
+		"window":  budgetWindow.String(),
+		"bytes":   newUsageLimit(u.bytes.total(now), b.maxBytesPerKey),
+		"fetches": newUsageLimit(u.fetches.total(now), 0),
+		"global": gin.H{
+			"bytes":   newUsageLimit(b.bytes.total(now), b.maxBytesGlobal),
+			"fetches": newUsageLimit(b.fetches.total(now), b.maxFetchesGlobal),
+		},
+	}
+	if err := b.exceeded(id, now); err != nil {
+		resp["exceeded"] = err.scope
+		resp["reset_at"] = err.reset.UTC()
+	}
+	b.mu.Unlock()
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// budgetCollector expose la consommation courante à chaque lecture de
+// /metrics ; enregistré par main pour le budget de l'engine du serveur
+type budgetCollector struct {
+	budget *budget
+}
+
+var (
+	budgetBytesDesc = prometheus.NewDesc("outbound_budget_bytes",
+		"Octets reçus des origines sur l'heure glissante, par clé (key_id vide : total).",
+		[]string{"key_id"}, nil)
+	budgetFetchesDesc = prometheus.NewDesc("outbound_budget_fetches",
+		"Requêtes sortantes sur l'heure glissante, par clé (key_id vide : total).",
+		[]string{"key_id"}, nil)
+)
+
+func (budgetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- budgetBytesDesc
+	ch <- budgetFetchesDesc
+}
+
+func (c budgetCollector) Collect(ch chan<- prometheus.Metric) {
+	b := c.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	ch <- prometheus.MustNewConstMetric(budgetBytesDesc, prometheus.GaugeValue, float64(b.bytes.total(now)), "")
+	ch <- prometheus.MustNewConstMetric(budgetFetchesDesc, prometheus.GaugeValue, float64(b.fetches.total(now)), "")
+	for id, u := range b.keys {
+		ch <- prometheus.MustNewConstMetric(budgetBytesDesc, prometheus.GaugeValue, float64(u.bytes.total(now)), id)
+		ch <- prometheus.MustNewConstMetric(budgetFetchesDesc, prometheus.GaugeValue, float64(u.fetches.total(now)), id)
+	}
+}
+
+// retryAfterSeconds : valeur de Retry-After jusqu'à t
+func retryAfterSeconds(t time.Time) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(time.Until(t).Seconds()))))
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// budgetOrigin sert testdata/budget/page.html (taille connue) sous toute
+// URL et compte les requêtes reçues
+func budgetOrigin(t *testing.T) (*httptest.Server, *atomic.Int64, int) {
+	t.Helper()
+	page := readFixture(t, "budget/page.html")
+	var hits atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &hits, len(page)
+}
+
+// réponse de GET /usage
+type usageResponse struct {
+	KeyID   string     `json:"key_id"`
+	Bytes   usageLimit `json:"bytes"`
+	Fetches usageLimit `json:"fetches"`
+	Global  struct {
+		Bytes   usageLimit `json:"bytes"`
+		Fetches usageLimit `json:"fetches"`
+	} `json:"global"`
+	Exceeded string     `json:"exceeded"`
+	ResetAt  *time.Time `json:"reset_at"`
+}
+
+func getUsage(t *testing.T, ts *httptest.Server) usageResponse {
+	t.Helper()
+	var usage usageResponse
+	if status := apiGet(t, ts, "/usage", &usage); status != http.StatusOK {
+		t.Fatalf("GET /usage: %d", status)
+	}
+	return usage
+}
+
+// extractStatus extrait pageURL ; n rend l'URL unique (pas de cache)
+func extractStatus(t *testing.T, ts *httptest.Server, pageURL string, n int) (int, []byte) {
+	t.Helper()
+	return apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(pageURL+"/?n="+strconv.Itoa(n)), "", "")
+}
+
+// checkBudgetExceeded vérifie un 429 BUDGET_EXCEEDED avec reset_at et Retry-After
+func checkBudgetExceeded(t *testing.T, status int, body []byte, scope string) {
+	t.Helper()
+	var resp struct {
+		Error apiError `json:"error"`
+	}
+	if status != http.StatusTooManyRequests || json.Unmarshal(body, &resp) != nil {
+		t.Fatalf("got %d %s, want 429", status, body)
+	}
+	if resp.Error.Code != codeBudgetExceeded || !strings.Contains(resp.Error.Message, scope) {
+		t.Errorf("error %s %q, want %s for %s", resp.Error.Code, resp.Error.Message, codeBudgetExceeded, scope)
+	}
+	if resp.Error.ResetAt == nil || !resp.Error.ResetAt.After(time.Now()) || resp.Error.ResetAt.After(time.Now().Add(budgetWindow)) {
+		t.Errorf("reset_at %v, want within the next hour", resp.Error.ResetAt)
+	}
+}
+
+func TestBudgetCutsAtByteBoundary(t *testing.T) {
+	origin, hits, size := budgetOrigin(t)
+	limit := size + size/2
+	_, ts := newTestServer(t, map[string]string{"MAX_BYTES_PER_KEY_PER_HOUR": strconv.Itoa(limit)})
+
+	if status, body := extractStatus(t, ts, origin.URL, 1); status != http.StatusOK {
+		t.Fatalf("first page: %d %s", status, body)
+	}
+	if usage := getUsage(t, ts); usage.Bytes.Used != int64(size) || *usage.Bytes.Remaining != int64(limit-size) {
+		t.Errorf("after one page: used %d remaining %d, want %d and %d", usage.Bytes.Used, *usage.Bytes.Remaining, size, limit-size)
+	}
+
+	// le second corps est coupé à la limite, octet près : seul l'octet qui
+	// distingue un dépassement de la fin du corps est lu au-delà
+	status, body := extractStatus(t, ts, origin.URL, 2)
+	checkBudgetExceeded(t, status, body, "bytes per key")
+	usage := getUsage(t, ts)
+	if usage.Bytes.Used != int64(limit)+1 || *usage.Bytes.Remaining != 0 || *usage.Bytes.Limit != int64(limit) {
+		t.Errorf("after the cutoff: used %d remaining %d, want %d+1 and 0", usage.Bytes.Used, *usage.Bytes.Remaining, limit)
+	}
+	if usage.Exceeded != "bytes per key" || usage.ResetAt == nil {
+		t.Errorf("exceeded %q reset_at %v", usage.Exceeded, usage.ResetAt)
+	}
+
+	// budget épuisé : refus avant tout contact avec l'origine
+	before := hits.Load()
+	status, body = extractStatus(t, ts, origin.URL, 3)
+	checkBudgetExceeded(t, status, body, "bytes per key")
+	if hits.Load() != before {
+		t.Error("origin contacted with an exhausted budget")
+	}
+	if usage := getUsage(t, ts); usage.Fetches.Used != 2 {
+		t.Errorf("%d fetches counted, want 2", usage.Fetches.Used)
+	}
+}
+
+func TestBudgetExactFit(t *testing.T) {
+	origin, _, size := budgetOrigin(t)
+	_, ts := newTestServer(t, map[string]string{"MAX_BYTES_PER_KEY_PER_HOUR": strconv.Itoa(size)})
+
+	// un corps de la taille exacte du budget passe
+	if status, body := extractStatus(t, ts, origin.URL, 1); status != http.StatusOK {
+		t.Fatalf("page of exactly the budget: %d %s", status, body)
+	}
+	if usage := getUsage(t, ts); usage.Bytes.Used != int64(size) {
+		t.Errorf("used %d, want %d", usage.Bytes.Used, size)
+	}
+	status, body := extractStatus(t, ts, origin.URL, 2)
+	checkBudgetExceeded(t, status, body, "bytes per key")
+}
+
+func TestBudgetIsPerKey(t *testing.T) {
+	origin, _, size := budgetOrigin(t)
+	_, ts := newTestServer(t, map[string]string{
+		"API_KEYS":                   testKey + ",other-key",
+		"MAX_BYTES_PER_KEY_PER_HOUR": strconv.Itoa(size),
+	})
+	extractStatus(t, ts, origin.URL, 1)
+	if status, body := extractStatus(t, ts, origin.URL, 2); status != http.StatusTooManyRequests {
+		t.Fatalf("exhausted key: %d %s", status, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/extract?url="+url.QueryEscape(origin.URL+"/?n=3"), nil)
+	req.Header.Set("X-API-Key", "other-key")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("other key: got %d, want 200", resp.StatusCode)
+	}
+	if usage := getUsage(t, ts); usage.Global.Bytes.Used != int64(2*size) || usage.Global.Bytes.Limit != nil {
+		t.Errorf("global bytes %+v, want %d without a limit", usage.Global.Bytes, 2*size)
+	}
+}
+
+func TestGlobalBudgets(t *testing.T) {
+	origin, hits, size := budgetOrigin(t)
+	t.Run("fetches", func(t *testing.T) {
+		_, ts := newTestServer(t, map[string]string{"MAX_FETCHES_PER_HOUR": "2"})
+		for n := 1; n <= 2; n++ {
+			if status, body := extractStatus(t, ts, origin.URL, n); status != http.StatusOK {
+				t.Fatalf("fetch %d: %d %s", n, status, body)
+			}
+		}
+		before := hits.Load()
+		status, body := extractStatus(t, ts, origin.URL, 3)
+		checkBudgetExceeded(t, status, body, "fetches")
+		if hits.Load() != before {
+			t.Error("origin contacted beyond MAX_FETCHES_PER_HOUR")
+		}
+		usage := getUsage(t, ts)
+		if usage.Global.Fetches.Used != 2 || *usage.Global.Fetches.Remaining != 0 || usage.Exceeded != "fetches" {
+			t.Errorf("global fetches %+v, exceeded %q", usage.Global.Fetches, usage.Exceeded)
+		}
+	})
+	t.Run("bytes", func(t *testing.T) {
+		_, ts := newTestServer(t, map[string]string{"MAX_BYTES_PER_HOUR": strconv.Itoa(size + 100)})
+		extractStatus(t, ts, origin.URL, 1)
+		status, body := extractStatus(t, ts, origin.URL, 2)
+		checkBudgetExceeded(t, status, body, "bytes")
+		if usage := getUsage(t, ts); usage.Global.Bytes.Used != int64(size+100)+1 {
+			t.Errorf("global bytes used %d, want %d+1", usage.Global.Bytes.Used, size+100)
+		}
+	})
+}
+
+// les octets reçus sont comptés, pas l'en-tête ni le corps décompressé
+func TestBudgetCountsDownloadedBytes(t *testing.T) {
+	page := readFixture(t, "budget/page.html")
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(page)
+	zw.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressed.Bytes())
+		case "/huge":
+			// sans Content-Length : lecture interrompue par MAX_BODY_BYTES
+			for i := 0; i < 100; i++ {
+				w.Write(bytes.Repeat([]byte("<p>filler text</p>\n"), 50))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer origin.Close()
+
+	t.Run("compressed", func(t *testing.T) {
+		_, ts := newTestServer(t, nil)
+		if status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(origin.URL+"/gzip"), "", ""); status != http.StatusOK {
+			t.Fatalf("got %d %s", status, body)
+		}
+		if usage := getUsage(t, ts); usage.Bytes.Used != int64(compressed.Len()) {
+			t.Errorf("used %d, want the %d compressed bytes (page is %d)", usage.Bytes.Used, compressed.Len(), len(page))
+		}
+	})
+	t.Run("aborted read", func(t *testing.T) {
+		_, ts := newTestServer(t, map[string]string{"MAX_BODY_BYTES": "1000"})
+		if status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(origin.URL+"/huge"), "", ""); status != http.StatusUnprocessableEntity {
+			t.Fatalf("got %d %s, want 422", status, body)
+		}
+		// au moins ce qui a été lu pour constater le dépassement, pas les 95 000 octets servis
+		if used := getUsage(t, ts).Bytes.Used; used <= 1000 || used >= 95000 {
+			t.Errorf("used %d bytes for an aborted read", used)
+		}
+	})
+}
+
+// fenêtre glissante : remise à zéro quand la tranche la plus ancienne sort
+func TestBudgetWindowReset(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 30, 0, time.UTC)
+	now := start
+	b := newBudget(100, 0, 0)
+	b.now = func() time.Time { return now }
+
+	body := &budgetBody{body: io.NopCloser(strings.NewReader(strings.Repeat("x", 150))), id: "k", budget: b}
+	data, err := io.ReadAll(body)
+	var budgetErr *budgetError
+	if len(data) != 100 || !errors.As(err, &budgetErr) {
+		t.Fatalf("read %d bytes, err %v; want 100 and a budget error", len(data), err)
+	}
+	if want := time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC); !budgetErr.reset.Equal(want) {
+		t.Errorf("reset %s, want %s", budgetErr.reset, want)
+	}
+
+	now = start.Add(30 * time.Minute)
+	if err := b.check("k"); err == nil {
+		t.Error("budget available again before the reset")
+	}
+	now = start.Add(time.Hour)
+	if err := b.check("k"); err != nil {
+		t.Errorf("budget still exhausted after the reset: %v", err)
+	}
+}
+
+func TestBudgetMetrics(t *testing.T) {
+	b := newBudget(0, 0, 0)
+	b.startFetch("k")
+	n, _ := b.reserve("k", 300)
+	b.record("k", n, 120)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(budgetCollector{budget: b})
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			got[family.GetName()+"{"+m.GetLabel()[0].GetValue()+"}"] = m.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"outbound_budget_bytes{}":    120,
+		"outbound_budget_bytes{k}":   120,
+		"outbound_budget_fetches{}":  1,
+		"outbound_budget_fetches{k}": 1,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Author est un auteur de l'article ; url pointe vers sa page quand la source l'indique
+type Author struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// au-delà, la signature est une liste de contributeurs, pas un auteur
+const maxAuthors = 10
+
+// mots qui ne sont pas des noms : fonctions, rubriques, comptes génériques.
+// Retirés en tête et en fin de nom ("Senior Reporter Jane Doe", "AP Staff") ;
+// un nom qui n'est fait que de ces mots est ignoré.
+var defaultBylineStopwords = []string{
+	"staff", "admin", "administrator", "editor", "editors", "editorial", "team",
+	"desk", "newsroom", "writer", "writers", "reporter", "reporters", "correspondent",
+	"contributor", "contributors", "columnist", "senior", "chief", "guest", "author",
+	"webmaster", "anonymous", "unknown", "wire", "reports", "rédaction", "redaction", "redacción", "redaktion",
+}
+
+// bylineStopwords : la liste par défaut complétée par extra (BYLINE_STOPWORDS)
+func bylineStopwords(extra []string) map[string]bool {
+	set := make(map[string]bool, len(defaultBylineStopwords)+len(extra))
+	for _, w := range append(defaultBylineStopwords[:len(defaultBylineStopwords):len(defaultBylineStopwords)], extra...) {
+		set[strings.ToLower(w)] = true
+	}
+	return set
+}
+
+// articles ignorés pour décider qu'un nom n'est fait que de mots vides ("The Editors")
+var bylineArticles = map[string]bool{"the": true, "la": true, "le": true, "les": true}
+
+var (
+	// "By", "Par", "Written by"... en tête de signature
+	bylinePrefix = regexp.MustCompile(`(?i)^(?:(?:written|reported|posted|published|text|words|photos?|reporting|story)\s+by|by|from|par|von|por)\s*:?\s+`)
+	// séparateurs entre auteurs
+	bylineSeparator = regexp.MustCompile(`(?i)\s*(?:[,;&/]|\s(?:and|et|und)\s)\s*`)
+	// fin de signature : horodatage ou mention de mise à jour
+	bylineTail = regexp.MustCompile(`(?i)[|•·]|\b(?:updated|published|posted|modified|yesterday|today)\b|\bmis à jour|\bpublié|` +
+		`\b(?:editing|edited|compiled|translated)\s+by\b|` +
+		`\b\d{1,2}[:h]\d{2}\b|\b\d{1,4}[/.-]\d{1,2}[/.-]\d{1,4}\b|` +
+		`\b(?:jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec|janv|févr|avr|mai|juin|juil|août|déc)[a-zéû]*\.?\s+\d{1,2}\b|` +
+		`\b\d{1,2}\s+(?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec|janv|févr|mars|avr|mai|juin|juil|août|déc)[a-zéû]*\b|` +
+		`\b\d+\s+(?:seconds?|minutes?|mins?|hours?|hrs?|days?|weeks?)\s+ago\b`)
+	// mot de liaison laissé devant l'horodatage coupé ("admin on 04/05/2023")
+	bylineConnective = regexp.MustCompile(`(?i)\s+(?:on|at|le|à|am|du)\s*$`)
+	// suffixes rattachés au nom précédent ("Martin Luther King, Jr.")
+	nameSuffix = regexp.MustCompile(`(?i)^(?:jr|sr|ii|iii|iv)\.?$`)
+	// précision entre parenthèses ("Jane Doe (Reuters)")
+	bylineParenthetical = regexp.MustCompile(`\s*\([^)]*\)`)
+)
+
+// éléments de commentaires, dont les auteurs ne sont pas ceux de l'article
+const commentSelector = `[itemtype*="Comment"], .comment, .comments, #comments, .comment-list`
+
+// extractAuthors retient la première source qui donne des auteurs : JSON-LD,
+// puis meta (article:author, author, twitter:creator), puis microdonnées,
+// liens rel=author et signature visible. Les liens de profil trouvés dans la
+// page complètent les url manquantes.
+func (e *engine) extractAuthors(doc *goquery.Document, ld []Author, pageURL string) []Author {
+	authors := ld
+	for _, key := range []string{"article:author", "author", "twitter:creator"} {
+		if len(authors) > 0 {
+			break
+		}
+		for _, v := range metaContents(doc, key) {
+			if isHTTPURL(v) { // article:author contient souvent l'URL d'un profil
+				continue
+			}
+			authors = append(authors, namedAuthors(e.splitByline(v))...)
+		}
+	}
+	if len(authors) == 0 {
+		authors = e.visibleAuthors(doc)
+	}
+
+	links := e.authorLinks(doc)
+	for i := range authors {
+		if authors[i].URL == "" {
+			authors[i].URL = links[strings.ToLower(authors[i].Name)]
+		}
+		authors[i].URL = resolveURL(pageURL, authors[i].URL)
+	}
+	return dedupeAuthors(authors)
+}
+
+// ldAuthors lit author d'un objet JSON-LD (chaîne, objet ou tableau). Le nom
+// d'une Person ou d'une Organization n'est pas découpé ("Anderson and Sons") ;
+// les organisations ne sont gardées qu'en l'absence de personne.
+func (e *engine) ldAuthors(v any) []Author {
+	var persons, orgs []Author
+	var walk func(any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case string:
+			persons = append(persons, namedAuthors(e.splitByline(t))...)
+		case map[string]any:
+			name := e.cleanAuthorName(ldName(t["name"]))
+			if name == "" {
+				return
+			}
+			a := Author{Name: name, URL: ldURL(t["url"])}
+			if isOrganizationType(t["@type"]) {
+				orgs = append(orgs, a)
+			} else {
+				persons = append(persons, a)
+			}
+		case []any:
+			for _, item := range t {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+	if len(persons) == 0 {
+		return orgs
+	}
+	return persons
+}
+
+func isOrganizationType(t any) bool {
+	switch v := t.(type) {
+	case string:
+		return strings.HasSuffix(v, "Organization") || v == "Corporation"
+	case []any:
+		for _, item := range v {
+			if isOrganizationType(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visibleAuthors : microdonnées itemprop=author, sinon liens rel=author,
+// sinon texte de la première signature (.byline, .author)
+func (e *engine) visibleAuthors(doc *goquery.Document) []Author {
+	var authors []Author
+	doc.Find(`[itemprop~="author"]`).Each(func(i int, s *goquery.Selection) {
+		if s.Closest(commentSelector).Length() > 0 || s.ParentsFiltered(`[itemprop~="author"]`).Length() > 0 {
+			return
+		}
+		name := s
+		if inner := s.Find(`[itemprop~="name"]`).First(); inner.Length() > 0 {
+			name = inner
+		}
+		text := firstNonEmpty(name.AttrOr("content", ""), name.Text())
+		a := Author{URL: firstNonEmpty(s.AttrOr("href", ""), s.Find(`[itemprop~="url"]`).AttrOr("href", ""))}
+		// une Person nomme un seul auteur ; un simple texte peut en nommer plusieurs
+		if _, scoped := s.Attr("itemscope"); scoped {
+			if a.Name = e.cleanAuthorName(text); a.Name != "" {
+				authors = append(authors, a)
+			}
+			return
+		}
+		for _, n := range e.splitByline(text) {
+			authors = append(authors, Author{Name: n, URL: a.URL})
+		}
+	})
+	if len(authors) > 0 {
+		return authors
+	}
+
+	doc.Find(`a[rel~="author"]`).Each(func(i int, s *goquery.Selection) {
+		if s.Closest(commentSelector).Length() > 0 {
+			return
+		}
+		if name := e.cleanAuthorName(s.Text()); name != "" {
+			authors = append(authors, Author{Name: name, URL: s.AttrOr("href", "")})
+		}
+	})
+	if len(authors) > 0 {
+		return authors
+	}
+
+	byline := doc.Find(".byline, .author").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return s.Closest(commentSelector).Length() == 0
+	}).First()
+	if byline.Length() == 0 {
+		return nil
+	}
+	// la date de la signature est le plus souvent dans un <time>
+	return namedAuthors(e.splitByline(byline.Clone().Find("time").Remove().End().Text()))
+}
+
+// authorLinks associe le nom affiché (en minuscules) au lien de profil
+func (e *engine) authorLinks(doc *goquery.Document) map[string]string {
+	links := map[string]string{}
+	doc.Find(`a[rel~="author"], a[itemprop~="author"], [itemprop~="author"] a, .byline a, .author a`).Each(func(i int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") || s.Closest(commentSelector).Length() > 0 {
+			return
+		}
+		if name := strings.ToLower(e.cleanAuthorName(s.Text())); name != "" && links[name] == "" {
+			links[name] = href
+		}
+	})
+	return links
+}
+
+// splitByline découpe une signature libre en noms :
+// "By Jane Doe, John Smith and AP Staff | Updated 3:45 PM" donne
+// Jane Doe, John Smith, AP
+func (e *engine) splitByline(s string) []string {
+	s = trimBylineTail(bylinePrefix.ReplaceAllString(normalizeText(strings.ReplaceAll(s, "\n", " ")), ""))
+	var names []string
+	for _, part := range bylineSeparator.Split(s, -1) {
+		part = strings.TrimSpace(part)
+		if nameSuffix.MatchString(part) && len(names) > 0 {
+			names[len(names)-1] += " " + part
+			continue
+		}
+		if name := e.cleanAuthorName(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// trimBylineTail coupe la signature au premier horodatage ou séparateur ("|")
+func trimBylineTail(s string) string {
+	if loc := bylineTail.FindStringIndex(s); loc != nil {
+		s = bylineConnective.ReplaceAllString(s[:loc[0]], "")
+	}
+	return s
+}
+
+// cleanAuthorName normalise un nom : préfixe "By", horodatage, précision
+// entre parenthèses, fonction après un tiret et mots vides en tête ou en fin
+// retirés ; "" si ce qui reste n'est pas un nom, ou n'est qu'une fonction
+// ("Senior Political Correspondent", mots vides aux deux bouts)
+func (e *engine) cleanAuthorName(s string) string {
+	s = normalizeText(strings.ReplaceAll(s, "\n", " "))
+	s = bylinePrefix.ReplaceAllString(s, "")
+	s = trimBylineTail(s)
+	s = bylineParenthetical.ReplaceAllString(s, "")
+	for _, dash := range []string{" - ", " – ", " — "} {
+		s, _, _ = strings.Cut(s, dash)
+	}
+	s = strings.Trim(strings.TrimLeft(strings.TrimSpace(s), "@"), " ,;:-–—")
+
+	isStop := func(w string) bool { return e.stopwords[strings.ToLower(strings.Trim(w, ".,:"))] }
+	// fonction après une virgule ("Ruth Okafor, Business Reporter")
+	if before, after, ok := strings.Cut(s, ", "); ok {
+		if role := strings.Fields(after); len(role) > 0 && isStop(role[len(role)-1]) {
+			s = before
+		}
+	}
+
+	words := strings.Fields(s)
+	onlyStop := true
+	for _, w := range words {
+		if !isStop(w) && !bylineArticles[strings.ToLower(w)] {
+			onlyStop = false
+		}
+	}
+	if onlyStop {
+		return ""
+	}
+	leading, trailing := false, false
+	for len(words) > 0 && isStop(words[0]) {
+		words, leading = words[1:], true
+	}
+	for len(words) > 0 && isStop(words[len(words)-1]) {
+		words, trailing = words[:len(words)-1], true
+	}
+	if leading && trailing {
+		return ""
+	}
+
+	name := strings.Trim(strings.Join(words, " "), " ,;:-–—")
+	if len(words) == 0 || len(words) > 8 || len(name) > 100 ||
+		strings.Contains(name, "@") || strings.Contains(strings.ToLower(name), "http") ||
+		!strings.ContainsFunc(name, unicode.IsLetter) {
+		return ""
+	}
+	return name
+}
+
+// dedupeAuthors retire les doublons à la casse près (en gardant une url
+// trouvée sur l'un d'eux) et borne la liste à maxAuthors
+func dedupeAuthors(authors []Author) []Author {
+	out := []Author{}
+	index := map[string]int{}
+	for _, a := range authors {
+		key := strings.ToLower(a.Name)
+		if i, ok := index[key]; ok {
+			if out[i].URL == "" {
+				out[i].URL = a.URL
+			}
+			continue
+		}
+		if len(out) == maxAuthors {
+			break
+		}
+		index[key] = len(out)
+		out = append(out, a)
+	}
+	return out
+}
+
+func namedAuthors(names []string) []Author {
+	authors := make([]Author, 0, len(names))
+	for _, n := range names {
+		authors = append(authors, Author{Name: n})
+	}
+	return authors
+}
+
+// authorNames : ancien champ author, noms séparés par des virgules
+func authorNames(authors []Author) string {
+	names := make([]string, len(authors))
+	for i, a := range authors {
+		names[i] = a.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// metaContents lit toutes les balises <meta> de la clé, dans l'ordre
+func metaContents(doc *goquery.Document, key string) []string {
+	var values []string
+	doc.Find(`meta[property="` + key + `"], meta[name="` + key + `"]`).Each(func(i int, s *goquery.Selection) {
+		if v := strings.TrimSpace(s.AttrOr("content", "")); v != "" {
+			values = append(values, v)
+		}
+	})
+	return values
+}
+
+func isHTTPURL(s string) bool {
+	s = strings.ToLower(s)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
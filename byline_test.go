@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// signatures relevées sur des sites d'information
+func TestSplitByline(t *testing.T) {
+	e := newTestEngine(t, nil)
+	tests := []struct {
+		byline string
+		want   []string
+	}{
+		{"By Jane Doe, John Smith and AP Staff | Updated 3:45 PM", []string{"Jane Doe", "John Smith", "AP"}},
+		{"By The Associated Press", []string{"The Associated Press"}},
+		{"By Dan Roberts / AFP", []string{"Dan Roberts", "AFP"}},
+		{"Maria García (Reuters) - 12 March 2024", []string{"Maria García"}},
+		{"Reporting by Alice Wong; Editing by Mark Potter", []string{"Alice Wong"}},
+		{"From staff and wire reports", nil},
+		{"By Sarah Jones, Senior Political Correspondent", []string{"Sarah Jones"}},
+		{"By Chris Evans — Sports Editor", []string{"Chris Evans"}},
+		{"By Martin Luther King, Jr. and Coretta Scott King", []string{"Martin Luther King Jr.", "Coretta Scott King"}},
+		{"Written by Tom Brown • 5 min read", []string{"Tom Brown"}},
+		{"By Priya Patel\nPublished 2 hours ago", []string{"Priya Patel"}},
+		{"Par Jean Dupont et Marie Martin, publié le 3 mai 2024 à 10h30", []string{"Jean Dupont", "Marie Martin"}},
+		{"By Lee Chang; Ana Souza & Bob Kim", []string{"Lee Chang", "Ana Souza", "Bob Kim"}},
+		{"Posted by admin on 04/05/2023", nil},
+		{"By Staff", nil},
+		{"@jdoe", []string{"jdoe"}},
+	}
+	for _, tt := range tests {
+		if got := e.splitByline(tt.byline); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitByline(%q) = %q, want %q", tt.byline, got, tt.want)
+		}
+	}
+}
+
+func TestBylineStopwordsConfig(t *testing.T) {
+	byline := "By Jane Doe and Sports Desk Weekend"
+	if got := newTestEngine(t, nil).splitByline(byline); !reflect.DeepEqual(got, []string{"Jane Doe", "Sports Desk Weekend"}) {
+		t.Errorf("default stoplist: %q", got)
+	}
+	e := newTestEngine(t, map[string]string{"BYLINE_STOPWORDS": "Sports,weekend"})
+	if got := e.splitByline(byline); !reflect.DeepEqual(got, []string{"Jane Doe"}) {
+		t.Errorf("BYLINE_STOPWORDS=Sports,weekend: %q", got)
+	}
+}
+
+func TestLDAuthors(t *testing.T) {
+	e := newTestEngine(t, nil)
+	tests := []struct {
+		name, author string
+		want         []Author
+	}{
+		// une Person nomme un seul auteur, même avec "and"
+		{"person with and", `{"@type": "Person", "name": "Anderson and Sons"}`, []Author{{Name: "Anderson and Sons"}}},
+		{"string split", `"Jane Doe and John Smith"`, []Author{{Name: "Jane Doe"}, {Name: "John Smith"}}},
+		{"array with url", `[{"@type": "Person", "name": "Jane Doe", "url": "https://example.com/jane"}, {"@type": "Person", "name": "John Smith"}]`,
+			[]Author{{Name: "Jane Doe", URL: "https://example.com/jane"}, {Name: "John Smith"}}},
+		{"organization dropped beside a person", `[{"@type": "NewsMediaOrganization", "name": "Daily Planet"}, {"@type": "Person", "name": "Lois Lane"}]`, []Author{{Name: "Lois Lane"}}},
+		{"organization alone", `{"@type": ["Organization"], "name": "Reuters"}`, []Author{{Name: "Reuters"}}},
+		{"generic account", `{"@type": "Person", "name": "admin"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v any
+			if err := json.Unmarshal([]byte(tt.author), &v); err != nil {
+				t.Fatal(err)
+			}
+			if got := e.ldAuthors(v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sources par ordre de priorité, et ancien champ author
+func TestExtractAuthors(t *testing.T) {
+	e := newTestEngine(t, nil)
+	tests := []struct {
+		fixture string
+		authors []Author
+		author  string
+	}{
+		{"jsonld.html", []Author{{Name: "Anderson and Sons", URL: "https://example.com/people/anderson-and-sons"}, {Name: "Ruth Okafor"}}, "Anderson and Sons, Ruth Okafor"},
+		{"visible.html", []Author{{Name: "Lena Fischer", URL: "https://example.com/staff/lena-fischer"}, {Name: "Tom Walsh"}, {Name: "AP"}}, "Lena Fischer, Tom Walsh, AP"},
+		{"relauthor.html", []Author{{Name: "Min-jun Kim", URL: "https://example.com/authors/kim"}, {Name: "Siobhán O'Brien", URL: "https://example.com/authors/obrien"}}, "Min-jun Kim, Siobhán O'Brien"},
+		{"meta.html", []Author{{Name: "Reuters"}}, "Reuters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			article := extractFixture(t, e, "byline/"+tt.fixture, extractOptions{})
+			if !reflect.DeepEqual(article.Authors, tt.authors) || article.Author != tt.author {
+				t.Errorf("authors %+v author %q\nwant %+v %q", article.Authors, article.Author, tt.authors, tt.author)
+			}
+		})
+	}
+}
+
+func TestDedupeAuthors(t *testing.T) {
+	got := dedupeAuthors([]Author{{Name: "Jane Doe"}, {Name: "JANE DOE", URL: "/jane"}, {Name: "John Smith"}})
+	want := []Author{{Name: "Jane Doe", URL: "/jane"}, {Name: "John Smith"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	many := make([]Author, maxAuthors+5)
+	for i := range many {
+		many[i] = Author{Name: string(rune('A'+i)) + " Writer"}
+	}
+	if got := dedupeAuthors(many); len(got) != maxAuthors {
+		t.Errorf("%d authors kept, want %d", len(got), maxAuthors)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// pageCache sérialise les articles dans un Store. Une entrée est fraîche
+// pendant ttl, puis conservée staleTTL de plus pour être revalidée.
+// Une panne du backend, ou une opération plus longue que timeout, se
+// traduit par une absence : le fetch a lieu.
+type pageCache struct {
+	store    Store
+	ttl      time.Duration
+	staleTTL time.Duration
+	timeout  time.Duration
+
+	// consultations depuis le démarrage, pour /cache/stats
+	hits, misses, errors atomic.Int64
+}
+
+// newPageCache : CACHE_TTL, CACHE_STALE_TTL et CACHE_STORE_TIMEOUT
+func newPageCache(store Store, cfg *Config) *pageCache {
+	return &pageCache{store: store, ttl: cfg.CacheTTL, staleTTL: cfg.CacheStaleTTL, timeout: cfg.CacheStoreTimeout}
+}
+
+// count compte une consultation (hit, miss, error), métrique comprise
+func (c *pageCache) count(result string) {
+	cacheRequests.WithLabelValues(result).Inc()
+	switch result {
+	case "hit":
+		c.hits.Add(1)
+	case "miss":
+		c.misses.Add(1)
+	case "error":
+		c.errors.Add(1)
+	}
+}
+
+// purge supprime les entrées de préfixe prefix ; les lectures suivantes sont des absences
+func (c *pageCache) purge(ctx context.Context, prefix string) (int, error) {
+	return c.store.DeletePrefix(ctx, prefix)
+}
+
+// storedArticle : article sérialisé avec ce que son JSON public omet
+type storedArticle struct {
+	StoredAt     time.Time       `json:"stored_at"`
+	Article      json.RawMessage `json:"article"`
+	Feed         *Feed           `json:"feed,omitempty"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+}
+
+// champs exportés d'Article, sans le MarshalJSON qui renvoie le flux
+type articleFields Article
+
+// préfixe commun des entrées du cache dans le Store
+const storePrefix = "article:"
+
+// storeKey : "article:<hôte>:<url>:<clé>", chaque partie en empreinte, pour
+// purger par hôte ou par URL sans garder d'URL en clair dans le Store.
+// La clé de cache commence par l'URL normalisée (voir cacheKey).
+func storeKey(key string) string {
+	pageURL, _, _ := strings.Cut(key, "|")
+	sum := sha256.Sum256([]byte(key))
+	return urlPrefix(pageURL) + hex.EncodeToString(sum[:])
+}
+
+// hostPrefix : préfixe des entrées d'un hôte (sans port)
+func hostPrefix(host string) string {
+	return storePrefix + shortHash(strings.ToLower(host)) + ":"
+}
+
+// urlPrefix : préfixe des entrées d'une URL normalisée, toutes options confondues
+func urlPrefix(normalized string) string {
+	host := ""
+	if u, err := url.Parse(normalized); err == nil {
+		host = u.Hostname()
+	}
+	return hostPrefix(host) + shortHash(normalized) + ":"
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// lookup retourne l'entrée, même expirée, et son âge
+func (c *pageCache) lookup(ctx context.Context, key string) (*Article, time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	data, ok, err := c.store.Get(ctx, storeKey(key))
+	if err != nil {
+		c.count("error")
+		log.Printf("cache get failed, fetching live: %v", err)
+		return nil, 0, false
+	}
+	if !ok {
+		return nil, 0, false
+	}
+	var stored storedArticle
+	var fields articleFields
+	if err := json.Unmarshal(data, &stored); err == nil {
+		err = json.Unmarshal(stored.Article, &fields)
+	}
+	if err != nil {
+		log.Printf("cache entry unreadable, fetching live: %v", err)
+		return nil, 0, false
+	}
+	article := Article(fields)
+	article.feed = stored.Feed
+	article.etag, article.lastModified = stored.ETag, stored.LastModified
+	return &article, time.Now().Sub(stored.StoredAt), true
+}
+
+// Set enregistre l'article ; un échec n'est que journalisé
+func (c *pageCache) Set(ctx context.Context, key string, article *Article) {
+	fields := articleFields(*article)
+	fields.Cached, fields.Revalidated, fields.Fetch = false, false, nil
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		log.Printf("cache encode failed: %v", err)
+		return
+	}
+	data, err := json.Marshal(storedArticle{
+		StoredAt:     time.Now(),
+		Article:      raw,
+		Feed:         article.feed,
+		ETag:         article.etag,
+		LastModified: article.lastModified,
+	})
+	if err != nil {
+		log.Printf("cache encode failed: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if err := c.store.Set(ctx, storeKey(key), data, c.ttl+c.staleTTL); err != nil {
+		log.Printf("cache set failed: %v", err)
+	}
+}
+
+// normalizeURL : hôte en minuscules, sans fragment ni port par défaut, query triée
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	// Encode trie les clés en conservant l'ordre des valeurs répétées
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+func cacheKey(pageURL string, opts extractOptions) string {
+	return normalizeURL(pageURL) + "|" + opts.Format +
+		"|" + strconv.FormatBool(opts.Raw) +
+		"|" + strconv.FormatBool(opts.IncludeDataImages) +
+		"|" + strings.Join(opts.KeepSelectors, ";") +
+		"|" + strconv.FormatBool(opts.FollowPagination) +
+		"|" + strconv.FormatBool(opts.ExpandFeed) +
+		"|" + strconv.FormatBool(opts.IncludeStructured) +
+		"|" + strconv.FormatBool(opts.IncludeLinks) +
+		"|" + strconv.FormatBool(opts.IncludeTables) +
+		"|" + strconv.FormatBool(opts.FlattenTables) +
+		"|" + strconv.FormatBool(opts.IncludeOffsets) +
+		"|" + opts.Prefer +
+		"|" + strconv.FormatBool(opts.VerifyFavicon) +
+		"|" + opts.Fetch.cacheKey()
+}
+
+// sharedExtraction : contexte de l'extraction partagée par les appelants
+// d'une même clé. Il ne dépend pas de l'appelant qui l'a lancée : son départ
+// ne fait pas échouer les autres, et le résultat est mis en cache. Il est
+// annulé quand le dernier appelant s'en va, ou après JOB_TIMEOUT, le plus
+// long qu'un appelant attende (BATCH_TIMEOUT est plus court).
+type sharedExtraction struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+func (e *engine) joinExtraction(ctx context.Context, key string) *sharedExtraction {
+	e.extractionsMu.Lock()
+	defer e.extractionsMu.Unlock()
+	x, ok := e.extractions[key]
+	if !ok {
+		x = &sharedExtraction{}
+		x.ctx, x.cancel = context.WithTimeout(context.WithoutCancel(ctx), e.cfg.JobTimeout)
+		e.extractions[key] = x
+	}
+	x.waiters++
+	return x
+}
+
+func (e *engine) leaveExtraction(key string, x *sharedExtraction) {
+	e.extractionsMu.Lock()
+	defer e.extractionsMu.Unlock()
+	x.waiters--
+	if x.waiters == 0 {
+		x.cancel()
+		if e.extractions[key] == x {
+			delete(e.extractions, key)
+		}
+	}
+}
+
+// extractCached passe par le cache, sauf si nocache force un fetch
+// (le résultat frais remplace alors l'entrée existante)
+func (e *engine) extractCached(ctx context.Context, pageURL string, opts extractOptions, nocache bool) (*Article, time.Duration, error) {
+	key := cacheKey(pageURL, opts)
+	cache := e.cache
+	var stale *Article
+	if !nocache {
+		article, age, ok := cache.lookup(ctx, key)
+		if ok && age <= cache.ttl {
+			cache.count("hit")
+			traceFrom(ctx).setCache("hit")
+			article.Cached = true
+			return article, age, nil
+		}
+		// entrée expirée : requête conditionnelle avec ses ETag / Last-Modified
+		if ok && (article.etag != "" || article.lastModified != "") {
+			stale = article
+		}
+		cache.count("miss")
+		traceFrom(ctx).setCache("miss")
+	} else {
+		traceFrom(ctx).setCache("bypass")
+	}
+
+	shared := e.joinExtraction(ctx, key)
+	defer e.leaveExtraction(key, shared)
+	ch := e.fetches.DoChan(key, func() (any, error) {
+		ctx := shared.ctx
+		if stale != nil {
+			opts.Fetch.conditional = conditionalHeaders{ETag: stale.etag, LastModified: stale.lastModified}
+		}
+
+		article, err := e.extractURL(ctx, pageURL, opts)
+		if errors.Is(err, errNotModified) && stale != nil {
+			// 304 : l'extraction précédente est resservie sans re-parser
+			cache.Set(ctx, key, stale)
+			revalidated := *stale
+			revalidated.Revalidated = true
+			return &revalidated, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cache.Set(ctx, key, article)
+		return article, nil
+	})
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return nil, 0, fetchError(ctx.Err())
+	}
+	if res.Err != nil {
+		return nil, 0, res.Err
+	}
+	fresh := *res.Val.(*Article)
+	return &fresh, 0, nil
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingOrigin sert une page par chemin et compte les requêtes ; release,
+// quand il n'est pas nil, retient les réponses jusqu'à sa fermeture
+type countingOrigin struct {
+	hits    atomic.Int64
+	release chan struct{}
+}
+
+func (o *countingOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.hits.Add(1)
+	if o.release != nil {
+		<-o.release
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><head><title>Page %s</title></head><body><article>", r.URL.Path)
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(w, "<p>Paragraph %d of %s, long enough to be kept as content by the extractor.</p>", i, r.URL.Path)
+	}
+	fmt.Fprint(w, "</article></body></html>")
+}
+
+func newCountingOrigin(t *testing.T, blocking bool) (*countingOrigin, *httptest.Server) {
+	t.Helper()
+	o := &countingOrigin{}
+	if blocking {
+		o.release = make(chan struct{})
+	}
+	ts := httptest.NewServer(o)
+	t.Cleanup(ts.Close)
+	return o, ts
+}
+
+func testOptions(t *testing.T, e *engine) extractOptions {
+	t.Helper()
+	opts := extractOptions{Format: formatText}
+	if err := opts.validate(e.cfg); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+// attend que l'origine ait reçu n requêtes
+func waitHits(t *testing.T, o *countingOrigin, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for o.hits.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("origin got %d requests, want %d", o.hits.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExtractCachedCoalescesConcurrentRequests(t *testing.T) {
+	origin, ts := newCountingOrigin(t, true)
+	e := newTestEngine(t, map[string]string{"HOST_CONCURRENCY": "10"})
+	opts := testOptions(t, e)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := e.extractCached(context.Background(), ts.URL+"/a", opts, false)
+			errs <- err
+		}()
+	}
+	waitHits(t, origin, 1)
+	time.Sleep(50 * time.Millisecond) // les autres appelants rejoignent le fetch en cours
+	close(origin.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := origin.hits.Load(); got != 1 {
+		t.Errorf("origin got %d requests, want 1", got)
+	}
+}
+
+// le départ du premier appelant ne fait pas échouer ceux qui partagent son fetch
+func TestExtractCachedSurvivesFirstCallerCancellation(t *testing.T) {
+	origin, ts := newCountingOrigin(t, true)
+	e := newTestEngine(t, nil)
+	opts := testOptions(t, e)
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, _, err := e.extractCached(first, ts.URL+"/a", opts, false)
+		firstErr <- err
+	}()
+	waitHits(t, origin, 1)
+
+	second := make(chan error, 1)
+	go func() {
+		article, _, err := e.extractCached(context.Background(), ts.URL+"/a", opts, false)
+		if err == nil && article.Title != "Page /a" {
+			err = fmt.Errorf("title %q", article.Title)
+		}
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-firstErr; err == nil {
+		t.Error("cancelled caller got no error")
+	}
+	close(origin.release)
+	if err := <-second; err != nil {
+		t.Fatalf("second caller: %v", err)
+	}
+	if got := origin.hits.Load(); got != 1 {
+		t.Errorf("origin got %d requests, want 1", got)
+	}
+	// le résultat a été mis en cache malgré l'annulation
+	article, _, err := e.extractCached(context.Background(), ts.URL+"/a", opts, false)
+	if err != nil || !article.Cached {
+		t.Errorf("after cancellation: cached=%v err=%v", article != nil && article.Cached, err)
+	}
+}
+
+func TestExtractCachedHitAndTTLExpiry(t *testing.T) {
+	origin, ts := newCountingOrigin(t, false)
+	e := newTestEngine(t, map[string]string{"CACHE_TTL": "50ms"})
+	opts := testOptions(t, e)
+	ctx := context.Background()
+
+	article, _, err := e.extractCached(ctx, ts.URL+"/a", opts, false)
+	if err != nil || article.Cached {
+		t.Fatalf("first call: cached=%v err=%v", article != nil && article.Cached, err)
+	}
+	article, _, err = e.extractCached(ctx, ts.URL+"/a", opts, false)
+	if err != nil || !article.Cached || origin.hits.Load() != 1 {
+		t.Fatalf("second call: cached=%v hits=%d err=%v", article != nil && article.Cached, origin.hits.Load(), err)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	article, _, err = e.extractCached(ctx, ts.URL+"/a", opts, false)
+	if err != nil || article.Cached || origin.hits.Load() != 2 {
+		t.Fatalf("after ttl: cached=%v hits=%d err=%v", article != nil && article.Cached, origin.hits.Load(), err)
+	}
+	if hits, misses := e.cache.hits.Load(), e.cache.misses.Load(); hits != 1 || misses != 2 {
+		t.Errorf("stats: %d hits, %d misses, want 1 and 2", hits, misses)
+	}
+}
+
+func TestExtractCachedEvictsLeastRecentlyUsed(t *testing.T) {
+	origin, ts := newCountingOrigin(t, false)
+	e := newTestEngine(t, map[string]string{"CACHE_MAX_ENTRIES": "2"})
+	opts := testOptions(t, e)
+	ctx := context.Background()
+
+	// a, b, a (a redevient le plus récent), puis c évince b
+	for _, path := range []string{"/a", "/b", "/a", "/c"} {
+		if _, _, err := e.extractCached(ctx, ts.URL+path, opts, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := origin.hits.Load(); got != 3 {
+		t.Fatalf("origin got %d requests, want 3", got)
+	}
+	for _, tt := range []struct {
+		path   string
+		cached bool
+	}{{"/a", true}, {"/c", true}, {"/b", false}} {
+		article, _, err := e.extractCached(ctx, ts.URL+tt.path, opts, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if article.Cached != tt.cached {
+			t.Errorf("%s: cached=%v, want %v", tt.path, article.Cached, tt.cached)
+		}
+	}
+}
+
+func TestExtractNoCacheBypassesAndRefreshes(t *testing.T) {
+	origin, ts := newCountingOrigin(t, false)
+	_, api := newTestServer(t, nil)
+	path := "/extract?url=" + ts.URL + "/a"
+
+	var article Article
+	if status := apiGet(t, api, path, &article); status != http.StatusOK || article.Cached {
+		t.Fatalf("first call: %d cached=%v", status, article.Cached)
+	}
+	article = Article{}
+	if status := apiGet(t, api, path+"&nocache=true", &article); status != http.StatusOK || article.Cached {
+		t.Fatalf("nocache: %d cached=%v", status, article.Cached)
+	}
+	if got := origin.hits.Load(); got != 2 {
+		t.Fatalf("origin got %d requests, want 2", got)
+	}
+	// l'extraction forcée a remplacé l'entrée, toujours servie ensuite
+	article = Article{}
+	if status := apiGet(t, api, path, &article); status != http.StatusOK || !article.Cached {
+		t.Fatalf("after nocache: %d cached=%v", status, article.Cached)
+	}
+	if got := origin.hits.Load(); got != 2 {
+		t.Errorf("origin got %d requests, want 2", got)
+	}
+}
+
+// revalidatingOrigin sert une page versionnée avec ETag et Last-Modified et
+// répond 304 aux requêtes conditionnelles sur la version courante
+type revalidatingOrigin struct {
+	mu          sync.Mutex
+	version     int
+	full        int
+	notModified int
+	ifNoneMatch []string
+	ifModified  []string
+}
+
+func (o *revalidatingOrigin) etag() string { return fmt.Sprintf(`"v%d"`, o.version) }
+
+func (o *revalidatingOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ifNoneMatch = append(o.ifNoneMatch, r.Header.Get("If-None-Match"))
+	o.ifModified = append(o.ifModified, r.Header.Get("If-Modified-Since"))
+	w.Header().Set("ETag", o.etag())
+	w.Header().Set("Last-Modified", time.Date(2024, 3, o.version, 8, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+	if r.Header.Get("If-None-Match") == o.etag() {
+		o.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	o.full++
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><head><title>Tide tables</title></head><body><article>")
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(w, "<p>Version %d of the tide tables, paragraph %d, with enough words to be kept as content.</p>", o.version, i)
+	}
+	fmt.Fprint(w, "</article></body></html>")
+}
+
+func (o *revalidatingOrigin) bump() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.version++
+}
+
+func (o *revalidatingOrigin) lastRequest() (ifNoneMatch, ifModified string, full, notModified int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := len(o.ifNoneMatch) - 1
+	return o.ifNoneMatch[n], o.ifModified[n], o.full, o.notModified
+}
+
+func TestConditionalRevalidation(t *testing.T) {
+	origin := &revalidatingOrigin{version: 1}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	ttl := 40 * time.Millisecond
+	_, ts := newTestServer(t, map[string]string{"CACHE_TTL": ttl.String()})
+	path := "/extract?url=" + url.QueryEscape(originTS.URL+"/tides")
+
+	var first Article
+	if status := apiGet(t, ts, path, &first); status != http.StatusOK || first.Revalidated || first.ContentHash == "" {
+		t.Fatalf("first fetch: %d revalidated=%v hash=%q", status, first.Revalidated, first.ContentHash)
+	}
+	if inm, ims, _, _ := origin.lastRequest(); inm != "" || ims != "" {
+		t.Errorf("first fetch was conditional: %q %q", inm, ims)
+	}
+
+	// entrée expirée : requête conditionnelle, 304, extraction resservie
+	time.Sleep(ttl + 20*time.Millisecond)
+	var second Article
+	if status := apiGet(t, ts, path, &second); status != http.StatusOK {
+		t.Fatalf("revalidation: %d", status)
+	}
+	inm, ims, full, notModified := origin.lastRequest()
+	if inm != `"v1"` || ims != "Fri, 01 Mar 2024 08:00:00 GMT" {
+		t.Errorf("validators sent: If-None-Match %q, If-Modified-Since %q", inm, ims)
+	}
+	if !second.Revalidated || second.Cached || full != 1 || notModified != 1 {
+		t.Errorf("revalidated=%v cached=%v, origin served %d full and %d 304", second.Revalidated, second.Cached, full, notModified)
+	}
+	if second.ContentHash != first.ContentHash || second.CleanText != first.CleanText {
+		t.Error("revalidated extraction differs from the cached one")
+	}
+
+	// l'entrée revalidée repart pour un TTL
+	var third Article
+	apiGet(t, ts, path, &third)
+	if !third.Cached {
+		t.Error("revalidated entry not cached again")
+	}
+}
+
+func TestIfHashShortCircuit(t *testing.T) {
+	origin := &revalidatingOrigin{version: 1}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	_, ts := newTestServer(t, nil)
+	path := "/extract?url=" + url.QueryEscape(originTS.URL+"/tides")
+
+	var article Article
+	apiGet(t, ts, path, &article)
+	status, body := apiRequest(t, ts, http.MethodGet, path+"&if_hash="+article.ContentHash, "", "")
+	if status != http.StatusNotModified || len(body) != 0 {
+		t.Errorf("matching if_hash: got %d with %d bytes", status, len(body))
+	}
+	if status, _ := apiRequest(t, ts, http.MethodGet, path+"&if_hash=0000", "", ""); status != http.StatusOK {
+		t.Errorf("other if_hash: got %d", status)
+	}
+}
+
+// une page modifiée invalide à la fois la revalidation et if_hash
+func TestChangedPageInvalidates(t *testing.T) {
+	origin := &revalidatingOrigin{version: 1}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	ttl := 40 * time.Millisecond
+	_, ts := newTestServer(t, map[string]string{"CACHE_TTL": ttl.String()})
+	path := "/extract?url=" + url.QueryEscape(originTS.URL+"/tides")
+
+	var before Article
+	apiGet(t, ts, path, &before)
+	origin.bump()
+	time.Sleep(ttl + 20*time.Millisecond)
+
+	var after Article
+	if status := apiGet(t, ts, path+"&if_hash="+before.ContentHash, &after); status != http.StatusOK {
+		t.Fatalf("changed page with the old if_hash: got %d", status)
+	}
+	inm, _, full, notModified := origin.lastRequest()
+	if inm != `"v1"` || full != 2 || notModified != 0 {
+		t.Errorf("If-None-Match %q, origin served %d full and %d 304", inm, full, notModified)
+	}
+	if after.Revalidated || after.ContentHash == before.ContentHash {
+		t.Errorf("revalidated=%v, hash unchanged=%v", after.Revalidated, after.ContentHash == before.ContentHash)
+	}
+	// les nouveaux validateurs remplacent les anciens
+	time.Sleep(ttl + 20*time.Millisecond)
+	apiGet(t, ts, path, nil)
+	if inm, _, _, notModified := origin.lastRequest(); inm != `"v2"` || notModified != 1 {
+		t.Errorf("next revalidation sent %q, %d 304", inm, notModified)
+	}
+}
+
+func TestTextHashNormalizesWhitespace(t *testing.T) {
+	a := textHash("Tide tables\n\nfor  March")
+	if a != textHash(" Tide tables for March ") {
+		t.Error("whitespace changes the hash")
+	}
+	if a == textHash("Tide tables for April") || len(a) != 64 {
+		t.Errorf("hash %q", a)
+	}
+}
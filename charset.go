@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// BOM reconnus, avec le charset qu'ils désignent
+var byteOrderMarks = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+}
+
+// charset envoyé par défaut par les serveurs, sans rien savoir de la page :
+// us-ascii, iso-8859-1 et latin1 sont des alias de windows-1252 en HTML
+const genericCharset = "windows-1252"
+
+// decodeBody convertit la page en UTF-8. Priorité : BOM, charset de l'en-tête
+// Content-Type, <meta charset> / http-equiv, puis détection sur les octets.
+// Un charset générique dans l'en-tête (iso-8859-1, us-ascii) cède devant
+// <meta> et la détection. Retourne aussi le nom du charset retenu.
+func decodeBody(body []byte, contentType string) (io.Reader, string) {
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(body, m.bom) {
+			// BOMOverride retire le BOM et décode selon lui
+			decoder := unicode.BOMOverride(encoding.Nop.NewDecoder())
+			return transform.NewReader(bytes.NewReader(body), decoder), m.name
+		}
+	}
+
+	enc, name := headerCharset(contentType)
+	if enc == nil || name == genericCharset {
+		// sans charset dans l'en-tête, DetermineEncoding lit <meta> puis les
+		// octets ; à défaut des deux il retient lui aussi windows-1252
+		enc, name, _ = charset.DetermineEncoding(body, "text/html")
+	}
+	return transform.NewReader(bytes.NewReader(body), enc.NewDecoder()), name
+}
+
+// headerCharset retourne l'encodage du paramètre charset de Content-Type,
+// ou nil s'il est absent ou inconnu
+func headerCharset(contentType string) (encoding.Encoding, string) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return nil, ""
+	}
+	enc, err := htmlindex.Get(params["charset"])
+	if err != nil {
+		return nil, ""
+	}
+	name, _ := htmlindex.Name(enc)
+	return enc, name
+}
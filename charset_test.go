@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBodyFixtures(t *testing.T) {
+	tests := []struct {
+		fixture, contentType string
+		wantCharset, want    string
+	}{
+		{"latin1.html", "text/html", "windows-1252", "Déjà les fêtes approchent"},
+		{"cp1251.html", "text/html", "windows-1251", "Московский театр представил"},
+		{"sjis.html", "text/html", "shift_jis", "明日の東京は朝から晴れて"},
+		// en-tête sans charset : <meta> décide
+		{"cp1251.html", "", "windows-1251", "Критики отметили"},
+		// en-tête explicite et spécifique : il l'emporte sur <meta>
+		{"sjis.html", "text/html; charset=Shift_JIS", "shift_jis", "傘を持って"},
+		// charset générique dans l'en-tête (défaut du serveur) : <meta> l'emporte
+		{"cp1251.html", "text/html; charset=ISO-8859-1", "windows-1251", "Московский"},
+		{"sjis.html", "text/html; charset=us-ascii", "shift_jis", "東京"},
+		// BOM : prioritaire et signalé
+		{"utf16bom.html", "text/html; charset=windows-1251", "utf-16le", "declares no charset at all, café"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture+" "+tt.contentType, func(t *testing.T) {
+			reader, name := decodeBody(readFixture(t, tt.fixture), tt.contentType)
+			if name != tt.wantCharset {
+				t.Errorf("charset %q, want %q", name, tt.wantCharset)
+			}
+			text, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(text), tt.want) {
+				t.Errorf("decoded text misses %q:\n%s", tt.want, text)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyStripsUTF8BOM(t *testing.T) {
+	body := append([]byte{0xEF, 0xBB, 0xBF}, "<p>Café</p>"...)
+	reader, name := decodeBody(body, "text/html; charset=iso-8859-1")
+	text, _ := io.ReadAll(reader)
+	if name != "utf-8" || string(text) != "<p>Café</p>" {
+		t.Errorf("got %q as %q", text, name)
+	}
+}
+
+// un en-tête spécifique faux l'emporte sur <meta> : c'est lui qu'on rapporte
+func TestHeaderCharsetBeatsMeta(t *testing.T) {
+	_, name := decodeBody([]byte(`<meta charset="shift_jis"><p>plain ascii</p>`), "text/html; charset=utf-8")
+	if name != "utf-8" {
+		t.Errorf("charset %q, want utf-8", name)
+	}
+}
+
+func TestDetectedCharsetInResponse(t *testing.T) {
+	body := readFixture(t, "cp1251.html")
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write(body)
+	}))
+	defer origin.Close()
+	_, ts := newTestServer(t, nil)
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+origin.URL, &article); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if article.DetectedCharset != "windows-1251" || article.Title != "Новости культуры" {
+		t.Errorf("charset %q, title %q", article.DetectedCharset, article.Title)
+	}
+	if !strings.Contains(article.CleanText, "аплодировали") {
+		t.Errorf("clean_text: %s", article.CleanText)
+	}
+}
+
+func TestExtractHTMLDecodesBOMPage(t *testing.T) {
+	e := newTestEngine(t, nil)
+	opts := extractOptions{Format: formatText}
+	article, err := e.extractHTML(context.Background(), readFixture(t, "utf16bom.html"), "text/html", "https://example.com/", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.DetectedCharset != "utf-16le" || article.Title != "Café with a byte order mark" {
+		t.Errorf("charset %q, title %q", article.DetectedCharset, article.Title)
+	}
+}
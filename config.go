@@ -0,0 +1,206 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config regroupe toute la configuration du service, lue une seule fois au
+// démarrage depuis l'environnement par loadConfig. newServer la transmet aux
+// handlers et au moteur d'extraction (engine) ; rien n'est lu ailleurs.
+type Config struct {
+	// serveur
+	Port                   string
+	ShutdownTimeout        time.Duration
+	ShutdownReadinessDelay time.Duration
+	SlowRequestThreshold   time.Duration
+
+	// clés et secrets
+	APIKeys        []string
+	APIKeysFile    string
+	AdminKeys      []string
+	MetricsToken   string
+	CallbackSecret string
+
+	// requêtes sortantes
+	FetchTimeout      time.Duration
+	FetchMaxAttempts  int
+	FetchRetryBackoff time.Duration
+	MaxRedirects      int
+	MaxBodyBytes      int64
+	AllowContentTypes []string
+	DefaultUserAgent  string
+	OutboundProxy     string
+	AllowRequestProxy bool
+	AllowPrivate      bool
+	RespectRobots     bool
+	RobotsCacheTTL    time.Duration
+	RobotsErrorTTL    time.Duration
+	FaviconCacheTTL   time.Duration
+	HostConcurrency   int
+	HostMinDelay      time.Duration
+
+	// cache
+	CacheTTL          time.Duration
+	CacheStaleTTL     time.Duration
+	CacheStoreTimeout time.Duration
+	CacheMaxEntries   int
+	RedisURL          string
+
+	// limites par client et budgets sortants (0 : pas de limite)
+	RateLimitRPM          int
+	RateLimitBurst        int
+	MaxUploadBytes        int64
+	MaxDiffParagraphs     int
+	MaxBytesPerKeyPerHour int64
+	MaxBytesPerHour       int64
+	MaxFetchesPerHour     int64
+
+	// concurrence
+	BatchConcurrency     int
+	BatchTimeout         time.Duration
+	JobWorkers           int
+	JobQueueSize         int
+	JobTimeout           time.Duration
+	JobTTL               time.Duration
+	CallbackMaxAttempts  int
+	CallbackRetryBackoff time.Duration
+
+	// extraction
+	AMPFallbackMinWords int
+	FeedExpandMax       int
+	MaxLinks            int
+	MaxPages            int
+	MaxTables           int
+	MaxTableCells       int
+	ReadingWPM          int
+	ReadingCPM          int
+	SimhashThreshold    int
+	SiteRulesFile       string
+	CleaningRulesFile   string
+	SanitizeAllowTags   []string
+	SanitizeAllowAttrs  []string
+	BylineStopwords     []string
+
+	// valeurs retenues, pour logValues
+	values []configValue
+}
+
+// loadConfig lit la configuration avec getenv (os.Getenv en production) et
+// la valide ; l'erreur liste toutes les variables invalides
+func loadConfig(getenv func(string) string) (*Config, error) {
+	r := &envReader{getenv: getenv}
+	cfg := &Config{
+		Port:                   r.str("PORT", "8080"),
+		ShutdownTimeout:        r.duration("SHUTDOWN_TIMEOUT", 20*time.Second),
+		ShutdownReadinessDelay: r.duration("SHUTDOWN_READINESS_DELAY", 5*time.Second),
+		SlowRequestThreshold:   r.duration("SLOW_REQUEST_THRESHOLD", 5*time.Second),
+
+		APIKeys:        r.list("API_KEYS", true),
+		APIKeysFile:    r.str("API_KEYS_FILE", ""),
+		AdminKeys:      r.list("ADMIN_KEYS", true),
+		MetricsToken:   r.secret("METRICS_TOKEN"),
+		CallbackSecret: r.secret("CALLBACK_SECRET"),
+
+		FetchTimeout:      r.duration("FETCH_TIMEOUT", defaultFetchTimeout),
+		FetchMaxAttempts:  r.positive("FETCH_MAX_ATTEMPTS", 3),
+		FetchRetryBackoff: r.duration("FETCH_RETRY_BACKOFF", 250*time.Millisecond),
+		MaxRedirects:      r.positive("MAX_REDIRECTS", 5),
+		MaxBodyBytes:      int64(r.positive("MAX_BODY_BYTES", 5<<20)),
+		AllowContentTypes: r.list("ALLOW_CONTENT_TYPES", false),
+		DefaultUserAgent:  r.str("DEFAULT_USER_AGENT", "Mozilla/5.0 (compatible; CleanWebArticle/1.0; +https://clean-web-article.onrender.com)"),
+		OutboundProxy:     r.urlValue("OUTBOUND_PROXY"),
+		AllowRequestProxy: r.boolean("ALLOW_REQUEST_PROXY", false),
+		AllowPrivate:      r.boolean("ALLOW_PRIVATE", false),
+		RespectRobots:     r.boolean("RESPECT_ROBOTS", true),
+		RobotsCacheTTL:    r.duration("ROBOTS_CACHE_TTL", time.Hour),
+		RobotsErrorTTL:    r.duration("ROBOTS_ERROR_TTL", time.Minute),
+		FaviconCacheTTL:   r.duration("FAVICON_CACHE_TTL", 24*time.Hour),
+		HostConcurrency:   r.positive("HOST_CONCURRENCY", 2),
+		HostMinDelay:      r.duration("HOST_MIN_DELAY", 500*time.Millisecond),
+
+		CacheTTL:          r.duration("CACHE_TTL", 15*time.Minute),
+		CacheStaleTTL:     r.duration("CACHE_STALE_TTL", 24*time.Hour),
+		CacheStoreTimeout: r.duration("CACHE_STORE_TIMEOUT", 500*time.Millisecond),
+		CacheMaxEntries:   r.positive("CACHE_MAX_ENTRIES", 1000),
+		RedisURL:          r.urlValue("REDIS_URL"),
+
+		RateLimitRPM:          r.positive("RATE_LIMIT_RPM", 60),
+		RateLimitBurst:        r.positive("RATE_LIMIT_BURST", 10),
+		MaxUploadBytes:        int64(r.positive("MAX_UPLOAD_BYTES", 5<<20)),
+		MaxDiffParagraphs:     r.positive("MAX_DIFF_PARAGRAPHS", 2000),
+		MaxBytesPerKeyPerHour: int64(r.count("MAX_BYTES_PER_KEY_PER_HOUR", 0)),
+		MaxBytesPerHour:       int64(r.count("MAX_BYTES_PER_HOUR", 0)),
+		MaxFetchesPerHour:     int64(r.count("MAX_FETCHES_PER_HOUR", 0)),
+
+		BatchConcurrency:     r.positive("BATCH_CONCURRENCY", 5),
+		BatchTimeout:         r.duration("BATCH_TIMEOUT", 30*time.Second),
+		JobWorkers:           r.positive("JOB_WORKERS", 4),
+		JobQueueSize:         r.positive("JOB_QUEUE_SIZE", 100),
+		JobTimeout:           r.duration("JOB_TIMEOUT", 2*time.Minute),
+		JobTTL:               r.duration("JOB_TTL", time.Hour),
+		CallbackMaxAttempts:  r.positive("CALLBACK_MAX_ATTEMPTS", 4),
+		CallbackRetryBackoff: r.duration("CALLBACK_RETRY_BACKOFF", time.Second),
+
+		AMPFallbackMinWords: r.positive("AMP_FALLBACK_MIN_WORDS", 150),
+		FeedExpandMax:       r.positive("FEED_EXPAND_MAX", 10),
+		MaxLinks:            r.positive("MAX_LINKS", 200),
+		MaxPages:            r.positive("MAX_PAGES", 5),
+		MaxTables:           r.positive("MAX_TABLES", 20),
+		MaxTableCells:       r.positive("MAX_TABLE_CELLS", 2000),
+		ReadingWPM:          r.positive("READING_WPM", 230),
+		ReadingCPM:          r.positive("READING_CPM", 500),
+		SimhashThreshold:    r.count("SIMHASH_THRESHOLD", 3),
+		SiteRulesFile:       r.str("SITE_RULES_FILE", ""),
+		CleaningRulesFile:   r.str("CLEANING_RULES_FILE", ""),
+		SanitizeAllowTags:   r.list("SANITIZE_ALLOW_TAGS", false),
+		SanitizeAllowAttrs:  r.list("SANITIZE_ALLOW_ATTRS", false),
+		BylineStopwords:     r.list("BYLINE_STOPWORDS", false),
+	}
+	cfg.validate(r)
+	cfg.values = r.values
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate vérifie ce qu'une variable seule ne dit pas : formats et
+// combinaisons incompatibles
+func (cfg *Config) validate(r *envReader) {
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		r.fail("PORT", "%q is not a TCP port", cfg.Port)
+	}
+	if cfg.RedisURL != "" {
+		if u, err := url.Parse(cfg.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			r.fail("REDIS_URL", "expected a redis:// or rediss:// URL")
+		}
+		if r.isSet("CACHE_MAX_ENTRIES") {
+			r.fail("CACHE_MAX_ENTRIES", "only applies to the in-memory cache and cannot be combined with REDIS_URL")
+		}
+	}
+	if cfg.ShutdownReadinessDelay >= cfg.ShutdownTimeout {
+		r.fail("SHUTDOWN_READINESS_DELAY", "%s must be shorter than SHUTDOWN_TIMEOUT (%s), which includes it", cfg.ShutdownReadinessDelay, cfg.ShutdownTimeout)
+	}
+	if cfg.BatchTimeout > cfg.JobTimeout {
+		r.fail("BATCH_TIMEOUT", "%s exceeds JOB_TIMEOUT (%s): async batches would be cut short", cfg.BatchTimeout, cfg.JobTimeout)
+	}
+	if strings.ContainsAny(cfg.DefaultUserAgent, "\r\n") {
+		r.fail("DEFAULT_USER_AGENT", "must not contain line breaks")
+	}
+	for _, pair := range cfg.SanitizeAllowAttrs {
+		if tag, key, ok := strings.Cut(pair, ":"); !ok || tag == "" || key == "" {
+			r.fail("SANITIZE_ALLOW_ATTRS", "%q is not tag:attribute", pair)
+		}
+	}
+}
+
+// logValues journalise chaque valeur retenue, secrets masqués
+func (cfg *Config) logValues() {
+	for _, v := range cfg.values {
+		log.Printf("config %s=%s", v.name, v.value)
+	}
+}
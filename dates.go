@@ -0,0 +1,364 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// signal retenu pour la date de publication (date_source)
+const (
+	dateSourceJSONLD    = "json_ld"
+	dateSourceMeta      = "meta"
+	dateSourceMicrodata = "microdata"
+	dateSourceTime      = "time"
+	dateSourceURL       = "url"
+	dateSourceText      = "text"
+	dateSourceRule      = "site_rule"
+)
+
+// fiabilité de la date de publication (date_confidence)
+const (
+	dateHigh   = "high"
+	dateMedium = "medium"
+	dateLow    = "low"
+)
+
+// une date plus ancienne ne vient pas d'un article en ligne
+var minPlausibleDate = time.Date(1995, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// tolérance pour les dates à venir : fuseaux et horloges décalées
+const futureDateSlack = 24 * time.Hour
+
+var (
+	publishedMetaKeys = []string{
+		"article:published_time", "og:published_time", "date", "pubdate", "publishdate",
+		"publish-date", "DC.date.issued", "dcterms.created", "sailthru.date", "parsely-pub-date",
+	}
+	modifiedMetaKeys = []string{
+		"article:modified_time", "og:updated_time", "dcterms.modified", "last-modified",
+	}
+)
+
+// <time> de la signature ou de l'en-tête de l'article
+const nearTimeSelector = `time[pubdate][datetime], .byline time[datetime], .author time[datetime], ` +
+	`article header time[datetime], header time[datetime], [class*="date"] time[datetime], ` +
+	`time[datetime][class*="publish"], time[datetime][itemprop~="datePublished"]`
+
+// éléments où une date est écrite en toutes lettres
+const visibleDateSelector = `.byline, .dateline, .date, .published, .post-date, .entry-date, ` +
+	`.article-date, .timestamp, .meta, [class*="date"], article header, time`
+
+// mots qui annoncent une date de mise à jour
+var updatedContext = regexp.MustCompile(`(?i)(?:updated|modified|edited|mis à jour|mise à jour|màj|actualisé)\W*(?:on|le)?\W*$`)
+
+// date dans un chemin (/2024/03/03/, /2024-03-03)
+var urlDate = regexp.MustCompile(`/((?:19|20)\d{2})[/-](\d{1,2})[/-](\d{1,2})(?:/|-|$)`)
+
+// articleDates : dates de l'article, au format RFC3339
+type articleDates struct {
+	published, modified string
+	source, confidence  string
+}
+
+// extractDates cherche la date de publication dans l'ordre JSON-LD, meta,
+// microdonnées, <time> près de la signature, chemin de l'URL, autre <time>,
+// puis date écrite dans la signature ; celle de mise à jour suit le même
+// ordre. Les dates antérieures à 1995 ou à venir sont écartées.
+func extractDates(doc *goquery.Document, ld Metadata, pageURL string, now time.Time) articleDates {
+	var d articleDates
+	text := visibleDates(doc, now)
+
+	published := []struct {
+		source, confidence string
+		values             []string
+	}{
+		{dateSourceJSONLD, dateHigh, []string{ld.PublishedAt}},
+		{dateSourceMeta, dateHigh, metaValues(doc, publishedMetaKeys)},
+		{dateSourceMicrodata, dateHigh, dateAttrs(doc.Find(`[itemprop~="datePublished"]`))},
+		{dateSourceTime, dateMedium, dateAttrs(notUpdated(doc.Find(nearTimeSelector)))},
+		{dateSourceURL, dateMedium, []string{urlPathDate(pageURL)}},
+		{dateSourceTime, dateLow, dateAttrs(notUpdated(doc.Find("time[datetime]")))},
+		{dateSourceText, dateLow, []string{text.published}},
+	}
+	for _, tier := range published {
+		if v := firstPlausibleDate(tier.values, now); v != "" {
+			d.published, d.source, d.confidence = v, tier.source, tier.confidence
+			break
+		}
+	}
+
+	d.modified = firstPlausibleDate(concat(
+		[]string{ld.ModifiedAt},
+		metaValues(doc, modifiedMetaKeys),
+		dateAttrs(doc.Find(`[itemprop~="dateModified"]`)),
+		dateAttrs(doc.Find(`time[datetime][class*="updat"], time[datetime][class*="modif"]`)),
+		[]string{text.modified},
+	), now)
+	d.dropEarlyModified()
+	return d
+}
+
+// dropEarlyModified : une mise à jour antérieure à la publication est une erreur de la page
+func (d *articleDates) dropEarlyModified() {
+	p, okP := parseDate(d.published)
+	m, okM := parseDate(d.modified)
+	if okP && okM && m.Before(p) {
+		d.modified = ""
+	}
+}
+
+func firstPlausibleDate(values []string, now time.Time) string {
+	for _, v := range values {
+		if t, ok := parseDate(v); ok && plausibleDate(t, now) {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// ruleDate lit la date désignée par une règle de site : format machine
+// ou date écrite ("3 mars 2024")
+func ruleDate(text string) string {
+	now := time.Now()
+	if v := firstPlausibleDate([]string{text}, now); v != "" {
+		return v
+	}
+	for _, m := range findTextDates(text, now) {
+		if plausibleDate(m.t, now) {
+			return m.t.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+func plausibleDate(t, now time.Time) bool {
+	return !t.Before(minPlausibleDate) && !t.After(now.Add(futureDateSlack))
+}
+
+func metaValues(doc *goquery.Document, keys []string) []string {
+	var values []string
+	for _, key := range keys {
+		values = append(values, metaContents(doc, key)...)
+	}
+	return values
+}
+
+// dateAttrs lit content ou datetime de chaque élément, hors commentaires
+func dateAttrs(s *goquery.Selection) []string {
+	var values []string
+	s.Each(func(i int, el *goquery.Selection) {
+		if el.Closest(commentSelector).Length() > 0 {
+			return
+		}
+		if v := firstNonEmpty(el.AttrOr("content", ""), el.AttrOr("datetime", "")); v != "" {
+			values = append(values, v)
+		}
+	})
+	return values
+}
+
+// notUpdated écarte les <time> marqués comme date de mise à jour
+func notUpdated(s *goquery.Selection) *goquery.Selection {
+	return s.FilterFunction(func(i int, el *goquery.Selection) bool {
+		class := strings.ToLower(el.AttrOr("class", "") + " " + el.AttrOr("itemprop", ""))
+		return !strings.Contains(class, "updat") && !strings.Contains(class, "modif")
+	})
+}
+
+// urlPathDate lit /2024/03/03/ ou /2024-03-03 dans le chemin ; "" sinon
+func urlPathDate(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	m := urlDate.FindStringSubmatch(u.Path)
+	if m == nil {
+		return ""
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	t, ok := validDate(year, month, day)
+	if !ok {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// validDate refuse les dates que time.Date normaliserait (31 février)
+func validDate(year, month, day int) (time.Time, bool) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return t, t.Year() == year && int(t.Month()) == month && t.Day() == day
+}
+
+func concat(lists ...[]string) []string {
+	var out []string
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	return out
+}
+
+// noms de mois, anglais et français, complets et abrégés
+var monthNames = map[string]time.Month{
+	"january": 1, "february": 2, "march": 3, "april": 4, "may": 5, "june": 6, "july": 7,
+	"august": 8, "september": 9, "october": 10, "november": 11, "december": 12,
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "jun": 6, "jul": 7, "aug": 8, "sep": 9,
+	"sept": 9, "oct": 10, "nov": 11, "dec": 12,
+	"janvier": 1, "février": 2, "fevrier": 2, "mars": 3, "avril": 4, "mai": 5, "juin": 6,
+	"juillet": 7, "août": 8, "aout": 8, "septembre": 9, "octobre": 10, "novembre": 11,
+	"décembre": 12, "decembre": 12, "janv": 1, "févr": 2, "fevr": 2, "avr": 4, "juil": 7, "déc": 12,
+}
+
+var (
+	monthPattern = func() string {
+		names := make([]string, 0, len(monthNames))
+		for n := range monthNames {
+			names = append(names, regexp.QuoteMeta(n))
+		}
+		// les plus longs d'abord : "september" avant "sep"
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+		return `(` + strings.Join(names, "|") + `)`
+	}()
+	// "March 3, 2024", "Mar. 3rd 2024"
+	monthDayYear = regexp.MustCompile(`(?i)\b` + monthPattern + `\.?\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`)
+	// "3 March 2024", "1er mars 2024", "3rd of March, 2024"
+	dayMonthYear = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th|er)?\s+(?:of\s+)?` + monthPattern + `\.?,?\s+(\d{4})\b`)
+	isoDate      = regexp.MustCompile(`\b((?:19|20)\d{2})-(\d{2})-(\d{2})\b`)
+	// "yesterday", "3 hours ago", "il y a 2 jours"
+	relativeDate = regexp.MustCompile(`(?i)\b(yesterday|today|hier|aujourd['’]hui)\b|` +
+		`\b(\d+)\s+(minutes?|hours?|days?)\s+ago\b|\bil y a\s+(\d+)\s+(minutes?|heures?|jours?)\b`)
+)
+
+// dateMatch est une date trouvée dans un texte, à la position start
+type dateMatch struct {
+	start int
+	end   int
+	t     time.Time
+}
+
+type textDates struct {
+	published, modified string
+}
+
+// visibleDates cherche des dates écrites dans la signature et l'en-tête ;
+// une date précédée de "Updated" ou "Mis à jour" est la date de mise à jour.
+// Les dates invraisemblables sont sautées pour laisser place aux suivantes.
+func visibleDates(doc *goquery.Document, now time.Time) textDates {
+	var d textDates
+	doc.Find(visibleDateSelector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if i >= 30 || (d.published != "" && d.modified != "") {
+			return false
+		}
+		if s.Closest(commentSelector).Length() > 0 {
+			return true
+		}
+		text := normalizeText(strings.ReplaceAll(s.Text(), "\n", " "))
+		if len(text) > 300 { // un bloc de texte, pas une signature
+			return true
+		}
+		prev := 0
+		for _, m := range findTextDates(text, now) {
+			if !plausibleDate(m.t, now) {
+				prev = m.end
+				continue
+			}
+			value := m.t.Format(time.RFC3339)
+			if updatedContext.MatchString(text[prev:m.start]) {
+				if d.modified == "" {
+					d.modified = value
+				}
+			} else if d.published == "" {
+				d.published = value
+			}
+			prev = m.end
+		}
+		return true
+	})
+	return d
+}
+
+// findTextDates : dates absolues et relatives du texte, dans l'ordre
+func findTextDates(text string, now time.Time) []dateMatch {
+	var matches []dateMatch
+	add := func(loc []int, t time.Time, ok bool) {
+		if ok {
+			matches = append(matches, dateMatch{start: loc[0], end: loc[1], t: t})
+		}
+	}
+	for _, m := range monthDayYear.FindAllStringSubmatchIndex(text, -1) {
+		month := monthNames[strings.ToLower(text[m[2]:m[3]])]
+		day, _ := strconv.Atoi(text[m[4]:m[5]])
+		year, _ := strconv.Atoi(text[m[6]:m[7]])
+		t, ok := validDate(year, int(month), day)
+		add(m, t, ok)
+	}
+	for _, m := range dayMonthYear.FindAllStringSubmatchIndex(text, -1) {
+		day, _ := strconv.Atoi(text[m[2]:m[3]])
+		month := monthNames[strings.ToLower(text[m[4]:m[5]])]
+		year, _ := strconv.Atoi(text[m[6]:m[7]])
+		t, ok := validDate(year, int(month), day)
+		add(m, t, ok)
+	}
+	for _, m := range isoDate.FindAllStringSubmatchIndex(text, -1) {
+		year, _ := strconv.Atoi(text[m[2]:m[3]])
+		month, _ := strconv.Atoi(text[m[4]:m[5]])
+		day, _ := strconv.Atoi(text[m[6]:m[7]])
+		t, ok := validDate(year, month, day)
+		add(m, t, ok)
+	}
+	for _, m := range relativeDate.FindAllStringSubmatchIndex(text, -1) {
+		t, ok := relativeTime(text, m, now)
+		add(m, t, ok)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	// "March 3, 2024" et "3, 2024" ne doivent pas compter deux fois
+	var out []dateMatch
+	for _, m := range matches {
+		if len(out) > 0 && m.start < out[len(out)-1].end {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// relativeTime convertit "yesterday" ou "3 hours ago" par rapport à now ;
+// les jours sont ramenés à minuit UTC
+func relativeTime(text string, m []int, now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	day := func(offset int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day()-offset, 0, 0, 0, 0, time.UTC)
+	}
+	if m[2] >= 0 {
+		switch strings.ToLower(text[m[2]:m[3]]) {
+		case "yesterday", "hier":
+			return day(1), true
+		default:
+			return day(0), true
+		}
+	}
+	numIdx, unitIdx := 4, 6
+	if m[4] < 0 {
+		numIdx, unitIdx = 8, 10
+	}
+	n, err := strconv.Atoi(text[m[numIdx]:m[numIdx+1]])
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch unit := strings.ToLower(text[m[unitIdx]:m[unitIdx+1]]); {
+	case strings.HasPrefix(unit, "minute"):
+		return now.Add(-time.Duration(n) * time.Minute).Truncate(time.Minute), true
+	case strings.HasPrefix(unit, "hour"), strings.HasPrefix(unit, "heure"):
+		return now.Add(-time.Duration(n) * time.Hour).Truncate(time.Minute), true
+	default:
+		return day(n), true
+	}
+}
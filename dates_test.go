@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// horloge des tests de dates : "yesterday" et les dates à venir en dépendent
+var datesNow = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+// datesOf applique extractDates à testdata/dates/name vu à l'adresse pageURL
+func datesOf(t *testing.T, e *engine, name, pageURL string) articleDates {
+	t.Helper()
+	doc := mustParse(t, string(readFixture(t, "dates/"+name)))
+	return extractDates(doc, e.jsonLDMetadata(doc), pageURL, datesNow)
+}
+
+// un fixture par étage de la chaîne ; chacun contient des signaux moins
+// fiables que celui qui doit l'emporter
+func TestDateFallbackChain(t *testing.T) {
+	e := newTestEngine(t, nil)
+	tests := []struct {
+		fixture, url string
+		want         articleDates
+	}{
+		{"jsonld.html", "https://example.com/story", articleDates{"2024-03-03T08:00:00Z", "2024-03-05T10:30:00Z", dateSourceJSONLD, dateHigh}},
+		{"meta.html", "https://example.com/story", articleDates{"2024-03-03T08:00:00+01:00", "2024-03-04T09:00:00+01:00", dateSourceMeta, dateHigh}},
+		{"microdata.html", "https://example.com/story", articleDates{"2024-03-03T00:00:00Z", "2024-03-06T00:00:00Z", dateSourceMicrodata, dateHigh}},
+		{"time.html", "https://example.com/2023/01/02/story", articleDates{"2024-03-03T07:00:00Z", "2024-03-04T18:00:00Z", dateSourceTime, dateMedium}},
+		{"plain.html", "https://example.com/news/2024/03/03/harbour-wall", articleDates{"2024-03-03T00:00:00Z", "", dateSourceURL, dateMedium}},
+		{"plain.html", "https://example.com/news/harbour-wall", articleDates{"2023-11-20T00:00:00Z", "", dateSourceTime, dateLow}},
+		{"text-en.html", "https://example.com/story", articleDates{"2024-03-03T00:00:00Z", "2026-10-13T00:00:00Z", dateSourceText, dateLow}},
+		{"text-fr.html", "https://example.com/story", articleDates{"2024-03-03T00:00:00Z", "2024-03-05T00:00:00Z", dateSourceText, dateLow}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture+" "+tt.url, func(t *testing.T) {
+			if got := datesOf(t, e, tt.fixture, tt.url); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// date à venir (meta) et date d'avant 1995 (texte) écartées au profit de la suivante
+func TestImplausibleDatesRejected(t *testing.T) {
+	got := datesOf(t, newTestEngine(t, nil), "implausible.html", "https://example.com/story")
+	want := articleDates{"2024-03-03T00:00:00Z", "", dateSourceText, dateLow}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, tt := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{minPlausibleDate.Add(-time.Second), false},
+		{minPlausibleDate, true},
+		{datesNow.Add(futureDateSlack), true},
+		{datesNow.Add(futureDateSlack + time.Minute), false},
+	} {
+		if got := plausibleDate(tt.t, datesNow); got != tt.want {
+			t.Errorf("plausibleDate(%s) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestModifiedBeforePublishedDropped(t *testing.T) {
+	doc := mustParse(t, `<html><head>
+<meta property="article:published_time" content="2024-03-05T00:00:00Z">
+<meta property="article:modified_time" content="2024-03-01T00:00:00Z">
+</head><body></body></html>`)
+	if got := extractDates(doc, Metadata{}, "https://example.com/", datesNow); got.published != "2024-03-05T00:00:00Z" || got.modified != "" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestFindTextDates(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"March 3, 2024", []string{"2024-03-03"}},
+		{"Mar. 3rd 2024", []string{"2024-03-03"}},
+		{"3rd of March, 2024", []string{"2024-03-03"}},
+		{"1er mars 2024", []string{"2024-03-01"}},
+		{"le 12 février 2024", []string{"2024-02-12"}},
+		{"2024-03-03 and 2024-02-30", []string{"2024-03-03"}},
+		{"3 hours ago", []string{"2026-10-14"}},
+		{"il y a 2 jours", []string{"2026-10-12"}},
+		{"hier", []string{"2026-10-13"}},
+		{"February 30, 2024", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range findTextDates(tt.text, datesNow) {
+			got = append(got, m.t.Format("2006-01-02"))
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("findTextDates(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// published_at, modified_at, date_source et date_confidence dans la réponse
+func TestArticleDates(t *testing.T) {
+	article := extractFixture(t, newTestEngine(t, nil), "dates/jsonld.html", extractOptions{})
+	if article.PublishedAt != "2024-03-03T08:00:00Z" || article.ModifiedAt != "2024-03-05T10:30:00Z" ||
+		article.DateSource != dateSourceJSONLD || article.DateConfidence != dateHigh {
+		t.Errorf("published %q modified %q source %q confidence %q", article.PublishedAt, article.ModifiedAt, article.DateSource, article.DateConfidence)
+	}
+}
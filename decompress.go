@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encodings annoncés aux origines ; deflate reste décodé s'il est envoyé quand même
+const acceptEncoding = "gzip, br"
+
+// decodedBody retourne le corps décompressé selon Content-Encoding (encodages
+// successifs appliqués dans l'ordre inverse). Sans en-tête, gzip et zlib sont
+// reconnus à leurs premiers octets ; brotli n'a pas de signature.
+// La limite de taille s'applique ensuite au flux décompressé.
+func decodedBody(resp *http.Response) (io.Reader, error) {
+	var r io.Reader = resp.Body
+
+	var encodings []string
+	for _, enc := range strings.Split(resp.Header.Get("Content-Encoding"), ",") {
+		if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+			encodings = append(encodings, enc)
+		}
+	}
+	if len(encodings) == 0 {
+		return sniffEncoding(r)
+	}
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(r)
+		case "deflate":
+			r, err = deflateReader(r)
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, unsupportedEncoding(encodings[i])
+		}
+		if err != nil {
+			return nil, decodeFailed(encodings[i])
+		}
+	}
+	return r, nil
+}
+
+// sniffEncoding détecte un corps gzip ou zlib servi sans Content-Encoding
+func sniffEncoding(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	switch {
+	case len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, decodeFailed("gzip")
+		}
+		return gz, nil
+	case isZlibHeader(magic):
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, decodeFailed("deflate")
+		}
+		return zr, nil
+	}
+	return br, nil
+}
+
+// deflateReader accepte zlib (conforme à la RFC) et deflate brut (serveurs fautifs)
+func deflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); isZlibHeader(magic) {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// isZlibHeader : méthode deflate (CM=8), fenêtre ≤ 32 Ko et somme de contrôle de l'en-tête valide
+func isZlibHeader(b []byte) bool {
+	return len(b) == 2 && b[0]&0x0f == 8 && b[0]>>4 <= 7 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+func unsupportedEncoding(enc string) error {
+	return newAPIError(http.StatusBadGateway, codeFetchFailed, "unsupported content encoding "+enc)
+}
+
+func decodeFailed(enc string) error {
+	return newAPIError(http.StatusBadGateway, codeFetchFailed, "failed to decode "+enc+" body")
+}
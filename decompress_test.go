@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compress(t *testing.T, data []byte, newWriter func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var (
+	gzipWriter   = func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	zlibWriter   = func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	flateWriter  = func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.BestCompression); return fw }
+	brotliWriter = func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+)
+
+// encodedOrigin sert chaque chemin de bodies avec son Content-Encoding et
+// note l'Accept-Encoding reçu
+type encodedOrigin struct {
+	mu             sync.Mutex
+	acceptEncoding string
+	bodies         map[string]encodedBody
+}
+
+type encodedBody struct {
+	encoding string
+	data     []byte
+}
+
+func (o *encodedOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	o.acceptEncoding = r.Header.Get("Accept-Encoding")
+	o.mu.Unlock()
+	body, ok := o.bodies[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if body.encoding != "" {
+		w.Header().Set("Content-Encoding", body.encoding)
+	}
+	w.Write(body.data)
+}
+
+func TestDecompressedBodies(t *testing.T) {
+	page := readFixture(t, "news.html")
+	want := extractFixture(t, newTestEngine(t, nil), "news.html", extractOptions{}).CleanText
+	gz := compress(t, page, gzipWriter)
+	br := compress(t, page, brotliWriter)
+	origin := &encodedOrigin{bodies: map[string]encodedBody{
+		"/gzip":           {"gzip", gz},
+		"/gzip-no-header": {"", gz},
+		"/x-gzip":         {"x-gzip", gz},
+		"/deflate":        {"deflate", compress(t, page, zlibWriter)},
+		"/deflate-raw":    {"deflate", compress(t, page, flateWriter)},
+		"/zlib-no-header": {"", compress(t, page, zlibWriter)},
+		"/br":             {"br", br},
+		"/gzip-then-br":   {"gzip, br", compress(t, gz, brotliWriter)},
+		"/identity":       {"identity", page},
+	}}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	_, ts := newTestServer(t, nil)
+
+	for path := range origin.bodies {
+		t.Run(path, func(t *testing.T) {
+			var article Article
+			if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(originTS.URL+path), &article); status != http.StatusOK {
+				t.Fatalf("status %d", status)
+			}
+			if article.CleanText != want {
+				t.Errorf("garbled text (%d words): %.80q", article.WordCount, article.CleanText)
+			}
+		})
+	}
+	if origin.acceptEncoding != acceptEncoding {
+		t.Errorf("Accept-Encoding %q, want %q", origin.acceptEncoding, acceptEncoding)
+	}
+}
+
+// la limite porte sur la taille décompressée : 10 Mo de zéros tiennent en 20 Ko
+func TestCompressedBombIsRejected(t *testing.T) {
+	bomb := compress(t, bytes.Repeat([]byte{0}, 10<<20), gzipWriter)
+	if len(bomb) > 32<<10 {
+		t.Fatalf("bomb is %d bytes compressed", len(bomb))
+	}
+	origin := &encodedOrigin{bodies: map[string]encodedBody{
+		"/bomb":           {"gzip", bomb},
+		"/bomb-no-header": {"", bomb},
+		"/bomb-br":        {"br", compress(t, bytes.Repeat([]byte{0}, 10<<20), brotliWriter)},
+	}}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	_, ts := newTestServer(t, map[string]string{"MAX_BODY_BYTES": "1000000"})
+
+	for path := range origin.bodies {
+		status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(originTS.URL+path), "", "")
+		if status != http.StatusUnprocessableEntity || errorCode(t, body) != codeBodyTooLarge {
+			t.Errorf("%s: got %d %s, want 422 %s", path, status, body, codeBodyTooLarge)
+		}
+	}
+}
+
+func TestUndecodableBodies(t *testing.T) {
+	origin := &encodedOrigin{bodies: map[string]encodedBody{
+		"/zstd":         {"zstd", []byte("whatever")},
+		"/corrupt-gzip": {"gzip", []byte("<html>not gzip</html>")},
+	}}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	_, ts := newTestServer(t, nil)
+
+	for path, want := range map[string]string{"/zstd": "unsupported content encoding zstd", "/corrupt-gzip": "failed to decode gzip body"} {
+		status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(originTS.URL+path), "", "")
+		if status != http.StatusBadGateway || errorCode(t, body) != codeFetchFailed || !strings.Contains(string(body), want) {
+			t.Errorf("%s: got %d %s", path, status, body)
+		}
+	}
+}
+
+func TestIsZlibHeader(t *testing.T) {
+	for _, tt := range []struct {
+		b    []byte
+		want bool
+	}{
+		{[]byte{0x78, 0x9c}, true},
+		{[]byte{0x78, 0x01}, true},
+		{[]byte{0x78, 0xda}, true},
+		{[]byte{0x1f, 0x8b}, false},
+		{[]byte("<h"), false},
+		{[]byte{0x78}, false},
+	} {
+		if got := isZlibHeader(tt.b); got != tt.want {
+			t.Errorf("isZlibHeader(%x) = %v", tt.b, got)
+		}
+	}
+}
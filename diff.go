@@ -0,0 +1,441 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lignes de contexte autour de chaque bloc du diff unifié
+const diffContext = 3
+
+// au-delà de ce taux de mots communs, deux paragraphes sont le même
+// paragraphe retouché (coquille corrigée, mot ajouté) et non un remplacement
+const similarParagraphRatio = 0.8
+
+// nature d'une opération du diff
+const (
+	opEqual = iota
+	opRemoved
+	opAdded
+)
+
+type diffOp struct {
+	kind int
+	text string
+}
+
+// paragraphChange : paragraphe retouché, avant et après
+type paragraphChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// diffResult est la réponse de /diff. Sans texte précédent (content_hash
+// seul), compared vaut "hash" et seul changed est renseigné.
+type diffResult struct {
+	Changed             bool              `json:"changed"`
+	Compared            string            `json:"compared"`
+	TitleChanged        bool              `json:"title_changed"`
+	PreviousTitle       string            `json:"previous_title,omitempty"`
+	Title               string            `json:"title"`
+	PreviousContentHash string            `json:"previous_content_hash"`
+	ContentHash         string            `json:"content_hash"`
+	AddedParagraphs     []string          `json:"added_paragraphs"`
+	RemovedParagraphs   []string          `json:"removed_paragraphs"`
+	ModifiedParagraphs  []paragraphChange `json:"modified_paragraphs"`
+	Diff                string            `json:"diff"`
+}
+
+// previousVersion est une extraction antérieure renvoyée par le client :
+// paragraphs ou clean_text, sinon seulement content_hash
+type previousVersion struct {
+	Title       string   `json:"title"`
+	CleanText   string   `json:"clean_text"`
+	Paragraphs  []string `json:"paragraphs"`
+	ContentHash string   `json:"content_hash"`
+}
+
+type diffRequest struct {
+	URL         string           `json:"url"`
+	PreviousURL string           `json:"previous_url"`
+	Previous    *previousVersion `json:"previous"`
+	Strict      bool             `json:"strict"`
+}
+
+// diffHandler compare deux pages extraites en direct (GET /diff?url=...&previous_url=...)
+func (s *server) diffHandler(c *gin.Context) {
+	pageURL, previousURL := c.Query("url"), c.Query("previous_url")
+	if pageURL == "" || previousURL == "" {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing url or previous_url parameter"))
+		return
+	}
+	opts, err := s.queryOptions(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	s.respondDiff(c, diffRequest{URL: pageURL, PreviousURL: previousURL, Strict: c.Query("strict") == "true"}, opts)
+}
+
+// diffPostHandler compare la page à une autre URL ou à une extraction
+// précédente envoyée dans le corps (POST /diff)
+func (s *server) diffPostHandler(c *gin.Context) {
+	var body diffRequest
+	if !s.bindJSON(c, &body) {
+		return
+	}
+	if body.URL == "" {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing url"))
+		return
+	}
+	if body.PreviousURL == "" && (body.Previous == nil || (body.Previous.CleanText == "" && len(body.Previous.Paragraphs) == 0 && body.Previous.ContentHash == "")) {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing previous_url or previous"))
+		return
+	}
+	// la table LCS du diff croît avec le produit des nombres de paragraphes
+	if previous := body.Previous; previous != nil {
+		if len(previous.Paragraphs) == 0 {
+			previous.Paragraphs = splitParagraphs(previous.CleanText)
+		}
+		if n := len(previous.Paragraphs); n > s.cfg.MaxDiffParagraphs {
+			respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("previous has %d paragraphs, at most %d allowed", n, s.cfg.MaxDiffParagraphs)))
+			return
+		}
+	}
+	opts, err := s.queryOptions(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	body.Strict = body.Strict || c.Query("strict") == "true"
+	s.respondDiff(c, body, opts)
+}
+
+// respondDiff extrait la page (et l'URL précédente) sans passer par le cache :
+// il s'agit de détecter les modifications de la page en ligne
+func (s *server) respondDiff(c *gin.Context, req diffRequest, opts extractOptions) {
+	ctx := c.Request.Context()
+
+	var (
+		previous    *Article
+		previousErr error
+		wg          sync.WaitGroup
+	)
+	if req.PreviousURL != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			previous, _, previousErr = s.engine.extractCached(ctx, req.PreviousURL, opts, true)
+		}()
+	}
+	current, _, err := s.engine.extractCached(ctx, req.URL, opts, true)
+	wg.Wait()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if previousErr != nil {
+		respondError(c, prefixError("previous_url: ", previousErr))
+		return
+	}
+	if current.feed != nil || (previous != nil && previous.feed != nil) {
+		respondError(c, newAPIError(http.StatusUnprocessableEntity, codeUnsupportedContentType, "feeds cannot be compared"))
+		return
+	}
+
+	var old previousVersion
+	switch {
+	case previous != nil:
+		old = previousVersion{Title: previous.Title, Paragraphs: previous.Paragraphs, ContentHash: previous.ContentHash}
+	case req.Previous != nil:
+		old = *req.Previous // paragraphes découpés par diffPostHandler
+	}
+	c.JSON(http.StatusOK, compareArticles(old, current, req.Strict))
+}
+
+// prefixError précise à quelle page se rapporte une erreur d'extraction
+func prefixError(prefix string, err error) error {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("%s%w", prefix, err)
+	}
+	prefixed := *apiErr
+	prefixed.Message = prefix + apiErr.Message
+	return &prefixed
+}
+
+// compareArticles compare le titre et les paragraphes. Hors strict, les
+// paragraphes sont comparés en minuscules sans ponctuation ni espaces, et
+// deux paragraphes proches sont signalés comme retouchés.
+func compareArticles(old previousVersion, current *Article, strict bool) diffResult {
+	res := diffResult{
+		Compared:           "text",
+		PreviousTitle:      old.Title,
+		Title:              current.Title,
+		ContentHash:        current.ContentHash,
+		AddedParagraphs:    []string{},
+		RemovedParagraphs:  []string{},
+		ModifiedParagraphs: []paragraphChange{},
+	}
+	if old.Title != "" {
+		res.TitleChanged = old.Title != current.Title
+		if !strict {
+			res.TitleChanged = titleKey(old.Title) != titleKey(current.Title)
+		}
+	}
+
+	if len(old.Paragraphs) == 0 {
+		// seule l'empreinte est connue : pas de détail possible
+		res.Compared = "hash"
+		res.PreviousContentHash = old.ContentHash
+		res.Changed = res.TitleChanged || old.ContentHash != current.ContentHash
+		return res
+	}
+	res.PreviousContentHash = old.ContentHash
+	if res.PreviousContentHash == "" {
+		res.PreviousContentHash = textHash(strings.Join(old.Paragraphs, "\n\n"))
+	}
+
+	ops := diffParagraphs(old.Paragraphs, current.Paragraphs, strict)
+	for _, run := range changedRuns(ops) {
+		var removed, added []string
+		for _, op := range run {
+			if op.kind == opRemoved {
+				removed = append(removed, op.text)
+			} else {
+				added = append(added, op.text)
+			}
+		}
+		if !strict {
+			var changes []paragraphChange
+			removed, added, changes = pairSimilar(removed, added)
+			res.ModifiedParagraphs = append(res.ModifiedParagraphs, changes...)
+		}
+		res.RemovedParagraphs = append(res.RemovedParagraphs, removed...)
+		res.AddedParagraphs = append(res.AddedParagraphs, added...)
+	}
+	if len(res.AddedParagraphs) > 0 || len(res.RemovedParagraphs) > 0 || len(res.ModifiedParagraphs) > 0 {
+		res.Changed = true
+		res.Diff = unifiedDiff(ops)
+	}
+	res.Changed = res.Changed || res.TitleChanged
+	return res
+}
+
+// diffParagraphs aligne les deux versions (plus longue sous-suite commune) ;
+// dans chaque bloc modifié, les retraits précèdent les ajouts
+func diffParagraphs(old, current []string, strict bool) []diffOp {
+	key := func(p string) string {
+		if strict {
+			return p
+		}
+		return titleKey(p)
+	}
+	a, b := make([]string, len(old)), make([]string, len(current))
+	for i, p := range old {
+		a[i] = key(p)
+	}
+	for j, p := range current {
+		b[j] = key(p)
+	}
+
+	lcs := lcsTable(a, b)
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		var removed, added []diffOp
+		for i < len(a) && j < len(b) && a[i] != b[j] {
+			if lcs[i+1][j] >= lcs[i][j+1] {
+				removed = append(removed, diffOp{opRemoved, old[i]})
+				i++
+			} else {
+				added = append(added, diffOp{opAdded, current[j]})
+				j++
+			}
+		}
+		if i == len(a) || j == len(b) {
+			for ; i < len(a); i++ {
+				removed = append(removed, diffOp{opRemoved, old[i]})
+			}
+			for ; j < len(b); j++ {
+				added = append(added, diffOp{opAdded, current[j]})
+			}
+		}
+		ops = append(ops, removed...)
+		ops = append(ops, added...)
+		if i < len(a) && j < len(b) {
+			ops = append(ops, diffOp{opEqual, current[j]})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// lcsTable : t[i][j] est la longueur de la plus longue sous-suite commune à a[i:] et b[j:]
+func lcsTable(a, b []string) [][]int {
+	t := make([][]int, len(a)+1)
+	for i := range t {
+		t[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				t[i][j] = t[i+1][j+1] + 1
+			} else {
+				t[i][j] = max(t[i+1][j], t[i][j+1])
+			}
+		}
+	}
+	return t
+}
+
+// changedRuns regroupe les opérations consécutives qui ne sont pas inchangées
+func changedRuns(ops []diffOp) [][]diffOp {
+	var runs [][]diffOp
+	start := -1
+	for i, op := range ops {
+		if op.kind != opEqual && start < 0 {
+			start = i
+		}
+		if op.kind == opEqual && start >= 0 {
+			runs = append(runs, ops[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		runs = append(runs, ops[start:])
+	}
+	return runs
+}
+
+// pairSimilar associe chaque paragraphe retiré au premier paragraphe ajouté
+// qui lui ressemble ; les autres restent retirés ou ajoutés
+func pairSimilar(removed, added []string) (restRemoved, restAdded []string, changes []paragraphChange) {
+	used := make([]bool, len(added))
+	for _, before := range removed {
+		paired := false
+		for j, after := range added {
+			if !used[j] && similarity(before, after) >= similarParagraphRatio {
+				used[j], paired = true, true
+				changes = append(changes, paragraphChange{Before: before, After: after})
+				break
+			}
+		}
+		if !paired {
+			restRemoved = append(restRemoved, before)
+		}
+	}
+	for j, after := range added {
+		if !used[j] {
+			restAdded = append(restAdded, after)
+		}
+	}
+	return restRemoved, restAdded, changes
+}
+
+// similarity : part des mots (normalisés) communs aux deux paragraphes, dans l'ordre
+func similarity(a, b string) float64 {
+	wa, wb := strings.Fields(titleKey(a)), strings.Fields(titleKey(b))
+	if len(wa)+len(wb) == 0 {
+		return 1
+	}
+	return 2 * float64(lcsTable(wa, wb)[0][0]) / float64(len(wa)+len(wb))
+}
+
+// diffLine : ligne du diff unifié, avec son préfixe (' ', '-' ou '+')
+type diffLine struct {
+	prefix byte
+	text   string
+}
+
+// unifiedDiff produit le diff unifié du texte, une ligne par ligne de paragraphe
+func unifiedDiff(ops []diffOp) string {
+	var lines []diffLine
+	for _, op := range ops {
+		prefix := byte(' ')
+		switch op.kind {
+		case opRemoved:
+			prefix = '-'
+		case opAdded:
+			prefix = '+'
+		}
+		for _, line := range strings.Split(op.text, "\n") {
+			lines = append(lines, diffLine{prefix, line})
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("--- previous\n+++ current\n")
+	oldLine, newLine := 0, 0 // lignes déjà parcourues dans chaque version
+	for i := 0; i < len(lines); {
+		if lines[i].prefix == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// étendre le bloc tant que deux modifications sont séparées par
+		// moins de 2*diffContext lignes inchangées
+		start := max(0, i-diffContext)
+		end := i
+		for end < len(lines) {
+			if lines[end].prefix != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].prefix == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				end = min(len(lines), end+diffContext)
+				break
+			}
+			end = next
+		}
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		oldCount, newCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.prefix != '+' {
+				oldCount++
+			}
+			if l.prefix != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, l := range lines[start:end] {
+			b.WriteByte(l.prefix)
+			b.WriteString(l.text)
+			b.WriteByte('\n')
+		}
+		for _, l := range lines[i:end] {
+			if l.prefix != '+' {
+				oldLine++
+			}
+			if l.prefix != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// hunkRange : "début,nombre" ; un bloc vide commence à la ligne qui le précède
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// paragraphes de testdata/diff/v1.html et v2.html
+const (
+	diffBuses      = "Buses are being diverted through the industrial estate, which adds around twenty minutes to journeys into the town centre."
+	diffBusesV2    = "Buses are being diverted through the industrial estate, which adds around twenty minutes to journeys into the town centre!"
+	diffCouncil    = "The council said it could not yet say how long the closure would last and urged drivers to avoid the area during rush hour."
+	diffCouncilV2  = "The council now expects the bridge to stay shut for at least six weeks while temporary steel supports are installed underneath."
+	diffFootpath   = "Pedestrians can still cross on the eastern footpath, which enginers say was not affected by the damage to the supports."
+	diffFootpathV2 = "Pedestrians can still cross on the eastern footpath, which engineers say was not affected by the damage to the supports."
+	diffShuttle    = "A free shuttle boat between the two quays will run every fifteen minutes from Thursday until the bridge reopens."
+)
+
+func TestDiffRewrittenAndAppended(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	var res diffResult
+	path := "/diff?url=" + url.QueryEscape(origin.URL+"/diff/v2.html") + "&previous_url=" + url.QueryEscape(origin.URL+"/diff/v1.html")
+	if status := apiGet(t, ts, path, &res); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+
+	if !res.Changed || res.TitleChanged || res.Compared != "text" {
+		t.Errorf("changed=%v title_changed=%v compared=%q", res.Changed, res.TitleChanged, res.Compared)
+	}
+	// le paragraphe réécrit est retiré puis ajouté, comme le paragraphe final
+	if want := []string{diffCouncil}; !reflect.DeepEqual(res.RemovedParagraphs, want) {
+		t.Errorf("removed %q, want %q", res.RemovedParagraphs, want)
+	}
+	if want := []string{diffCouncilV2, diffShuttle}; !reflect.DeepEqual(res.AddedParagraphs, want) {
+		t.Errorf("added %q, want %q", res.AddedParagraphs, want)
+	}
+	// coquille corrigée : retouché, pas remplacé ; ponctuation et espaces ignorés
+	if want := []paragraphChange{{diffFootpath, diffFootpathV2}}; !reflect.DeepEqual(res.ModifiedParagraphs, want) {
+		t.Errorf("modified %q, want %q", res.ModifiedParagraphs, want)
+	}
+	if res.PreviousContentHash == "" || res.PreviousContentHash == res.ContentHash {
+		t.Errorf("hashes %q and %q", res.PreviousContentHash, res.ContentHash)
+	}
+	checkGolden(t, "diff-v1-v2.diff", res.Diff)
+}
+
+func TestDiffStrict(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	var res diffResult
+	path := "/diff?url=" + url.QueryEscape(origin.URL+"/diff/v2.html") + "&previous_url=" + url.QueryEscape(origin.URL+"/diff/v1.html") + "&strict=true"
+	if status := apiGet(t, ts, path, &res); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if want := []string{diffBuses, diffCouncil, diffFootpath}; !reflect.DeepEqual(res.RemovedParagraphs, want) {
+		t.Errorf("strict removed %q, want %q", res.RemovedParagraphs, want)
+	}
+	if want := []string{diffBusesV2, diffCouncilV2, diffFootpathV2, diffShuttle}; !reflect.DeepEqual(res.AddedParagraphs, want) {
+		t.Errorf("strict added %q, want %q", res.AddedParagraphs, want)
+	}
+	if len(res.ModifiedParagraphs) != 0 {
+		t.Errorf("strict modified %q, want none", res.ModifiedParagraphs)
+	}
+}
+
+func TestDiffAgainstPreviousExtraction(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	var v1 Article
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(origin.URL+"/diff/v1.html"), &v1); status != http.StatusOK {
+		t.Fatalf("extract v1: %d", status)
+	}
+	current := origin.URL + "/diff/v2.html"
+
+	// texte stocké : même résultat qu'avec deux URL
+	body, _ := json.Marshal(map[string]any{"url": current, "previous": map[string]string{"title": v1.Title, "clean_text": v1.CleanText}})
+	status, data := apiRequest(t, ts, http.MethodPost, "/diff", "application/json", string(body))
+	var res diffResult
+	if status != http.StatusOK || json.Unmarshal(data, &res) != nil {
+		t.Fatalf("POST /diff: %d %s", status, data)
+	}
+	if !reflect.DeepEqual(res.AddedParagraphs, []string{diffCouncilV2, diffShuttle}) || len(res.ModifiedParagraphs) != 1 || res.PreviousContentHash != v1.ContentHash {
+		t.Errorf("stored text: %+v", res)
+	}
+
+	// empreinte seule : changed sans détail
+	for _, tt := range []struct {
+		page    string
+		changed bool
+	}{{current, true}, {origin.URL + "/diff/v1.html", false}} {
+		body, _ := json.Marshal(map[string]any{"url": tt.page, "previous": map[string]string{"content_hash": v1.ContentHash}})
+		status, data := apiRequest(t, ts, http.MethodPost, "/diff", "application/json", string(body))
+		var res diffResult
+		if status != http.StatusOK || json.Unmarshal(data, &res) != nil {
+			t.Fatalf("POST /diff hash: %d %s", status, data)
+		}
+		if res.Compared != "hash" || res.Changed != tt.changed || res.Diff != "" {
+			t.Errorf("%s by hash: compared=%q changed=%v", tt.page, res.Compared, res.Changed)
+		}
+	}
+}
+
+func TestDiffUnchangedPage(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	page := url.QueryEscape(origin.URL + "/diff/v1.html")
+	var res diffResult
+	if status := apiGet(t, ts, "/diff?url="+page+"&previous_url="+page, &res); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if res.Changed || res.Diff != "" || len(res.AddedParagraphs)+len(res.RemovedParagraphs)+len(res.ModifiedParagraphs) != 0 {
+		t.Errorf("same page reported as changed: %+v", res)
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	page := url.QueryEscape(origin.URL + "/diff/v1.html")
+
+	requests := []struct {
+		method, path, body string
+		status             int
+		message            string
+	}{
+		{http.MethodGet, "/diff?url=" + page, "", http.StatusBadRequest, "missing required parameter previous_url"},
+		{http.MethodPost, "/diff", `{"url":"` + origin.URL + `/diff/v1.html"}`, http.StatusBadRequest, "missing previous_url or previous"},
+		{http.MethodPost, "/diff", `{"previous_url":"` + origin.URL + `/diff/v1.html"}`, http.StatusBadRequest, "missing required field url"},
+		{http.MethodGet, "/diff?url=" + page + "&previous_url=" + url.QueryEscape(origin.URL+"/diff/gone.html"), "", http.StatusNotFound, "previous_url: "},
+		{http.MethodGet, "/diff?url=" + page + "&previous_url=" + url.QueryEscape(origin.URL+"/feeds/rss.xml"), "", http.StatusUnprocessableEntity, "feeds cannot be compared"},
+	}
+	for _, r := range requests {
+		status, body := apiRequest(t, ts, r.method, r.path, "application/json", r.body)
+		if status != r.status || !strings.Contains(string(body), r.message) {
+			t.Errorf("%s %s: got %d %s, want %d %q", r.method, r.path, status, body, r.status, r.message)
+		}
+	}
+}
+
+func TestCompareTitles(t *testing.T) {
+	current := &Article{Title: "Harbour bridge closed!", Paragraphs: []string{"Same text."}}
+	old := previousVersion{Title: "Harbour bridge closed", Paragraphs: []string{"Same text."}}
+	if res := compareArticles(old, current, false); res.Changed || res.TitleChanged {
+		t.Errorf("punctuation-only title change reported: %+v", res)
+	}
+	if res := compareArticles(old, current, true); !res.Changed || !res.TitleChanged || res.Diff != "" {
+		t.Errorf("strict title change: %+v", res)
+	}
+	old.Title = "Harbour bridge reopens"
+	if res := compareArticles(old, current, false); !res.TitleChanged || res.PreviousTitle != old.Title {
+		t.Errorf("title change: %+v", res)
+	}
+}
+
+// corps limité à MAX_UPLOAD_BYTES, paragraphes précédents à MAX_DIFF_PARAGRAPHS
+// (la table LCS croît avec leur produit) : refus avant tout téléchargement
+func TestDiffBodyLimits(t *testing.T) {
+	origin, originTS := newCountingOrigin(t, false)
+	_, ts := newTestServer(t, map[string]string{"MAX_UPLOAD_BYTES": "2000", "MAX_DIFF_PARAGRAPHS": "3"})
+	post := func(previous map[string]any) (int, []byte) {
+		body, _ := json.Marshal(map[string]any{"url": originTS.URL + "/page", "previous": previous})
+		return apiRequest(t, ts, http.MethodPost, "/diff", "application/json", string(body))
+	}
+
+	tests := []struct {
+		name     string
+		previous map[string]any
+		status   int
+		code     string
+		message  string
+	}{
+		{"body too large", map[string]any{"clean_text": strings.Repeat("word ", 500)}, http.StatusRequestEntityTooLarge, codeBodyTooLarge, "request body too large"},
+		{"many tiny paragraphs", map[string]any{"paragraphs": []string{"a", "b", "c", "d"}}, http.StatusBadRequest, codeInvalidRequest, "previous has 4 paragraphs, at most 3 allowed"},
+		{"many paragraphs in clean_text", map[string]any{"clean_text": "a\n\nb\n\nc\n\nd"}, http.StatusBadRequest, codeInvalidRequest, "previous has 4 paragraphs, at most 3 allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := post(tt.previous)
+			if status != tt.status || errorCode(t, body) != tt.code || !strings.Contains(string(body), tt.message) {
+				t.Errorf("got %d %s, want %d %s %q", status, body, tt.status, tt.code, tt.message)
+			}
+		})
+	}
+	if hits := origin.hits.Load(); hits != 0 {
+		t.Errorf("origin fetched %d times for refused diffs", hits)
+	}
+
+	if status, body := post(map[string]any{"paragraphs": []string{"a", "b", "c"}}); status != http.StatusOK {
+		t.Errorf("3 paragraphs: got %d %s", status, body)
+	}
+}
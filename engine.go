@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// engine est le moteur d'extraction : client HTTP, limiteurs, budgets,
+// caches, jobs et règles, construits une fois à partir de la configuration.
+// Chaque server a le sien ; deux engines ne partagent aucun état.
+type engine struct {
+	cfg *Config
+
+	client         *http.Client
+	contentTypes   []string                         // defaultContentTypes + ALLOW_CONTENT_TYPES
+	proxy          func(*url.URL) (*url.URL, error) // OUTBOUND_PROXY, sinon HTTP_PROXY / HTTPS_PROXY
+	trustedProxies map[string]bool                  // host:port des proxys de l'opérateur
+	hosts          *politeLimiter
+	budget         *budget
+
+	cache    *pageCache
+	fetches  singleflight.Group // requêtes identiques en cours partagent un seul fetch
+	robots   *robotsCache
+	favicons *faviconCache
+	jobs     *jobRunner
+
+	extractionsMu sync.Mutex
+	extractions   map[string]*sharedExtraction // contextes des fetchs partagés en cours
+
+	rules     atomic.Pointer[siteRuleSet] // SITE_RULES_FILE, relu à chaque SIGHUP
+	junk      cleaningRules               // règles intégrées + CLEANING_RULES_FILE
+	stopwords map[string]bool             // mots vides des signatures + BYLINE_STOPWORDS
+	sanitizer *sanitizePolicy
+}
+
+// newEngine construit le moteur ; une règle, un proxy ou une liste blanche
+// invalide empêche le démarrage. Le cache est en mémoire jusqu'à openCacheStore.
+func newEngine(cfg *Config) (*engine, error) {
+	e := &engine{
+		cfg:            cfg,
+		contentTypes:   append(defaultContentTypes[:len(defaultContentTypes):len(defaultContentTypes)], cfg.AllowContentTypes...),
+		trustedProxies: map[string]bool{},
+		hosts:          newHostLimiter(cfg.HostConcurrency, cfg.HostMinDelay),
+		budget:         newBudget(cfg.MaxBytesPerKeyPerHour, cfg.MaxBytesPerHour, cfg.MaxFetchesPerHour),
+		cache:          newPageCache(newMemoryStore(cfg.CacheMaxEntries), cfg),
+		robots:         newRobotsCache(),
+		favicons:       newFaviconCache(),
+		extractions:    make(map[string]*sharedExtraction),
+		junk:           loadCleaningRules(cfg.CleaningRulesFile),
+		stopwords:      bylineStopwords(cfg.BylineStopwords),
+	}
+	if err := e.configureProxy(); err != nil {
+		return nil, err
+	}
+	e.client = e.newHTTPClient()
+	sanitizer, err := newSanitizePolicy(cfg.SanitizeAllowTags, cfg.SanitizeAllowAttrs)
+	if err != nil {
+		return nil, err
+	}
+	e.sanitizer = sanitizer
+	if err := e.loadSiteRules(); err != nil {
+		return nil, err
+	}
+	e.jobs = newJobRunner(e)
+	return e, nil
+}
+
+// openCacheStore choisit le backend du cache au démarrage ; un Redis
+// configuré (REDIS_URL) mais injoignable empêche le démarrage
+func (e *engine) openCacheStore(ctx context.Context) error {
+	if e.cfg.RedisURL == "" {
+		return nil
+	}
+	store, err := newRedisStore(ctx, e.cfg.RedisURL)
+	if err != nil {
+		return err
+	}
+	e.cache.store = store
+	return nil
+}
+
+// startCleanup nettoie périodiquement limiteurs, caches, budgets et jobs
+func (e *engine) startCleanup() {
+	e.hosts.startCleanup(time.Minute, 10*time.Minute)
+	e.robots.startCleanup(10*time.Minute, e.cfg.RobotsCacheTTL)
+	e.favicons.startCleanup(10 * time.Minute)
+	e.budget.startCleanup(10 * time.Minute)
+	e.jobs.startCleanup(time.Minute, e.cfg.JobTTL)
+}
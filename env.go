@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envReader lit les variables d'environnement pour loadConfig. Une valeur
+// invalide n'est pas remplacée en silence par le défaut : l'erreur est
+// retenue, et toutes sont rapportées ensemble. Chaque valeur retenue est
+// notée pour le journal de démarrage, les secrets masqués.
+type envReader struct {
+	getenv func(string) string
+	errs   []error
+	values []configValue
+}
+
+// configValue : valeur retenue pour une variable, telle que journalisée
+type configValue struct {
+	name, value string
+}
+
+func (r *envReader) lookup(name string) string {
+	return strings.TrimSpace(r.getenv(name))
+}
+
+func (r *envReader) isSet(name string) bool {
+	return r.lookup(name) != ""
+}
+
+func (r *envReader) fail(name, format string, args ...any) {
+	r.errs = append(r.errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+}
+
+func (r *envReader) record(name, value string) {
+	r.values = append(r.values, configValue{name, value})
+}
+
+func (r *envReader) err() error {
+	return errors.Join(r.errs...)
+}
+
+// str lit une chaîne, ou def si la variable est vide
+func (r *envReader) str(name, def string) string {
+	v := r.lookup(name)
+	if v == "" {
+		v = def
+	}
+	r.record(name, strconv.Quote(v))
+	return v
+}
+
+// secret lit une chaîne qui n'apparaît jamais dans le journal
+func (r *envReader) secret(name string) string {
+	v := r.lookup(name)
+	r.record(name, redacted(v != ""))
+	return v
+}
+
+// urlValue lit une URL dont le mot de passe est masqué dans le journal
+func (r *envReader) urlValue(name string) string {
+	v := r.lookup(name)
+	shown := strconv.Quote(v)
+	if u, err := url.Parse(v); err == nil && v != "" {
+		shown = strconv.Quote(u.Redacted())
+	}
+	r.record(name, shown)
+	return v
+}
+
+// list lit une liste séparée par des virgules ; secret masque son contenu
+func (r *envReader) list(name string, secret bool) []string {
+	var out []string
+	for _, v := range strings.Split(r.lookup(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	if secret {
+		r.record(name, fmt.Sprintf("%s (%d)", redacted(len(out) > 0), len(out)))
+	} else {
+		r.record(name, strconv.Quote(strings.Join(out, ",")))
+	}
+	return out
+}
+
+// duration lit une durée strictement positive avec unité ("5s", "1m30s")
+func (r *envReader) duration(name string, def time.Duration) time.Duration {
+	d := def
+	if v := r.lookup(name); v != "" {
+		parsed, err := time.ParseDuration(v)
+		switch {
+		case err != nil:
+			r.fail(name, "%q is not a duration (expected a number with a unit, e.g. 10s or 1m)", v)
+		case parsed <= 0:
+			r.fail(name, "must be positive, got %s", v)
+		default:
+			d = parsed
+		}
+	}
+	r.record(name, d.String())
+	return d
+}
+
+// positive lit un entier strictement positif
+func (r *envReader) positive(name string, def int) int {
+	return r.integer(name, def, 1)
+}
+
+// count lit un entier positif ou nul (0 : pas de limite, selon la variable)
+func (r *envReader) count(name string, def int) int {
+	return r.integer(name, def, 0)
+}
+
+func (r *envReader) integer(name string, def, min int) int {
+	n := def
+	if v := r.lookup(name); v != "" {
+		parsed, err := strconv.Atoi(v)
+		switch {
+		case err != nil:
+			r.fail(name, "%q is not an integer", v)
+		case parsed < min:
+			r.fail(name, "must be at least %d, got %d", min, parsed)
+		default:
+			n = parsed
+		}
+	}
+	r.record(name, strconv.Itoa(n))
+	return n
+}
+
+// boolean lit true / false (ou 1 / 0, t / f)
+func (r *envReader) boolean(name string, def bool) bool {
+	b := def
+	if v := r.lookup(name); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			r.fail(name, "%q is not a boolean (expected true or false)", v)
+		} else {
+			b = parsed
+		}
+	}
+	r.record(name, strconv.FormatBool(b))
+	return b
+}
+
+func redacted(set bool) string {
+	if set {
+		return "[redacted]"
+	}
+	return "[unset]"
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// codes d'erreur renvoyés dans {"error": {"code": ...}}
+const (
+	codeInvalidRequest         = "INVALID_REQUEST"
+	codeUnauthorized           = "UNAUTHORIZED"
+	codeRateLimited            = "RATE_LIMITED"
+	codeBudgetExceeded         = "BUDGET_EXCEEDED"
+	codeInvalidURL             = "INVALID_URL"
+	codeForbiddenAddress       = "FORBIDDEN_ADDRESS"
+	codeRobotsDisallowed       = "ROBOTS_DISALLOWED"
+	codeDNSFailure             = "DNS_FAILURE"
+	codeConnectionRefused      = "CONNECTION_REFUSED"
+	codeTLSError               = "TLS_ERROR"
+	codeProxyError             = "PROXY_ERROR"
+	codeUpstreamTimeout        = "UPSTREAM_TIMEOUT"
+	codeTooManyRedirects       = "TOO_MANY_REDIRECTS"
+	codeRedirectLoop           = "REDIRECT_LOOP"
+	codeFetchFailed            = "FETCH_FAILED"
+	codeUpstreamNotFound       = "UPSTREAM_NOT_FOUND"
+	codeUpstreamClientError    = "UPSTREAM_CLIENT_ERROR"
+	codeUpstreamServerError    = "UPSTREAM_SERVER_ERROR"
+	codeUnsupportedContentType = "UNSUPPORTED_CONTENT_TYPE"
+	codeBodyTooLarge           = "BODY_TOO_LARGE"
+	codeParseFailed            = "PARSE_FAILED"
+	codeJobQueueFull           = "JOB_QUEUE_FULL"
+	codeJobNotFound            = "JOB_NOT_FOUND"
+	codeInternal               = "INTERNAL_ERROR"
+)
+
+// apiError est l'erreur typée renvoyée par l'extraction et les handlers
+type apiError struct {
+	Status         int    `json:"-"`
+	Code           string `json:"code"`
+	Message        string `json:"message"`
+	UpstreamStatus int    `json:"upstream_status,omitempty"`
+	Attempts       int    `json:"attempts,omitempty"`
+	RequestID      string `json:"request_id,omitempty"`
+	// fin de l'épuisement d'un budget (BUDGET_EXCEEDED)
+	ResetAt *time.Time `json:"reset_at,omitempty"`
+	// paramètre refusé par la validation (INVALID_REQUEST), type attendu et valeurs admises
+	Parameter string   `json:"parameter,omitempty"`
+	Expected  string   `json:"expected,omitempty"`
+	Allowed   []string `json:"allowed,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code, message string) *apiError {
+	return &apiError{Status: status, Code: code, Message: message}
+}
+
+// fetchError classe une erreur de transport (DNS, connexion, TLS, délai...)
+func fetchError(err error) *apiError {
+	var dnsErr *net.DNSError
+	var certInvalid x509.CertificateInvalidError
+	var unknownAuth x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var certVerify *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+
+	var budgetErr *budgetError
+	var redirectsErr *tooManyRedirectsError
+
+	switch {
+	case errors.As(err, &budgetErr):
+		return budgetExceeded(budgetErr)
+	case errors.Is(err, errForbiddenAddress):
+		return newAPIError(http.StatusBadRequest, codeForbiddenAddress, errForbiddenAddress.Error())
+	case isProxyError(err):
+		return newAPIError(http.StatusBadGateway, codeProxyError, "could not fetch through the proxy")
+	case errors.As(err, &redirectsErr):
+		return newAPIError(http.StatusBadGateway, codeTooManyRedirects, redirectsErr.Error())
+	case errors.Is(err, errRedirectLoop):
+		return newAPIError(http.StatusBadGateway, codeRedirectLoop, errRedirectLoop.Error())
+	case isTimeout(err):
+		return newAPIError(http.StatusGatewayTimeout, codeUpstreamTimeout, "upstream fetch timed out")
+	case errors.As(err, &dnsErr):
+		return newAPIError(http.StatusBadGateway, codeDNSFailure, "could not resolve host "+dnsErr.Name)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return newAPIError(http.StatusBadGateway, codeConnectionRefused, "upstream refused the connection")
+	case errors.As(err, &certInvalid), errors.As(err, &unknownAuth), errors.As(err, &hostErr),
+		errors.As(err, &certVerify), errors.As(err, &recordErr), errors.As(err, &alertErr):
+		return newAPIError(http.StatusBadGateway, codeTLSError, "tls handshake with upstream failed")
+	}
+	return newAPIError(http.StatusBadGateway, codeFetchFailed, "failed to fetch url")
+}
+
+// upstreamStatusError traduit un statut HTTP non 2xx de l'origine
+func upstreamStatusError(status int) *apiError {
+	msg := fmt.Sprintf("upstream returned status %d", status)
+	var e *apiError
+	switch {
+	case status == http.StatusNotFound || status == http.StatusGone:
+		e = newAPIError(http.StatusNotFound, codeUpstreamNotFound, msg)
+	case status >= 500:
+		e = newAPIError(http.StatusBadGateway, codeUpstreamServerError, msg)
+	default:
+		e = newAPIError(http.StatusBadGateway, codeUpstreamClientError, msg)
+	}
+	e.UpstreamStatus = status
+	return e
+}
+
+// toAPIError garantit une erreur typée, quelle que soit son origine
+func toAPIError(err error) *apiError {
+	var e *apiError
+	if errors.As(err, &e) {
+		return e
+	}
+	var budgetErr *budgetError
+	if errors.As(err, &budgetErr) {
+		return budgetExceeded(budgetErr)
+	}
+	return newAPIError(http.StatusInternalServerError, codeInternal, err.Error())
+}
+
+// respondError est le point unique de conversion erreur -> réponse JSON
+func respondError(c *gin.Context, err error) {
+	e := *toAPIError(err)
+	e.RequestID = c.GetString(ctxRequestID)
+	c.Set(ctxErrorCode, e.Code)
+	if e.ResetAt != nil {
+		c.Header("Retry-After", retryAfterSeconds(*e.ResetAt))
+	}
+	c.AbortWithStatusJSON(e.Status, gin.H{"error": e})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestFetchErrorCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"dns", &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Name: "nope.invalid", Err: "no such host"}}}, http.StatusBadGateway, codeDNSFailure},
+		{"refused", &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, http.StatusBadGateway, codeConnectionRefused},
+		{"tls unknown authority", &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, http.StatusBadGateway, codeTLSError},
+		{"tls hostname", &url.Error{Op: "Get", Err: x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}}, http.StatusBadGateway, codeTLSError},
+		{"deadline", &url.Error{Op: "Get", Err: context.DeadlineExceeded}, http.StatusGatewayTimeout, codeUpstreamTimeout},
+		{"forbidden", &url.Error{Op: "Get", Err: errForbiddenAddress}, http.StatusBadRequest, codeForbiddenAddress},
+		{"loop", &url.Error{Op: "Get", Err: errRedirectLoop}, http.StatusBadGateway, codeRedirectLoop},
+		{"too many redirects", &url.Error{Op: "Get", Err: &tooManyRedirectsError{max: 5}}, http.StatusBadGateway, codeTooManyRedirects},
+		{"other", errors.New("connection reset"), http.StatusBadGateway, codeFetchFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fetchError(tt.err)
+			if got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got %d %s, want %d %s", got.Status, got.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestUpstreamStatusError(t *testing.T) {
+	tests := []struct {
+		upstream, status int
+		code             string
+	}{
+		{404, http.StatusNotFound, codeUpstreamNotFound},
+		{410, http.StatusNotFound, codeUpstreamNotFound},
+		{403, http.StatusBadGateway, codeUpstreamClientError},
+		{429, http.StatusBadGateway, codeUpstreamClientError},
+		{500, http.StatusBadGateway, codeUpstreamServerError},
+		{503, http.StatusBadGateway, codeUpstreamServerError},
+	}
+	for _, tt := range tests {
+		got := upstreamStatusError(tt.upstream)
+		if got.Status != tt.status || got.Code != tt.code || got.UpstreamStatus != tt.upstream {
+			t.Errorf("%d: got %d %s upstream=%d", tt.upstream, got.Status, got.Code, got.UpstreamStatus)
+		}
+	}
+}
+
+func TestToAPIErrorWrapsUntypedErrors(t *testing.T) {
+	typed := newAPIError(http.StatusTeapot, codeInvalidRequest, "typed")
+	if got := toAPIError(fmt.Errorf("context: %w", typed)); got != typed {
+		t.Errorf("wrapped apiError lost: %+v", got)
+	}
+	if got := toAPIError(errors.New("boom")); got.Status != http.StatusInternalServerError || got.Code != codeInternal {
+		t.Errorf("untyped error: %+v", got)
+	}
+}
+
+// chaque code de bout en bout, enveloppe comprise
+func TestErrorEnvelope(t *testing.T) {
+	mux := http.NewServeMux()
+	for _, status := range []int{403, 404, 410, 500, 503} {
+		mux.HandleFunc(fmt.Sprintf("/status/%d", status), func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+	}
+	mux.HandleFunc("/pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4"))
+	})
+	mux.HandleFunc("/large", largePage)
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte("<rss><channel><item><title>broken"))
+	})
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+	tlsOrigin := httptest.NewTLSServer(http.HandlerFunc(largePage)) // certificat inconnu du client
+	defer tlsOrigin.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	_, ts := newTestServer(t, map[string]string{"FETCH_MAX_ATTEMPTS": "1", "MAX_BODY_BYTES": "1000"})
+	tests := []struct {
+		name, url string
+		status    int
+		code      string
+		upstream  int
+	}{
+		{"invalid url", "not a url", http.StatusBadRequest, codeInvalidURL, 0},
+		{"upstream 404", origin.URL + "/status/404", http.StatusNotFound, codeUpstreamNotFound, 404},
+		{"upstream 410", origin.URL + "/status/410", http.StatusNotFound, codeUpstreamNotFound, 410},
+		{"upstream 403", origin.URL + "/status/403", http.StatusBadGateway, codeUpstreamClientError, 403},
+		{"upstream 500", origin.URL + "/status/500", http.StatusBadGateway, codeUpstreamServerError, 500},
+		{"upstream 503", origin.URL + "/status/503", http.StatusBadGateway, codeUpstreamServerError, 503},
+		{"non html", origin.URL + "/pdf", http.StatusUnprocessableEntity, codeUnsupportedContentType, 0},
+		{"too large", origin.URL + "/large", http.StatusUnprocessableEntity, codeBodyTooLarge, 0},
+		{"parse failure", origin.URL + "/feed", http.StatusUnprocessableEntity, codeParseFailed, 0},
+		{"tls", tlsOrigin.URL, http.StatusBadGateway, codeTLSError, 0},
+		{"connection refused", closed.URL, http.StatusBadGateway, codeConnectionRefused, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(tt.url), "", "")
+			if status != tt.status || errorCode(t, body) != tt.code {
+				t.Fatalf("got %d %s, want %d %s", status, body, tt.status, tt.code)
+			}
+			if want := fmt.Sprintf(`"upstream_status":%d`, tt.upstream); tt.upstream != 0 && !strings.Contains(string(body), want) {
+				t.Errorf("missing %s in %s", want, body)
+			}
+			if !strings.Contains(string(body), `"message":`) || !strings.Contains(string(body), `"request_id":`) {
+				t.Errorf("incomplete envelope: %s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Article est le résultat d'une extraction
+type Article struct {
+	Title           string          `json:"title"`
+	RawTitle        string          `json:"raw_title"`
+	Author          string          `json:"author"` // noms des auteurs, séparés par des virgules
+	Authors         []Author        `json:"authors"`
+	PublishedAt     string          `json:"published_at"`
+	ModifiedAt      string          `json:"modified_at"`
+	DateSource      string          `json:"date_source"`
+	DateConfidence  string          `json:"date_confidence"`
+	Image           string          `json:"image"`
+	Favicon         string          `json:"favicon,omitempty"`
+	Description     string          `json:"description"`
+	CanonicalURL    string          `json:"canonical_url"`
+	SiteName        string          `json:"site_name"`
+	FeedURL         string          `json:"feed_url"`
+	CleanText       string          `json:"clean_text"`
+	Paragraphs      []string        `json:"paragraphs"`
+	Summary         string          `json:"summary,omitempty"`
+	TokensEstimate  int             `json:"tokens_estimate"`
+	WordCount       int             `json:"word_count"`
+	ReadingTime     int             `json:"reading_time_seconds"`
+	ContentHash     string          `json:"content_hash"`
+	SimHash         string          `json:"simhash"` // vide sous 50 mots
+	Language        string          `json:"language"`
+	Access          string          `json:"access"`
+	BlockReason     string          `json:"block_reason,omitempty"`
+	Images          []Image         `json:"images"`
+	Links           []Link          `json:"links,omitempty"`
+	Tables          []Table         `json:"tables,omitempty"`
+	Blocks          []TextBlock     `json:"blocks,omitempty"`  // include_offsets
+	Version         string          `json:"version,omitempty"` // pipelineVersion, avec blocks
+	Format          string          `json:"format"`
+	Content         string          `json:"content"`
+	Cached          bool            `json:"cached"`
+	Revalidated     bool            `json:"revalidated"`
+	DetectedCharset string          `json:"detected_charset"`
+	FinalURL        string          `json:"final_url"`
+	SourceVariant   string          `json:"source_variant"`
+	RedirectChain   []string        `json:"redirect_chain"`
+	Attempts        int             `json:"attempts"`
+	Fetch           *fetchDebug     `json:"fetch,omitempty"`
+	PagesFetched    int             `json:"pages_fetched"`
+	PageURLs        []string        `json:"page_urls"`
+	StructuredData  *StructuredData `json:"structured_data,omitempty"`
+
+	nextPage string // page suivante détectée (follow_pagination)
+	feed     *Feed  // renseigné quand l'URL est un flux RSS/Atom
+	ampURL   string // version <link rel="amphtml"> de la page
+
+	etag         string // validateurs de la réponse, pour la revalidation
+	lastModified string
+}
+
+// errNotModified : l'origine a répondu 304 à une requête conditionnelle
+var errNotModified = errors.New("not modified")
+
+// MarshalJSON renvoie le flux à la place de l'article quand l'URL en est un
+func (a Article) MarshalJSON() ([]byte, error) {
+	if a.feed != nil {
+		feed := *a.feed
+		feed.Cached = a.Cached
+		return json.Marshal(feed)
+	}
+	type plain Article
+	return json.Marshal(plain(a))
+}
+
+// extractOptions regroupe les paramètres d'une extraction
+type extractOptions struct {
+	Raw    bool   // ancien comportement : concaténation des <p>
+	Format string // text (défaut), html ou markdown
+	Fetch  fetchOptions
+
+	IncludeDataImages bool     // conserver les images en data: URI
+	KeepSelectors     []string // éléments à ne jamais retirer
+	FollowPagination  bool     // suivre les pages suivantes de l'article
+	ExpandFeed        bool     // extraire aussi les entrées d'un flux
+	IncludeStructured bool     // renvoyer JSON-LD, og:/twitter: et microdonnées
+	IncludeLinks      bool     // renvoyer les liens du contenu principal
+	IncludeTables     bool     // renvoyer les tableaux de données du contenu principal
+	FlattenTables     bool     // verser les tableaux imbriqués dans leur cellule
+	IncludeOffsets    bool     // renvoyer les blocs de text et leurs positions
+	Prefer            string   // "amp" : version AMP tentée quelle que soit la page d'origine
+	VerifyFavicon     bool     // vérifier l'icône du site par une requête HEAD
+}
+
+// validate vérifie les options et complète celles qui dépendent de la
+// configuration (User-Agent par défaut)
+func (o *extractOptions) validate(cfg *Config) error {
+	if !validFormat(o.Format) {
+		return newAPIError(http.StatusBadRequest, codeInvalidRequest, "unsupported format")
+	}
+	if !validPrefer(o.Prefer) {
+		return newAPIError(http.StatusBadRequest, codeInvalidRequest, "unsupported prefer value")
+	}
+	if err := validateRequestProxy(o.Fetch.Proxy, cfg.AllowRequestProxy); err != nil {
+		return err
+	}
+	if o.Fetch.UserAgent == "" {
+		o.Fetch.UserAgent = cfg.DefaultUserAgent
+	}
+	return validateHeaders(o.Fetch.Headers)
+}
+
+// extractURL télécharge la page et en extrait l'article
+func (e *engine) extractURL(ctx context.Context, pageURL string, opts extractOptions) (*Article, error) {
+	resp, body, attempts, err := e.fetchPage(ctx, pageURL, opts.Fetch)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, errNotModified
+	}
+	opts.Fetch.conditional = conditionalHeaders{} // pas pour les pages suivantes ni les entrées d'un flux
+
+	contentType := resp.Header.Get("Content-Type")
+	if isFeed(body, contentType) {
+		feed, err := parseFeed(body, resp.Request.URL.String())
+		if err != nil {
+			return nil, err
+		}
+		if opts.ExpandFeed {
+			e.expandFeed(ctx, feed, opts)
+		}
+		return &Article{
+			feed:         feed,
+			Attempts:     attempts,
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+		}, nil
+	}
+	// XML accepté pour les flux uniquement
+	if mediaType, _, _ := mime.ParseMediaType(contentType); feedContentTypes[mediaType] && !e.contentTypeAllowed(mediaType) {
+		return nil, unsupportedContentType(mediaType)
+	}
+
+	article, err := e.extractHTML(ctx, body, contentType, resp.Request.URL.String(), opts)
+	if err != nil {
+		return nil, err
+	}
+	article.FinalURL = resp.Request.URL.String()
+	article.RedirectChain = redirectChain(resp)
+	article.Attempts = attempts
+	article.etag = resp.Header.Get("ETag")
+	article.lastModified = resp.Header.Get("Last-Modified")
+	if e.useAMP(article, opts) {
+		article = e.extractAMP(ctx, article, opts)
+	}
+	article.Favicon = e.siteFavicon(ctx, article.FinalURL, article.Favicon, opts.VerifyFavicon)
+	article.PagesFetched = 1
+	article.PageURLs = []string{article.FinalURL}
+	if opts.FollowPagination {
+		e.followPagination(ctx, article, opts)
+	}
+	return article, nil
+}
+
+// extractHTML décode et parse un document puis en extrait l'article,
+// sans rien télécharger. pageURL sert à résoudre les liens relatifs.
+func (e *engine) extractHTML(ctx context.Context, body []byte, contentType, pageURL string, opts extractOptions) (*Article, error) {
+	parseStart := time.Now()
+	reader, charsetName := decodeBody(body, contentType)
+	doc, err := goquery.NewDocumentFromReader(reader)
+	observePhase(ctx, "parse", parseStart)
+	if err != nil {
+		return nil, newAPIError(http.StatusUnprocessableEntity, codeParseFailed, "failed to parse page")
+	}
+
+	// avant extractDocument, qui modifie le document
+	var next string
+	if opts.FollowPagination {
+		next = findNextPage(doc, pageURL)
+	}
+
+	extractStart := time.Now()
+	article := e.extractDocument(doc, pageURL, opts)
+	article.nextPage = next
+	observePhase(ctx, "extract", extractStart)
+
+	article.DetectedCharset = charsetName
+	return article, nil
+}
+
+// fetchPage valide l'URL, télécharge la page (avec nouvelles tentatives sur
+// les échecs transitoires) et retourne la réponse, son corps et le nombre de tentatives
+func (e *engine) fetchPage(ctx context.Context, pageURL string, opts fetchOptions) (*http.Response, []byte, int, error) {
+	ctx = withProxy(ctx, opts.Proxy)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, nil, 0, newAPIError(http.StatusBadRequest, codeInvalidURL, "invalid url")
+	}
+	if err := e.validateURL(ctx, req.URL); err != nil {
+		if errors.Is(err, errForbiddenAddress) {
+			return nil, nil, 0, newAPIError(http.StatusBadRequest, codeForbiddenAddress, err.Error())
+		}
+		return nil, nil, 0, newAPIError(http.StatusBadRequest, codeInvalidURL, err.Error())
+	}
+	opts.apply(req)
+	if opts.RespectRobots {
+		if err := e.checkRobots(ctx, req.URL, opts.UserAgent); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	start := time.Now()
+	defer observePhase(ctx, "fetch", start)
+
+	// la place est gardée jusqu'à la fin de la lecture du corps
+	release, err := e.hosts.acquire(ctx, strings.ToLower(req.URL.Hostname()))
+	if err != nil {
+		e := fetchError(err)
+		recordUpstreamError(e)
+		return nil, nil, 0, e
+	}
+	defer release()
+
+	resp, attempts, err := e.doWithRetry(req)
+	if err != nil {
+		e := fetchError(err)
+		e.Attempts = attempts
+		recordUpstreamError(e)
+		return nil, nil, attempts, e
+	}
+	defer resp.Body.Close()
+
+	// réponse à une requête conditionnelle : pas de corps
+	if resp.StatusCode == http.StatusNotModified && opts.conditional != (conditionalHeaders{}) {
+		return resp, nil, attempts, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := upstreamStatusError(resp.StatusCode)
+		e.Attempts = attempts
+		recordUpstreamError(e)
+		return nil, nil, attempts, e
+	}
+
+	body, err := e.readBody(resp)
+	if err != nil {
+		recordUpstreamError(toAPIError(err))
+		return nil, nil, attempts, err
+	}
+	return resp, body, attempts, nil
+}
+
+// extractDocument construit l'article à partir d'un document déjà parsé
+func (e *engine) extractDocument(doc *goquery.Document, pageURL string, opts extractOptions) *Article {
+	base := documentBase(doc, pageURL)
+	baseURL := pageURL
+	if base != nil {
+		baseURL = base.String()
+	}
+	meta := e.extractMetadata(doc, baseURL)
+	rule, hasRule := e.siteRuleFor(pageURL)
+	if hasRule {
+		e.applyRuleMetadata(rule, doc, &meta)
+	}
+	signals := collectAccessSignals(doc, meta)
+	var structured *StructuredData
+	if opts.IncludeStructured {
+		structured = extractStructuredData(doc, base)
+	}
+
+	var blocks []textBlock
+	var main *goquery.Selection
+	if !opts.Raw {
+		if hasRule {
+			rule.removeFrom(doc)
+			main = e.ruleMainContent(rule, doc, opts.KeepSelectors)
+		}
+		if main == nil {
+			main = e.extractMainContent(doc, opts.KeepSelectors)
+		}
+	}
+	if main != nil {
+		blocks = contentBlocks(main)
+	} else {
+		blocks = paragraphBlocks(doc)
+		main = doc.Find("body")
+	}
+	cleanText, kept := cleanForLLM(blocks)
+
+	format := opts.Format
+	if format == "" {
+		format = formatText
+	}
+	content := cleanText
+	if format != formatText {
+		cleaned := e.sanitizer.cleanHTML(main, base, opts.IncludeDataImages)
+		if format == formatHTML {
+			content = e.sanitizer.renderHTML(cleaned, opts.IncludeDataImages)
+		} else {
+			content = renderMarkdown(cleaned)
+		}
+	}
+
+	// image principale : métadonnées, sinon première image du corps
+	images := collectImages(main, base, opts.IncludeDataImages)
+	if meta.Image == "" && len(images) > 0 {
+		meta.Image = images[0].URL
+	}
+	var links []Link
+	if opts.IncludeLinks {
+		links = collectLinks(main, base, e.cfg.MaxLinks)
+	}
+	var tables []Table
+	if opts.IncludeTables {
+		tables = collectTables(main, opts.FlattenTables, e.cfg.MaxTables, e.cfg.MaxTableCells)
+	}
+
+	article := &Article{
+		Title:          meta.Title,
+		Author:         meta.Author,
+		Authors:        meta.Authors,
+		PublishedAt:    meta.PublishedAt,
+		ModifiedAt:     meta.ModifiedAt,
+		DateSource:     meta.DateSource,
+		DateConfidence: meta.DateConfidence,
+		Image:          meta.Image,
+		Description:    meta.Description,
+		CanonicalURL:   meta.CanonicalURL,
+		SiteName:       meta.SiteName,
+		FeedURL:        meta.FeedURL,
+		CleanText:      cleanText,
+		Images:         images,
+		Links:          links,
+		Tables:         tables,
+		Format:         format,
+		Content:        content,
+
+		StructuredData: structured,
+		RawTitle:       meta.RawTitle,
+		Favicon:        findFavicon(doc, base),
+		SourceVariant:  variantCanonical,
+		ampURL:         meta.AMPURL,
+	}
+	if opts.IncludeOffsets {
+		article.Blocks = publicBlocks(kept)
+		article.Version = pipelineVersion
+	}
+	article.computeStats(e.cfg)
+	article.Access, article.BlockReason = signals.classify(article.CleanText, article.WordCount)
+	return article
+}
+
+// computeStats recalcule les statistiques dérivées de CleanText, découpage
+// en paragraphes et empreinte du texte normalisé (espaces) compris ; la
+// durée de lecture suit READING_WPM / READING_CPM
+func (a *Article) computeStats(cfg *Config) {
+	a.Paragraphs = splitParagraphs(a.CleanText)
+	words, cjk := countWords(a.CleanText)
+	a.TokensEstimate = len(strings.Fields(a.CleanText)) // estimation simple
+	a.WordCount = words
+	a.ReadingTime = readingTime(words, cjk, cfg.ReadingWPM, cfg.ReadingCPM)
+	a.Language = detectLanguage(a.CleanText, words)
+	a.ContentHash = textHash(a.CleanText)
+	a.SimHash = simhash(a.CleanText, words)
+	if a.Blocks != nil {
+		a.Blocks = locateBlocks(a.CleanText, a.Blocks)
+	}
+}
+
+// textHash : empreinte du texte aux espaces près (content_hash)
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// paragraphBlocks retourne tous les <p> de la page, un bloc chacun
+func paragraphBlocks(doc *goquery.Document) []textBlock {
+	var blocks []textBlock
+	doc.Find("p").Each(func(i int, s *goquery.Selection) {
+		if text := normalizeText(strings.ReplaceAll(s.Text(), "\n", " ")); text != "" {
+			blocks = append(blocks, textBlock{kind: blockParagraph, text: text})
+		}
+	})
+	return blocks
+}
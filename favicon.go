@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// taille supposée d'une apple-touch-icon sans attribut sizes
+const defaultTouchIconSize = 180
+
+type faviconEntry struct {
+	url      string // "" : icône vérifiée absente
+	verified bool
+	expires  time.Time
+}
+
+// faviconCache garde l'icône de chaque hôte, pour ne pas refaire la
+// résolution (et la vérification) sur chaque page d'un même site
+type faviconCache struct {
+	mu      sync.Mutex
+	entries map[string]faviconEntry
+}
+
+func newFaviconCache() *faviconCache {
+	return &faviconCache{entries: make(map[string]faviconEntry)}
+}
+
+// findFavicon choisit parmi les <link rel="icon">, "shortcut icon" et
+// "apple-touch-icon" celle de plus grande taille déclarée ("any", une
+// icône vectorielle, l'emporte), sinon /favicon.ico à la racine du site
+func findFavicon(doc *goquery.Document, base *url.URL) string {
+	if base == nil {
+		return ""
+	}
+	best, bestSize := "", -1
+	doc.Find("link[rel][href]").Each(func(i int, s *goquery.Selection) {
+		rels := strings.Fields(strings.ToLower(s.AttrOr("rel", "")))
+		touch, icon := false, false
+		for _, rel := range rels {
+			switch rel {
+			case "icon":
+				icon = true
+			case "apple-touch-icon", "apple-touch-icon-precomposed":
+				touch = true
+			}
+		}
+		if !icon && !touch {
+			return
+		}
+		href := resolveAgainst(base, s.AttrOr("href", ""))
+		if u, err := url.Parse(href); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		size := iconSize(s.AttrOr("sizes", ""))
+		if size == 0 && touch {
+			size = defaultTouchIconSize
+		}
+		if size > bestSize {
+			best, bestSize = href, size
+		}
+	})
+	if best != "" {
+		return best
+	}
+	return base.Scheme + "://" + base.Host + "/favicon.ico"
+}
+
+// iconSize lit la plus grande dimension de sizes ("16x16 32x32", "any")
+func iconSize(sizes string) int {
+	largest := 0
+	for _, size := range strings.Fields(strings.ToLower(sizes)) {
+		if size == "any" {
+			return 1 << 16
+		}
+		w, h, ok := strings.Cut(size, "x")
+		if !ok {
+			continue
+		}
+		width, err1 := strconv.Atoi(w)
+		height, err2 := strconv.Atoi(h)
+		if err1 == nil && err2 == nil {
+			largest = max(largest, width, height)
+		}
+	}
+	return largest
+}
+
+// siteFavicon retourne l'icône en cache pour l'hôte de pageURL, ou retient
+// candidate pour FAVICON_CACHE_TTL. Avec verify, une requête HEAD écarte une
+// icône en 404 / 410.
+func (e *engine) siteFavicon(ctx context.Context, pageURL, candidate string, verify bool) string {
+	u, err := url.Parse(pageURL)
+	if err != nil || candidate == "" {
+		return candidate
+	}
+	host := u.Scheme + "://" + strings.ToLower(u.Host)
+
+	cache := e.favicons
+	cache.mu.Lock()
+	entry, ok := cache.entries[host]
+	cache.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) && (entry.verified || !verify) {
+		return entry.url
+	}
+
+	entry = faviconEntry{url: candidate, expires: time.Now().Add(e.cfg.FaviconCacheTTL)}
+	if verify {
+		entry.verified = true
+		if !e.faviconExists(ctx, candidate) {
+			entry.url = ""
+		}
+	}
+	cache.mu.Lock()
+	cache.entries[host] = entry
+	cache.mu.Unlock()
+	return entry.url
+}
+
+// faviconExists : HEAD soumis aux mêmes règles (SSRF, délai, redirections)
+// que le fetch ; seuls 404 et 410 comptent comme absence
+func (e *engine) faviconExists(ctx context.Context, iconURL string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, iconURL, nil)
+	if err != nil {
+		return false
+	}
+	if err := e.validateURL(ctx, req.URL); err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", e.cfg.DefaultUserAgent)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true // échec réseau : l'icône déclarée est conservée
+	}
+	resp.Body.Close()
+	return resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusGone
+}
+
+// cleanup retire les entrées expirées
+func (c *faviconCache) cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for host, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, host)
+		}
+	}
+}
+
+// startCleanup nettoie périodiquement le cache des icônes
+func (c *faviconCache) startCleanup(every time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			c.cleanup()
+		}
+	}()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFindFavicon(t *testing.T) {
+	base, _ := url.Parse("https://harbour.example/news/tides")
+	tests := []struct {
+		name, links, want string
+	}{
+		{"largest declared size", `<link rel="icon" href="/a.png" sizes="16x16"><link rel="icon" href="/b.png" sizes="64x64 32x32"><link rel="icon" href="/c.png" sizes="48x48">`, "https://harbour.example/b.png"},
+		{"svg any wins", `<link rel="icon" href="/big.png" sizes="512x512"><link rel="icon" href="/logo.svg" sizes="any">`, "https://harbour.example/logo.svg"},
+		{"touch icon without sizes", `<link rel="shortcut icon" href="/fav.png" sizes="32x32"><link rel="apple-touch-icon" href="/touch.png">`, "https://harbour.example/touch.png"},
+		{"icon larger than the touch default", `<link rel="apple-touch-icon-precomposed" href="/touch.png"><link rel="icon" href="/icon.png" sizes="192x192">`, "https://harbour.example/icon.png"},
+		{"first undeclared size kept", `<link rel="icon" href="one.ico"><link rel="icon" href="two.ico">`, "https://harbour.example/news/one.ico"},
+		{"non-http hrefs skipped", `<link rel="icon" href="data:image/png;base64,AAAA" sizes="64x64"><link rel="icon" href="javascript:x()"><link rel="icon" href="//cdn.example/i.png">`, "https://cdn.example/i.png"},
+		{"fallback", `<link rel="stylesheet" href="/s.css">`, "https://harbour.example/favicon.ico"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := mustParse(t, "<html><head>"+tt.links+"</head><body></body></html>")
+			if got := findFavicon(doc, base); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIconSize(t *testing.T) {
+	tests := map[string]int{"": 0, "16x16": 16, "16x16 48x32": 48, "ANY": 1 << 16, "big": 0, "12x": 0}
+	for sizes, want := range tests {
+		if got := iconSize(sizes); got != want {
+			t.Errorf("iconSize(%q) = %d, want %d", sizes, got, want)
+		}
+	}
+}
+
+// faviconOrigin sert testdata/favicon et compte les HEAD ; seules les
+// icônes de present existent
+type faviconOrigin struct {
+	heads   atomic.Int64
+	present map[string]bool
+	files   http.Handler
+}
+
+func newFaviconOrigin(t *testing.T, present ...string) (*faviconOrigin, *httptest.Server) {
+	t.Helper()
+	o := &faviconOrigin{present: make(map[string]bool), files: http.FileServer(http.Dir("testdata/favicon"))}
+	for _, p := range present {
+		o.present[p] = true
+	}
+	ts := httptest.NewServer(o)
+	t.Cleanup(ts.Close)
+	return o, ts
+}
+
+func (o *faviconOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		o.heads.Add(1)
+		if !o.present[r.URL.Path] {
+			http.NotFound(w, r)
+		}
+		return
+	}
+	o.files.ServeHTTP(w, r)
+}
+
+// faviconOf extrait pageURL (avec la query extra) et retourne le champ favicon
+func faviconOf(t *testing.T, ts *httptest.Server, pageURL, extra string) (string, bool) {
+	t.Helper()
+	var resp map[string]any
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(pageURL)+extra, &resp); status != http.StatusOK {
+		t.Fatalf("GET %s: %d %v", pageURL, status, resp)
+	}
+	icon, ok := resp["favicon"].(string)
+	return icon, ok
+}
+
+func TestFaviconInResponse(t *testing.T) {
+	origin, site := newFaviconOrigin(t)
+	_, ts := newTestServer(t, nil)
+
+	// icône déclarée la plus grande, en URL absolue, sans requête vers l'image
+	if icon, _ := faviconOf(t, ts, site.URL+"/icons.html", ""); icon != site.URL+"/static/icon-192.png" {
+		t.Errorf("icons.html: favicon %q", icon)
+	}
+	if heads := origin.heads.Load(); heads != 0 {
+		t.Errorf("%d HEAD requests without verify_favicon", heads)
+	}
+
+	// page sans icône déclarée, sur un autre hôte : /favicon.ico à la racine
+	_, other := newFaviconOrigin(t)
+	if icon, _ := faviconOf(t, ts, other.URL+"/noicon.html", ""); icon != other.URL+"/favicon.ico" {
+		t.Errorf("noicon.html: favicon %q, want the /favicon.ico fallback", icon)
+	}
+}
+
+func TestVerifyFavicon(t *testing.T) {
+	origin, site := newFaviconOrigin(t, "/static/icon-192.png")
+	_, ts := newTestServer(t, nil)
+
+	if icon, _ := faviconOf(t, ts, site.URL+"/icons.html", "&verify_favicon=true"); icon != site.URL+"/static/icon-192.png" {
+		t.Errorf("existing icon: favicon %q", icon)
+	}
+	// deuxième page du site : pas de nouvelle vérification
+	faviconOf(t, ts, site.URL+"/noicon.html", "&verify_favicon=true")
+	if heads := origin.heads.Load(); heads != 1 {
+		t.Errorf("%d HEAD requests for one host, want 1", heads)
+	}
+
+	// /favicon.ico absent : le champ disparaît
+	missing, site2 := newFaviconOrigin(t)
+	if icon, ok := faviconOf(t, ts, site2.URL+"/noicon.html", "&verify_favicon=true"); ok {
+		t.Errorf("404 icon kept: %q", icon)
+	}
+	if missing.heads.Load() != 1 {
+		t.Errorf("%d HEAD requests, want 1", missing.heads.Load())
+	}
+	// sans verify, l'entrée vérifiée absente reste valable
+	if icon, ok := faviconOf(t, ts, site2.URL+"/noicon.html", ""); ok {
+		t.Errorf("verified-missing icon returned without verify: %q", icon)
+	}
+}
+
+// la vérification suit les règles SSRF du fetch
+func TestVerifyFaviconBlockedTarget(t *testing.T) {
+	e := newTestEngine(t, map[string]string{"ALLOW_PRIVATE": "false"})
+	if e.faviconExists(t.Context(), "http://127.0.0.1:1/favicon.ico") {
+		t.Error("private icon URL reported as existing")
+	}
+	if !strings.HasPrefix(e.siteFavicon(t.Context(), "https://harbour.example/a", "https://harbour.example/favicon.ico", false), "https://") {
+		t.Error("unverified candidate not returned")
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+// types de contenu acceptés pour les flux, en plus des types HTML
+var feedContentTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+	"application/rdf+xml":  true,
+	"application/xml":      true,
+	"text/xml":             true,
+}
+
+// Feed est la réponse renvoyée à la place de l'article pour un flux RSS/Atom
+type Feed struct {
+	Type     string     `json:"type"` // toujours "feed"
+	Title    string     `json:"title"`
+	SiteURL  string     `json:"site_url"`
+	FinalURL string     `json:"final_url"`
+	Cached   bool       `json:"cached"`
+	Items    []FeedItem `json:"items"`
+}
+
+// FeedItem est une entrée du flux ; Article et Error ne sont remplis qu'avec expand=true
+type FeedItem struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	PublishedAt string    `json:"published_at"`
+	Summary     string    `json:"summary"`
+	Article     *Article  `json:"article,omitempty"`
+	Error       *apiError `json:"error,omitempty"`
+}
+
+// RSS 2.0 (items dans <channel>) et RSS 1.0 / RDF (items à la racine)
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Links []string  `xml:"link"` // <link> RSS et <atom:link href> vide
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Links       []string `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	DCDate      string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+type atomDocument struct {
+	Title   atomText    `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     atomText   `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   atomText   `xml:"summary"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// atomText : texte brut, HTML échappé ou XHTML en ligne selon type
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) plain() string {
+	if t.Type == "xhtml" {
+		return htmlText(t.Inner)
+	}
+	return htmlText(t.Text)
+}
+
+// alternate retourne le lien rel="alternate" (ou sans rel) d'une liste de liens Atom
+func alternate(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+// feedRoot retourne rss, feed ou rdf selon l'élément racine, "" sinon
+func feedRoot(body []byte) string {
+	dec := newFeedDecoder(body)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			switch root := strings.ToLower(start.Name.Local); root {
+			case "rss", "feed", "rdf":
+				return root
+			}
+			return ""
+		}
+	}
+}
+
+// isFeed détecte un flux par son Content-Type ou par son élément racine
+func isFeed(body []byte, contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/rss+xml" || mediaType == "application/atom+xml" {
+		return true
+	}
+	return feedRoot(body) != ""
+}
+
+func newFeedDecoder(body []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.CharsetReader = charset.NewReaderLabel
+	return dec
+}
+
+// parseFeed lit un flux RSS 2.0, RSS 1.0 ou Atom ; les liens relatifs
+// sont résolus par rapport à feedURL
+func parseFeed(body []byte, feedURL string) (*Feed, error) {
+	feed := &Feed{Type: "feed", FinalURL: feedURL, Items: []FeedItem{}}
+	parseFailed := newAPIError(http.StatusUnprocessableEntity, codeParseFailed, "failed to parse feed")
+
+	switch feedRoot(body) {
+	case "rss", "rdf":
+		var doc rssDocument
+		if err := newFeedDecoder(body).Decode(&doc); err != nil {
+			return nil, parseFailed
+		}
+		feed.Title = strings.TrimSpace(doc.Channel.Title)
+		feed.SiteURL = resolveURL(feedURL, firstNonEmpty(doc.Channel.Links...))
+		for _, item := range append(doc.Channel.Items, doc.Items...) {
+			link := firstNonEmpty(item.Links...)
+			if link == "" && item.GUID.IsPermaLink != "false" && strings.HasPrefix(item.GUID.Value, "http") {
+				link = item.GUID.Value
+			}
+			feed.Items = append(feed.Items, FeedItem{
+				Title:       htmlText(item.Title),
+				URL:         resolveURL(feedURL, strings.TrimSpace(link)),
+				PublishedAt: normalizeDate(firstNonEmpty(item.PubDate, item.DCDate)),
+				Summary:     htmlText(item.Description),
+			})
+		}
+	case "feed":
+		var doc atomDocument
+		if err := newFeedDecoder(body).Decode(&doc); err != nil {
+			return nil, parseFailed
+		}
+		feed.Title = doc.Title.plain()
+		feed.SiteURL = resolveURL(feedURL, alternate(doc.Links))
+		for _, entry := range doc.Entries {
+			summary := entry.Summary.plain()
+			if summary == "" {
+				summary = entry.Content.plain()
+			}
+			feed.Items = append(feed.Items, FeedItem{
+				Title:       entry.Title.plain(),
+				URL:         resolveURL(feedURL, alternate(entry.Links)),
+				PublishedAt: normalizeDate(firstNonEmpty(entry.Published, entry.Updated)),
+				Summary:     summary,
+			})
+		}
+	default:
+		return nil, parseFailed
+	}
+	return feed, nil
+}
+
+// htmlText retire le balisage d'un fragment HTML et normalise les espaces
+func htmlText(s string) string {
+	if !strings.Contains(s, "<") && !strings.Contains(s, "&") {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+// expandFeed extrait le texte des FEED_EXPAND_MAX premières entrées, avec la
+// concurrence des lots ; l'échec d'une entrée n'affecte pas les autres
+func (e *engine) expandFeed(ctx context.Context, feed *Feed, opts extractOptions) {
+	opts.ExpandFeed = false // pas d'expansion récursive d'un flux lié
+
+	var indexes []int
+	var urls []string
+	for i, item := range feed.Items {
+		if len(urls) == e.cfg.FeedExpandMax {
+			break
+		}
+		if item.URL != "" {
+			indexes = append(indexes, i)
+			urls = append(urls, item.URL)
+		}
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	for j, res := range e.runBatch(ctx, urls, opts, false, e.cfg.BatchConcurrency) {
+		item := &feed.Items[indexes[j]]
+		item.Article = res.Result
+		item.Error = res.Error
+	}
+}
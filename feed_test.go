@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseFeedRSS(t *testing.T) {
+	feed, err := parseFeed(readFixture(t, "feeds/rss.xml"), "https://harbour.example/feeds/rss.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Type != "feed" || feed.Title != "Harbour Gazette" || feed.SiteURL != "https://harbour.example/" {
+		t.Errorf("feed: type=%q title=%q site=%q", feed.Type, feed.Title, feed.SiteURL)
+	}
+	want := []FeedItem{
+		{Title: "Ferry & dock upgrades approved", URL: "https://harbour.example/feeds/story.html", PublishedAt: normalizeDate("Mon, 04 Mar 2024 09:30:00 GMT"), Summary: "The council approved new docks."},
+		{Title: "Fish market reopens", URL: "https://harbour.example/fish-market", PublishedAt: normalizeDate("2024-03-02T08:00:00Z"), Summary: "Stalls are back on the quay."},
+		{Title: "Removed story", URL: "https://harbour.example/feeds/missing.html"},
+	}
+	checkFeedItems(t, feed.Items, want)
+}
+
+func TestParseFeedAtom(t *testing.T) {
+	feed, err := parseFeed(readFixture(t, "feeds/atom.xml"), "https://notes.example/feeds/atom.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Title != "Field Notes" || feed.SiteURL != "https://notes.example/notes/" {
+		t.Errorf("feed: title=%q site=%q", feed.Title, feed.SiteURL)
+	}
+	want := []FeedItem{
+		// published prime sur updated
+		{Title: "Spring migration", URL: "https://notes.example/feeds/story.html", PublishedAt: normalizeDate("2024-03-05T10:00:00+01:00"), Summary: "Geese are back early this year."},
+		// lien sans rel, contenu XHTML faute de résumé
+		{Title: "Winter count", URL: "https://notes.example/winter-count", PublishedAt: normalizeDate("2024-01-15T18:00:00Z"), Summary: "Forty swans counted."},
+	}
+	checkFeedItems(t, feed.Items, want)
+}
+
+func checkFeedItems(t *testing.T, got, want []FeedItem) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d items, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if want[i].PublishedAt == "" && i < 2 {
+			t.Fatalf("item %d: reference date did not normalize", i)
+		}
+		if got[i] != want[i] {
+			t.Errorf("item %d:\n got %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestIsFeed(t *testing.T) {
+	tests := []struct {
+		name, body, contentType string
+		want                    bool
+	}{
+		{"rss root", `<?xml version="1.0"?><rss version="2.0"><channel/></rss>`, "text/xml", true},
+		{"atom root", `<feed xmlns="http://www.w3.org/2005/Atom"></feed>`, "application/octet-stream", true},
+		{"rdf root", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"></rdf:RDF>`, "application/xml", true},
+		{"declared rss", `not even xml`, "application/rss+xml; charset=utf-8", true},
+		{"declared atom", ``, "application/atom+xml", true},
+		{"html page", `<!DOCTYPE html><html><body><p>rss</p></body></html>`, "text/html", false},
+		{"other xml", `<?xml version="1.0"?><sitemap/>`, "application/xml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFeed([]byte(tt.body), tt.contentType); got != tt.want {
+				t.Errorf("isFeed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedRejectsGarbage(t *testing.T) {
+	if _, err := parseFeed([]byte("<html></html>"), "https://example.com/"); err == nil || toAPIError(err).Code != codeParseFailed {
+		t.Errorf("got %v, want %s", err, codeParseFailed)
+	}
+}
+
+// réponse /extract d'un flux : forme feed au lieu de l'article
+type feedResponse struct {
+	Feed
+	WordCount *int `json:"word_count"`
+}
+
+func TestExtractFeedEndToEnd(t *testing.T) {
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+
+	for _, name := range []string{"rss.xml", "atom.xml"} {
+		t.Run(name, func(t *testing.T) {
+			var resp feedResponse
+			if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(fixtures.URL+"/feeds/"+name), &resp); status != http.StatusOK {
+				t.Fatalf("status %d", status)
+			}
+			if resp.Type != "feed" || resp.WordCount != nil || len(resp.Items) == 0 {
+				t.Fatalf("not a feed response: %+v", resp)
+			}
+			for _, item := range resp.Items {
+				if item.Article != nil || item.Error != nil {
+					t.Errorf("%s expanded without expand=true", item.URL)
+				}
+			}
+		})
+	}
+}
+
+// Content-Type de flux sans déclaration XML ni racine reconnue par l'en-tête
+func TestExtractFeedByContentType(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(readFixture(t, "feeds/rss.xml"))
+	}))
+	defer origin.Close()
+	_, ts := newTestServer(t, nil)
+
+	var resp feedResponse
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(origin.URL+"/feed"), &resp); status != http.StatusOK || resp.Type != "feed" || len(resp.Items) != 3 {
+		t.Fatalf("status %d, %+v", status, resp)
+	}
+}
+
+func TestExtractFeedExpand(t *testing.T) {
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+
+	var resp feedResponse
+	if status := apiGet(t, ts, "/extract?expand=true&url="+url.QueryEscape(fixtures.URL+"/feeds/rss.xml"), &resp); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if len(resp.Items) != 3 {
+		t.Fatalf("%d items", len(resp.Items))
+	}
+	story, external, missing := resp.Items[0], resp.Items[1], resp.Items[2]
+	if story.Error != nil || story.Article == nil || !strings.Contains(story.Article.CleanText, "ferry docks") {
+		t.Errorf("story not expanded: %+v", story)
+	}
+	// les échecs restent propres à leur entrée (.example ne se résout jamais)
+	if external.Article != nil || external.Error == nil {
+		t.Errorf("unreachable item: %+v", external)
+	}
+	if missing.Article != nil || missing.Error == nil || missing.Error.Code != codeUpstreamNotFound {
+		t.Errorf("missing item: %+v", missing)
+	}
+}
+
+func TestExtractFeedExpandMax(t *testing.T) {
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, map[string]string{"FEED_EXPAND_MAX": "1"})
+
+	var resp feedResponse
+	apiGet(t, ts, "/extract?expand=true&url="+url.QueryEscape(fixtures.URL+"/feeds/rss.xml"), &resp)
+	expanded := 0
+	for _, item := range resp.Items {
+		if item.Article != nil || item.Error != nil {
+			expanded++
+		}
+	}
+	if expanded != 1 || resp.Items[0].Article == nil {
+		t.Errorf("%d items expanded, want only the first", expanded)
+	}
+}
+
+func TestArticleFeedURL(t *testing.T) {
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(fixtures.URL+"/feeds/story.html"), &article); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if article.FeedURL != fixtures.URL+"/feeds/rss.xml" {
+		t.Errorf("feed_url %q", article.FeedURL)
+	}
+
+	// sans lien alternate, le champ reste vide
+	e := newTestEngine(t, nil)
+	if got := extractFixture(t, e, "news.html", extractOptions{}).FeedURL; got != "" {
+		t.Errorf("feed_url %q on a page without a feed link", got)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// timeout par défaut des requêtes sortantes
+const defaultFetchTimeout = 10 * time.Second
+
+// types acceptés par défaut, complétés par ALLOW_CONTENT_TYPES
+var defaultContentTypes = []string{"text/html", "application/xhtml+xml"}
+
+var errRedirectLoop = errors.New("redirect loop detected")
+
+// tooManyRedirectsError : plus de max redirections (MAX_REDIRECTS)
+type tooManyRedirectsError struct {
+	max int
+}
+
+func (e *tooManyRedirectsError) Error() string {
+	return fmt.Sprintf("too many redirects (max %d)", e.max)
+}
+
+// newHTTPClient crée le client partagé par tous les handlers de l'engine
+// (pool de connexions), avec FETCH_TIMEOUT
+func (e *engine) newHTTPClient() *http.Client {
+	timeout := e.cfg.FetchTimeout
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   e.dialControl,
+	}
+	trusted := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 e.proxyFor,
+		DialContext:           e.proxyDialer(dialer, trusted),
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		MaxConnsPerHost:       20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		DisableCompression:    true, // Accept-Encoding et décompression gérés par readBody
+	}
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     &budgetTransport{base: transport, budget: e.budget},
+		CheckRedirect: e.checkRedirect,
+	}
+}
+
+// isTimeout indique si l'erreur vient d'un dépassement de délai
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// checkRedirect limite le nombre de sauts, détecte les boucles
+// et valide chaque destination comme l'URL d'origine
+func (e *engine) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > e.cfg.MaxRedirects {
+		return &tooManyRedirectsError{max: e.cfg.MaxRedirects}
+	}
+	target := req.URL.String()
+	for _, prev := range via {
+		if prev.URL.String() == target {
+			return errRedirectLoop
+		}
+	}
+	return e.validateURL(req.Context(), req.URL)
+}
+
+// redirectChain retourne les URLs ayant répondu par une redirection,
+// dans l'ordre, en commençant par l'URL demandée
+func redirectChain(resp *http.Response) []string {
+	chain := []string{}
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		chain = append([]string{r.Request.URL.String()}, chain...)
+	}
+	return chain
+}
+
+// readBody vérifie le type de contenu, décompresse puis lit au plus
+// MAX_BODY_BYTES octets décompressés. Un corps trop gros est refusé plutôt
+// que tronqué au milieu d'une balise.
+func (e *engine) readBody(resp *http.Response) ([]byte, error) {
+	limit := e.cfg.MaxBodyBytes
+	header := resp.Header.Get("Content-Type")
+	if header != "" {
+		// refus immédiat, sans télécharger le corps
+		mediaType, _, _ := mime.ParseMediaType(header)
+		if !e.contentTypeAllowed(mediaType) && !feedContentTypes[mediaType] {
+			return nil, unsupportedContentType(mediaType)
+		}
+	}
+	if resp.ContentLength > limit {
+		return nil, bodyTooLarge(limit)
+	}
+
+	decoded, err := decodedBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(io.LimitReader(decoded, limit+1))
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	if int64(len(body)) > limit {
+		return nil, bodyTooLarge(limit)
+	}
+
+	// sans en-tête, on se fie aux 512 premiers octets ;
+	// avec, on refuse un contenu manifestement binaire
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	if header == "" && !e.contentTypeAllowed(sniffed) && !feedContentTypes[sniffed] {
+		return nil, unsupportedContentType(sniffed)
+	}
+	if header != "" && !strings.HasPrefix(sniffed, "text/") && sniffed != "application/xml" {
+		return nil, unsupportedContentType(sniffed)
+	}
+	return body, nil
+}
+
+func (e *engine) contentTypeAllowed(mediaType string) bool {
+	for _, allowed := range e.contentTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func unsupportedContentType(mediaType string) error {
+	if mediaType == "" {
+		mediaType = "unknown"
+	}
+	return newAPIError(http.StatusUnprocessableEntity, codeUnsupportedContentType, "unsupported content type "+mediaType)
+}
+
+func bodyTooLarge(limit int64) error {
+	return newAPIError(http.StatusUnprocessableEntity, codeBodyTooLarge, fmt.Sprintf("response body exceeds %d bytes", limit))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// hangingOrigin ne répond jamais ; gone est fermé quand le client abandonne
+func hangingOrigin(t *testing.T) (*httptest.Server, chan struct{}) {
+	t.Helper()
+	gone := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(gone)
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, gone
+}
+
+func TestFetchTimeoutReturns504(t *testing.T) {
+	origin, gone := hangingOrigin(t)
+	_, ts := newTestServer(t, map[string]string{"FETCH_TIMEOUT": "100ms", "FETCH_MAX_ATTEMPTS": "1"})
+	before := runtime.NumGoroutine()
+
+	start := time.Now()
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+origin.URL, "", "")
+	if status != http.StatusGatewayTimeout || errorCode(t, body) != codeUpstreamTimeout {
+		t.Fatalf("got %d %s, want 504 %s", status, body, codeUpstreamTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("handler took %s", elapsed)
+	}
+	select {
+	case <-gone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request still open after the timeout")
+	}
+
+	// les goroutines du fetch se terminent (connexions inactives à part)
+	ts.Client().CloseIdleConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before+2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before+2 {
+		t.Errorf("%d goroutines after the request, %d before", n, before)
+	}
+}
+
+// un client qui se déconnecte annule le fetch en cours
+func TestClientDisconnectCancelsFetch(t *testing.T) {
+	origin, gone := hangingOrigin(t)
+	_, ts := newTestServer(t, map[string]string{"FETCH_TIMEOUT": "10s"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/extract?url="+origin.URL, nil)
+	req.Header.Set("X-API-Key", testKey)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := ts.Client().Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+	select {
+	case <-gone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request not cancelled with the client")
+	}
+}
+
+func TestSharedClientIsConfigured(t *testing.T) {
+	e := newTestEngine(t, map[string]string{"FETCH_TIMEOUT": "3s"})
+	if e.client.Timeout != 3*time.Second {
+		t.Errorf("client timeout %s", e.client.Timeout)
+	}
+	transport, ok := e.client.Transport.(*budgetTransport)
+	if !ok {
+		t.Skipf("transport %T", e.client.Transport)
+	}
+	if base, ok := transport.base.(*http.Transport); !ok || base.MaxIdleConnsPerHost == 0 {
+		t.Errorf("no pooling limits on %T", transport.base)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// noms courts acceptés dans fields
+var fieldAliases = map[string]string{
+	"text": "clean_text",
+}
+
+// fieldTree : champ -> sous-champs retenus ; nil désigne le champ entier
+type fieldTree map[string]fieldTree
+
+// fieldSelection restreint la réponse aux champs demandés (fields=title,images.url)
+// ou en retire (fields=-images,-links). Les deux formes peuvent se combiner.
+type fieldSelection struct {
+	include fieldTree
+	exclude fieldTree
+	paths   []fieldPath // chemins demandés, revérifiés selon la forme de la réponse
+}
+
+// fieldPath : chemin tel qu'écrit par le client et sa forme résolue (alias compris)
+type fieldPath struct {
+	name string
+	path []string
+}
+
+// formes possibles d'une réponse : un article, ou un flux (type "feed")
+var responseKinds = []struct {
+	name string
+	typ  reflect.Type
+}{
+	{"article", reflect.TypeOf(Article{})},
+	{"feed", reflect.TypeOf(Feed{})},
+}
+
+// parseFields lit le paramètre fields ; nil si absent. Les chemins sont
+// vérifiés d'après les balises json d'Article et de Feed : tout nouveau champ
+// est sélectionnable sans autre modification. project revérifie ensuite
+// chaque chemin selon la forme effective de la réponse.
+func parseFields(raw string) (*fieldSelection, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	sel := &fieldSelection{}
+	var unknown []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		tree := &sel.include
+		if strings.HasPrefix(field, "-") {
+			field, tree = strings.TrimSpace(field[1:]), &sel.exclude
+		}
+		if field == "" {
+			continue
+		}
+		path := strings.Split(field, ".")
+		if alias, ok := fieldAliases[path[0]]; ok {
+			path[0] = alias
+		}
+		known := false
+		for _, kind := range responseKinds {
+			known = known || fieldPathExists(kind.typ, path)
+		}
+		if !known {
+			unknown = append(unknown, field)
+			continue
+		}
+		if *tree == nil {
+			*tree = fieldTree{}
+		}
+		tree.add(path)
+		sel.paths = append(sel.paths, fieldPath{name: field, path: path})
+	}
+	if len(unknown) > 0 {
+		return nil, newAPIError(http.StatusBadRequest, codeInvalidRequest, "unknown fields: "+strings.Join(unknown, ", "))
+	}
+	if sel.include == nil && sel.exclude == nil {
+		return nil, nil
+	}
+	return sel, nil
+}
+
+// check refuse les chemins absents de la forme de réponse kind
+// (fields=items sur un article, fields=word_count sur un flux)
+func (s *fieldSelection) check(kind string, t reflect.Type) error {
+	var unknown []string
+	for _, p := range s.paths {
+		if !fieldPathExists(t, p.path) {
+			unknown = append(unknown, p.name)
+		}
+	}
+	if len(unknown) > 0 {
+		return newAPIError(http.StatusBadRequest, codeInvalidRequest, "unknown fields for a response of type "+kind+": "+strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func (t fieldTree) add(path []string) {
+	sub, seen := t[path[0]]
+	if seen && sub == nil {
+		return // champ déjà retenu en entier
+	}
+	if len(path) == 1 {
+		t[path[0]] = nil
+		return
+	}
+	if sub == nil {
+		sub = fieldTree{}
+		t[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+// fieldPathExists suit le chemin dans les champs JSON du type ; au-delà
+// d'une map ou d'une valeur quelconque (JSON-LD), tout chemin est accepté
+func fieldPathExists(t reflect.Type, path []string) bool {
+	for _, name := range path {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map, reflect.Interface:
+			return true
+		case reflect.Struct:
+		default:
+			return false
+		}
+		field, ok := jsonField(t, name)
+		if !ok {
+			return false
+		}
+		t = field.Type
+	}
+	return true
+}
+
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// project applique la sélection à la représentation JSON de l'article, en
+// conservant l'ordre des champs ; un flux est projeté selon les champs de Feed
+func (s *fieldSelection) project(a *Article) (any, error) {
+	if s == nil {
+		return a, nil
+	}
+	kind := responseKinds[0]
+	if a.feed != nil {
+		kind = responseKinds[1]
+	}
+	if err := s.check(kind.name, kind.typ); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	if s.include != nil {
+		raw = projectJSON(raw, s.include, true)
+	}
+	if s.exclude != nil {
+		raw = projectJSON(raw, s.exclude, false)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// projectJSON garde (keep) ou retire les champs de tree dans un objet,
+// élément par élément pour un tableau ; les autres valeurs sont inchangées
+func projectJSON(raw json.RawMessage, tree fieldTree, keep bool) json.RawMessage {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return raw
+	}
+	switch raw[0] {
+	case '[':
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return raw
+		}
+		for i := range items {
+			items[i] = projectJSON(items[i], tree, keep)
+		}
+		out, _ := json.Marshal(items)
+		return out
+	case '{':
+		keys, values, err := decodeObject(raw)
+		if err != nil {
+			return raw
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		n := 0
+		for _, k := range keys {
+			sub, listed := tree[k]
+			value := values[k]
+			switch {
+			case keep && !listed, !keep && listed && sub == nil:
+				continue
+			case sub != nil:
+				value = projectJSON(value, sub, keep)
+			}
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			name, _ := json.Marshal(k)
+			buf.Write(name)
+			buf.WriteByte(':')
+			buf.Write(value)
+			n++
+		}
+		buf.WriteByte('}')
+		return buf.Bytes()
+	}
+	return raw
+}
+
+// decodeObject retourne les clés d'un objet JSON dans l'ordre du document
+func decodeObject(raw json.RawMessage) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	var keys []string
+	values := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		k, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, dup := values[k]; !dup {
+			keys = append(keys, k)
+		}
+		values[k] = value
+	}
+	return keys, values, nil
+}
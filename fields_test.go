@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// extractFields extrait testdata/name avec fields et retourne le statut et le corps
+func extractFields(t *testing.T, name, fields string) (int, []byte) {
+	t.Helper()
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	path := "/extract?url=" + url.QueryEscape(origin.URL+"/"+name) + "&fields=" + url.QueryEscape(fields)
+	return apiRequest(t, ts, http.MethodGet, path, "", "")
+}
+
+// topKeys retourne les clés de premier niveau d'un objet JSON, dans l'ordre
+func topKeys(t *testing.T, body []byte) []string {
+	t.Helper()
+	keys, _, err := decodeObject(body)
+	if err != nil {
+		t.Fatalf("%v in %s", err, body)
+	}
+	return keys
+}
+
+func TestFieldsInclusion(t *testing.T) {
+	status, body := extractFields(t, "news.html", "word_count,title,text")
+	if status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	// ordre de la réponse, pas celui de la requête ; text désigne clean_text
+	if got, want := topKeys(t, body), []string{"title", "clean_text", "word_count"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys %v, want %v", got, want)
+	}
+	var article Article
+	json.Unmarshal(body, &article)
+	if article.Title == "" || article.CleanText == "" || article.WordCount == 0 {
+		t.Errorf("empty projected values: %s", body)
+	}
+}
+
+func TestFieldsExclusion(t *testing.T) {
+	status, body := extractFields(t, "images.html", "-images,-paragraphs,-content")
+	if status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	keys := topKeys(t, body)
+	for _, k := range keys {
+		if k == "images" || k == "paragraphs" || k == "content" {
+			t.Errorf("%s not excluded", k)
+		}
+	}
+	if len(keys) < 10 || !strings.Contains(string(body), `"clean_text"`) {
+		t.Errorf("exclusion removed too much: %v", keys)
+	}
+}
+
+func TestFieldsNestedProjection(t *testing.T) {
+	status, body := extractFields(t, "images.html", "title,images.url")
+	if status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	var resp struct {
+		Title  string           `json:"title"`
+		Images []map[string]any `json:"images"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Images) == 0 {
+		t.Fatalf("no images in %s", body)
+	}
+	for _, img := range resp.Images {
+		if _, ok := img["url"]; !ok || len(img) != 1 {
+			t.Errorf("image %v, want url only", img)
+		}
+	}
+
+	// inclusion et exclusion combinées : images sans alt
+	_, body = extractFields(t, "images.html", "images,-images.alt")
+	if strings.Contains(string(body), `"alt"`) || !strings.Contains(string(body), `"width"`) {
+		t.Errorf("images,-images.alt: %s", body)
+	}
+}
+
+func TestFieldsUnknownField(t *testing.T) {
+	for _, fields := range []string{"title,nope", "images.size", "-bogus", "title.length"} {
+		status, body := extractFields(t, "news.html", fields)
+		if status != http.StatusBadRequest || errorCode(t, body) != codeInvalidRequest {
+			t.Errorf("fields=%s: got %d %s, want 400 %s", fields, status, body, codeInvalidRequest)
+		}
+	}
+	if _, err := parseFields("title,nope,-bogus"); err == nil || !strings.Contains(err.Error(), "nope, bogus") {
+		t.Errorf("parseFields: %v, want both unknown fields listed", err)
+	}
+}
+
+func TestFieldsFollowResponseKind(t *testing.T) {
+	status, body := extractFields(t, "feeds/rss.xml", "title,items.title")
+	if status != http.StatusOK {
+		t.Fatalf("feed items.title: got %d %s", status, body)
+	}
+	var feed struct {
+		Title string           `json:"title"`
+		Items []map[string]any `json:"items"`
+	}
+	json.Unmarshal(body, &feed)
+	if feed.Title != "Harbour Gazette" || len(feed.Items) != 3 || len(feed.Items[0]) != 1 {
+		t.Errorf("projected feed: %s", body)
+	}
+
+	// champs d'article sur un flux, et inversement
+	tests := []struct {
+		name, fields, want string
+	}{
+		{"feeds/rss.xml", "title,word_count", "unknown fields for a response of type feed: word_count"},
+		{"news.html", "items", "unknown fields for a response of type article: items"},
+	}
+	for _, tt := range tests {
+		status, body := extractFields(t, tt.name, tt.fields)
+		if status != http.StatusBadRequest || !strings.Contains(string(body), tt.want) {
+			t.Errorf("%s fields=%s: got %d %s, want 400 %q", tt.name, tt.fields, status, body, tt.want)
+		}
+	}
+}
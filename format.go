@@ -0,0 +1,249 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// formats de sortie acceptés par le paramètre format
+const (
+	formatText     = "text"
+	formatHTML     = "html"
+	formatMarkdown = "markdown"
+)
+
+func validFormat(f string) bool {
+	return f == formatText || f == formatHTML || f == formatMarkdown
+}
+
+// cleanHTML nettoie une copie du sous-arbre (voir sanitize.go) et résout
+// les liens relatifs
+func (p *sanitizePolicy) cleanHTML(s *goquery.Selection, base *url.URL, dataImages bool) *goquery.Selection {
+	s = s.Clone()
+
+	// pixels de suivi
+	s.Find(`img[width="1"], img[height="1"]`).Remove()
+
+	for _, n := range s.Nodes {
+		p.sanitizeNode(n, base, dataImages)
+	}
+	return s
+}
+
+func resolveAgainst(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == nil || ref == "" {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(r).String()
+}
+
+// renderHTML sérialise le sous-arbre nettoyé, puis nettoie de nouveau le
+// résultat tel qu'un navigateur l'analysera
+func (p *sanitizePolicy) renderHTML(s *goquery.Selection, dataImages bool) string {
+	out, err := goquery.OuterHtml(s)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(p.sanitizeFragment(out, dataImages))
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`\s+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// renderMarkdown convertit le sous-arbre nettoyé en Markdown
+func renderMarkdown(s *goquery.Selection) string {
+	var b strings.Builder
+	for _, n := range s.Nodes {
+		b.WriteString(mdNode(n, 0))
+	}
+	return tidyMarkdown(b.String())
+}
+
+// tidyMarkdown supprime les espaces en fin de ligne et les lignes vides en trop,
+// sans toucher aux blocs de code
+func tidyMarkdown(md string) string {
+	lines := strings.Split(md, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+	}
+	md = strings.Join(lines, "\n")
+	md = blankLines.ReplaceAllString(md, "\n\n")
+	return strings.TrimSpace(md)
+}
+
+func mdChildren(n *html.Node, depth int) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		part := mdNode(c, depth)
+		// pas d'espace en début de ligne
+		cur := b.String()
+		if cur == "" || strings.HasSuffix(cur, "\n") {
+			part = strings.TrimLeft(part, " ")
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func mdNode(n *html.Node, depth int) string {
+	if n.Type == html.TextNode {
+		return spaceRun.ReplaceAllString(n.Data, " ")
+	}
+	if n.Type != html.ElementNode {
+		return mdChildren(n, depth)
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + mdInline(n, depth) + "\n\n"
+	case "p", "div", "section", "article", "main", "header", "figure", "table", "tr":
+		return "\n\n" + mdChildren(n, depth) + "\n\n"
+	case "figcaption", "td", "th":
+		return mdChildren(n, depth) + " "
+	case "br":
+		return "\\\n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "strong", "b":
+		return wrapInline("**", mdInline(n, depth))
+	case "em", "i":
+		return wrapInline("_", mdInline(n, depth))
+	case "del", "s":
+		return wrapInline("~~", mdInline(n, depth))
+	case "code":
+		return wrapInline("`", nodeText(n))
+	case "a":
+		text := mdInline(n, depth)
+		href := attr(n, "href")
+		if href == "" {
+			return text
+		}
+		if text == "" {
+			text = href
+		}
+		return "[" + text + "](" + href + ")"
+	case "img":
+		src := attr(n, "src")
+		if src == "" {
+			return ""
+		}
+		return "![" + attr(n, "alt") + "](" + src + ")"
+	case "pre":
+		code := strings.TrimRight(nodeText(n), "\n")
+		lang := ""
+		if c := n.FirstChild; c != nil && c.Type == html.ElementNode && c.Data == "code" {
+			lang = codeLanguage(c)
+		}
+		return "\n\n```" + lang + "\n" + code + "\n```\n\n"
+	case "blockquote":
+		inner := tidyMarkdown(mdChildren(n, depth))
+		lines := strings.Split(inner, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimRight("> "+l, " ")
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case "ul", "ol":
+		return mdList(n, depth)
+	}
+	return mdChildren(n, depth)
+}
+
+// mdList gère les listes imbriquées par indentation
+func mdList(n *html.Node, depth int) string {
+	ordered := n.Data == "ol"
+	num := 1
+	if v, err := strconv.Atoi(attr(n, "start")); err == nil {
+		num = v
+	}
+	indent := strings.Repeat("  ", depth)
+
+	var b strings.Builder
+	b.WriteString("\n")
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.Data != "li" {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		item := strings.TrimSpace(mdChildren(c, depth+1))
+		item = blankLines.ReplaceAllString(item, "\n")
+		item = strings.ReplaceAll(item, "\n\n", "\n")
+		b.WriteString(indent + marker + item + "\n")
+	}
+	if depth == 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func mdInline(n *html.Node, depth int) string {
+	return strings.TrimSpace(spaceRun.ReplaceAllString(mdChildren(n, depth), " "))
+}
+
+// wrapInline entoure le texte du marqueur, sauf s'il est vide
+func wrapInline(marker, text string) string {
+	if text == "" {
+		return ""
+	}
+	return marker + text + marker
+}
+
+// nodeText retourne le texte brut, espaces compris
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// codeLanguage lit class="language-go" ou "lang-go"
+func codeLanguage(n *html.Node) string {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(c, prefix) {
+				return strings.TrimPrefix(c, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
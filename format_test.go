@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatsMatchGoldenFiles(t *testing.T) {
+	e := newTestEngine(t, nil)
+	for _, tt := range []struct{ format, golden string }{
+		{formatText, "format.txt"},
+		{formatHTML, "format.html"},
+		{formatMarkdown, "format.md"},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			article := extractFixture(t, e, "format.html", extractOptions{Format: tt.format})
+			if article.Format != tt.format {
+				t.Errorf("format %q, want %q", article.Format, tt.format)
+			}
+			checkGolden(t, tt.golden, article.Content)
+		})
+	}
+}
+
+func TestHTMLFormatStripsScriptsAndTracking(t *testing.T) {
+	e := newTestEngine(t, nil)
+	content := extractFixture(t, e, "format.html", extractOptions{Format: formatHTML}).Content
+	for _, banned := range []string{"<script", "<style", "onclick", "data-analytics-id", "data-track", "tracker"} {
+		if strings.Contains(content, banned) {
+			t.Errorf("%q left in html output", banned)
+		}
+	}
+}
+
+func TestMarkdownKeepsStructure(t *testing.T) {
+	e := newTestEngine(t, nil)
+	md := extractFixture(t, e, "format.html", extractOptions{Format: formatMarkdown}).Content
+	for _, want := range []string{
+		"## Installing the tools",
+		"###### Small print",
+		"**fast**",
+		"[so many blogs](https://example.com/guides/hosting)",
+		"[Markdown plugin](https://example.com/plugins/markdown)",
+		"![Build pipeline](https://example.com/images/diagram.png)",
+		"site:\n  title: My blog\n  theme:    minimal",
+		"> Keep the configuration small",
+		"1. Write the post",
+		"`public/`",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown misses %q:\n%s", want, md)
+		}
+	}
+	// liste imbriquée : l'élément enfant est indenté sous son parent
+	if !strings.Contains(md, "- A text editor\n  - Any editor") {
+		t.Errorf("nested list flattened:\n%s", md)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// en-têtes gérés par le transport ou dangereux à relayer
+var blockedHeaders = map[string]bool{
+	"Host":                true,
+	"Content-Length":      true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Transfer-Encoding":   true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Upgrade":             true,
+	"Expect":              true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Accept-Encoding":     true, // négocié par apply, décompressé par readBody
+}
+
+// fetchOptions personnalise la requête sortante
+type fetchOptions struct {
+	UserAgent string // DEFAULT_USER_AGENT si vide, posé par extractOptions.validate
+	Headers   map[string]string
+	Cookies   string
+
+	RespectRobots bool   // refuser les chemins interdits par robots.txt
+	Proxy         string // proxy de cette requête (ALLOW_REQUEST_PROXY)
+
+	conditional conditionalHeaders // revalidation du cache, première requête seulement
+}
+
+// conditionalHeaders : validateurs de la réponse précédente
+type conditionalHeaders struct {
+	ETag         string
+	LastModified string
+}
+
+// fetchDebug est renvoyé sous "fetch" quand debug=true
+type fetchDebug struct {
+	UserAgent string   `json:"user_agent"`
+	Headers   []string `json:"headers"`
+	Cookies   bool     `json:"cookies"`
+	Proxy     string   `json:"proxy,omitempty"` // sans mot de passe
+}
+
+// parseHeadersParam décode le paramètre headers (objet JSON)
+func parseHeadersParam(raw string) (map[string]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+		return nil, newAPIError(http.StatusBadRequest, codeInvalidRequest, "headers must be a JSON object of strings")
+	}
+	return headers, nil
+}
+
+// validateHeaders refuse les en-têtes hop-by-hop ou dangereux
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		canonical := http.CanonicalHeaderKey(name)
+		if blockedHeaders[canonical] || strings.HasPrefix(canonical, "Sec-") {
+			return newAPIError(http.StatusBadRequest, codeInvalidRequest, "header not allowed: "+canonical)
+		}
+		if strings.ContainsAny(name+value, "\r\n") {
+			return newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid header: "+canonical)
+		}
+	}
+	return nil
+}
+
+// apply ajoute User-Agent, en-têtes et cookies à la requête
+func (o fetchOptions) apply(req *http.Request) {
+	req.Header.Set("User-Agent", o.UserAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	for name, value := range o.Headers {
+		req.Header.Set(name, value)
+	}
+	if o.Cookies != "" {
+		req.Header.Set("Cookie", o.Cookies)
+	}
+	o.conditional.apply(req)
+}
+
+// apply transforme les validateurs en If-None-Match / If-Modified-Since
+func (h conditionalHeaders) apply(req *http.Request) {
+	if h.ETag != "" {
+		req.Header.Set("If-None-Match", h.ETag)
+	}
+	if h.LastModified != "" {
+		req.Header.Set("If-Modified-Since", h.LastModified)
+	}
+}
+
+// cacheKey résume (haché, cookies compris) les options qui influencent le contenu
+func (o fetchOptions) cacheKey() string {
+	canonical := make(map[string]string, len(o.Headers))
+	names := make([]string, 0, len(o.Headers))
+	for name, value := range o.Headers {
+		name = http.CanonicalHeaderKey(name)
+		canonical[name] = value
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	io.WriteString(h, o.UserAgent)
+	for _, name := range names {
+		io.WriteString(h, "\n"+name+": "+canonical[name])
+	}
+	io.WriteString(h, "\n"+o.Cookies)
+	io.WriteString(h, "\n"+strconv.FormatBool(o.RespectRobots))
+	io.WriteString(h, "\n"+o.Proxy)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func (o fetchOptions) debug() *fetchDebug {
+	names := []string{}
+	for name := range o.Headers {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	sort.Strings(names)
+	debug := &fetchDebug{UserAgent: o.UserAgent, Headers: names, Cookies: o.Cookies != ""}
+	if u, err := parseProxyURL(o.Proxy); err == nil {
+		debug.Proxy = u.Redacted()
+	}
+	return debug
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// captureOrigin garde les en-têtes de la dernière requête reçue
+type captureOrigin struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+func (o *captureOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	o.header = r.Header.Clone()
+	o.mu.Unlock()
+	largePage(w, r)
+}
+
+func (o *captureOrigin) last() http.Header {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.header
+}
+
+func TestRequestHeadersPropagate(t *testing.T) {
+	capture := &captureOrigin{}
+	origin := httptest.NewServer(capture)
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"DEFAULT_USER_AGENT": "DefaultBot/2.0"})
+
+	// User-Agent par défaut, jamais celui de Go
+	apiGet(t, ts, "/extract?nocache=true&url="+url.QueryEscape(origin.URL), nil)
+	if ua := capture.last().Get("User-Agent"); ua != "DefaultBot/2.0" {
+		t.Errorf("default User-Agent %q", ua)
+	}
+
+	query := url.Values{
+		"url":        {origin.URL},
+		"nocache":    {"true"},
+		"debug":      {"true"},
+		"user_agent": {"Mozilla/5.0 (Custom)"},
+		"headers":    {`{"Accept-Language": "fr-FR", "x-custom": "42"}`},
+		"cookies":    {"consent=yes; session=abc"},
+	}
+	var article Article
+	if status := apiGet(t, ts, "/extract?"+query.Encode(), &article); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	got := capture.last()
+	for name, want := range map[string]string{
+		"User-Agent":      "Mozilla/5.0 (Custom)",
+		"Accept-Language": "fr-FR",
+		"X-Custom":        "42",
+		"Cookie":          "consent=yes; session=abc",
+	} {
+		if got.Get(name) != want {
+			t.Errorf("%s = %q, want %q", name, got.Get(name), want)
+		}
+	}
+
+	// debug=true : l'UA envoyé et le nom des en-têtes, sans les cookies
+	if article.Fetch == nil || article.Fetch.UserAgent != "Mozilla/5.0 (Custom)" || !article.Fetch.Cookies {
+		t.Fatalf("fetch debug %+v", article.Fetch)
+	}
+	if len(article.Fetch.Headers) != 2 || article.Fetch.Headers[0] != "Accept-Language" || article.Fetch.Headers[1] != "X-Custom" {
+		t.Errorf("debug headers %q", article.Fetch.Headers)
+	}
+}
+
+func TestBlockedHeadersAreRejected(t *testing.T) {
+	capture := &captureOrigin{}
+	origin := httptest.NewServer(capture)
+	defer origin.Close()
+	_, ts := newTestServer(t, nil)
+
+	for _, headers := range []string{
+		`{"Host": "internal.example"}`,
+		`{"content-length": "0"}`,
+		`{"Connection": "close"}`,
+		`{"Transfer-Encoding": "chunked"}`,
+		`{"Proxy-Authorization": "Basic eA=="}`,
+		`{"Sec-Fetch-Mode": "navigate"}`,
+		`{"X-Injected": "a\r\nHost: evil"}`,
+		`not json`,
+	} {
+		query := url.Values{"url": {origin.URL}, "headers": {headers}}
+		status, body := apiRequest(t, ts, http.MethodGet, "/extract?"+query.Encode(), "", "")
+		if status != http.StatusBadRequest || errorCode(t, body) != codeInvalidRequest {
+			t.Errorf("%s: got %d %s, want 400", headers, status, body)
+		}
+	}
+	if capture.last() != nil {
+		t.Error("a request with a blocked header reached the origin")
+	}
+}
+
+func TestCacheKeyDependsOnFetchOptions(t *testing.T) {
+	base := fetchOptions{UserAgent: "a"}
+	same := fetchOptions{UserAgent: "a", Headers: map[string]string{}}
+	if base.cacheKey() != same.cacheKey() {
+		t.Error("empty headers change the key")
+	}
+	lower := fetchOptions{UserAgent: "a", Headers: map[string]string{"accept-language": "fr"}}
+	upper := fetchOptions{UserAgent: "a", Headers: map[string]string{"Accept-Language": "fr"}}
+	if lower.cacheKey() != upper.cacheKey() {
+		t.Error("header name case changes the key")
+	}
+	for _, other := range []fetchOptions{
+		{UserAgent: "b"},
+		{UserAgent: "a", Cookies: "c=1"},
+		{UserAgent: "a", Headers: map[string]string{"Accept-Language": "en"}},
+	} {
+		if other.cacheKey() == base.cacheKey() {
+			t.Errorf("%+v shares the key of %+v", other, base)
+		}
+	}
+}
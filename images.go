@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// en dessous de cette taille (px), une image est une icône ou un pixel de suivi
+const minImageSize = 50
+
+// Image est une image du corps de l'article
+type Image struct {
+	URL     string `json:"url"`
+	Alt     string `json:"alt"`
+	Caption string `json:"caption"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// srcsetCandidate est une entrée de srcset ("img.jpg 800w" ou "img.jpg 2x")
+type srcsetCandidate struct {
+	url     string
+	width   int     // descripteur w
+	density float64 // descripteur x
+}
+
+// documentBase retourne l'URL de base de la page, <base href> compris s'il
+// reste en http(s) : un <base href="javascript:..."> ferait de chaque lien
+// et de chaque image une URL exécutable
+func documentBase(doc *goquery.Document, pageURL string) *url.URL {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if ref, err := url.Parse(strings.TrimSpace(href)); err == nil {
+			if resolved := base.ResolveReference(ref); resolved.Scheme == "http" || resolved.Scheme == "https" {
+				base = resolved
+			}
+		}
+	}
+	return base
+}
+
+// collectImages liste les images du contenu principal, dans l'ordre du document
+func collectImages(main *goquery.Selection, base *url.URL, includeData bool) []Image {
+	images := []Image{}
+	seen := make(map[string]bool)
+
+	main.Find("img").Each(func(i int, img *goquery.Selection) {
+		width, _ := strconv.Atoi(img.AttrOr("width", ""))
+		height, _ := strconv.Atoi(img.AttrOr("height", ""))
+		if (width > 0 && width < minImageSize) || (height > 0 && height < minImageSize) {
+			return
+		}
+
+		src, srcWidth := bestImageSource(img)
+		if src == "" {
+			return
+		}
+		if srcWidth > 0 && srcWidth < minImageSize {
+			return
+		}
+		if strings.HasPrefix(src, "data:") {
+			if !includeData {
+				return
+			}
+		} else {
+			src = resolveAgainst(base, src)
+		}
+		if seen[src] {
+			return
+		}
+		seen[src] = true
+
+		if width == 0 {
+			width = srcWidth
+		}
+		images = append(images, Image{
+			URL:     src,
+			Alt:     strings.TrimSpace(img.AttrOr("alt", "")),
+			Caption: strings.TrimSpace(img.Closest("figure").Find("figcaption").First().Text()),
+			Width:   width,
+			Height:  height,
+		})
+	})
+	return images
+}
+
+// bestImageSource choisit le meilleur candidat parmi srcset, les <source>
+// d'un <picture> parent, src et les attributs de lazy-loading
+func bestImageSource(img *goquery.Selection) (string, int) {
+	var candidates []srcsetCandidate
+	candidates = append(candidates, parseSrcset(img.AttrOr("srcset", ""))...)
+	candidates = append(candidates, parseSrcset(img.AttrOr("data-srcset", ""))...)
+	if picture := img.Parent(); goquery.NodeName(picture) == "picture" {
+		picture.Find("source").Each(func(i int, source *goquery.Selection) {
+			candidates = append(candidates, parseSrcset(source.AttrOr("srcset", ""))...)
+		})
+	}
+
+	if len(candidates) > 0 {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].width != candidates[j].width {
+				return candidates[i].width > candidates[j].width
+			}
+			return candidates[i].density > candidates[j].density
+		})
+		return candidates[0].url, candidates[0].width
+	}
+
+	for _, attr := range []string{"src", "data-src", "data-original", "data-lazy-src"} {
+		if src := strings.TrimSpace(img.AttrOr(attr, "")); src != "" {
+			return src, 0
+		}
+	}
+	return "", 0
+}
+
+// parseSrcset découpe un attribut srcset en candidats
+func parseSrcset(srcset string) []srcsetCandidate {
+	var out []srcsetCandidate
+	for _, part := range strings.Split(srcset, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		c := srcsetCandidate{url: fields[0], density: 1}
+		if len(fields) > 1 {
+			d := fields[1]
+			switch {
+			case strings.HasSuffix(d, "w"):
+				c.width, _ = strconv.Atoi(strings.TrimSuffix(d, "w"))
+			case strings.HasSuffix(d, "x"):
+				c.density, _ = strconv.ParseFloat(strings.TrimSuffix(d, "x"), 64)
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCollectImagesFixture(t *testing.T) {
+	e := newTestEngine(t, nil)
+	article := extractFixture(t, e, "images.html", extractOptions{})
+	want := []Image{
+		// plus grand candidat du srcset, légende du <figure>
+		{URL: "https://cdn.example.org/stories/coast/cliffs-1200.jpg", Alt: "The cliffs at dawn", Caption: "The cliffs at dawn, seen from the northern beach.", Width: 1200, Height: 600},
+		// <source> du <picture>, chemin absolu résolu contre <base href>
+		{URL: "https://cdn.example.org/media/lighthouse.webp", Alt: "The lighthouse", Width: 1600},
+		// descripteur x : la plus haute densité
+		{URL: "https://cdn.example.org/stories/coast/images/harbour@2x.jpg", Alt: "The harbour", Width: 640, Height: 480},
+	}
+	if !reflect.DeepEqual(article.Images, want) {
+		t.Errorf("images:\n%+v\nwant:\n%+v", article.Images, want)
+	}
+	// og:image reste l'image principale
+	if article.Image != "https://cdn.example.org/social/coast-card.jpg" {
+		t.Errorf("lead image %q", article.Image)
+	}
+}
+
+func TestDataImagesFlag(t *testing.T) {
+	e := newTestEngine(t, nil)
+	article := extractFixture(t, e, "images.html", extractOptions{IncludeDataImages: true})
+	found := false
+	for _, img := range article.Images {
+		found = found || strings.HasPrefix(img.URL, "data:image/png")
+	}
+	if !found {
+		t.Errorf("data URI missing with data_images: %+v", article.Images)
+	}
+}
+
+// sans og:image, la première image du corps
+func TestLeadImageFallsBackToBodyImage(t *testing.T) {
+	e := newTestEngine(t, nil)
+	page := strings.Replace(string(readFixture(t, "images.html")), `<meta property="og:image"`, `<meta property="og:unused"`, 1)
+	doc := mustParse(t, page)
+	article := e.extractDocument(doc, "https://example.com/coast", extractOptions{Format: formatText})
+	if article.Image != "https://cdn.example.org/stories/coast/cliffs-1200.jpg" {
+		t.Errorf("lead image %q", article.Image)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	got := parseSrcset(" a.jpg 400w,b.jpg  2x , c.jpg ")
+	want := []srcsetCandidate{{url: "a.jpg", width: 400, density: 1}, {url: "b.jpg", density: 2}, {url: "c.jpg", density: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSrcset = %+v", got)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// en-tête portant la signature HMAC-SHA256 du corps du callback
+const signatureHeader = "X-Signature-256"
+
+// statuts d'un job
+const (
+	jobPending = "pending"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// job est une extraction exécutée en arrière-plan (async=true)
+type job struct {
+	ID         string       `json:"job_id"`
+	Status     string       `json:"status"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Result     any          `json:"result,omitempty"`
+	Error      *apiError    `json:"error,omitempty"`
+	Callback   *callbackLog `json:"callback,omitempty"`
+
+	keyID       string
+	requestID   string
+	callbackURL string
+	run         func(ctx context.Context) (any, error)
+}
+
+// callbackLog rend compte de la livraison du résultat au callback
+type callbackLog struct {
+	URL       string `json:"url"`
+	Delivered bool   `json:"delivered"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// jobRunner : file bornée, pool de workers et jobs conservés jusqu'à
+// expiration. Les callbacks passent par le client de l'engine.
+type jobRunner struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	queue   chan *job
+	closed  bool
+	retries map[string]*time.Timer // nouvelles tentatives de callback programmées, par job
+	wg      sync.WaitGroup
+
+	engine *engine
+}
+
+// newJobRunner démarre JOB_WORKERS workers sur une file de JOB_QUEUE_SIZE jobs
+func newJobRunner(e *engine) *jobRunner {
+	r := &jobRunner{
+		jobs:    make(map[string]*job),
+		queue:   make(chan *job, e.cfg.JobQueueSize),
+		retries: make(map[string]*time.Timer),
+		engine:  e,
+	}
+	for i := 0; i < e.cfg.JobWorkers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	return r
+}
+
+// submit met le job en file ; false si la file est pleine ou le serveur s'arrête
+func (r *jobRunner) submit(j *job) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	select {
+	case r.queue <- j:
+		r.jobs[j.ID] = j
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *jobRunner) work() {
+	defer r.wg.Done()
+	for j := range r.queue {
+		r.execute(j)
+	}
+}
+
+func (r *jobRunner) execute(j *job) {
+	r.update(j, func() { j.Status = jobRunning })
+
+	ctx, cancel := context.WithTimeout(withBudgetKey(context.Background(), j.keyID), r.engine.cfg.JobTimeout)
+	result, err := j.run(ctx)
+	cancel()
+
+	r.update(j, func() {
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil {
+			e := *toAPIError(err)
+			e.RequestID = j.requestID
+			j.Status, j.Error = jobFailed, &e
+		} else {
+			j.Status, j.Result = jobDone, result
+		}
+	})
+
+	if j.callbackURL != "" {
+		r.deliver(j)
+	}
+}
+
+func (r *jobRunner) update(j *job, fn func()) {
+	r.mu.Lock()
+	fn()
+	r.mu.Unlock()
+}
+
+// get retourne une copie du job, seulement pour la clé qui l'a créé
+func (r *jobRunner) get(id, keyID string) (job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, ok := r.jobs[id]
+	if !ok || j.keyID != keyID {
+		return job{}, false
+	}
+	snapshot := *j
+	if j.Callback != nil {
+		cb := *j.Callback
+		snapshot.Callback = &cb
+	}
+	return snapshot, true
+}
+
+// deliver envoie le résultat signé au callback ; la première tentative part
+// du worker, les suivantes sont programmées par attemptCallback
+func (r *jobRunner) deliver(j *job) {
+	r.mu.Lock()
+	payload, err := json.Marshal(gin.H{"job_id": j.ID, "status": j.Status, "result": j.Result, "error": j.Error})
+	r.mu.Unlock()
+	if err != nil {
+		log.Printf("job %s: encoding callback payload: %v", j.ID, err)
+		return
+	}
+	r.attemptCallback(j, payload, 1)
+}
+
+// attemptCallback envoie la tentative attempt. Sur erreur réseau, 429 ou 5xx,
+// la suivante est programmée avec time.AfterFunc après backoff
+// (CALLBACK_MAX_ATTEMPTS, CALLBACK_RETRY_BACKOFF) : aucun worker n'attend.
+// drain annule les tentatives programmées.
+func (r *jobRunner) attemptCallback(j *job, payload []byte, attempt int) {
+	cfg := r.engine.cfg
+	retry, err := r.postCallback(j, payload)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.retries, j.ID)
+	j.Callback.Attempts = attempt
+	j.Callback.Delivered = err == nil
+	j.Callback.LastError = ""
+	if err == nil {
+		return
+	}
+	j.Callback.LastError = err.Error()
+	switch {
+	case !retry || attempt == cfg.CallbackMaxAttempts:
+		log.Printf("job %s: callback delivery failed after %d attempts: %v", j.ID, attempt, err)
+	case r.closed:
+		log.Printf("job %s: shutting down, callback retry abandoned after %d attempts", j.ID, attempt)
+	default:
+		r.retries[j.ID] = time.AfterFunc(backoff(cfg.CallbackRetryBackoff, attempt), func() {
+			r.attemptCallback(j, payload, attempt+1)
+		})
+	}
+}
+
+// postCallback envoie une tentative, imputée à la clé du job ; retry
+// indique si l'échec est transitoire
+func (r *jobRunner) postCallback(j *job, payload []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(withBudgetKey(context.Background(), j.keyID), defaultFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", r.engine.cfg.DefaultUserAgent)
+	req.Header.Set("X-Job-Id", j.ID)
+	req.Header.Set(signatureHeader, signPayload(payload, r.engine.cfg.CallbackSecret))
+
+	resp, err := r.engine.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errForbiddenAddress), err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+}
+
+// signPayload : "sha256=" + HMAC-SHA256 hexadécimal du corps avec CALLBACK_SECRET
+func signPayload(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// cleanup oublie les jobs terminés depuis plus de ttl
+func (r *jobRunner) cleanup(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, j := range r.jobs {
+		if j.FinishedAt != nil && now.Sub(*j.FinishedAt) > ttl {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+// startCleanup nettoie périodiquement les jobs expirés
+func (r *jobRunner) startCleanup(every, ttl time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			r.cleanup(ttl)
+		}
+	}()
+}
+
+// drain refuse les nouveaux jobs, annule les nouvelles tentatives de callback
+// programmées et attend la fin des jobs en file, au plus jusqu'à ctx
+func (r *jobRunner) drain(ctx context.Context) {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	for id, timer := range r.retries {
+		if timer.Stop() {
+			log.Printf("job %s: shutting down, callback retry abandoned", id)
+		}
+		delete(r.retries, id)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("shutdown: abandoning unfinished jobs")
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startJob met run en file et répond 202 ; callback_url (facultatif) doit être
+// une URL http(s) publique et nécessite CALLBACK_SECRET pour la signature
+func (s *server) startJob(c *gin.Context, callbackURL string, run func(ctx context.Context) (any, error)) {
+	j := &job{
+		ID:        newJobID(),
+		Status:    jobPending,
+		CreatedAt: time.Now(),
+		keyID:     keyID(c.GetString(ctxAPIKey)),
+		requestID: c.GetString(ctxRequestID),
+		run:       run,
+	}
+
+	if callbackURL != "" {
+		if s.cfg.CallbackSecret == "" {
+			respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "callbacks are disabled (CALLBACK_SECRET is not set)"))
+			return
+		}
+		u, err := url.Parse(callbackURL)
+		if err == nil {
+			err = s.engine.validateURL(c.Request.Context(), u)
+		}
+		if err != nil {
+			respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid callback_url"))
+			return
+		}
+		j.callbackURL = callbackURL
+		j.Callback = &callbackLog{URL: callbackURL}
+	}
+
+	if !s.engine.jobs.submit(j) {
+		respondError(c, newAPIError(http.StatusServiceUnavailable, codeJobQueueFull, "job queue is full, retry later"))
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"job_id": j.ID, "status": jobPending, "status_url": "/jobs/" + j.ID})
+}
+
+// jobHandler renvoie l'état d'un job (GET /jobs/:id)
+func (s *server) jobHandler(c *gin.Context) {
+	j, ok := s.engine.jobs.get(c.Param("id"), keyID(c.GetString(ctxAPIKey)))
+	if !ok {
+		respondError(c, newAPIError(http.StatusNotFound, codeJobNotFound, "job not found"))
+		return
+	}
+	c.JSON(http.StatusOK, j)
+}
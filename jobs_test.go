@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testCallbackSecret = "callback-secret"
+
+// callbackReceiver répond status aux failures premières livraisons, puis 200,
+// et garde chaque livraison reçue
+type callbackReceiver struct {
+	mu         sync.Mutex
+	attempts   atomic.Int64
+	failures   int64
+	status     int
+	deliveries []delivery
+}
+
+type delivery struct {
+	body      []byte
+	signature string
+	jobID     string
+}
+
+func (cr *callbackReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	n := cr.attempts.Add(1)
+	cr.mu.Lock()
+	cr.deliveries = append(cr.deliveries, delivery{body, r.Header.Get(signatureHeader), r.Header.Get("X-Job-Id")})
+	cr.mu.Unlock()
+	if n <= cr.failures {
+		w.WriteHeader(cr.status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cr *callbackReceiver) last() delivery {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return cr.deliveries[len(cr.deliveries)-1]
+}
+
+func newCallbackReceiver(t *testing.T, failures int64, status int) (*callbackReceiver, string) {
+	t.Helper()
+	cr := &callbackReceiver{failures: failures, status: status}
+	ts := httptest.NewServer(cr)
+	t.Cleanup(ts.Close)
+	return cr, ts.URL + "/hook"
+}
+
+// startAsync lance GET /extract?async=true et retourne le job_id
+func startAsync(t *testing.T, ts *httptest.Server, pageURL, callbackURL string) string {
+	t.Helper()
+	path := "/extract?async=true&url=" + url.QueryEscape(pageURL)
+	if callbackURL != "" {
+		path += "&callback_url=" + url.QueryEscape(callbackURL)
+	}
+	var accepted struct {
+		JobID     string `json:"job_id"`
+		Status    string `json:"status"`
+		StatusURL string `json:"status_url"`
+	}
+	if status := apiGet(t, ts, path, &accepted); status != http.StatusAccepted {
+		t.Fatalf("async extract: status %d", status)
+	}
+	if accepted.JobID == "" || accepted.Status != jobPending || accepted.StatusURL != "/jobs/"+accepted.JobID {
+		t.Fatalf("accepted %+v", accepted)
+	}
+	return accepted.JobID
+}
+
+// waitJob interroge /jobs/:id jusqu'à ce que done soit vrai
+func waitJob(t *testing.T, ts *httptest.Server, id string, done func(job) bool) job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var j job
+		if status := apiGet(t, ts, "/jobs/"+id, &j); status != http.StatusOK {
+			t.Fatalf("GET /jobs/%s: status %d", id, status)
+		}
+		if done(j) {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s stuck: %+v", id, j)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobCallbackSignatureAndRetries(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	receiver, hook := newCallbackReceiver(t, 2, http.StatusServiceUnavailable)
+	_, ts := newTestServer(t, map[string]string{"CALLBACK_SECRET": testCallbackSecret, "CALLBACK_RETRY_BACKOFF": "5ms"})
+
+	id := startAsync(t, ts, origin.URL+"/story", hook)
+	j := waitJob(t, ts, id, func(j job) bool { return j.Callback != nil && j.Callback.Delivered })
+	if j.Status != jobDone || j.Result == nil || j.FinishedAt == nil {
+		t.Errorf("job %+v", j)
+	}
+	if j.Callback.Attempts != 3 || receiver.attempts.Load() != 3 || j.Callback.LastError != "" {
+		t.Errorf("callback %+v after %d receiver attempts, want 3", j.Callback, receiver.attempts.Load())
+	}
+
+	got := receiver.last()
+	if got.jobID != id {
+		t.Errorf("X-Job-Id %q", got.jobID)
+	}
+	if want := signPayload(got.body, testCallbackSecret); got.signature != want || !strings.HasPrefix(want, "sha256=") {
+		t.Errorf("signature %q, want %q", got.signature, want)
+	}
+	if got.signature == signPayload(got.body, "other-secret") {
+		t.Error("signature does not depend on the secret")
+	}
+	var payload struct {
+		JobID  string  `json:"job_id"`
+		Status string  `json:"status"`
+		Result Article `json:"result"`
+	}
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.JobID != id || payload.Status != jobDone || payload.Result.WordCount == 0 {
+		t.Errorf("payload %+v", payload)
+	}
+}
+
+func TestJobCallbackGivesUp(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"CALLBACK_SECRET": testCallbackSecret, "CALLBACK_RETRY_BACKOFF": "1ms", "CALLBACK_MAX_ATTEMPTS": "3"})
+
+	t.Run("transient", func(t *testing.T) {
+		receiver, hook := newCallbackReceiver(t, 100, http.StatusBadGateway)
+		j := waitJob(t, ts, startAsync(t, ts, origin.URL+"/a", hook), func(j job) bool { return j.Callback.Attempts == 3 })
+		time.Sleep(20 * time.Millisecond)
+		if receiver.attempts.Load() != 3 || j.Callback.Delivered || !strings.Contains(j.Callback.LastError, "502") {
+			t.Errorf("callback %+v after %d attempts", j.Callback, receiver.attempts.Load())
+		}
+	})
+	t.Run("client error", func(t *testing.T) {
+		receiver, hook := newCallbackReceiver(t, 100, http.StatusBadRequest)
+		j := waitJob(t, ts, startAsync(t, ts, origin.URL+"/b", hook), func(j job) bool { return j.Callback.Attempts == 1 })
+		time.Sleep(20 * time.Millisecond)
+		if receiver.attempts.Load() != 1 || j.Callback.Delivered {
+			t.Errorf("4xx retried: %+v after %d attempts", j.Callback, receiver.attempts.Load())
+		}
+	})
+}
+
+// une extraction en échec livre l'enveloppe d'erreur
+func TestFailedJobDeliversError(t *testing.T) {
+	origin := httptest.NewServer(http.NotFoundHandler())
+	defer origin.Close()
+	receiver, hook := newCallbackReceiver(t, 0, 0)
+	_, ts := newTestServer(t, map[string]string{"CALLBACK_SECRET": testCallbackSecret})
+
+	j := waitJob(t, ts, startAsync(t, ts, origin.URL+"/gone", hook), func(j job) bool { return j.Callback.Delivered })
+	if j.Status != jobFailed || j.Error == nil || j.Error.Code != codeUpstreamNotFound || j.Result != nil {
+		t.Errorf("job %+v", j)
+	}
+	var payload struct {
+		Status string   `json:"status"`
+		Error  apiError `json:"error"`
+	}
+	json.Unmarshal(receiver.last().body, &payload)
+	if payload.Status != jobFailed || payload.Error.Code != codeUpstreamNotFound {
+		t.Errorf("payload %s", receiver.last().body)
+	}
+}
+
+// les nouvelles tentatives n'occupent pas le worker
+func TestCallbackRetryDoesNotBlockWorker(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	receiver, hook := newCallbackReceiver(t, 100, http.StatusServiceUnavailable)
+	s, ts := newTestServer(t, map[string]string{
+		"CALLBACK_SECRET":        testCallbackSecret,
+		"CALLBACK_RETRY_BACKOFF": "1h",
+		"JOB_WORKERS":            "1",
+	})
+
+	first := startAsync(t, ts, origin.URL+"/first", hook)
+	waitJob(t, ts, first, func(j job) bool { return j.Callback.Attempts == 1 })
+	second := startAsync(t, ts, origin.URL+"/second", "")
+	j := waitJob(t, ts, second, func(j job) bool { return j.Status == jobDone || j.Status == jobFailed })
+	if j.Status != jobDone {
+		t.Fatalf("second job %+v", j)
+	}
+
+	// drain annule la tentative programmée
+	s.engine.jobs.mu.Lock()
+	pending := len(s.engine.jobs.retries)
+	s.engine.jobs.mu.Unlock()
+	if pending != 1 {
+		t.Fatalf("%d retries scheduled, want 1", pending)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	s.engine.jobs.drain(ctx)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("drain waited %s on a scheduled retry", time.Since(start))
+	}
+	s.engine.jobs.mu.Lock()
+	pending = len(s.engine.jobs.retries)
+	s.engine.jobs.mu.Unlock()
+	if pending != 0 || receiver.attempts.Load() != 1 {
+		t.Errorf("%d retries left, %d attempts", pending, receiver.attempts.Load())
+	}
+}
+
+// les livraisons sont imputées à la clé qui a créé le job
+func TestCallbackCountsTowardKeyUsage(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	_, hook := newCallbackReceiver(t, 1, http.StatusServiceUnavailable)
+	_, ts := newTestServer(t, map[string]string{"CALLBACK_SECRET": testCallbackSecret, "CALLBACK_RETRY_BACKOFF": "1ms"})
+
+	waitJob(t, ts, startAsync(t, ts, origin.URL+"/a", hook), func(j job) bool { return j.Callback.Delivered })
+	var usage struct {
+		Fetches struct {
+			Used int64 `json:"used"`
+		} `json:"fetches"`
+	}
+	apiGet(t, ts, "/usage", &usage)
+	// la page, puis deux livraisons
+	if usage.Fetches.Used != 3 {
+		t.Errorf("key usage %d fetches, want 3", usage.Fetches.Used)
+	}
+}
+
+func TestJobsArePerKeyAndValidated(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"API_KEYS": testKey + ",other-key", "CALLBACK_SECRET": testCallbackSecret})
+
+	id := startAsync(t, ts, origin.URL, "")
+	waitJob(t, ts, id, func(j job) bool { return j.Status == jobDone })
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/jobs/"+id, nil)
+	req.Header.Set("X-API-Key", "other-key")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("other key: got %d, want 404", resp.StatusCode)
+	}
+
+	for _, callback := range []string{"ftp://example.com/hook", "not a url"} {
+		status, body := apiRequest(t, ts, http.MethodGet, "/extract?async=true&url="+url.QueryEscape(origin.URL)+"&callback_url="+url.QueryEscape(callback), "", "")
+		if status != http.StatusBadRequest || errorCode(t, body) != codeInvalidRequest {
+			t.Errorf("callback %q: got %d %s", callback, status, body)
+		}
+	}
+}
+
+func TestCallbackRequiresSecret(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?async=true&url=https://example.com/&callback_url="+url.QueryEscape("https://example.com/hook"), "", "")
+	if status != http.StatusBadRequest || !strings.Contains(string(body), "CALLBACK_SECRET") {
+		t.Errorf("got %d %s", status, body)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Link est un lien du contenu principal ; les doublons sont regroupés dans Count
+type Link struct {
+	URL      string `json:"url"`
+	Text     string `json:"text"`
+	Rel      string `json:"rel"`
+	Internal bool   `json:"internal"`
+	Count    int    `json:"count"`
+}
+
+// schémas ignorés : ce ne sont pas des pages à explorer
+var skippedLinkSchemes = map[string]bool{
+	"javascript": true,
+	"mailto":     true,
+	"tel":        true,
+	"sms":        true,
+	"data":       true,
+}
+
+// collectLinks liste les liens <a href> du contenu principal, dans l'ordre du
+// document, résolus par rapport à base ; internal compare leur domaine
+// enregistrable à celui de base. Au plus max liens distincts (MAX_LINKS).
+func collectLinks(main *goquery.Selection, base *url.URL, max int) []Link {
+	links := []Link{}
+	var site string
+	if base != nil {
+		site = registrableDomain(base.String())
+	}
+
+	main.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+		href := strings.TrimSpace(a.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") {
+			return
+		}
+		abs := resolveAgainst(base, href)
+		u, err := url.Parse(abs)
+		if err != nil || skippedLinkSchemes[strings.ToLower(u.Scheme)] || u.Host == "" {
+			return
+		}
+
+		links = addLink(links, Link{
+			URL:      abs,
+			Text:     strings.Join(strings.Fields(a.Text()), " "),
+			Rel:      strings.ToLower(strings.Join(strings.Fields(a.AttrOr("rel", "")), " ")),
+			Internal: site != "" && registrableDomain(abs) == site,
+			Count:    1,
+		}, max)
+	})
+	return links
+}
+
+// addLink ajoute le lien ou incrémente son doublon (valeurs rel fusionnées),
+// sans dépasser max liens distincts
+func addLink(links []Link, link Link, max int) []Link {
+	for i := range links {
+		if links[i].URL != link.URL {
+			continue
+		}
+		links[i].Count += link.Count
+		if links[i].Text == "" {
+			links[i].Text = link.Text
+		}
+		for _, rel := range strings.Fields(link.Rel) {
+			if !strings.Contains(" "+links[i].Rel+" ", " "+rel+" ") {
+				links[i].Rel = strings.TrimSpace(links[i].Rel + " " + rel)
+			}
+		}
+		return links
+	}
+	if len(links) >= max {
+		return links
+	}
+	return append(links, link)
+}
+
+// registrableDomain : domaine enregistrable (blog.example.co.uk -> example.co.uk),
+// ou l'hôte tel quel pour une IP ou un nom sans suffixe public
+func registrableDomain(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func linksOf(t *testing.T, e *engine, name string) []Link {
+	t.Helper()
+	return extractFixture(t, e, name, extractOptions{IncludeLinks: true}).Links
+}
+
+func TestLinksRelative(t *testing.T) {
+	e := newTestEngine(t, nil)
+	// extractFixture sert la page comme https://example.com/links/relative.html
+	got := linksOf(t, e, "links/relative.html")
+	want := []Link{
+		{URL: "https://example.com/links/maps/north.html", Text: "north car park", Internal: true, Count: 1},
+		{URL: "https://example.com/trails/ridge", Text: "ridge trail", Rel: "nofollow", Internal: true, Count: 3},
+		{URL: "https://example.com/archive/2023/ridge.html", Text: "previous edition", Internal: true, Count: 1},
+		{URL: "https://blog.example.com/bridge", Text: "club blog", Rel: "nofollow", Internal: true, Count: 1},
+		{URL: "https://shop.partner.example/boots", Text: "partner shop", Rel: "sponsored noopener", Count: 1},
+		{URL: "https://forum.example.org/t/ridge", Text: "forum thread", Rel: "ugc", Count: 1},
+	}
+	checkLinks(t, got, want)
+}
+
+func TestLinksBaseHrefAndProtocolRelative(t *testing.T) {
+	e := newTestEngine(t, nil)
+	got := linksOf(t, e, "links/base.html")
+	want := []Link{
+		{URL: "https://static.example.com/guides/coast/cliffs.html", Text: "cliff path", Internal: true, Count: 1},
+		{URL: "https://static.example.com/steps", Text: "the old steps", Internal: true, Count: 1},
+		{URL: "https://tides.example.net/harbour", Text: "harbour office", Count: 1},
+		{URL: "https://cdn.example.com/photos/coast", Text: "image server", Internal: true, Count: 1},
+	}
+	checkLinks(t, got, want)
+}
+
+func checkLinks(t *testing.T, got, want []Link) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%d links, want %d:\n%+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("link %d:\n got %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// MAX_LINKS borne le nombre de liens distincts ; les doublons comptent encore
+func TestLinksCap(t *testing.T) {
+	var page strings.Builder
+	page.WriteString("<html><body><article>")
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&page, `<p>Paragraph %d links to <a href="/page/%d">page %d</a> and back to <a href="/page/0">the first page</a> of this long series.</p>`, i, i, i)
+	}
+	page.WriteString("</article></body></html>")
+	base, _ := url.Parse("https://example.com/series")
+	links := collectLinks(mustParse(t, page.String()).Find("article"), base, 5)
+	if len(links) != 5 {
+		t.Fatalf("%d links, want 5", len(links))
+	}
+	if links[0].URL != "https://example.com/page/0" || links[0].Count != 31 {
+		t.Errorf("first link %+v, want /page/0 counted 31 times", links[0])
+	}
+}
+
+func TestLinksAreOptIn(t *testing.T) {
+	e := newTestEngine(t, nil)
+	if links := extractFixture(t, e, "links/relative.html", extractOptions{}).Links; links != nil {
+		t.Errorf("links returned without include_links: %v", links)
+	}
+	if links := linksOf(t, e, "links/relative.html"); links == nil {
+		t.Error("include_links returned no array")
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://blog.example.com/x":     "example.com",
+		"https://www.example.co.uk/":     "example.co.uk",
+		"http://127.0.0.1:8080/":         "127.0.0.1",
+		"https://user.github.io/project": "user.github.io",
+		"https://EXAMPLE.com./":          "example.com",
+	} {
+		if got := registrableDomain(raw); got != want {
+			t.Errorf("registrableDomain(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logger JSON, une ligne par événement
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// clés du contexte gin
+const (
+	ctxRequestID = "requestID"
+	ctxErrorCode = "errorCode"
+)
+
+type traceKey struct{}
+
+// requestTrace accumule les mesures d'une requête à travers le pipeline
+// (partagée entre les workers d'un lot, d'où le verrou)
+type requestTrace struct {
+	mu          sync.Mutex
+	fetch       time.Duration
+	parse       time.Duration
+	cacheStatus string
+}
+
+func withTrace(ctx context.Context, t *requestTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// traceFrom retourne la trace de la requête, ou nil hors requête HTTP
+func traceFrom(ctx context.Context) *requestTrace {
+	t, _ := ctx.Value(traceKey{}).(*requestTrace)
+	return t
+}
+
+func (t *requestTrace) addFetch(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.fetch += d
+	t.mu.Unlock()
+}
+
+func (t *requestTrace) addParse(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.parse += d
+	t.mu.Unlock()
+}
+
+// setCache enregistre hit, miss ou bypass (le dernier l'emporte dans un lot)
+func (t *requestTrace) setCache(status string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.cacheStatus = status
+	t.mu.Unlock()
+}
+
+// requestID réutilise X-Request-Id s'il est raisonnable, sinon en génère un
+func requestID(incoming string) string {
+	if len(incoming) > 0 && len(incoming) <= 128 {
+		valid := true
+		for _, r := range incoming {
+			if r < 0x21 || r > 0x7e {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return incoming
+		}
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// keyID identifie une clé API dans les logs sans jamais l'exposer
+func keyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// targetHost ne garde que l'hôte de l'URL extraite (la query peut être sensible)
+func targetHost(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// requestLogger émet une ligne JSON par requête
+func (s *server) requestLogger(c *gin.Context) {
+	start := time.Now()
+	id := requestID(c.GetHeader("X-Request-Id"))
+	c.Set(ctxRequestID, id)
+	c.Header("X-Request-Id", id)
+
+	trace := &requestTrace{}
+	c.Request = c.Request.WithContext(withTrace(c.Request.Context(), trace))
+
+	c.Next()
+
+	duration := time.Since(start)
+	outcome := c.GetString(ctxErrorCode)
+	if outcome == "" {
+		outcome = "OK"
+	}
+
+	trace.mu.Lock()
+	attrs := []any{
+		slog.String("request_id", id),
+		slog.String("method", c.Request.Method),
+		slog.String("route", c.FullPath()),
+		slog.Int("status", c.Writer.Status()),
+		slog.String("outcome", outcome),
+		slog.Float64("duration_ms", msec(duration)),
+		slog.Float64("fetch_ms", msec(trace.fetch)),
+		slog.Float64("parse_ms", msec(trace.parse)),
+		slog.Int("response_bytes", c.Writer.Size()),
+		slog.String("cache", trace.cacheStatus),
+		slog.String("target_host", targetHost(c.Query("url"))),
+		slog.String("key_id", keyID(c.GetString(ctxAPIKey))),
+		slog.String("client_ip", c.ClientIP()),
+	}
+	trace.mu.Unlock()
+
+	level := slog.LevelInfo
+	if duration > s.cfg.SlowRequestThreshold {
+		level = slog.LevelWarn
+	}
+	logger.Log(c.Request.Context(), level, "request", attrs...)
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
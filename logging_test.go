@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer : tampon partagé entre le handler slog et le test
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLogs redirige logger vers un tampon jusqu'à la fin du test
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	previous := logger
+	logger = slog.New(slog.NewJSONHandler(buf, nil))
+	t.Cleanup(func() { logger = previous })
+	return buf
+}
+
+// requestLines décode les lignes "request" du journal
+func requestLines(t *testing.T, logs string) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("%v in %q", err, line)
+		}
+		if entry["msg"] == "request" {
+			lines = append(lines, entry)
+		}
+	}
+	return lines
+}
+
+func TestRequestLogFields(t *testing.T) {
+	const secretKey = "super-secret-api-key"
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"API_KEYS": secretKey})
+	logs := captureLogs(t)
+
+	target := origin.URL + "/article?token=private-token"
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/extract?url="+url.QueryEscape(target), nil)
+	req.Header.Set("X-API-Key", secretKey)
+	req.Header.Set("X-Request-Id", "trace-123")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Request-Id") != "trace-123" {
+		t.Errorf("X-Request-Id %q", resp.Header.Get("X-Request-Id"))
+	}
+
+	lines := requestLines(t, logs.String())
+	if len(lines) != 1 {
+		t.Fatalf("%d request lines:\n%s", len(lines), logs)
+	}
+	entry := lines[0]
+	for _, field := range []string{"request_id", "route", "status", "outcome", "duration_ms", "fetch_ms", "parse_ms", "response_bytes", "cache", "target_host", "key_id"} {
+		if _, ok := entry[field]; !ok {
+			t.Errorf("field %s missing", field)
+		}
+	}
+	if entry["request_id"] != "trace-123" || entry["target_host"] != "127.0.0.1" || entry["cache"] != "miss" || entry["outcome"] != "OK" || entry["level"] != "INFO" {
+		t.Errorf("entry %v", entry)
+	}
+	if entry["key_id"] != keyID(secretKey) || entry["key_id"] == "" {
+		t.Errorf("key_id %v", entry["key_id"])
+	}
+	if fetch, _ := entry["fetch_ms"].(float64); fetch <= 0 {
+		t.Errorf("fetch_ms %v", entry["fetch_ms"])
+	}
+	for _, secret := range []string{secretKey, "private-token"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("%q logged in cleartext", secret)
+		}
+	}
+}
+
+// la clé passée en query n'apparaît pas non plus, et l'erreur reprend le request_id
+func TestRequestLogForErrorsAndQueryKeys(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	logs := captureLogs(t)
+
+	resp, err := ts.Client().Get(ts.URL + "/extract?key=" + testKey + "&url=" + url.QueryEscape("ftp://example.com/file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+
+	lines := requestLines(t, logs.String())
+	if len(lines) != 1 {
+		t.Fatalf("%d request lines", len(lines))
+	}
+	if lines[0]["outcome"] != body.Error.Code || body.Error.Code == "" {
+		t.Errorf("outcome %v, error code %s", lines[0]["outcome"], body.Error.Code)
+	}
+	if body.Error.RequestID == "" || lines[0]["request_id"] != body.Error.RequestID || resp.Header.Get("X-Request-Id") != body.Error.RequestID {
+		t.Errorf("request ids: log %v, body %q, header %q", lines[0]["request_id"], body.Error.RequestID, resp.Header.Get("X-Request-Id"))
+	}
+	if strings.Contains(logs.String(), testKey) {
+		t.Error("query key logged in cleartext")
+	}
+}
+
+func TestSlowRequestLogsWarn(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		largePage(w, r)
+	}))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"SLOW_REQUEST_THRESHOLD": "10ms"})
+	logs := captureLogs(t)
+
+	apiGet(t, ts, "/extract?url="+url.QueryEscape(origin.URL), nil)
+	apiGet(t, ts, "/usage", nil)
+	lines := requestLines(t, logs.String())
+	if len(lines) != 2 || lines[0]["level"] != "WARN" || lines[1]["level"] != "INFO" {
+		t.Errorf("levels: %v", lines)
+	}
+}
+
+func TestRequestIDValidation(t *testing.T) {
+	if requestID("abc-123") != "abc-123" {
+		t.Error("valid id replaced")
+	}
+	for _, bad := range []string{"", "with space", "line\nbreak", strings.Repeat("x", 129)} {
+		if id := requestID(bad); id == bad || len(id) != 16 {
+			t.Errorf("requestID(%q) = %q", bad, id)
+		}
+	}
+}
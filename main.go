@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"log"
+	"log/slog"
 	"net/http"
-	"regexp"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-const API_KEY = "demo_12345" // clé API
+// nombre maximal de mots du texte renvoyé
+const maxTextWords = 1500
+
 func looksLikeContent(line string) bool {
 	// phrase assez longue
 	if len(line) < 50 {
@@ -49,93 +56,219 @@ func isBoilerplate(line string) bool {
 	return false
 }
 
-func cleanForLLM(raw string) string {
-	lines := strings.Split(raw, "\n")
+// cleanForLLM garde les paragraphes qui ressemblent à du contenu, les
+// intertitres et les listes (une ligne "- élément" par élément), sans
+// doublons ni boilerplate, séparés par une ligne vide. Renvoie aussi les
+// blocs retenus, avec leur texte tel qu'il figure dans le résultat.
+func cleanForLLM(blocks []textBlock) (string, []textBlock) {
 	seen := make(map[string]bool)
-	var cleaned []string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	var paragraphs []string
+	var kept []textBlock
+	words := 0
+	lastItem := false
 
-		if !looksLikeContent(line) {
-			continue
+	for _, b := range blocks {
+		if words >= maxTextWords {
+			break
 		}
-		if isBoilerplate(line) {
+		if b.kind == blockParagraph && !looksLikeContent(strings.Join(strings.Fields(b.text), " ")) {
 			continue
 		}
-		if seen[line] {
+		if isBoilerplate(b.text) || seen[b.text] {
 			continue
 		}
+		seen[b.text] = true
 
-		seen[line] = true
-		cleaned = append(cleaned, line)
-	}
-
-	text := strings.Join(cleaned, "\n\n")
-	text = strings.TrimSpace(text)
+		text := b.text
+		if b.kind == blockItem {
+			text = "- " + strings.ReplaceAll(text, "\n", " ")
+		}
+		// limite raisonnable pour LLM
+		if fields := strings.Fields(text); words+len(fields) > maxTextWords {
+			text = strings.Join(fields[:maxTextWords-words], " ") + "..."
+		}
+		words += len(strings.Fields(text))
+		b.text = text
+		kept = append(kept, b)
 
-	// limite raisonnable pour LLM
-	words := strings.Fields(text)
-	if len(words) > 1500 {
-		text = strings.Join(words[:1500], " ") + "..."
+		// les éléments d'une même liste restent sur des lignes consécutives
+		if b.kind == blockItem && lastItem {
+			paragraphs[len(paragraphs)-1] += "\n" + text
+		} else {
+			paragraphs = append(paragraphs, text)
+		}
+		lastItem = b.kind == blockItem
 	}
-	text = regexp.MustCompile(`\n{3,}`).ReplaceAllString(text, "\n\n")
-
-	return text
+	return strings.Join(paragraphs, "\n\n"), kept
 }
 
-func extractHandler(c *gin.Context) {
-	// Vérification de la clé API
-	key := c.Query("key")
-	if key != API_KEY {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing API key"})
-		return
+// queryOptions lit les options d'extraction dans la query string
+func (s *server) queryOptions(c *gin.Context) (extractOptions, error) {
+	opts := extractOptions{
+		Raw:               c.Query("raw") == "true", // ancien comportement, pour comparaison
+		Format:            c.DefaultQuery("format", formatText),
+		IncludeDataImages: c.Query("data_images") == "true",
+		FollowPagination:  c.Query("follow_pagination") == "true",
+		ExpandFeed:        c.Query("expand") == "true",
+		IncludeStructured: c.Query("include_structured") == "true",
+		IncludeLinks:      c.Query("include_links") == "true",
+		IncludeTables:     c.Query("include_tables") == "true",
+		FlattenTables:     c.Query("flatten_tables") == "true",
+		IncludeOffsets:    c.Query("include_offsets") == "true",
+		Prefer:            c.Query("prefer"),
+		VerifyFavicon:     c.Query("verify_favicon") == "true",
+		Fetch: fetchOptions{
+			UserAgent: c.Query("user_agent"),
+			Cookies:   c.Query("cookies"),
+			Proxy:     c.Query("proxy"),
+		},
 	}
+	headers, err := parseHeadersParam(c.Query("headers"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Fetch.Headers = headers
+	opts.Fetch.RespectRobots = s.cfg.RespectRobots
+	if v := c.Query("respect_robots"); v != "" {
+		opts.Fetch.RespectRobots = v == "true"
+	}
+	if opts.KeepSelectors, err = parseKeepSelectors(c.Query("keep_selectors")); err != nil {
+		return opts, err
+	}
+	err = opts.validate(s.cfg)
+	return opts, err
+}
 
+func (s *server) extractHandler(c *gin.Context) {
 	// Lire l'URL
 	url := c.Query("url")
 	if url == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing url parameter"})
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing url parameter"))
 		return
 	}
 
-	resp, err := http.Get(url)
+	opts, err := s.queryOptions(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch url"})
+		respondError(c, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	fields, err := parseFields(c.Query("fields"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse page"})
+		respondError(c, err)
 		return
 	}
 
-	title := doc.Find("title").First().Text()
-	var paragraphs []string
+	nocache, debug := c.Query("nocache") == "true", c.Query("debug") == "true"
+	if c.Query("async") == "true" {
+		s.startJob(c, c.Query("callback_url"), func(ctx context.Context) (any, error) {
+			article, _, err := s.engine.extractCached(ctx, url, opts, nocache)
+			if err != nil {
+				return nil, err
+			}
+			if debug {
+				article.Fetch = opts.Fetch.debug()
+			}
+			return fields.project(article)
+		})
+		return
+	}
 
-	doc.Find("p").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if text != "" {
-			paragraphs = append(paragraphs, text)
+	article, age, err := s.engine.extractCached(c.Request.Context(), url, opts, nocache)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Age", strconv.Itoa(int(age.Seconds())))
+	// le client a déjà ce texte : inutile de renvoyer le corps
+	if hash := c.Query("if_hash"); hash != "" && hash == article.ContentHash {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if debug {
+		article.Fetch = opts.Fetch.debug()
+	}
+
+	body, err := fields.project(article)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+func main() {
+	slog.SetDefault(logger)
+
+	cfg, err := loadConfig(os.Getenv)
+	if err != nil {
+		// une ligne par variable invalide
+		for _, line := range strings.Split(err.Error(), "\n") {
+			log.Printf("invalid configuration: %s", line)
 		}
-	})
+		os.Exit(1)
+	}
+	cfg.logValues()
+	s, err := newServer(cfg)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
 
-	rawText := strings.Join(paragraphs, "\n")
-	cleanText := cleanForLLM(rawText)
+	if err := s.loadAPIKeys(); err != nil {
+		log.Fatalf("failed to load api keys: %v", err)
+	}
+	s.reloadKeysOnSIGHUP()
+	s.engine.reloadSiteRulesOnSIGHUP()
+	if err := loadAPISpec(); err != nil {
+		log.Fatalf("invalid API description: %v", err)
+	}
+	if err := s.engine.openCacheStore(context.Background()); err != nil {
+		log.Fatalf("failed to open cache store: %v", err)
+	}
+	prometheus.MustRegister(budgetCollector{budget: s.engine.budget})
+	s.limiter.startCleanup(time.Minute, 10*time.Minute)
+	s.engine.startCleanup()
 
-	tokens := len(strings.Fields(cleanText)) // estimation simple
+	router := s.routes()
+	if err := checkSpecRoutes(router.Routes()); err != nil {
+		log.Fatalf("openapi.json out of date: %v", err)
+	}
 
-	c.JSON(200, gin.H{
-		"title":           title,
-		"clean_text":      cleanText,
-		"tokens_estimate": tokens,
-	})
+	if err := s.serve(router); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func main() {
-	router := gin.Default()
-	router.GET("/extract", extractHandler)
-	router.Run(":8080") // Render utilise ça
+// routes construit le routeur de l'API
+func (s *server) routes() *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery(), s.requestLogger, s.metricsMiddleware)
+	router.GET("/metrics", s.metricsHandler())
+	router.GET("/healthz", s.healthzHandler)
+	router.GET("/readyz", s.readyzHandler)
+	router.GET("/openapi.json", s.openapiHandler)
+
+	api := router.Group("/", s.requireAPIKey, s.rateLimit, s.validateRequest)
+	api.GET("/docs", s.docsHandler)
+	api.GET("/jobs/:id", s.jobHandler)
+	api.GET("/usage", s.usageHandler)
+	api.GET("/similarity", s.similarityHandler)
+
+	// routes qui contactent les origines : soumises aux budgets sortants
+	fetching := api.Group("", s.checkBudget)
+	fetching.GET("/extract", s.extractHandler)
+	fetching.POST("/extract", s.extractPostHandler)
+	fetching.POST("/extract/batch", s.batchHandler)
+	fetching.GET("/summarize", s.summarizeHandler)
+	fetching.POST("/summarize", s.summarizePostHandler)
+	fetching.GET("/diff", s.diffHandler)
+	fetching.POST("/diff", s.diffPostHandler)
+
+	admin := router.Group("/", s.requireAdminKey, s.validateRequest)
+	admin.GET("/rules", s.rulesHandler) // chemin de SITE_RULES_FILE compris
+	admin.DELETE("/cache", s.cachePurgeHandler)
+	admin.DELETE("/cache/all", s.cachePurgeAllHandler)
+	admin.GET("/cache/stats", s.cacheStatsHandler)
+	return router
 }
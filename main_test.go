@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gin-gonic/gin"
+)
+
+// go test -update réécrit les fichiers de référence de testdata/golden
+var update = flag.Bool("update", false, "rewrite testdata/golden files")
+
+// clés des serveurs de test
+const (
+	testKey      = "test-key"
+	testAdminKey = "test-admin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+	logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	if err := loadAPISpec(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// testConfig charge la configuration des tests : origines locales
+// autorisées (httptest écoute sur 127.0.0.1), robots.txt ignoré, délais
+// courts (arrêt compris), débit large ; env complète ou remplace ces valeurs
+func testConfig(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	values := map[string]string{
+		"API_KEYS":                 testKey,
+		"ADMIN_KEYS":               testAdminKey,
+		"ALLOW_PRIVATE":            "true",
+		"RESPECT_ROBOTS":           "false",
+		"HOST_MIN_DELAY":           "1ms",
+		"FETCH_RETRY_BACKOFF":      "1ms",
+		"RATE_LIMIT_BURST":         "1000",
+		"SHUTDOWN_READINESS_DELAY": "1ms",
+	}
+	for k, v := range env {
+		values[k] = v
+	}
+	cfg, err := loadConfig(func(name string) string { return values[name] })
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	return cfg
+}
+
+// newTestServer construit un server complet (routes, clés, engine) à partir
+// de testConfig et le sert avec httptest
+func newTestServer(t *testing.T, env map[string]string) (*server, *httptest.Server) {
+	t.Helper()
+	s, err := newServer(testConfig(t, env))
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+	if err := s.loadAPIKeys(); err != nil {
+		t.Fatalf("loadAPIKeys: %v", err)
+	}
+	router := s.routes()
+	if err := checkSpecRoutes(router.Routes()); err != nil {
+		t.Fatalf("checkSpecRoutes: %v", err)
+	}
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// newTestEngine : engine de testConfig, pour les étapes du pipeline testées seules
+func newTestEngine(t *testing.T, env map[string]string) *engine {
+	t.Helper()
+	e, err := newEngine(testConfig(t, env))
+	if err != nil {
+		t.Fatalf("newEngine: %v", err)
+	}
+	return e
+}
+
+// fixtureServer sert testdata/ (Content-Type déduit de l'extension)
+func fixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// readFixture lit testdata/name
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// extractFixture extrait testdata/name comme une page de example.com
+func extractFixture(t *testing.T, e *engine, name string, opts extractOptions) *Article {
+	t.Helper()
+	if opts.Format == "" {
+		opts.Format = formatText
+	}
+	if err := opts.validate(e.cfg); err != nil {
+		t.Fatal(err)
+	}
+	article, err := e.extractHTML(context.Background(), readFixture(t, name), "text/html; charset=utf-8", "https://example.com/"+name, opts)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return article
+}
+
+// checkGolden compare got à testdata/golden/name
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file (go test -update to rewrite)\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// mustParse analyse un document HTML
+func mustParse(t *testing.T, page string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// apiRequest envoie une requête authentifiée par testKey et retourne le
+// statut et le corps
+func apiRequest(t *testing.T, ts *httptest.Server, method, path, contentType, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", testKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// apiGet : GET authentifié, corps décodé dans out quand il n'est pas nil
+func apiGet(t *testing.T, ts *httptest.Server, path string, out any) int {
+	t.Helper()
+	status, body := apiRequest(t, ts, http.MethodGet, path, "", "")
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			t.Fatalf("GET %s: %v in %s", path, err, body)
+		}
+	}
+	return status
+}
+
+// errorCode lit error.code d'une réponse d'erreur
+func errorCode(t *testing.T, body []byte) string {
+	t.Helper()
+	var resp struct {
+		Error apiError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("%v in %s", err, body)
+	}
+	return resp.Error.Code
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Metadata regroupe les informations décrivant l'article
+type Metadata struct {
+	Title       string
+	RawTitle    string
+	Author      string
+	Authors     []Author
+	PublishedAt string
+	ModifiedAt  string
+	// signal retenu pour PublishedAt et sa fiabilité
+	DateSource     string
+	DateConfidence string
+	Image          string
+	Description    string
+	CanonicalURL   string
+	SiteName       string
+	FeedURL        string
+	AMPURL         string
+}
+
+// types JSON-LD considérés comme des articles
+var articleTypes = map[string]bool{
+	"Article":              true,
+	"NewsArticle":          true,
+	"BlogPosting":          true,
+	"ReportageNewsArticle": true,
+	"TechArticle":          true,
+	"ScholarlyArticle":     true,
+	"WebPage":              true,
+}
+
+// extractMetadata applique l'ordre de priorité JSON-LD > OpenGraph > meta > heuristiques
+// (extractAuthors pour les auteurs, extractDates pour les dates).
+// À appeler avant extractMainContent, qui retire les <script> du document.
+func (e *engine) extractMetadata(doc *goquery.Document, pageURL string) Metadata {
+	ld := e.jsonLDMetadata(doc)
+
+	meta := Metadata{
+		Title: firstNonEmpty(
+			ld.Title,
+			metaContent(doc, "og:title"),
+			metaContent(doc, "twitter:title"),
+			strings.TrimSpace(doc.Find("title").First().Text()),
+		),
+		Authors: e.extractAuthors(doc, ld.Authors, pageURL),
+		Image: firstNonEmpty(
+			ld.Image,
+			metaContent(doc, "og:image"),
+			metaContent(doc, "twitter:image"),
+			metaContent(doc, "twitter:image:src"),
+		),
+		Description: firstNonEmpty(
+			ld.Description,
+			metaContent(doc, "og:description"),
+			metaContent(doc, "twitter:description"),
+			metaContent(doc, "description"),
+		),
+		CanonicalURL: firstNonEmpty(
+			ld.CanonicalURL,
+			metaContent(doc, "og:url"),
+			doc.Find(`link[rel="canonical"]`).First().AttrOr("href", ""),
+		),
+		SiteName: firstNonEmpty(
+			ld.SiteName,
+			metaContent(doc, "og:site_name"),
+			metaContent(doc, "application-name"),
+		),
+		FeedURL: doc.Find(`link[rel~="alternate"][type="application/rss+xml"], link[rel~="alternate"][type="application/atom+xml"]`).
+			First().AttrOr("href", ""),
+		AMPURL: doc.Find(`link[rel~="amphtml"]`).First().AttrOr("href", ""),
+	}
+
+	meta.Author = authorNames(meta.Authors)
+	dates := extractDates(doc, ld, pageURL, time.Now())
+	meta.PublishedAt, meta.ModifiedAt = dates.published, dates.modified
+	meta.DateSource, meta.DateConfidence = dates.source, dates.confidence
+	meta.RawTitle = meta.Title
+	meta.Title = cleanTitle(meta.Title, []string{
+		doc.Find("h1").First().Text(),
+		ld.Title,
+		metaContent(doc, "og:title"),
+	}, meta.SiteName, pageURL)
+
+	meta.Image = resolveURL(pageURL, meta.Image)
+	meta.FeedURL = resolveURL(pageURL, strings.TrimSpace(meta.FeedURL))
+	meta.AMPURL = resolveURL(pageURL, strings.TrimSpace(meta.AMPURL))
+	meta.CanonicalURL = resolveURL(pageURL, meta.CanonicalURL)
+	return meta
+}
+
+// metaContent lit <meta property=...> ou <meta name=...>
+func metaContent(doc *goquery.Document, key string) string {
+	sel := doc.Find(`meta[property="` + key + `"], meta[name="` + key + `"]`).First()
+	return strings.TrimSpace(sel.AttrOr("content", ""))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// resolveURL rend ref absolue par rapport à base
+func resolveURL(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// jsonLDMetadata lit le premier bloc JSON-LD de type article
+func (e *engine) jsonLDMetadata(doc *goquery.Document) Metadata {
+	var meta Metadata
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		data, err := parseJSONLD(s.Text())
+		if err != nil {
+			return true
+		}
+		obj := findArticleObject(data)
+		if obj == nil {
+			return true
+		}
+		meta = Metadata{
+			Title:        ldString(obj["headline"]),
+			Authors:      e.ldAuthors(obj["author"]),
+			PublishedAt:  ldString(obj["datePublished"]),
+			ModifiedAt:   ldString(obj["dateModified"]),
+			Image:        ldURL(obj["image"]),
+			Description:  ldString(obj["description"]),
+			CanonicalURL: firstNonEmpty(ldURL(obj["mainEntityOfPage"]), ldString(obj["url"])),
+			SiteName:     ldName(obj["publisher"]),
+		}
+		return false
+	})
+	return meta
+}
+
+// findArticleObject parcourt objets, tableaux et @graph
+func findArticleObject(data any) map[string]any {
+	switch v := data.(type) {
+	case []any:
+		for _, item := range v {
+			if obj := findArticleObject(item); obj != nil {
+				return obj
+			}
+		}
+	case map[string]any:
+		if isArticleType(v["@type"]) {
+			return v
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findArticleObject(graph)
+		}
+	}
+	return nil
+}
+
+func isArticleType(t any) bool {
+	switch v := t.(type) {
+	case string:
+		return articleTypes[v]
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && articleTypes[s] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ldString(v any) string {
+	s, _ := v.(string)
+	return strings.TrimSpace(s)
+}
+
+// ldName accepte une chaîne, un objet {name} ou un tableau de ceux-ci
+func ldName(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]any:
+		return ldString(t["name"])
+	case []any:
+		var names []string
+		for _, item := range t {
+			if n := ldName(item); n != "" {
+				names = append(names, n)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+// ldURL accepte une chaîne, un objet {url|@id} ou un tableau (premier élément)
+func ldURL(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]any:
+		return firstNonEmpty(ldString(t["url"]), ldString(t["@id"]))
+	case []any:
+		for _, item := range t {
+			if u := ldURL(item); u != "" {
+				return u
+			}
+		}
+	}
+	return ""
+}
+
+// formats de date rencontrés en pratique, du plus au moins précis
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC850,
+	time.RFC822,
+	time.RFC822Z,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2006-01-02",
+	"2006/01/02",
+	"2006.01.02",
+	"20060102",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+// normalizeDate convertit une date libre en RFC3339, ou "" si illisible.
+// Les dates sans fuseau sont supposées en UTC, le décalage d'origine est conservé sinon.
+func normalizeDate(s string) string {
+	if t, ok := parseDate(s); ok {
+		return t.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// parseDate essaie les formats de dateLayouts
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// ISO 8601
+		{"2026-03-01T08:00:00Z", "2026-03-01T08:00:00Z"},
+		{"2026-03-01T08:00:00.123Z", "2026-03-01T08:00:00Z"},
+		{"2026-03-01T08:00:00+02:00", "2026-03-01T08:00:00+02:00"},
+		{"2026-03-01T08:00:00+0200", "2026-03-01T08:00:00+02:00"},
+		{"2026-03-01T08:00:00", "2026-03-01T08:00:00Z"},
+		{"2026-03-01T08:00", "2026-03-01T08:00:00Z"},
+		{"2026-03-01 08:00:00", "2026-03-01T08:00:00Z"},
+		// RFC 1123 et voisins
+		{"Sun, 01 Mar 2026 08:00:00 GMT", "2026-03-01T08:00:00Z"},
+		{"Sun, 01 Mar 2026 08:00:00 +0100", "2026-03-01T08:00:00+01:00"},
+		{"Sun, 1 Mar 2026 08:00:00 GMT", "2026-03-01T08:00:00Z"},
+		// YYYY-MM-DD et variantes
+		{"2026-03-01", "2026-03-01T00:00:00Z"},
+		{"2026/03/01", "2026-03-01T00:00:00Z"},
+		{"2026.03.01", "2026-03-01T00:00:00Z"},
+		{"20260301", "2026-03-01T00:00:00Z"},
+		{"  2026-03-01  ", "2026-03-01T00:00:00Z"},
+		{"March 1, 2026", "2026-03-01T00:00:00Z"},
+		{"1 March 2026", "2026-03-01T00:00:00Z"},
+		// illisibles
+		{"", ""},
+		{"yesterday-ish", ""},
+		{"2026-13-01", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeDate(tt.in); got != tt.want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// JSON-LD > Open Graph > meta > heuristiques
+func TestMetadataPrecedence(t *testing.T) {
+	e := newTestEngine(t, nil)
+	article := extractFixture(t, e, "meta.html", extractOptions{})
+	checks := []struct{ field, got, want string }{
+		{"author", article.Author, "Jane Ld"},
+		{"published_at", article.PublishedAt, "2026-03-02T09:30:00+01:00"},
+		{"image", article.Image, "https://example.com/img/og.jpg"},
+		{"description", article.Description, "OG description of the story."},
+		{"canonical_url", article.CanonicalURL, "https://example.com/stories/canonical"},
+		{"site_name", article.SiteName, "The Example Times"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+}
+
+// les champs absents restent des chaînes vides, jamais omis
+func TestMissingMetadataIsEmptyString(t *testing.T) {
+	e := newTestEngine(t, nil)
+	data, err := json.Marshal(extractFixture(t, e, "nometa.html", extractOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"author", "published_at", "image", "description", "canonical_url", "site_name"} {
+		if v, ok := fields[name]; !ok || v != "" {
+			t.Errorf("%s = %#v, want empty string", name, v)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requêtes HTTP traitées, par route et statut.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Durée des requêtes HTTP, par route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+
+	requestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Requêtes HTTP en cours.",
+	})
+
+	phaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "extraction_phase_duration_seconds",
+		Help:    "Durée des phases d'extraction (fetch, parse, extract).",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"phase"})
+
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_fetch_errors_total",
+		Help: "Échecs de fetch vers les origines, par catégorie.",
+	}, []string{"category"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Consultations du cache d'extraction (hit, miss, error).",
+	}, []string{"result"})
+)
+
+// catégories d'erreurs upstream exposées dans les métriques
+var upstreamErrorCategories = map[string]string{
+	codeUpstreamTimeout:     "timeout",
+	codeDNSFailure:          "dns",
+	codeTLSError:            "tls",
+	codeConnectionRefused:   "connection_refused",
+	codeUpstreamNotFound:    "4xx",
+	codeUpstreamClientError: "4xx",
+	codeUpstreamServerError: "5xx",
+	codeTooManyRedirects:    "redirect",
+	codeRedirectLoop:        "redirect",
+	codeFetchFailed:         "other",
+}
+
+// observePhase mesure la durée d'une phase d'extraction (métriques et logs)
+func observePhase(ctx context.Context, phase string, start time.Time) {
+	d := time.Since(start)
+	phaseDuration.WithLabelValues(phase).Observe(d.Seconds())
+	switch phase {
+	case "fetch":
+		traceFrom(ctx).addFetch(d)
+	case "parse":
+		traceFrom(ctx).addParse(d)
+	}
+}
+
+// recordUpstreamError compte l'erreur si elle concerne l'origine
+func recordUpstreamError(e *apiError) {
+	if category, ok := upstreamErrorCategories[e.Code]; ok {
+		upstreamErrors.WithLabelValues(category).Inc()
+	}
+}
+
+// metricsMiddleware compte les requêtes et mesure leur durée
+func (s *server) metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	requestsInFlight.Inc()
+	defer requestsInFlight.Dec()
+
+	c.Next()
+
+	// route déclarée (et non l'URL brute) pour borner la cardinalité
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	requestsTotal.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+	requestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+}
+
+// metricsHandler sert /metrics, protégé par METRICS_TOKEN s'il est défini
+func (s *server) metricsHandler() gin.HandlerFunc {
+	token := s.cfg.MetricsToken
+	h := promhttp.Handler()
+	return func(c *gin.Context) {
+		if token != "" {
+			got := c.GetHeader("Authorization")
+			if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+				respondError(c, newAPIError(http.StatusUnauthorized, codeUnauthorized, "invalid or missing metrics token"))
+				return
+			}
+		}
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
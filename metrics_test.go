@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrapeMetrics lit /metrics : valeur par série (nom et labels tels qu'exposés)
+func scrapeMetrics(t *testing.T, ts *httptest.Server, token string) map[string]float64 {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/metrics", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("/metrics: %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	series := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(line[i+1:], 64); err == nil {
+			series[line[:i]] = v
+		}
+	}
+	return series
+}
+
+func TestMetricsCountersMove(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", largePage)
+	mux.HandleFunc("/gone", http.NotFound)
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+	_, ts := newTestServer(t, nil)
+
+	ok := `http_requests_total{method="GET",route="/extract",status="200"}`
+	notFound := `http_requests_total{method="GET",route="/extract",status="404"}`
+	upstream4xx := `upstream_fetch_errors_total{category="4xx"}`
+	hits, misses := `cache_requests_total{result="hit"}`, `cache_requests_total{result="miss"}`
+	fetches := `extraction_phase_duration_seconds_count{phase="fetch"}`
+	parses := `extraction_phase_duration_seconds_count{phase="parse"}`
+	durations := `http_request_duration_seconds_count{route="/extract"}`
+
+	before := scrapeMetrics(t, ts, "")
+	apiGet(t, ts, "/extract?url="+origin.URL+"/page", nil)
+	apiGet(t, ts, "/extract?url="+origin.URL+"/page", nil) // servi par le cache
+	apiRequest(t, ts, http.MethodGet, "/extract?url="+origin.URL+"/gone", "", "")
+	after := scrapeMetrics(t, ts, "")
+
+	for series, want := range map[string]float64{
+		ok: 2, notFound: 1, upstream4xx: 1, hits: 1, misses: 2, fetches: 2, parses: 1, durations: 3,
+	} {
+		if got := after[series] - before[series]; got != want {
+			t.Errorf("%s moved by %v, want %v", series, got, want)
+		}
+	}
+	if _, ok := after["http_requests_in_flight"]; !ok {
+		t.Error("in-flight gauge missing")
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	_, ts := newTestServer(t, map[string]string{"METRICS_TOKEN": "scrape-secret"})
+	resp, err := ts.Client().Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without token: got %d", resp.StatusCode)
+	}
+	if series := scrapeMetrics(t, ts, "scrape-secret"); len(series) == 0 {
+		t.Error("no series with the token")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// version du pipeline d'extraction, renvoyée avec les blocs (include_offsets).
+// À incrémenter dès qu'un changement peut déplacer le texte produit : les
+// positions enregistrées par un client ne valent que pour une version.
+const pipelineVersion = "2026.10.1"
+
+// natures de bloc renvoyées
+const (
+	blockTypeParagraph = "paragraph"
+	blockTypeHeading   = "heading"
+	blockTypeListItem  = "list_item"
+	blockTypeQuote     = "blockquote"
+	blockTypeCode      = "code"
+)
+
+// TextBlock : bloc de clean_text, repéré par ses positions en runes, fin exclue :
+// clean_text[start:end] vaut Text. Les blocs sont séparés par "\n\n", les éléments
+// de liste consécutifs d'une même page par "\n".
+type TextBlock struct {
+	Type  string `json:"type"`
+	Level int    `json:"level,omitempty"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+func publicBlocks(blocks []textBlock) []TextBlock {
+	out := make([]TextBlock, 0, len(blocks))
+	for _, b := range blocks {
+		tb := TextBlock{Type: blockTypeParagraph, Text: b.text}
+		switch {
+		case b.kind == blockHeading:
+			tb.Type, tb.Level = blockTypeHeading, b.level
+		case b.kind == blockItem:
+			tb.Type = blockTypeListItem
+		case b.kind == blockPre:
+			tb.Type = blockTypeCode
+		case b.quote:
+			tb.Type = blockTypeQuote
+		}
+		out = append(out, tb)
+	}
+	return out
+}
+
+// locateBlocks calcule les positions des blocs dans text, dans l'ordre. Les
+// blocs doivent reconstituer text exactement (followPagination retire ceux
+// des paragraphes dédoublonnés) : au premier bloc introuvable, l'écart est
+// journalisé et seuls les blocs déjà placés sont renvoyés.
+func locateBlocks(text string, blocks []TextBlock) []TextBlock {
+	out := blocks[:0]
+	offset, runes := 0, 0 // position courante, en octets et en runes
+	for i, b := range blocks {
+		rest := text[offset:]
+		sep := ""
+		if offset > 0 {
+			switch {
+			case strings.HasPrefix(rest, "\n\n"+b.Text):
+				sep = "\n\n"
+			case strings.HasPrefix(rest, "\n"+b.Text):
+				sep = "\n"
+			}
+		}
+		// le bloc doit finir là où finit le texte ou commence le suivant
+		end := len(sep) + len(b.Text)
+		if !strings.HasPrefix(rest[len(sep):], b.Text) || (offset > 0 && sep == "") || (end < len(rest) && rest[end] != '\n') {
+			log.Printf("offsets: block %d of %d not found at rune %d of clean_text", i+1, len(blocks), runes)
+			return out
+		}
+		b.Start = runes + len(sep)
+		b.End = b.Start + utf8.RuneCountInString(b.Text)
+		offset += end
+		runes = b.End
+		out = append(out, b)
+	}
+	return out
+}
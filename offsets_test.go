@@ -0,0 +1,179 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// checkBlocks vérifie l'invariant documenté de TextBlock : chaque bloc vaut
+// text[start:end] (en runes), et les blocs joints par leurs séparateurs
+// ("\n\n", "\n" entre éléments de liste) redonnent text exactement
+func checkBlocks(t *testing.T, text string, blocks []TextBlock) {
+	t.Helper()
+	if len(blocks) == 0 {
+		t.Fatal("no blocks")
+	}
+	runes := []rune(text)
+	var rebuilt strings.Builder
+	pos := 0
+	for i, b := range blocks {
+		if b.Start < pos || b.End < b.Start || b.End > len(runes) {
+			t.Fatalf("block %d %q: [%d, %d) out of order or out of range (previous end %d, %d runes)", i, b.Text, b.Start, b.End, pos, len(runes))
+		}
+		sep := string(runes[pos:b.Start])
+		switch {
+		case i == 0 && sep != "":
+			t.Errorf("text starts with %q before the first block", sep)
+		case i > 0 && sep != "\n\n" && !(sep == "\n" && b.Type == blockTypeListItem && blocks[i-1].Type == blockTypeListItem):
+			t.Errorf("block %d %q: separator %q after a %s", i, b.Text, sep, blocks[i-1].Type)
+		}
+		if got := string(runes[b.Start:b.End]); got != b.Text || b.End-b.Start != utf8.RuneCountInString(b.Text) {
+			t.Errorf("block %d: text[%d:%d] = %q, want %q", i, b.Start, b.End, got, b.Text)
+		}
+		rebuilt.WriteString(sep + b.Text)
+		pos = b.End
+	}
+	if rebuilt.String() != text {
+		t.Errorf("blocks joined:\n%q\nclean_text:\n%q", rebuilt.String(), text)
+	}
+}
+
+func TestBlocksReconstructText(t *testing.T) {
+	e := newTestEngine(t, nil)
+	for _, name := range []string{
+		"news.html", "blog.html", "format.html", "meta.html", "heavynav.html",
+		"text/lists.html", "text/poetry.html", "text/nested-divs.html",
+		"boilerplate/local-news.html", "boilerplate/recipe-blog.html", "boilerplate/tech-review.html",
+		"byline/visible.html", "tables/layout.html", "offsets/part1.html", "offsets/part2.html",
+	} {
+		t.Run(name, func(t *testing.T) {
+			article := extractFixture(t, e, name, extractOptions{IncludeOffsets: true})
+			checkBlocks(t, article.CleanText, article.Blocks)
+			if article.Version != pipelineVersion {
+				t.Errorf("version %q, want %q", article.Version, pipelineVersion)
+			}
+		})
+	}
+}
+
+// positions en runes : accents, tiret cadratin et emoji comptent pour un
+func TestBlockOffsetsAreRunes(t *testing.T) {
+	article := extractFixture(t, newTestEngine(t, nil), "offsets/part1.html", extractOptions{IncludeOffsets: true})
+	blocks := map[string]TextBlock{}
+	for _, b := range article.Blocks {
+		if first := strings.Fields(b.Text)[0]; blocks[first].Text == "" {
+			blocks[first] = b
+		}
+	}
+	cafe, after := blocks["The"], blocks["-"]
+	if !strings.HasSuffix(cafe.Text, "🚲.") || cafe.End-cafe.Start != utf8.RuneCountInString(cafe.Text) || len(cafe.Text)-(cafe.End-cafe.Start) != 9 {
+		t.Errorf("block %q spans %d runes", cafe.Text, cafe.End-cafe.Start)
+	}
+	// le bloc suivant commence 9 octets plus loin qu'en runes : deux é, deux — et 🚲
+	if byteStart := strings.Index(article.CleanText, after.Text); after.Start != cafe.End+2 || byteStart != after.Start+9 {
+		t.Errorf("next block at rune %d (byte %d), after %d", after.Start, byteStart, cafe.End)
+	}
+	if got := string([]rune(article.CleanText)[after.Start:after.End]); got != after.Text {
+		t.Errorf("runes [%d:%d] = %q", after.Start, after.End, got)
+	}
+}
+
+func TestBlockTypes(t *testing.T) {
+	article := extractFixture(t, newTestEngine(t, nil), "text/lists.html", extractOptions{IncludeOffsets: true})
+	type kind struct {
+		Type  string
+		Level int
+	}
+	var got []kind
+	for _, b := range article.Blocks {
+		got = append(got, kind{b.Type, b.Level})
+	}
+	want := []kind{
+		// le <h1>, repris du titre, n'est pas répété dans le texte
+		{blockTypeParagraph, 0}, {blockTypeHeading, 2},
+		{blockTypeListItem, 0}, {blockTypeListItem, 0}, {blockTypeListItem, 0},
+		{blockTypeHeading, 3},
+		{blockTypeListItem, 0}, {blockTypeListItem, 0}, {blockTypeListItem, 0}, {blockTypeListItem, 0},
+		{blockTypeParagraph, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("block types %v\nwant %v", got, want)
+	}
+
+	plain := extractFixture(t, newTestEngine(t, nil), "text/lists.html", extractOptions{})
+	if plain.Blocks != nil || plain.Version != "" {
+		t.Errorf("blocks without include_offsets: %d, version %q", len(plain.Blocks), plain.Version)
+	}
+}
+
+// pages 2 et 3 recopient l'introduction (et la page 2 la liste de la page 1) :
+// leurs blocs disparaissent avec les paragraphes dédoublonnés, et les blocs
+// des trois pages reconstituent toujours clean_text
+func TestBlocksWithPagination(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	for _, format := range []string{formatText, formatMarkdown, formatHTML} {
+		t.Run(format, func(t *testing.T) {
+			var article Article
+			path := "/extract?include_offsets=true&follow_pagination=true&format=" + format + "&url=" + url.QueryEscape(origin.URL+"/offsets/part1.html")
+			if status := apiGet(t, ts, path, &article); status != http.StatusOK || article.PagesFetched != 3 {
+				t.Fatalf("got %d, %d pages", status, article.PagesFetched)
+			}
+			checkBlocks(t, article.CleanText, article.Blocks)
+
+			counts := map[string]int{}
+			for _, b := range article.Blocks {
+				counts[b.Text]++
+			}
+			for _, text := range []string{
+				"This guide to the towpath is published in three parts, and every part opens with this same short introduction.",
+				"- A puncture repair kit and a spare inner tube",
+			} {
+				if counts[text] != 1 {
+					t.Errorf("%q: %d blocks, want 1", text, counts[text])
+				}
+			}
+			// 6 blocs par page, 3 pour la page 3 ; la liste de la page 3 suit
+			// celle de la page 2, dans un autre paragraphe
+			types := map[string]int{}
+			for _, b := range article.Blocks {
+				types[b.Type]++
+			}
+			want := map[string]int{blockTypeParagraph: 6, blockTypeHeading: 2, blockTypeListItem: 6, blockTypeQuote: 1}
+			if !reflect.DeepEqual(types, want) || len(article.Blocks) != 15 {
+				t.Errorf("%d blocks %v, want 15 %v", len(article.Blocks), types, want)
+			}
+		})
+	}
+}
+
+// un bloc absent de text est une erreur du pipeline : locateBlocks s'arrête
+// au lieu d'écarter le bloc et de placer les suivants
+func TestLocateBlocksStopsAtMismatch(t *testing.T) {
+	tests := []struct {
+		name, text string
+		blocks     []string
+		located    int
+	}{
+		{"exact", "One.\n\n- a\n- b\n\nTwo.", []string{"One.", "- a", "- b", "Two."}, 4},
+		{"missing block", "One.\n\nTwo.", []string{"One.", "Dropped.", "Two."}, 1},
+		{"no separator", "One.Two.", []string{"One.", "Two."}, 0},
+		{"partial match", "One.\n\nTwo and more.", []string{"One.", "Two"}, 1},
+		{"wrong first block", "One.", []string{"Two."}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var blocks []TextBlock
+			for _, text := range tt.blocks {
+				blocks = append(blocks, TextBlock{Type: blockTypeParagraph, Text: text})
+			}
+			if got := locateBlocks(tt.text, blocks); len(got) != tt.located {
+				t.Errorf("located %d blocks, want %d: %+v", len(got), tt.located, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openapi.json est la description de référence de l'API : servie telle
+// quelle, elle sert aussi à valider paramètres et corps des requêtes, et
+// checkSpecRoutes refuse de démarrer si une route n'y figure pas
+//
+//go:embed openapi.json
+var openapiJSON []byte
+
+// page Swagger UI minimale (GET /docs), qui charge /openapi.json
+const docsPage = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>clean_web_article API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+<script>window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// sous-ensemble d'OpenAPI 3 utilisé par la validation
+type specSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Enum                 []any                  `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Properties           map[string]*specSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Items                *specSchema            `json:"items"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+}
+
+type specParameter struct {
+	Ref      string      `json:"$ref"`
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required"`
+	Schema   *specSchema `json:"schema"`
+}
+
+type specOperation struct {
+	Parameters  []specParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type openAPISpec struct {
+	Paths      map[string]map[string]*specOperation `json:"paths"`
+	Components struct {
+		Parameters      map[string]specParameter `json:"parameters"`
+		Schemas         map[string]*specSchema   `json:"schemas"`
+		SecuritySchemes map[string]struct {
+			In   string `json:"in"`
+			Name string `json:"name"`
+		} `json:"securitySchemes"`
+	} `json:"components"`
+
+	// opérations par "MÉTHODE /chemin/gin" ; paramètres de requête admis partout (clé d'API)
+	operations   map[string]*specOperation
+	securityKeys map[string]bool
+}
+
+var apiSpec *openAPISpec
+
+// loadAPISpec analyse openapi.json et résout les $ref des paramètres
+func loadAPISpec() error {
+	var spec openAPISpec
+	if err := json.Unmarshal(openapiJSON, &spec); err != nil {
+		return fmt.Errorf("openapi.json: %w", err)
+	}
+	spec.operations = map[string]*specOperation{}
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			for i, p := range op.Parameters {
+				if p.Ref == "" {
+					continue
+				}
+				resolved, ok := spec.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+				if !ok {
+					return fmt.Errorf("openapi.json: %s %s: unknown parameter %s", method, path, p.Ref)
+				}
+				op.Parameters[i] = resolved
+			}
+			spec.operations[strings.ToUpper(method)+" "+ginPath(path)] = op
+		}
+	}
+	spec.securityKeys = map[string]bool{}
+	for _, s := range spec.Components.SecuritySchemes {
+		if s.In == "query" {
+			spec.securityKeys[s.Name] = true
+		}
+	}
+	apiSpec = &spec
+	return nil
+}
+
+// ginPath convertit "/jobs/{id}" en "/jobs/:id"
+func ginPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			parts[i] = ":" + p[1:len(p)-1]
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// checkSpecRoutes vérifie que routes enregistrées et opérations décrites
+// se correspondent exactement
+func checkSpecRoutes(routes gin.RoutesInfo) error {
+	var problems []string
+	registered := map[string]bool{}
+	for _, r := range routes {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if apiSpec.operations[key] == nil {
+			problems = append(problems, key+" is not described in openapi.json")
+		}
+	}
+	for key := range apiSpec.operations {
+		if !registered[key] {
+			problems = append(problems, key+" is described in openapi.json but not routed")
+		}
+	}
+	sort.Strings(problems)
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *server) openapiHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openapiJSON)
+}
+
+func (s *server) docsHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
+
+// validateRequest confronte paramètres de requête et corps JSON à
+// l'opération décrite dans openapi.json. Doit être placé après
+// l'authentification : un client sans clé reçoit 401, pas 400.
+func (s *server) validateRequest(c *gin.Context) {
+	op := apiSpec.operations[c.Request.Method+" "+c.FullPath()]
+	if op == nil {
+		c.Next()
+		return
+	}
+	if err := apiSpec.validateQuery(op, c.Request.URL.Query()); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := apiSpec.validateBody(c, op, s.cfg.MaxUploadBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Next()
+}
+
+func (s *openAPISpec) validateQuery(op *specOperation, query map[string][]string) error {
+	params := map[string]specParameter{}
+	for _, p := range op.Parameters {
+		if p.In == "query" {
+			params[p.Name] = p
+		}
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names) // erreur stable quand plusieurs paramètres sont faux
+
+	for _, name := range names {
+		p, ok := params[name]
+		if !ok {
+			if s.securityKeys[name] {
+				continue
+			}
+			return unknownParameter(name, params)
+		}
+		for _, v := range query[name] {
+			if err := s.validateQueryValue(p, v); err != nil {
+				return err
+			}
+		}
+	}
+	for name, p := range params {
+		if p.Required && (len(query[name]) == 0 || query[name][0] == "") {
+			return invalidParameter(name, p.Schema, "missing required parameter %s", name)
+		}
+	}
+	return nil
+}
+
+// validateQueryValue : booléens "true" / "false", entiers bornés, énumérations ;
+// une valeur vide vaut absence (valeur par défaut du handler)
+func (s *openAPISpec) validateQueryValue(p specParameter, v string) error {
+	schema := s.resolve(p.Schema)
+	if v == "" || schema == nil {
+		return nil
+	}
+	var value any = v
+	switch schema.Type {
+	case "boolean":
+		if v != "true" && v != "false" {
+			return invalidParameter(p.Name, schema, "invalid value %q for %s: expected true or false", v, p.Name)
+		}
+		return nil
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(v, 64); err != nil || (schema.Type == "integer" && !isInteger(v)) {
+			return invalidParameter(p.Name, schema, "invalid value %q for %s: expected %s", v, p.Name, schema.Type)
+		}
+		value = json.Number(v)
+	}
+	return s.validateValue(p.Name, schema, value)
+}
+
+func isInteger(v string) bool {
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil
+}
+
+// validateBody valide un corps JSON ; les autres types (text/html) et le
+// JSON mal formé sont laissés au handler, qui a ses propres erreurs ; au-delà
+// de maxBytes, le handler répond 413
+func (s *openAPISpec) validateBody(c *gin.Context, op *specOperation, maxBytes int64) error {
+	if op.RequestBody == nil || c.Request.Body == nil {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	content, ok := op.RequestBody.Content["application/json"]
+	// une opération qui n'accepte que du JSON le décode quel que soit le Content-Type
+	if !ok || (mediaType != "application/json" && len(op.RequestBody.Content) > 1) {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+	if err != nil || int64(len(body)) > maxBytes {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if dec.Decode(&value) != nil {
+		return nil
+	}
+	return s.validateValue("", content.Schema, value)
+}
+
+func (s *openAPISpec) resolve(schema *specSchema) *specSchema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// validateValue vérifie une valeur JSON décodée (nombres en json.Number) ;
+// path nomme le champ fautif ("urls[2]", "headers.Accept")
+func (s *openAPISpec) validateValue(path string, schema *specSchema, value any) error {
+	schema = s.resolve(schema)
+	if schema == nil || value == nil {
+		return nil
+	}
+	name := path
+	if name == "" {
+		name = "body"
+	}
+	if !matchesType(schema.Type, value) {
+		return invalidParameter(name, schema, "invalid value for %s: expected %s", name, schema.Type)
+	}
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		return invalidParameter(name, schema, "invalid value %q for %s: expected one of %s", fmt.Sprint(value), name, strings.Join(enumValues(schema), ", "))
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		if (schema.Minimum != nil && f < *schema.Minimum) || (schema.Maximum != nil && f > *schema.Maximum) {
+			return invalidParameter(name, schema, "invalid value %s for %s: expected %s", v, name, describeRange(schema))
+		}
+	case []any:
+		if (schema.MinItems != nil && len(v) < *schema.MinItems) || (schema.MaxItems != nil && len(v) > *schema.MaxItems) {
+			return invalidParameter(name, schema, "%s has %d items: expected %s", name, len(v), describeItems(schema))
+		}
+		for i, item := range v {
+			if err := s.validateValue(fmt.Sprintf("%s[%d]", path, i), schema.Items, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, key := range schema.Required {
+			if _, ok := v[key]; !ok {
+				field := joinPath(path, key)
+				return invalidParameter(field, schema.Properties[key], "missing required field %s", field)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := joinPath(path, key)
+			if prop, ok := schema.Properties[key]; ok {
+				if err := s.validateValue(field, prop, v[key]); err != nil {
+					return err
+				}
+				continue
+			}
+			switch extra := bytes.TrimSpace(schema.AdditionalProperties); {
+			case string(extra) == "false":
+				return unknownField(field, key, schema)
+			case len(extra) > 0 && extra[0] == '{':
+				var additional specSchema
+				if json.Unmarshal(extra, &additional) == nil {
+					if err := s.validateValue(field, &additional, v[key]); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(typ string, value any) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "" || typ == "string"
+	case bool:
+		return typ == "" || typ == "boolean"
+	case json.Number:
+		if typ == "integer" {
+			return isInteger(v.String())
+		}
+		return typ == "" || typ == "number"
+	case []any:
+		return typ == "" || typ == "array"
+	case map[string]any:
+		return typ == "" || typ == "object"
+	}
+	return typ == ""
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func enumValues(schema *specSchema) []string {
+	var values []string
+	for _, e := range schema.Enum {
+		if v := fmt.Sprint(e); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func describeRange(schema *specSchema) string {
+	switch {
+	case schema.Minimum != nil && schema.Maximum != nil:
+		return fmt.Sprintf("%s between %g and %g", schema.Type, *schema.Minimum, *schema.Maximum)
+	case schema.Minimum != nil:
+		return fmt.Sprintf("%s >= %g", schema.Type, *schema.Minimum)
+	default:
+		return fmt.Sprintf("%s <= %g", schema.Type, *schema.Maximum)
+	}
+}
+
+func describeItems(schema *specSchema) string {
+	switch {
+	case schema.MinItems != nil && schema.MaxItems != nil:
+		return fmt.Sprintf("between %d and %d", *schema.MinItems, *schema.MaxItems)
+	case schema.MinItems != nil:
+		return fmt.Sprintf("at least %d", *schema.MinItems)
+	default:
+		return fmt.Sprintf("at most %d", *schema.MaxItems)
+	}
+}
+
+// invalidParameter : 400 INVALID_REQUEST nommant le paramètre, son type et ses valeurs admises
+func invalidParameter(name string, schema *specSchema, format string, args ...any) *apiError {
+	e := newAPIError(http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf(format, args...))
+	e.Parameter = name
+	if schema = apiSpec.resolve(schema); schema != nil {
+		e.Expected = schema.Type
+		e.Allowed = enumValues(schema)
+	}
+	return e
+}
+
+// unknownParameter suggère le paramètre connu le plus proche ("uri" -> "url")
+// et liste ceux que l'opération accepte
+func unknownParameter(name string, params map[string]specParameter) *apiError {
+	known := make([]string, 0, len(params))
+	for n := range params {
+		known = append(known, n)
+	}
+	sort.Strings(known)
+	msg := "unknown parameter " + name
+	if hint := closestName(name, known); hint != "" {
+		msg += " (did you mean " + hint + "?)"
+	}
+	e := newAPIError(http.StatusBadRequest, codeInvalidRequest, msg)
+	e.Parameter, e.Allowed = name, known
+	return e
+}
+
+func unknownField(field, key string, schema *specSchema) *apiError {
+	known := make([]string, 0, len(schema.Properties))
+	for n := range schema.Properties {
+		known = append(known, n)
+	}
+	sort.Strings(known)
+	msg := "unknown field " + field
+	if hint := closestName(key, known); hint != "" {
+		msg += " (did you mean " + hint + "?)"
+	}
+	e := newAPIError(http.StatusBadRequest, codeInvalidRequest, msg)
+	e.Parameter, e.Allowed = field, known
+	return e
+}
+
+// closestName : nom connu à distance d'édition 2 au plus, "" sinon
+func closestName(name string, known []string) string {
+	best, bestDist := "", 3
+	for _, k := range known {
+		if d := editDistance(strings.ToLower(name), k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
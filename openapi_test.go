@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// méthodes de gin.Context qui lisent un paramètre de requête
+var queryReaders = map[string]bool{"Query": true, "DefaultQuery": true, "GetQuery": true, "QueryArray": true, "GetQueryArray": true}
+
+// packageQueryReads analyse les sources du paquet : pour chaque fonction ou
+// méthode, les paramètres de requête lus directement et les fonctions appelées
+// (par nom, toutes receveuses confondues : une sur-approximation)
+func packageQueryReads(t *testing.T) (reads, calls map[string][]string) {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads, calls = map[string][]string{}, map[string][]string{}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				switch f := call.Fun.(type) {
+				case *ast.Ident:
+					calls[fn.Name.Name] = append(calls[fn.Name.Name], f.Name)
+				case *ast.SelectorExpr:
+					if queryReaders[f.Sel.Name] && len(call.Args) > 0 {
+						if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+							param, _ := strconv.Unquote(lit.Value)
+							reads[fn.Name.Name] = append(reads[fn.Name.Name], param)
+						}
+						return true
+					}
+					calls[fn.Name.Name] = append(calls[fn.Name.Name], f.Sel.Name)
+				}
+				return true
+			})
+		}
+	}
+	return reads, calls
+}
+
+// handlerQueryParams : paramètres lus par handler et les fonctions qu'il appelle
+func handlerQueryParams(handler string, reads, calls map[string][]string) []string {
+	seen := map[string]bool{}
+	params := map[string]bool{}
+	var visit func(string)
+	visit = func(fn string) {
+		if seen[fn] {
+			return
+		}
+		seen[fn] = true
+		for _, p := range reads[fn] {
+			params[p] = true
+		}
+		for _, callee := range calls[fn] {
+			visit(callee)
+		}
+	}
+	visit(handler)
+	return sortedKeys(params)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// paramètres lus par les handlers et décrits par openapi.json : les mêmes,
+// dans les deux sens, pour chaque route
+func TestHandlerQueryParamsMatchSpec(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	reads, calls := packageQueryReads(t)
+	checked := 0
+	for _, route := range s.routes().Routes() {
+		// "clean_web_article.(*server).extractHandler-fm"
+		_, method, ok := strings.Cut(route.Handler, "(*server).")
+		method = strings.TrimSuffix(method, "-fm")
+		if !ok || strings.Contains(method, ".") {
+			continue // handler construit (promhttp), sans paramètre
+		}
+		op := apiSpec.operations[route.Method+" "+route.Path]
+		checked++
+		spec := map[string]bool{}
+		for _, p := range op.Parameters {
+			if p.In == "query" {
+				spec[p.Name] = true
+			}
+		}
+		var read []string
+		for _, p := range handlerQueryParams(method, reads, calls) {
+			if !apiSpec.securityKeys[p] {
+				read = append(read, p)
+			}
+		}
+
+		for _, p := range read {
+			if !spec[p] {
+				t.Errorf("%s %s: %s reads %q, not described in openapi.json", route.Method, route.Path, method, p)
+			}
+		}
+		for _, p := range sortedKeys(spec) {
+			if !slices.Contains(read, p) {
+				t.Errorf("%s %s: %q described in openapi.json but never read by %s", route.Method, route.Path, p, method)
+			}
+		}
+	}
+	if checked < len(apiSpec.operations)-1 {
+		t.Errorf("only %d of %d routes checked", checked, len(apiSpec.operations))
+	}
+}
+
+// corps JSON décodés par les handlers, par schéma de openapi.json
+var bodyTypes = map[string]reflect.Type{
+	"BatchRequest":  reflect.TypeOf(batchRequest{}),
+	"DiffRequest":   reflect.TypeOf(diffRequest{}),
+	"UploadRequest": reflect.TypeOf(uploadRequest{}),
+}
+
+// compareBodyFields : champs JSON de typ et propriétés de schema, structures
+// imbriquées comprises
+func compareBodyFields(t *testing.T, path string, typ reflect.Type, schema *specSchema) {
+	t.Helper()
+	schema = apiSpec.resolve(schema)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	fields := map[string]bool{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = true
+		prop, ok := schema.Properties[name]
+		if !ok {
+			t.Errorf("%s: field %q decoded by the handler, not described in openapi.json", path, name)
+			continue
+		}
+		if ft := f.Type; (ft.Kind() == reflect.Struct || (ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct)) && apiSpec.resolve(prop).Properties != nil {
+			compareBodyFields(t, path+"."+name, ft, prop)
+		}
+	}
+	for name := range schema.Properties {
+		if !fields[name] {
+			t.Errorf("%s: field %q described in openapi.json but not decoded by the handler", path, name)
+		}
+	}
+}
+
+func TestHandlerBodiesMatchSpec(t *testing.T) {
+	for key, op := range apiSpec.operations {
+		if op.RequestBody == nil {
+			continue
+		}
+		content, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(content.Schema.Ref, "#/components/schemas/")
+		typ, ok := bodyTypes[name]
+		if !ok {
+			t.Errorf("%s: no handler type registered for schema %s", key, name)
+			continue
+		}
+		compareBodyFields(t, name, typ, content.Schema)
+	}
+}
+
+// erreur de validation : code, message et description du paramètre
+func validationError(t *testing.T, status int, body []byte) apiError {
+	t.Helper()
+	var resp struct {
+		Error apiError `json:"error"`
+	}
+	if status != http.StatusBadRequest || json.Unmarshal(body, &resp) != nil {
+		t.Fatalf("got %d %s, want 400", status, body)
+	}
+	if resp.Error.Code != codeInvalidRequest {
+		t.Errorf("code %s, want %s", resp.Error.Code, codeInvalidRequest)
+	}
+	return resp.Error
+}
+
+func TestQueryValidation(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	page := url.QueryEscape(origin.URL + "/news.html")
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+page+"&format=markdown&include_tables=true&respect_robots=false", &article); status != http.StatusOK || article.Title == "" {
+		t.Fatalf("valid request: %d %+v", status, article)
+	}
+
+	tests := []struct {
+		name, query        string
+		parameter, message string
+		expected           string
+		allowed            []string
+	}{
+		{"wrong boolean", "/extract?url=" + page + "&raw=yes", "raw", `invalid value "yes" for raw: expected true or false`, "boolean", nil},
+		{"wrong integer", "/summarize?url=" + page + "&sentences=three", "sentences", `invalid value "three" for sentences: expected integer`, "integer", nil},
+		{"unknown enum", "/extract?url=" + page + "&format=pdf", "format", `invalid value "pdf" for format: expected one of`, "string", []string{"text", "markdown", "html"}},
+		{"unknown parameter", "/extract?uri=" + page, "uri", "unknown parameter uri (did you mean url?)", "", nil},
+		{"missing required", "/extract?format=text", "url", "missing required parameter url", "string", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := apiRequest(t, ts, http.MethodGet, tt.query, "", "")
+			e := validationError(t, status, body)
+			if e.Parameter != tt.parameter || !strings.Contains(e.Message, tt.message) || e.Expected != tt.expected {
+				t.Errorf("got parameter=%q expected=%q %q", e.Parameter, e.Expected, e.Message)
+			}
+			for _, v := range tt.allowed {
+				if !slices.Contains(e.Allowed, v) {
+					t.Errorf("allowed %q lacks %q", e.Allowed, v)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyValidation(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	page := origin.URL + "/news.html"
+
+	status, body := apiRequest(t, ts, http.MethodPost, "/extract/batch", "application/json", `{"urls":["`+page+`"],"format":"markdown"}`)
+	if status != http.StatusOK {
+		t.Fatalf("valid body: %d %s", status, body)
+	}
+
+	tests := []struct {
+		name, body         string
+		parameter, message string
+		expected           string
+	}{
+		{"wrong type", `{"urls":"` + page + `"}`, "urls", "invalid value for urls: expected array", "array"},
+		{"wrong item type", `{"urls":["` + page + `", 3]}`, "urls[1]", "invalid value for urls[1]: expected string", "string"},
+		{"unknown enum", `{"urls":["` + page + `"],"format":"pdf"}`, "format", `invalid value "pdf" for format: expected one of`, "string"},
+		{"unknown field", `{"urls":["` + page + `"],"foramt":"text"}`, "foramt", "unknown field foramt (did you mean format?)", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := apiRequest(t, ts, http.MethodPost, "/extract/batch", "application/json", tt.body)
+			e := validationError(t, status, body)
+			if e.Parameter != tt.parameter || !strings.Contains(e.Message, tt.message) || e.Expected != tt.expected {
+				t.Errorf("got parameter=%q expected=%q %q", e.Parameter, e.Expected, e.Message)
+			}
+		})
+	}
+}
+
+func TestSpecServed(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	status, body := apiRequest(t, ts, http.MethodGet, "/openapi.json", "", "")
+	var doc map[string]any
+	if status != http.StatusOK || json.Unmarshal(body, &doc) != nil || doc["openapi"] == nil {
+		t.Fatalf("GET /openapi.json: %d", status)
+	}
+	if status, body := apiRequest(t, ts, http.MethodGet, "/docs", "", ""); status != http.StatusOK || !strings.Contains(string(body), "/openapi.json") {
+		t.Errorf("GET /docs: %d", status)
+	}
+	resp, err := ts.Client().Get(ts.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /docs without a key: %d, want 401", resp.StatusCode)
+	}
+}
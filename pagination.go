@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// au-delà de cette part de paragraphes déjà vus, la page est un doublon
+const maxPageOverlap = 0.8
+
+// libellés usuels des liens "page suivante"
+var nextPageText = regexp.MustCompile(`(?i)^(next|next page|suivant|suivante|page suivante|siguiente|weiter|nächste seite|»|›|>|→)\s*[»›>→]?$`)
+
+// segment de chemin numérique, ex. /article/2/
+var pathPageNumber = regexp.MustCompile(`/(\d{1,3})/?$`)
+
+// findNextPage cherche l'URL de la page suivante sur le même hôte :
+// <link rel="next">, lien rel=next ou libellé "suivant", puis ?page=N+1 et /N+1/
+func findNextPage(doc *goquery.Document, pageURL string) string {
+	current, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	base := documentBase(doc, pageURL)
+
+	sameHost := func(href string) string {
+		if href = strings.TrimSpace(href); href == "" || strings.HasPrefix(href, "#") {
+			return ""
+		}
+		u := resolveAgainst(base, href)
+		parsed, err := url.Parse(u)
+		if err != nil || !strings.EqualFold(parsed.Host, current.Host) {
+			return ""
+		}
+		parsed.Fragment = ""
+		if parsed.String() == stripFragment(current) {
+			return ""
+		}
+		return parsed.String()
+	}
+
+	if next := sameHost(doc.Find(`link[rel~="next"]`).First().AttrOr("href", "")); next != "" {
+		return next
+	}
+	if next := sameHost(doc.Find(`a[rel~="next"]`).First().AttrOr("href", "")); next != "" {
+		return next
+	}
+
+	var found string
+	doc.Find("a[href]").EachWithBreak(func(i int, a *goquery.Selection) bool {
+		label := strings.TrimSpace(a.Text())
+		if label == "" {
+			label = a.AttrOr("aria-label", "")
+		}
+		if nextPageText.MatchString(label) {
+			found = sameHost(a.AttrOr("href", ""))
+		}
+		return found == ""
+	})
+	if found != "" {
+		return found
+	}
+
+	// motifs d'URL : on ne retient le candidat que s'il est lié depuis la page
+	candidates := make(map[string]bool)
+	for _, c := range nextPageCandidates(current) {
+		candidates[c] = true
+	}
+	doc.Find("a[href]").EachWithBreak(func(i int, a *goquery.Selection) bool {
+		if u := sameHost(a.AttrOr("href", "")); candidates[u] {
+			found = u
+		}
+		return found == ""
+	})
+	return found
+}
+
+// nextPageCandidates construit ?page=N+1 et /N+1/ à partir de l'URL courante
+func nextPageCandidates(current *url.URL) []string {
+	var out []string
+
+	q := current.Query()
+	for _, key := range []string{"page", "p", "pg"} {
+		n := 1
+		if v := q.Get(key); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil {
+				continue
+			}
+		}
+		next := *current
+		nq := current.Query()
+		nq.Set(key, strconv.Itoa(n+1))
+		next.RawQuery = nq.Encode()
+		next.Fragment = ""
+		out = append(out, next.String())
+	}
+
+	next := *current
+	next.Fragment = ""
+	if m := pathPageNumber.FindStringSubmatchIndex(current.Path); m != nil {
+		n, _ := strconv.Atoi(current.Path[m[2]:m[3]])
+		next.Path = current.Path[:m[2]] + strconv.Itoa(n+1) + current.Path[m[3]:]
+	} else {
+		next.Path = strings.TrimSuffix(current.Path, "/") + "/2/"
+	}
+	out = append(out, next.String())
+	return out
+}
+
+func stripFragment(u *url.URL) string {
+	c := *u
+	c.Fragment = ""
+	return c.String()
+}
+
+// followPagination télécharge les pages suivantes et les ajoute à l'article.
+// Titre et métadonnées restent ceux de la page 1 ; une erreur arrête le suivi
+// sans faire échouer l'extraction. Au plus MAX_PAGES pages, la première comprise.
+func (e *engine) followPagination(ctx context.Context, article *Article, opts extractOptions) {
+	defer article.computeStats(e.cfg)
+
+	seen := make(map[string]bool)
+	for _, p := range splitParagraphs(article.CleanText) {
+		seen[p] = true
+	}
+	visited := map[string]bool{article.FinalURL: true}
+	seenImages := make(map[string]bool)
+	for _, img := range article.Images {
+		seenImages[img.URL] = true
+	}
+
+	next := article.nextPage
+	for next != "" && !visited[next] && article.PagesFetched < e.cfg.MaxPages {
+		visited[next] = true
+
+		resp, body, _, err := e.fetchPage(ctx, next, opts.Fetch)
+		if err != nil {
+			log.Printf("pagination stopped at %s: %v", next, err)
+			return
+		}
+		// une redirection ne doit pas non plus quitter l'hôte de l'article
+		if host, first := resp.Request.URL.Hostname(), articleHost(article.FinalURL); !strings.EqualFold(host, first) {
+			log.Printf("pagination stopped at %s: redirected to another host (%s)", next, host)
+			return
+		}
+		finalURL := resp.Request.URL.String()
+		page, err := e.extractHTML(ctx, body, resp.Header.Get("Content-Type"), finalURL, opts)
+		if err != nil {
+			log.Printf("pagination stopped at %s: %v", next, err)
+			return
+		}
+
+		// retire les paragraphes d'introduction recopiés sur chaque page, et
+		// leurs blocs
+		paragraphs := splitParagraphs(page.CleanText)
+		var fresh []string
+		for _, p := range paragraphs {
+			if !seen[p] {
+				fresh = append(fresh, p)
+			}
+		}
+		var freshBlocks []TextBlock
+		for _, group := range blockParagraphs(page.Blocks) {
+			if !seen[paragraphText(group)] {
+				freshBlocks = append(freshBlocks, group...)
+			}
+		}
+		if len(paragraphs) == 0 || float64(len(paragraphs)-len(fresh))/float64(len(paragraphs)) > maxPageOverlap {
+			return
+		}
+		for _, p := range fresh {
+			seen[p] = true
+		}
+
+		article.CleanText += "\n\n" + strings.Join(fresh, "\n\n")
+		switch article.Format {
+		case formatText:
+			article.Content = article.CleanText
+		case formatHTML:
+			article.Content += "\n" + page.Content
+		default:
+			article.Content += "\n\n" + page.Content
+		}
+		article.Blocks = append(article.Blocks, freshBlocks...)
+		for _, link := range page.Links {
+			article.Links = addLink(article.Links, link, e.cfg.MaxLinks)
+		}
+		for _, table := range page.Tables {
+			if len(article.Tables) < e.cfg.MaxTables {
+				article.Tables = append(article.Tables, table)
+			}
+		}
+		for _, img := range page.Images {
+			if !seenImages[img.URL] {
+				seenImages[img.URL] = true
+				article.Images = append(article.Images, img)
+			}
+		}
+		article.PagesFetched++
+		article.PageURLs = append(article.PageURLs, finalURL)
+		visited[finalURL] = true
+		next = page.nextPage
+	}
+}
+
+func articleHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func splitParagraphs(text string) []string {
+	var out []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// blockParagraphs regroupe les blocs par paragraphe de clean_text : les
+// éléments de liste consécutifs partagent un paragraphe (cleanForLLM)
+func blockParagraphs(blocks []TextBlock) [][]TextBlock {
+	var groups [][]TextBlock
+	for i, b := range blocks {
+		if i > 0 && b.Type == blockTypeListItem && blocks[i-1].Type == blockTypeListItem {
+			groups[len(groups)-1] = append(groups[len(groups)-1], b)
+			continue
+		}
+		groups = append(groups, []TextBlock{b})
+	}
+	return groups
+}
+
+// paragraphText : texte du paragraphe formé par group, tel que splitParagraphs
+// le découpe
+func paragraphText(group []TextBlock) string {
+	texts := make([]string, len(group))
+	for i, b := range group {
+		texts[i] = b.Text
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
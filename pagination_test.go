@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// intro recopiée en tête de chaque page par le CMS
+const paginationIntro = "This long read was published in three parts, and each part repeats this short introduction."
+
+// sitePage écrit une page d'article dont le <head> contient head et le corps les paragraphes
+func sitePage(w http.ResponseWriter, title, head string, paragraphs ...string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><head><title>%s</title>%s</head><body><article>", title, head)
+	for _, p := range paragraphs {
+		fmt.Fprintf(w, "<p>%s</p>", p)
+	}
+	fmt.Fprint(w, "</article></body></html>")
+}
+
+// partParagraphs : paragraphes propres à la partie n
+func partParagraphs(n int) []string {
+	return []string{
+		fmt.Sprintf("Part %d begins here, with a paragraph that only appears on this page of the story.", n),
+		fmt.Sprintf("Part %d goes on with more details, long enough to be kept by the extractor every time.", n),
+	}
+}
+
+// paginatedSite : /story -> ?page=2 (<link rel=next>) -> /story/3/ (lien
+// « Next page ») ; /other renvoie vers un autre hôte, /hop vers une page 2
+// du même hôte redirigée (302) vers un autre, /dup vers une page presque
+// identique
+func paginatedSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sitePage(w, "Elsewhere", "", partParagraphs(9)...)
+	}))
+	t.Cleanup(elsewhere.Close)
+	// autre nom d'hôte : les deux serveurs écoutent sur 127.0.0.1
+	elsewhereURL := strings.Replace(elsewhere.URL, "127.0.0.1", "localhost", 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/story", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			body := append([]string{paginationIntro}, partParagraphs(2)...)
+			body = append(body, `<a href="/story/3/">Next page</a>`)
+			sitePage(w, "Story, part 2", "", body...)
+			return
+		}
+		sitePage(w, "The story", `<meta name="author" content="Jane Pager"><link rel="next" href="/story?page=2">`,
+			append([]string{paginationIntro}, partParagraphs(1)...)...)
+	})
+	mux.HandleFunc("/story/3/", func(w http.ResponseWriter, r *http.Request) {
+		sitePage(w, "Story, part 3", "", append([]string{paginationIntro}, partParagraphs(3)...)...)
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		sitePage(w, "Other", `<link rel="next" href="`+elsewhere.URL+`/page2">`, partParagraphs(1)...)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			http.Redirect(w, r, elsewhereURL+"/page2", http.StatusFound)
+			return
+		}
+		sitePage(w, "Hop", `<link rel="next" href="/hop?page=2">`, partParagraphs(1)...)
+	})
+	mux.HandleFunc("/dup", func(w http.ResponseWriter, r *http.Request) {
+		sitePage(w, "Dup", `<link rel="next" href="/dup/2/">`, partParagraphs(1)...)
+	})
+	mux.HandleFunc("/dup/2/", func(w http.ResponseWriter, r *http.Request) {
+		sitePage(w, "Dup 2", `<link rel="next" href="/dup/3/">`, partParagraphs(1)...)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestFollowPaginationStitchesThreePages(t *testing.T) {
+	site := paginatedSite(t)
+	_, ts := newTestServer(t, nil)
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?follow_pagination=true&url="+url.QueryEscape(site.URL+"/story"), &article); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	want := []string{paginationIntro}
+	for n := 1; n <= 3; n++ {
+		want = append(want, partParagraphs(n)...)
+	}
+	if got := splitParagraphs(article.CleanText); !reflect.DeepEqual(got, want) {
+		t.Errorf("paragraphs:\n%q\nwant:\n%q", got, want)
+	}
+	if article.PagesFetched != 3 {
+		t.Errorf("pages_fetched %d", article.PagesFetched)
+	}
+	wantURLs := []string{site.URL + "/story", site.URL + "/story?page=2", site.URL + "/story/3/"}
+	if !reflect.DeepEqual(article.PageURLs, wantURLs) {
+		t.Errorf("page_urls %q", article.PageURLs)
+	}
+	// titre et métadonnées de la page 1
+	if article.Title != "The story" || article.Author != "Jane Pager" {
+		t.Errorf("title %q, author %q", article.Title, article.Author)
+	}
+	words, _ := countWords(article.CleanText)
+	if article.WordCount != words {
+		t.Errorf("word_count %d for %d words", article.WordCount, words)
+	}
+}
+
+func TestFollowPaginationLimits(t *testing.T) {
+	site := paginatedSite(t)
+	tests := []struct {
+		name, path string
+		env        map[string]string
+		pages      int
+	}{
+		{"max pages", "/story", map[string]string{"MAX_PAGES": "2"}, 2},
+		{"other host", "/other", nil, 1},
+		{"redirect to another host", "/hop", nil, 1},
+		{"overlapping page", "/dup", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newTestServer(t, tt.env)
+			var article Article
+			apiGet(t, ts, "/extract?follow_pagination=true&url="+url.QueryEscape(site.URL+tt.path), &article)
+			if article.PagesFetched != tt.pages || len(article.PageURLs) != tt.pages {
+				t.Errorf("pages_fetched %d, page_urls %q, want %d", article.PagesFetched, article.PageURLs, tt.pages)
+			}
+			if strings.Contains(article.CleanText, "Part 9") {
+				t.Error("content from another host")
+			}
+		})
+	}
+}
+
+// sans follow_pagination, la page 1 seule
+func TestPaginationIsOptIn(t *testing.T) {
+	site := paginatedSite(t)
+	_, ts := newTestServer(t, nil)
+	var article Article
+	apiGet(t, ts, "/extract?url="+url.QueryEscape(site.URL+"/story"), &article)
+	if article.PagesFetched != 1 || strings.Contains(article.CleanText, "Part 2") {
+		t.Errorf("pages_fetched %d:\n%s", article.PagesFetched, article.CleanText)
+	}
+}
+
+func TestNextPageCandidates(t *testing.T) {
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://example.com/a?page=2", []string{"https://example.com/a?page=3", "https://example.com/a?p=2&page=2", "https://example.com/a?page=2&pg=2", "https://example.com/a/2/?page=2"}},
+		{"https://example.com/story/4/", []string{"https://example.com/story/4/?page=2", "https://example.com/story/4/?p=2", "https://example.com/story/4/?pg=2", "https://example.com/story/5/"}},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := nextPageCandidates(u); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("nextPageCandidates(%s) = %q", tt.url, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostSlot : état d'un hôte
+type hostSlot struct {
+	sem      chan struct{}
+	next     time.Time // date au plus tôt de la prochaine requête
+	lastSeen time.Time
+}
+
+// politeLimiter espace et plafonne les fetchs vers un même hôte, quel que
+// soit le client ou le lot qui les demande : concurrency fetchs simultanés
+// (HOST_CONCURRENCY), delay entre deux requêtes (HOST_MIN_DELAY)
+type politeLimiter struct {
+	mu          sync.Mutex
+	concurrency int
+	delay       time.Duration
+	hosts       map[string]*hostSlot
+}
+
+func newHostLimiter(concurrency int, delay time.Duration) *politeLimiter {
+	return &politeLimiter{
+		concurrency: concurrency,
+		delay:       delay,
+		hosts:       make(map[string]*hostSlot),
+	}
+}
+
+// acquire attend une place libre pour host puis le délai minimal depuis la
+// requête précédente. release doit être appelé une fois le corps lu.
+func (l *politeLimiter) acquire(ctx context.Context, host string) (release func(), err error) {
+	l.mu.Lock()
+	s, ok := l.hosts[host]
+	if !ok {
+		s = &hostSlot{sem: make(chan struct{}, l.concurrency)}
+		l.hosts[host] = s
+	}
+	s.lastSeen = time.Now()
+	l.mu.Unlock()
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release = func() { <-s.sem }
+
+	// réserve le créneau suivant avant d'attendre
+	l.mu.Lock()
+	now := time.Now()
+	start := now
+	if s.next.After(now) {
+		start = s.next
+	}
+	s.next = start.Add(l.delay)
+	l.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return release, nil
+}
+
+// cleanup supprime les hôtes inactifs depuis plus de idle
+func (l *politeLimiter) cleanup(idle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for host, s := range l.hosts {
+		if len(s.sem) == 0 && now.Sub(s.lastSeen) > idle && now.After(s.next) {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+// startCleanup nettoie périodiquement les hôtes inactifs
+func (l *politeLimiter) startCleanup(every, idle time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			l.cleanup(idle)
+		}
+	}()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// politeOrigin note l'heure d'arrivée de chaque requête et le plus grand
+// nombre de requêtes servies en même temps
+type politeOrigin struct {
+	mu       sync.Mutex
+	active   int
+	peak     int
+	arrivals []time.Time
+	hold     time.Duration
+}
+
+func (o *politeOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	o.active++
+	o.peak = max(o.peak, o.active)
+	o.arrivals = append(o.arrivals, time.Now())
+	o.mu.Unlock()
+
+	time.Sleep(o.hold)
+	largePage(w, r)
+
+	o.mu.Lock()
+	o.active--
+	o.mu.Unlock()
+}
+
+func (o *politeOrigin) stats() (int, []time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	arrivals := append([]time.Time(nil), o.arrivals...)
+	sort.Slice(arrivals, func(i, j int) bool { return arrivals[i].Before(arrivals[j]) })
+	return o.peak, arrivals
+}
+
+func samePageURLs(base string, n int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = base + "/page/" + strings.Repeat("x", i+1)
+	}
+	return urls
+}
+
+func TestSameHostBatchIsSerialized(t *testing.T) {
+	origin := &politeOrigin{hold: 10 * time.Millisecond}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	delay := 40 * time.Millisecond
+	_, ts := newTestServer(t, map[string]string{
+		"HOST_CONCURRENCY":  "1",
+		"HOST_MIN_DELAY":    delay.String(),
+		"BATCH_CONCURRENCY": "5",
+	})
+
+	status, body := postBatch(t, ts, samePageURLs(originTS.URL, 5))
+	if status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	for i, r := range decodeBatch(t, body) {
+		if r.Error != nil {
+			t.Fatalf("result %d: %+v", i, r.Error)
+		}
+	}
+	peak, arrivals := origin.stats()
+	if peak != 1 || len(arrivals) != 5 {
+		t.Fatalf("peak concurrency %d over %d requests, want 1 over 5", peak, len(arrivals))
+	}
+	// le délai sépare les départs ; à l'arrivée, la latence locale décale
+	// un écart isolé mais pas la durée totale du lot
+	for i := 1; i < len(arrivals); i++ {
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < delay/2 {
+			t.Errorf("requests %d and %d only %s apart, want about %s", i-1, i, gap, delay)
+		}
+	}
+	if span := arrivals[len(arrivals)-1].Sub(arrivals[0]); span < 4*delay-delay/4 {
+		t.Errorf("5 requests over %s, want at least %s", span, 4*delay)
+	}
+}
+
+func TestHostConcurrencyCap(t *testing.T) {
+	origin := &politeOrigin{hold: 50 * time.Millisecond}
+	originTS := httptest.NewServer(origin)
+	defer originTS.Close()
+	_, ts := newTestServer(t, map[string]string{"BATCH_CONCURRENCY": "8"})
+
+	postBatch(t, ts, samePageURLs(originTS.URL, 8))
+	if peak, arrivals := origin.stats(); peak != 2 || len(arrivals) != 8 {
+		t.Errorf("peak concurrency %d over %d requests, want the default 2 over 8", peak, len(arrivals))
+	}
+}
+
+// les hôtes sont limités séparément
+func TestHostLimiterIsPerHost(t *testing.T) {
+	l := newHostLimiter(1, time.Hour)
+	ctx := context.Background()
+	release, err := l.acquire(ctx, "a.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	other, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	releaseB, err := l.acquire(other, "b.example")
+	if err != nil {
+		t.Fatalf("b.example waited on a.example: %v", err)
+	}
+	releaseB()
+
+	// a.example : place prise, puis délai d'une heure
+	busy, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(busy, "a.example"); err == nil {
+		t.Error("second a.example fetch did not wait")
+	}
+}
+
+func TestHostLimiterCleanup(t *testing.T) {
+	l := newHostLimiter(2, 0)
+	release, _ := l.acquire(context.Background(), "idle.example")
+	release()
+	busy, _ := l.acquire(context.Background(), "busy.example")
+	defer busy()
+
+	time.Sleep(5 * time.Millisecond)
+	l.cleanup(time.Millisecond)
+	if _, ok := l.hosts["idle.example"]; ok {
+		t.Error("idle host kept")
+	}
+	if _, ok := l.hosts["busy.example"]; !ok {
+		t.Error("host with a fetch in progress removed")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true}
+
+var proxyDefaultPorts = map[string]string{"http": "80", "https": "443", "socks5": "1080"}
+
+type proxyKey struct{}
+
+// configureProxy lit OUTBOUND_PROXY, sinon HTTP_PROXY / HTTPS_PROXY / NO_PROXY.
+// Les proxys de l'opérateur sont de confiance : eux seuls peuvent être sur
+// un réseau privé.
+func (e *engine) configureProxy() error {
+	if e.cfg.OutboundProxy != "" {
+		u, err := parseProxyURL(e.cfg.OutboundProxy)
+		if err != nil {
+			return fmt.Errorf("OUTBOUND_PROXY: %w", err)
+		}
+		e.proxy = func(*url.URL) (*url.URL, error) { return u, nil }
+		e.trustedProxies[proxyAddr(u)] = true
+		return nil
+	}
+
+	env := httpproxy.FromEnvironment()
+	e.proxy = env.ProxyFunc()
+	for _, raw := range []string{env.HTTPProxy, env.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw // même tolérance que httpproxy
+		}
+		if u, err := url.Parse(raw); err == nil && u.Host != "" {
+			e.trustedProxies[proxyAddr(u)] = true
+		}
+	}
+	return nil
+}
+
+// parseProxyURL accepte http://, https:// et socks5://, identifiants compris
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Hostname() == "" {
+		return nil, errors.New("invalid proxy url")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !proxySchemes[u.Scheme] {
+		return nil, errors.New("unsupported proxy scheme " + u.Scheme)
+	}
+	return u, nil
+}
+
+// proxyAddr : adresse composée par le transport pour joindre le proxy
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = proxyDefaultPorts[strings.ToLower(u.Scheme)]
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// validateRequestProxy vérifie le paramètre proxy ; refusé sans ALLOW_REQUEST_PROXY
+func validateRequestProxy(raw string, allowed bool) error {
+	if raw == "" {
+		return nil
+	}
+	if !allowed {
+		return newAPIError(http.StatusBadRequest, codeInvalidRequest, "per-request proxies are disabled (ALLOW_REQUEST_PROXY is not set)")
+	}
+	if _, err := parseProxyURL(raw); err != nil {
+		return newAPIError(http.StatusBadRequest, codeInvalidRequest, err.Error())
+	}
+	return nil
+}
+
+// withProxy fait passer les requêtes créées avec ctx par le proxy donné
+func withProxy(ctx context.Context, raw string) context.Context {
+	if raw == "" {
+		return ctx
+	}
+	u, err := parseProxyURL(raw)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyKey{}, u)
+}
+
+// proxyFor : proxy de la requête, sinon proxy global (Transport.Proxy)
+func (e *engine) proxyFor(req *http.Request) (*url.URL, error) {
+	if u, ok := req.Context().Value(proxyKey{}).(*url.URL); ok {
+		return u, nil
+	}
+	return e.proxy(req.URL)
+}
+
+// proxyDialer : les proxys de confiance sont joints sans le contrôle SSRF
+// (un proxy d'entreprise est souvent sur une adresse privée) ; les proxys
+// fournis par le client et les origines restent contrôlés
+func (e *engine) proxyDialer(direct, trusted *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if e.trustedProxies[strings.ToLower(addr)] {
+			return trusted.DialContext(ctx, network, addr)
+		}
+		return direct.DialContext(ctx, network, addr)
+	}
+}
+
+// isProxyError : échec de connexion ou de négociation avec le proxy
+func isProxyError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks"))
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testProxy : proxy HTTP de transfert ; il note les cibles et l'en-tête
+// Proxy-Authorization. Une cible dans pages est servie par le proxy lui-même
+// (adresses publiques fictives, injoignables depuis les tests).
+type testProxy struct {
+	mu      sync.Mutex
+	targets []string
+	auth    []string
+	pages   map[string]http.HandlerFunc
+}
+
+func newTestProxy(t *testing.T) (*testProxy, *httptest.Server) {
+	t.Helper()
+	p := &testProxy{pages: make(map[string]http.HandlerFunc)}
+	ts := httptest.NewServer(p)
+	t.Cleanup(ts.Close)
+	return p, ts
+}
+
+func (p *testProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.IsAbs() {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, r.URL.Host)
+	p.auth = append(p.auth, r.Header.Get("Proxy-Authorization"))
+	page := p.pages[r.URL.Host]
+	p.mu.Unlock()
+	if page != nil {
+		page(w, r)
+		return
+	}
+
+	req, _ := http.NewRequestWithContext(r.Context(), r.Method, r.URL.String(), r.Body)
+	req.Header = r.Header.Clone()
+	req.Header.Del("Proxy-Authorization")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func (p *testProxy) seen() ([]string, []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...), append([]string(nil), p.auth...)
+}
+
+// startSOCKS5 démarre un serveur SOCKS5 minimal (CONNECT, avec ou sans
+// identifiants) et retourne son adresse et le nombre de connexions relayées
+func startSOCKS5(t *testing.T, user, pass string) (string, func() int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	relayed := 0
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				target, ok := socks5Handshake(conn, user, pass)
+				if !ok {
+					return
+				}
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				mu.Lock()
+				relayed++
+				mu.Unlock()
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return relayed
+	}
+}
+
+// socks5Handshake négocie l'authentification et lit la cible du CONNECT (RFC 1928, 1929)
+func socks5Handshake(conn net.Conn, user, pass string) (string, bool) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil || head[0] != 5 {
+		return "", false
+	}
+	methods := make([]byte, head[1])
+	io.ReadFull(conn, methods)
+	if user == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		ver := make([]byte, 2)
+		io.ReadFull(conn, ver)
+		gotUser := make([]byte, ver[1])
+		io.ReadFull(conn, gotUser)
+		n := make([]byte, 1)
+		io.ReadFull(conn, n)
+		gotPass := make([]byte, n[0])
+		io.ReadFull(conn, gotPass)
+		if string(gotUser) != user || string(gotPass) != pass {
+			conn.Write([]byte{1, 1})
+			return "", false
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+		return "", false
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		n := make([]byte, 1)
+		io.ReadFull(conn, n)
+		name := make([]byte, n[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return "", false
+	}
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), true
+}
+
+func TestOutboundProxyCarriesTraffic(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	proxy, proxyServer := newTestProxy(t)
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	proxyURL.User = url.UserPassword("egress", "s3cret")
+	_, ts := newTestServer(t, map[string]string{"OUTBOUND_PROXY": proxyURL.String()})
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(origin.URL+"/story"), &article); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if article.WordCount == 0 {
+		t.Error("empty article through the proxy")
+	}
+	targets, auth := proxy.seen()
+	if len(targets) != 1 || targets[0] != strings.TrimPrefix(origin.URL, "http://") {
+		t.Errorf("proxy targets %v", targets)
+	}
+	// egress:s3cret en Basic
+	if len(auth) != 1 || auth[0] != "Basic ZWdyZXNzOnMzY3JldA==" {
+		t.Errorf("Proxy-Authorization %v", auth)
+	}
+}
+
+// HTTP_PROXY est honoré et, comme proxy de l'opérateur, peut être sur un
+// réseau privé même sans ALLOW_PRIVATE ; NO_PROXY l'écarte
+func TestEnvironmentProxy(t *testing.T) {
+	proxy, proxyServer := newTestProxy(t)
+	proxy.pages["93.184.215.14"] = largePage
+	t.Setenv("HTTP_PROXY", proxyServer.URL)
+	t.Setenv("NO_PROXY", "internal.example")
+	s, ts := newTestServer(t, map[string]string{"ALLOW_PRIVATE": "false"})
+
+	if status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape("http://93.184.215.14/story"), "", ""); status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if targets, _ := proxy.seen(); len(targets) != 1 || targets[0] != "93.184.215.14" {
+		t.Errorf("proxy targets %v", targets)
+	}
+
+	bypass, _ := url.Parse("http://internal.example/page")
+	if u, err := s.engine.proxy(bypass); u != nil || err != nil {
+		t.Errorf("NO_PROXY host proxied through %v (%v)", u, err)
+	}
+}
+
+func TestRequestProxyParam(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	global, globalServer := newTestProxy(t)
+	perRequest, perRequestServer := newTestProxy(t)
+	_, ts := newTestServer(t, map[string]string{"OUTBOUND_PROXY": globalServer.URL, "ALLOW_REQUEST_PROXY": "true"})
+
+	proxyURL, _ := url.Parse(perRequestServer.URL)
+	proxyURL.User = url.UserPassword("rotating", "hunter2")
+	var resp struct {
+		Fetch fetchDebug `json:"fetch"`
+	}
+	path := "/extract?url=" + url.QueryEscape(origin.URL+"/a") + "&proxy=" + url.QueryEscape(proxyURL.String()) + "&debug=true"
+	status, body := apiRequest(t, ts, http.MethodGet, path, "", "")
+	if status != http.StatusOK {
+		t.Fatalf("GET: got %d %s", status, body)
+	}
+	json.Unmarshal(body, &resp)
+	if strings.Contains(string(body), "hunter2") || !strings.Contains(resp.Fetch.Proxy, "rotating") {
+		t.Errorf("fetch.proxy %q (password must be redacted)", resp.Fetch.Proxy)
+	}
+
+	// même paramètre dans le corps d'un batch
+	batch := `{"urls":["` + origin.URL + `/b"],"proxy":"` + perRequestServer.URL + `"}`
+	if status, body := apiRequest(t, ts, http.MethodPost, "/extract/batch", "application/json", batch); status != http.StatusOK {
+		t.Fatalf("batch: got %d %s", status, body)
+	}
+
+	if targets, _ := perRequest.seen(); len(targets) != 2 {
+		t.Errorf("per-request proxy saw %v, want 2 requests", targets)
+	}
+	if targets, _ := global.seen(); len(targets) != 0 {
+		t.Errorf("global proxy used despite the proxy parameter: %v", targets)
+	}
+
+	// un proxy fourni par le client n'échappe pas au contrôle SSRF
+	_, strict := newTestServer(t, map[string]string{"ALLOW_PRIVATE": "false", "ALLOW_REQUEST_PROXY": "true"})
+	path = "/extract?url=" + url.QueryEscape("http://93.184.215.14/story") + "&proxy=" + url.QueryEscape(perRequestServer.URL)
+	if status, body := apiRequest(t, strict, http.MethodGet, path, "", ""); status != http.StatusBadRequest || errorCode(t, body) != codeForbiddenAddress {
+		t.Errorf("private per-request proxy: got %d %s, want 400 %s", status, body, codeForbiddenAddress)
+	}
+}
+
+func TestRequestProxyRejectedWhenDisabled(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	proxy, proxyServer := newTestProxy(t)
+	_, ts := newTestServer(t, nil)
+
+	requests := []struct{ method, path, body string }{
+		{http.MethodGet, "/extract?url=" + url.QueryEscape(origin.URL) + "&proxy=" + url.QueryEscape(proxyServer.URL), ""},
+		{http.MethodPost, "/extract/batch", `{"urls":["` + origin.URL + `"],"proxy":"` + proxyServer.URL + `"}`},
+	}
+	for _, r := range requests {
+		status, body := apiRequest(t, ts, r.method, r.path, "application/json", r.body)
+		if status != http.StatusBadRequest || errorCode(t, body) != codeInvalidRequest || !strings.Contains(string(body), "ALLOW_REQUEST_PROXY") {
+			t.Errorf("%s %s: got %d %s, want 400 mentioning ALLOW_REQUEST_PROXY", r.method, r.path, status, body)
+		}
+	}
+	if targets, _ := proxy.seen(); len(targets) != 0 {
+		t.Errorf("proxy reached: %v", targets)
+	}
+}
+
+func TestRequestProxyValidation(t *testing.T) {
+	tests := map[string]string{
+		"ftp://proxy.example:21":   "unsupported proxy scheme ftp",
+		"socks4://proxy.example":   "unsupported proxy scheme socks4",
+		"http://":                  "invalid proxy url",
+		"proxy.example:3128":       "invalid proxy url",
+		"http://user:pw@:8080":     "invalid proxy url",
+		"SOCKS5://proxy.example:1": "",
+		"https://u:p@proxy:8443":   "",
+	}
+	for raw, want := range tests {
+		err := validateRequestProxy(raw, true)
+		if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("%q: got %v, want %q", raw, err, want)
+		}
+	}
+	if u, _ := parseProxyURL("socks5://proxy.example"); proxyAddr(u) != "proxy.example:1080" {
+		t.Errorf("socks5 default port: %s", proxyAddr(u))
+	}
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	addr, relayed := startSOCKS5(t, "alice", "wonder")
+	_, ts := newTestServer(t, map[string]string{"OUTBOUND_PROXY": "socks5://alice:wonder@" + addr})
+
+	if status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(origin.URL+"/story"), "", ""); status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if relayed() != 1 {
+		t.Errorf("%d connections relayed, want 1", relayed())
+	}
+
+	// mauvais identifiants : échec de négociation SOCKS
+	_, ts = newTestServer(t, map[string]string{"OUTBOUND_PROXY": "socks5://alice:wrong@" + addr, "FETCH_MAX_ATTEMPTS": "1"})
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(origin.URL+"/other"), "", "")
+	if status != http.StatusBadGateway || errorCode(t, body) != codeProxyError {
+		t.Errorf("bad credentials: got %d %s, want 502 %s", status, body, codeProxyError)
+	}
+}
+
+func TestUnreachableProxyReturnsProxyError(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	_, ts := newTestServer(t, map[string]string{"OUTBOUND_PROXY": dead.URL})
+
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(origin.URL), "", "")
+	if status != http.StatusBadGateway || errorCode(t, body) != codeProxyError {
+		t.Fatalf("got %d %s, want 502 %s", status, body, codeProxyError)
+	}
+}
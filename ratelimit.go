@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bucket : seau à jetons d'un client
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	lastSeen time.Time
+}
+
+// limiter applique un débit par clé API (ou IP à défaut)
+type limiter struct {
+	mu      sync.Mutex
+	rate    float64 // jetons par seconde
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func newLimiter(perMinute, burst int) *limiter {
+	return &limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// allow consomme un jeton et retourne le nombre restant et l'attente
+// avant le prochain jeton (si refusé) ou avant le remplissage complet
+func (l *limiter) allow(key string) (ok bool, remaining int, retryAfter, reset time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	} else {
+		retryAfter = l.wait(1 - b.tokens)
+	}
+	return ok, int(b.tokens), retryAfter, l.wait(l.burst - b.tokens)
+}
+
+// wait : temps nécessaire pour regagner n jetons
+func (l *limiter) wait(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / l.rate * float64(time.Second))
+}
+
+// cleanup supprime les seaux inactifs depuis plus de idle
+func (l *limiter) cleanup(idle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for k, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idle {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// startCleanup nettoie périodiquement les seaux inactifs
+func (l *limiter) startCleanup(every, idle time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			l.cleanup(idle)
+		}
+	}()
+}
+
+// rateLimit doit être placé après requireAPIKey
+func (s *server) rateLimit(c *gin.Context) {
+	key := c.GetString(ctxAPIKey)
+	if key == "" {
+		key = "ip:" + c.ClientIP()
+	}
+
+	ok, remaining, retryAfter, reset := s.limiter.allow(key)
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(s.limiter.now().Add(reset).Unix(), 10))
+	if !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondError(c, newAPIError(http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded"))
+		return
+	}
+	c.Next()
+}
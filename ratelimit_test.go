@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// N+1 requêtes d'affilée : exactement une 429, en-têtes sur chaque réponse
+func TestRateLimitBurst(t *testing.T) {
+	const burst = 5
+	_, ts := newTestServer(t, map[string]string{"RATE_LIMIT_RPM": "60", "RATE_LIMIT_BURST": strconv.Itoa(burst)})
+
+	limited := 0
+	for i := 0; i < burst+1; i++ {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/usage", nil)
+		req.Header.Set("X-API-Key", testKey)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("X-RateLimit-Remaining") == "" || resp.Header.Get("X-RateLimit-Reset") == "" {
+			t.Errorf("request %d: rate limit headers missing", i)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			limited++
+			if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retry < 1 {
+				t.Errorf("Retry-After = %q", resp.Header.Get("Retry-After"))
+			}
+		}
+	}
+	if limited != 1 {
+		t.Errorf("%d responses were 429, want 1", limited)
+	}
+}
+
+// les clés ont chacune leur seau
+func TestRateLimitPerKey(t *testing.T) {
+	l := newLimiter(60, 1)
+	if ok, _, _, _ := l.allow("a"); !ok {
+		t.Fatal("first request of a refused")
+	}
+	if ok, _, _, _ := l.allow("a"); ok {
+		t.Error("second request of a allowed")
+	}
+	if ok, _, _, _ := l.allow("b"); !ok {
+		t.Error("b limited by a")
+	}
+}
+
+func TestRateLimitRefills(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newLimiter(60, 3) // un jeton par seconde
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _, _, _ := l.allow("k"); !ok {
+			t.Fatalf("request %d refused", i)
+		}
+	}
+	ok, remaining, retryAfter, reset := l.allow("k")
+	if ok || remaining != 0 || retryAfter != time.Second || reset != 3*time.Second {
+		t.Fatalf("empty bucket: ok=%v remaining=%d retry=%s reset=%s", ok, remaining, retryAfter, reset)
+	}
+
+	now = now.Add(time.Second)
+	if ok, _, _, _ := l.allow("k"); !ok {
+		t.Error("no token after one second")
+	}
+	now = now.Add(time.Minute) // remplissage complet, plafonné à burst
+	for i := 0; i < 3; i++ {
+		if ok, _, _, _ := l.allow("k"); !ok {
+			t.Errorf("request %d refused after the window", i)
+		}
+	}
+	if ok, _, _, _ := l.allow("k"); ok {
+		t.Error("bucket refilled beyond burst")
+	}
+}
+
+func TestRateLimitCleanupDropsIdleBuckets(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newLimiter(60, 3)
+	l.now = func() time.Time { return now }
+	l.allow("idle")
+	now = now.Add(5 * time.Minute)
+	l.allow("active")
+	l.cleanup(time.Minute)
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("idle bucket kept")
+	}
+	if _, ok := l.buckets["active"]; !ok {
+		t.Error("active bucket dropped")
+	}
+}
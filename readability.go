@@ -0,0 +1,143 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// indices de classe/id inspirés d'Arc90 readability
+var (
+	positiveHints = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negativeHints = regexp.MustCompile(`(?i)sidebar|nav|footer|comment|combx|contact|foot|masthead|meta|outbrain|promo|related|scroll|share|shoutbox|sponsor|shopping|tags|tool|widget|banner|cookie|ad-|ads`)
+	unlikelyHints = regexp.MustCompile(`(?i)combx|comment|community|disqus|extra|foot|header|menu|remark|rss|shoutbox|sidebar|sponsor|ad-break|agegate|pagination|pager|popup|cookie|consent|newsletter|related|share|social`)
+	maybeHints    = regexp.MustCompile(`(?i)and|article|body|column|main|shadow`)
+)
+
+// éléments dont le texte compte comme contenu
+const contentSelector = "p, pre, td, blockquote, li"
+
+// éléments jamais utiles pour le texte
+const junkSelector = "script, style, noscript, iframe, form, nav, aside, footer, header, svg, button"
+
+func classWeight(s *goquery.Selection) float64 {
+	weight := 0.0
+	for _, attr := range []string{"class", "id"} {
+		v, ok := s.Attr(attr)
+		if !ok || v == "" {
+			continue
+		}
+		if negativeHints.MatchString(v) {
+			weight -= 25
+		}
+		if positiveHints.MatchString(v) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+func tagWeight(tag string) float64 {
+	switch tag {
+	case "article":
+		return 10
+	case "div", "main", "section":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		return -5
+	}
+	return 0
+}
+
+// linkDensity : part du texte contenue dans des liens
+func linkDensity(s *goquery.Selection) float64 {
+	textLen := len(strings.TrimSpace(s.Text()))
+	if textLen == 0 {
+		return 0
+	}
+	linkLen := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		linkLen += len(strings.TrimSpace(a.Text()))
+	})
+	return float64(linkLen) / float64(textLen)
+}
+
+// removeUnlikely retire les blocs qui ne sont presque jamais du contenu
+func removeUnlikely(doc *goquery.Document, protected func(*goquery.Selection) bool) {
+	doc.Find(junkSelector).Each(func(i int, s *goquery.Selection) {
+		if !protected(s) {
+			s.Remove()
+		}
+	})
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "body" || goquery.NodeName(s) == "html" {
+			return
+		}
+		hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if unlikelyHints.MatchString(hint) && !maybeHints.MatchString(hint) && !protected(s) {
+			s.Remove()
+		}
+	})
+}
+
+// extractMainContent note les conteneurs candidats et retourne le meilleur
+// sous-arbre, ou nil si aucun candidat n'a été trouvé. Les éléments protégés
+// par keep ne sont jamais retirés.
+func (e *engine) extractMainContent(doc *goquery.Document, keep []string) *goquery.Selection {
+	protected := e.keepFilter(keep)
+	e.removeBoilerplate(doc, protected)
+	removeUnlikely(doc, protected)
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			s := doc.FindNodes(n)
+			scores[n] = tagWeight(n.Data) + classWeight(s)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	doc.Find(contentSelector).Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if len(text) < 25 {
+			return
+		}
+
+		// 1 point de base, 1 par virgule, 1 par tranche de 100 caractères (max 3)
+		score := 1.0
+		score += float64(strings.Count(text, ","))
+		score += float64(min(len(text)/100, 3))
+
+		parent := s.Nodes[0].Parent
+		addScore(parent, score)
+		if parent != nil {
+			addScore(parent.Parent, score/2)
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range candidates {
+		s := doc.FindNodes(n)
+		score := scores[n] * (1 - linkDensity(s))
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return doc.FindNodes(best)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractMainContentDropsBoilerplate(t *testing.T) {
+	tests := []struct {
+		fixture string
+		keep    []string // phrases du corps de l'article
+		drop    []string // phrases du décor de la page
+	}{
+		{
+			fixture: "news.html",
+			keep:    []string{"approve a new tram line", "420 million euros", "first section opening in 2029"},
+			drop:    []string{"We use cookies", "Bus fares to rise", "waiting for this tram", "waste of public money", "All rights reserved", "Opinion"},
+		},
+		{
+			fixture: "blog.html",
+			keep:    []string{"For three years I kept every note", "small friction", "no notifications at all"},
+			drop:    []string{"I write about productivity", "September 2026", "Subscribe to the newsletter", "Share on Twitter", "Load the comments"},
+		},
+		{
+			fixture: "heavynav.html",
+			keep:    []string{"A dripping tap wastes", "adjustable spanner", "regrinding"},
+			drop:    []string{"Kitchen repairs", "cordless drills", "squeaky door hinge", "Sponsored", "About us", "Home"},
+		},
+	}
+	e := newTestEngine(t, nil)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			article := extractFixture(t, e, tt.fixture, extractOptions{})
+			for _, phrase := range tt.keep {
+				if !strings.Contains(article.CleanText, phrase) {
+					t.Errorf("missing %q in:\n%s", phrase, article.CleanText)
+				}
+			}
+			for _, phrase := range tt.drop {
+				if strings.Contains(article.CleanText, phrase) {
+					t.Errorf("boilerplate %q kept in:\n%s", phrase, article.CleanText)
+				}
+			}
+		})
+	}
+}
+
+// raw=true : l'ancienne concaténation des <p>, décor compris
+func TestRawKeepsEveryParagraph(t *testing.T) {
+	e := newTestEngine(t, nil)
+	article := extractFixture(t, e, "news.html", extractOptions{Raw: true})
+	for _, phrase := range []string{"approve a new tram line", "waiting for this tram", "waste of public money"} {
+		if !strings.Contains(article.CleanText, phrase) {
+			t.Errorf("raw text misses %q:\n%s", phrase, article.CleanText)
+		}
+	}
+}
+
+func TestExtractEndpointKeepsTitleAndText(t *testing.T) {
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+fixtures.URL+"/news.html", &article); status != 200 {
+		t.Fatalf("got %d", status)
+	}
+	if article.Title != "City council approves new tram line" {
+		t.Errorf("title %q", article.Title)
+	}
+	if !strings.Contains(article.CleanText, "420 million euros") || strings.Contains(article.CleanText, "All rights reserved") {
+		t.Errorf("clean_text:\n%s", article.CleanText)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// redirectOrigin : /start -> /hop/2 -> hop/3 (relatif) -> /final, et une
+// boucle /loop/a <-> /loop/b
+func redirectOrigin(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var ts *httptest.Server
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, ts.URL+"/hop/2", http.StatusMovedPermanently) // absolu
+	})
+	mux.HandleFunc("/hop/2", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "3") // relatif au répertoire courant
+		w.WriteHeader(http.StatusFound)
+	})
+	mux.HandleFunc("/hop/3", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/final") // relatif à la racine
+		w.WriteHeader(http.StatusSeeOther)
+	})
+	mux.HandleFunc("/final", largePage)
+	mux.HandleFunc("/loop/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop/b", http.StatusFound)
+	})
+	mux.HandleFunc("/loop/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop/a", http.StatusFound)
+	})
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRedirectChainIsReported(t *testing.T) {
+	origin := redirectOrigin(t)
+	_, ts := newTestServer(t, nil)
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+origin.URL+"/start", &article); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	if article.FinalURL != origin.URL+"/final" {
+		t.Errorf("final_url %q", article.FinalURL)
+	}
+	want := []string{origin.URL + "/start", origin.URL + "/hop/2", origin.URL + "/hop/3"}
+	if !reflect.DeepEqual(article.RedirectChain, want) {
+		t.Errorf("redirect_chain %q, want %q", article.RedirectChain, want)
+	}
+}
+
+func TestRedirectErrors(t *testing.T) {
+	origin := redirectOrigin(t)
+	tests := []struct {
+		name, path, maxRedirects, code string
+	}{
+		{"loop", "/loop/a", "5", codeRedirectLoop},
+		{"too many hops", "/start", "2", codeTooManyRedirects},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newTestServer(t, map[string]string{"MAX_REDIRECTS": tt.maxRedirects})
+			status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+origin.URL+tt.path, "", "")
+			if status != http.StatusBadGateway || errorCode(t, body) != tt.code {
+				t.Errorf("got %d %s, want 502 %s", status, body, tt.code)
+			}
+		})
+	}
+}
+
+func TestNoRedirectGivesEmptyChain(t *testing.T) {
+	origin := redirectOrigin(t)
+	_, ts := newTestServer(t, nil)
+	var article Article
+	apiGet(t, ts, "/extract?url="+origin.URL+"/final", &article)
+	if article.FinalURL != origin.URL+"/final" || article.RedirectChain == nil || len(article.RedirectChain) != 0 {
+		t.Errorf("final_url %q, redirect_chain %#v", article.FinalURL, article.RedirectChain)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisStore partage le cache entre instances (REDIS_URL)
+type redisStore struct {
+	client *redis.Client
+}
+
+// newRedisStore se connecte à REDIS_URL (redis://[:motdepasse@]hôte:port/base)
+// et vérifie que le serveur répond
+func newRedisStore(ctx context.Context, rawURL string) (*redisStore, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis %s unreachable: %w", opts.Addr, err)
+	}
+	return &redisStore{client: client}, nil
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+// nombre de clés demandées à chaque SCAN
+const redisScanCount = 500
+
+func (s *redisStore) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	deleted := 0
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := s.client.Unlink(ctx, batch...).Result()
+		deleted += int(n)
+		batch = batch[:0]
+		return err
+	}
+	iter := s.client.Scan(ctx, 0, prefix+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == redisScanCount {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
+
+// Stats compte les clés par SCAN ; la mémoire est celle du serveur Redis
+// (used_memory) et l'ordre d'utilisation des clés n'est pas connu
+func (s *redisStore) Stats(ctx context.Context, prefix string, recent int) (storeStats, error) {
+	var stats storeStats
+	iter := s.client.Scan(ctx, 0, prefix+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		stats.Entries++
+	}
+	if err := iter.Err(); err != nil {
+		return stats, err
+	}
+	if info, err := s.client.Info(ctx, "memory").Result(); err == nil {
+		for _, line := range strings.Split(info, "\n") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(line), "used_memory:"); ok {
+				stats.Bytes, _ = strconv.ParseInt(v, 10, 64)
+			}
+		}
+	}
+	return stats, nil
+}
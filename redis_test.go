@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newRedisEngine : engine dont le cache est un miniredis
+func newRedisEngine(t *testing.T, mr *miniredis.Miniredis, env map[string]string) *engine {
+	t.Helper()
+	values := map[string]string{"REDIS_URL": "redis://" + mr.Addr()}
+	for k, v := range env {
+		values[k] = v
+	}
+	e := newTestEngine(t, values)
+	if err := e.openCacheStore(context.Background()); err != nil {
+		t.Fatalf("openCacheStore: %v", err)
+	}
+	return e
+}
+
+func TestRedisStoreSetGetExpiry(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	s, err := newRedisStore(ctx, "redis://"+mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := s.Get(ctx, "article:a"); ok || err != nil {
+		t.Fatalf("missing key: ok=%v err=%v", ok, err)
+	}
+	if err := s.Set(ctx, "article:a", []byte("one"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := s.Get(ctx, "article:a"); !ok || err != nil || string(value) != "one" {
+		t.Fatalf("get: %q ok=%v err=%v", value, ok, err)
+	}
+	if ttl := mr.TTL("article:a"); ttl != time.Minute {
+		t.Errorf("ttl %s, want 1m", ttl)
+	}
+
+	mr.FastForward(time.Minute + time.Second)
+	if _, ok, err := s.Get(ctx, "article:a"); ok || err != nil {
+		t.Errorf("after expiry: ok=%v err=%v", ok, err)
+	}
+
+	s.Set(ctx, "article:h1:x", []byte("1"), time.Minute)
+	s.Set(ctx, "article:h1:y", []byte("2"), time.Minute)
+	s.Set(ctx, "article:h2:z", []byte("3"), time.Minute)
+	if err := s.Delete(ctx, "article:h2:z"); err != nil || mr.Exists("article:h2:z") {
+		t.Errorf("delete: err=%v", err)
+	}
+	if stats, err := s.Stats(ctx, "article:", 10); err != nil || stats.Entries != 2 {
+		t.Errorf("stats: %+v err=%v", stats, err)
+	}
+	if n, err := s.DeletePrefix(ctx, "article:h1:"); n != 2 || err != nil {
+		t.Errorf("delete prefix: %d err=%v", n, err)
+	}
+}
+
+// deux instances sur le même Redis partagent les extractions
+func TestRedisCacheSharedBetweenEngines(t *testing.T) {
+	mr := miniredis.RunT(t)
+	origin, ts := newCountingOrigin(t, false)
+	a := newRedisEngine(t, mr, nil)
+	b := newRedisEngine(t, mr, nil)
+	ctx := context.Background()
+
+	article, _, err := a.extractCached(ctx, ts.URL+"/a", testOptions(t, a), false)
+	if err != nil || article.Cached {
+		t.Fatalf("first instance: cached=%v err=%v", article != nil && article.Cached, err)
+	}
+	article, _, err = b.extractCached(ctx, ts.URL+"/a", testOptions(t, b), false)
+	if err != nil || !article.Cached || origin.hits.Load() != 1 {
+		t.Fatalf("second instance: cached=%v hits=%d err=%v", article != nil && article.Cached, origin.hits.Load(), err)
+	}
+
+	// la valeur est l'article sérialisé, sous une clé dérivée de l'URL
+	keys := mr.Keys()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], storePrefix) || strings.Contains(keys[0], "127.0.0.1") {
+		t.Fatalf("keys %v", keys)
+	}
+	value, _ := mr.Get(keys[0])
+	if !strings.Contains(value, `"clean_text"`) {
+		t.Errorf("stored value is not a serialized article: %.200s", value)
+	}
+	// expiration Redis : fraîcheur puis période de revalidation
+	if ttl := mr.TTL(keys[0]); ttl != a.cfg.CacheTTL+a.cfg.CacheStaleTTL {
+		t.Errorf("redis ttl %s, want %s", ttl, a.cfg.CacheTTL+a.cfg.CacheStaleTTL)
+	}
+
+	mr.FastForward(a.cfg.CacheTTL + a.cfg.CacheStaleTTL + time.Second)
+	article, _, err = b.extractCached(ctx, ts.URL+"/a", testOptions(t, b), false)
+	if err != nil || article.Cached || origin.hits.Load() != 2 {
+		t.Errorf("after redis expiry: cached=%v hits=%d err=%v", article != nil && article.Cached, origin.hits.Load(), err)
+	}
+}
+
+// une panne de Redis en cours de route : chaque requête est servie en direct
+func TestRedisFailureDegradesToLiveFetch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	origin, ts := newCountingOrigin(t, false)
+	e := newRedisEngine(t, mr, map[string]string{"CACHE_STORE_TIMEOUT": "100ms"})
+	ctx := context.Background()
+
+	if _, _, err := e.extractCached(ctx, ts.URL+"/a", testOptions(t, e), false); err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+
+	for i := 0; i < 2; i++ {
+		article, _, err := e.extractCached(ctx, ts.URL+"/a", testOptions(t, e), false)
+		if err != nil || article.Cached || article.CleanText == "" {
+			t.Fatalf("with redis down: cached=%v err=%v", article != nil && article.Cached, err)
+		}
+	}
+	if hits := origin.hits.Load(); hits != 3 {
+		t.Errorf("origin hits %d, want 3", hits)
+	}
+
+	// erreurs de Get comme de Set : jamais d'erreur pour le client
+	mr = miniredis.RunT(t)
+	e = newRedisEngine(t, mr, nil)
+	mr.SetError("READONLY simulated failure")
+	article, _, err := e.extractCached(ctx, ts.URL+"/b", testOptions(t, e), false)
+	if err != nil || article.Cached {
+		t.Errorf("with redis erroring: cached=%v err=%v", article != nil && article.Cached, err)
+	}
+}
+
+func TestRedisUnreachableFailsStartup(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	e := newTestEngine(t, map[string]string{"REDIS_URL": "redis://" + addr})
+	err := e.openCacheStore(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "redis "+addr+" unreachable") {
+		t.Fatalf("got %v, want redis %s unreachable", err, addr)
+	}
+	if _, ok := e.cache.store.(*memoryStore); !ok {
+		t.Errorf("store replaced despite the failure: %T", e.cache.store)
+	}
+}
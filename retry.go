@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// au-delà de cette attente (Retry-After compris), on abandonne plutôt que de bloquer
+const maxRetryDelay = 10 * time.Second
+
+// doWithRetry envoie la requête et la rejoue sur les échecs transitoires
+// (connexion réinitialisée, délai de connexion, 429, 502, 503, 504), au plus
+// FETCH_MAX_ATTEMPTS fois en tout ; l'attente part de FETCH_RETRY_BACKOFF.
+// Le corps de la réponse retournée n'est pas encore lu : une fois la lecture
+// commencée, plus aucune tentative n'est faite. Retourne aussi le nombre de tentatives.
+func (e *engine) doWithRetry(req *http.Request) (*http.Response, int, error) {
+	ctx := req.Context()
+	maxAttempts, base := e.cfg.FetchMaxAttempts, e.cfg.FetchRetryBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := e.client.Do(req.Clone(ctx))
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			delay = backoff(base, attempt)
+			if attempt >= maxAttempts || !retryableError(err) || !fitsDeadline(ctx, delay) {
+				return nil, attempt, err
+			}
+		case retryableStatus(resp.StatusCode):
+			if attempt >= maxAttempts {
+				return resp, attempt, nil
+			}
+			delay = backoff(base, attempt)
+			if resp.StatusCode == http.StatusTooManyRequests {
+				if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					delay = d
+				}
+			}
+			if delay > maxRetryDelay || !fitsDeadline(ctx, delay) {
+				return resp, attempt, nil
+			}
+			resp.Body.Close()
+		default:
+			return resp, attempt, nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryableError : connexion réinitialisée ou fermée avant la réponse, délai de connexion dépassé
+func retryableError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout()
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff : exponentiel (base, 2×base, 4×base...) plus une gigue aléatoire du même ordre
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	if d <= 0 || d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d + rand.N(d+1)
+}
+
+// retryAfter lit Retry-After, en secondes ou en date HTTP
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// fitsDeadline indique si l'attente laisse encore du temps avant l'échéance du contexte
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyOrigin répond status aux failures premières requêtes (toutes si
+// failures < 0), puis sert la page
+type flakyOrigin struct {
+	hits       atomic.Int64
+	failures   int64
+	status     int
+	retryAfter string
+}
+
+func (o *flakyOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := o.hits.Add(1)
+	if o.failures < 0 || n <= o.failures {
+		if o.status == 0 {
+			// connexion coupée sans réponse
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		if o.retryAfter != "" {
+			w.Header().Set("Retry-After", o.retryAfter)
+		}
+		w.WriteHeader(o.status)
+		return
+	}
+	largePage(w, r)
+}
+
+func newFlakyOrigin(t *testing.T, o *flakyOrigin) string {
+	t.Helper()
+	ts := httptest.NewServer(o)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestRetryRecoversAfterTwoFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin *flakyOrigin
+	}{
+		{"503", &flakyOrigin{failures: 2, status: http.StatusServiceUnavailable}},
+		{"502", &flakyOrigin{failures: 2, status: http.StatusBadGateway}},
+		{"429 with Retry-After", &flakyOrigin{failures: 2, status: http.StatusTooManyRequests, retryAfter: "0"}},
+		{"connection reset", &flakyOrigin{failures: 2}},
+	}
+	_, ts := newTestServer(t, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := newFlakyOrigin(t, tt.origin)
+			var article Article
+			if status := apiGet(t, ts, "/extract?url="+url.QueryEscape(origin), &article); status != http.StatusOK {
+				t.Fatalf("status %d", status)
+			}
+			if article.Attempts != 3 || tt.origin.hits.Load() != 3 {
+				t.Errorf("attempts %d, origin hits %d, want 3", article.Attempts, tt.origin.hits.Load())
+			}
+		})
+	}
+}
+
+func TestRetryGivesUpOnPersistent503(t *testing.T) {
+	origin := &flakyOrigin{failures: -1, status: http.StatusServiceUnavailable}
+	originURL := newFlakyOrigin(t, origin)
+	_, ts := newTestServer(t, nil)
+
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(originURL), "", "")
+	var resp struct {
+		Error apiError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusBadGateway || resp.Error.Code != codeUpstreamServerError || resp.Error.UpstreamStatus != http.StatusServiceUnavailable {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if resp.Error.Attempts != 3 || origin.hits.Load() != 3 {
+		t.Errorf("attempts %d, origin hits %d, want 3", resp.Error.Attempts, origin.hits.Load())
+	}
+}
+
+func TestRetryHonorsMaxAttempts(t *testing.T) {
+	origin := &flakyOrigin{failures: -1, status: http.StatusGatewayTimeout}
+	originURL := newFlakyOrigin(t, origin)
+	_, ts := newTestServer(t, map[string]string{"FETCH_MAX_ATTEMPTS": "5"})
+
+	apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(originURL), "", "")
+	if got := origin.hits.Load(); got != 5 {
+		t.Errorf("origin hits %d, want 5", got)
+	}
+}
+
+// les 4xx autres que 429 ne sont jamais rejoués
+func TestRetrySkipsClientErrors(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden, http.StatusBadRequest} {
+		origin := &flakyOrigin{failures: -1, status: status}
+		originURL := newFlakyOrigin(t, origin)
+		apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(originURL), "", "")
+		if got := origin.hits.Load(); got != 1 {
+			t.Errorf("status %d: origin hits %d, want 1", status, got)
+		}
+	}
+}
+
+// un Retry-After qui dépasse l'échéance rend la réponse 429 sans attendre
+func TestRetryAfterBeyondDeadline(t *testing.T) {
+	origin := &flakyOrigin{failures: -1, status: http.StatusTooManyRequests, retryAfter: "30"}
+	originURL := newFlakyOrigin(t, origin)
+	_, ts := newTestServer(t, map[string]string{"FETCH_TIMEOUT": "2s"})
+
+	start := time.Now()
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(originURL), "", "")
+	if time.Since(start) > time.Second {
+		t.Errorf("waited %s for a Retry-After past the deadline", time.Since(start))
+	}
+	if status != http.StatusBadGateway || errorCode(t, body) != codeUpstreamClientError || origin.hits.Load() != 1 {
+		t.Errorf("got %d %s after %d hits", status, body, origin.hits.Load())
+	}
+}
+
+func TestRetryAfterParsing(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"0", 0, true},
+		{"-5", 0, false},
+		{"Fri, 01 Mar 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Fri, 01 Mar 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBackoffGrowsWithJitter(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 1; attempt <= 4; attempt++ {
+		low := base << (attempt - 1)
+		for i := 0; i < 50; i++ {
+			if d := backoff(base, attempt); d < low || d > 2*low {
+				t.Fatalf("attempt %d: backoff %s outside [%s, %s]", attempt, d, low, 2*low)
+			}
+		}
+	}
+	if d := backoff(time.Second, 40); d < maxRetryDelay || d > 2*maxRetryDelay {
+		t.Errorf("backoff not capped: %s", d)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	maxRobotsBytes = 512 << 10
+	robotsTimeout  = 5 * time.Second
+)
+
+// robotsRule : Allow ou Disallow, motif compilé (* et $ gérés)
+type robotsRule struct {
+	allow   bool
+	length  int // longueur du motif, la règle la plus spécifique l'emporte
+	pattern *regexp.Regexp
+}
+
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// robotsFile est un robots.txt interprété
+type robotsFile struct {
+	groups      []robotsGroup
+	disallowAll bool // origine en erreur : tout est refusé jusqu'à expiration
+}
+
+type robotsEntry struct {
+	file       *robotsFile
+	expires    time.Time
+	validators conditionalHeaders // pour revalider le fichier à expiration
+}
+
+// robotsCache garde un robots.txt par origine (schéma + hôte)
+type robotsCache struct {
+	mu      sync.Mutex
+	entries map[string]robotsEntry
+	flight  singleflight.Group // téléchargements en cours, par origine
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{entries: make(map[string]robotsEntry)}
+}
+
+// checkRobots refuse l'URL si le robots.txt de son hôte l'interdit à notre
+// user agent (DEFAULT_USER_AGENT) ; userAgent, celui réellement envoyé
+// (user_agent), doit aussi être autorisé : il ne permet pas de contourner
+// les règles qui nous visent
+func (e *engine) checkRobots(ctx context.Context, u *url.URL, userAgent string) error {
+	file, path := e.robotsFor(ctx, u), u.RequestURI()
+	if file.allowed(path, e.cfg.DefaultUserAgent) && (userAgent == e.cfg.DefaultUserAgent || file.allowed(path, userAgent)) {
+		return nil
+	}
+	return newAPIError(http.StatusForbidden, codeRobotsDisallowed, "fetching this url is disallowed by robots.txt")
+}
+
+// robotsFor retourne le robots.txt en cache ou le télécharge
+// (une seule requête par origine, partagée entre les appels concurrents)
+func (e *engine) robotsFor(ctx context.Context, u *url.URL) *robotsFile {
+	origin := u.Scheme + "://" + strings.ToLower(u.Host)
+	cache := e.robots
+
+	cache.mu.Lock()
+	entry, ok := cache.entries[origin]
+	cache.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.file
+	}
+
+	var prev *robotsEntry
+	if ok && entry.validators != (conditionalHeaders{}) {
+		prev = &entry
+	}
+
+	v, _, _ := cache.flight.Do(origin, func() (any, error) {
+		// indépendant de l'annulation de la requête qui a déclenché le fetch
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), robotsTimeout)
+		defer cancel()
+
+		fresh := e.fetchRobots(fetchCtx, origin, prev)
+		cache.mu.Lock()
+		cache.entries[origin] = fresh
+		cache.mu.Unlock()
+		return fresh.file, nil
+	})
+	return v.(*robotsFile)
+}
+
+// fetchRobots : 2xx interprété, 4xx tout autorisé, gardés ROBOTS_CACHE_TTL ;
+// 5xx ou erreur tout refusé pendant ROBOTS_ERROR_TTL. Un fichier déjà lu est
+// revalidé par requête conditionnelle et conservé sur 304.
+func (e *engine) fetchRobots(ctx context.Context, origin string, prev *robotsEntry) robotsEntry {
+	ttl := e.cfg.RobotsCacheTTL
+	refused := robotsEntry{file: &robotsFile{disallowAll: true}, expires: time.Now().Add(e.cfg.RobotsErrorTTL)}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return refused
+	}
+	req.Header.Set("User-Agent", e.cfg.DefaultUserAgent)
+	if prev != nil {
+		prev.validators.apply(req)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return refused
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && prev != nil:
+		return robotsEntry{file: prev.file, expires: time.Now().Add(ttl), validators: prev.validators}
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		decoded, err := decodedBody(resp)
+		if err != nil {
+			return refused
+		}
+		body, err := io.ReadAll(io.LimitReader(decoded, maxRobotsBytes))
+		if err != nil {
+			return refused
+		}
+		return robotsEntry{
+			file:    parseRobots(body),
+			expires: time.Now().Add(ttl),
+			validators: conditionalHeaders{
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+			},
+		}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return refused
+	default:
+		return robotsEntry{file: &robotsFile{}, expires: time.Now().Add(ttl)}
+	}
+}
+
+// parseRobots lit les groupes User-agent / Allow / Disallow
+func parseRobots(body []byte) *robotsFile {
+	file := &robotsFile{}
+	var current *robotsGroup
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			// des User-agent consécutifs partagent le même groupe
+			if current == nil || inRules {
+				file.groups = append(file.groups, robotsGroup{})
+				current = &file.groups[len(file.groups)-1]
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if value == "" {
+				continue // Disallow vide : tout est autorisé
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   field == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		}
+	}
+	return file
+}
+
+// robotsPattern traduit un motif robots.txt en expression ancrée au début du chemin
+func robotsPattern(value string) *regexp.Regexp {
+	anchored := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed applique le groupe le plus spécifique pour userAgent (sinon *),
+// puis la règle la plus longue qui correspond ; Allow l'emporte à égalité
+func (f *robotsFile) allowed(path, userAgent string) bool {
+	if f.disallowAll {
+		return false
+	}
+
+	ua := strings.ToLower(userAgent)
+	var group *robotsGroup
+	best := -1
+	for i := range f.groups {
+		for _, agent := range f.groups[i].agents {
+			switch {
+			case agent == "*" && best < 0:
+				group, best = &f.groups[i], 0
+			case agent != "*" && strings.Contains(ua, agent) && len(agent) > best:
+				group, best = &f.groups[i], len(agent)
+			}
+		}
+	}
+	if group == nil {
+		return true
+	}
+
+	allow, length := true, -1
+	for _, rule := range group.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > length || (rule.length == length && rule.allow) {
+			allow, length = rule.allow, rule.length
+		}
+	}
+	return allow
+}
+
+// cleanup retire les entrées expirées depuis plus de ttl (ROBOTS_CACHE_TTL) ;
+// les plus récentes restent disponibles pour une revalidation
+func (c *robotsCache) cleanup(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for origin, entry := range c.entries {
+		if now.After(entry.expires.Add(ttl)) {
+			delete(c.entries, origin)
+		}
+	}
+}
+
+// startCleanup nettoie périodiquement le cache robots.txt
+func (c *robotsCache) startCleanup(every, ttl time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			c.cleanup(ttl)
+		}
+	}()
+}
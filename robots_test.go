@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRobotsAllowed(t *testing.T) {
+	file := parseRobots(readFixture(t, "robots/restrictive.txt"))
+	const service = "Mozilla/5.0 (compatible; CleanWebArticle/1.0)"
+	tests := []struct {
+		path, userAgent string
+		want            bool
+	}{
+		{"/public/page", "SomeCrawler/2.0", true},
+		{"/news/story", "SomeCrawler/2.0", false},
+		// groupe nommé : remplace entièrement *
+		{"/news/story", service, true},
+		{"/archive/2023", service, true},
+		{"/private/notes", service, false},
+		{"/files/report.pdf", service, false},
+		{"/files/report.pdf?download=1", service, true}, // $ ancre la fin
+		{"/public/page", "BadBot/1.0", false},
+	}
+	for _, tt := range tests {
+		if got := file.allowed(tt.path, tt.userAgent); got != tt.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", tt.path, tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsLongestRuleWins(t *testing.T) {
+	file := parseRobots([]byte("User-agent: *\nDisallow: /shop\nAllow: /shop/catalog\nAllow: /p\nDisallow: /p\n"))
+	for path, want := range map[string]bool{
+		"/shop/cart":    false,
+		"/shop/catalog": true,
+		"/page":         true, // à égalité, Allow l'emporte
+	} {
+		if got := file.allowed(path, "any"); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+// robotsOrigin sert robots.txt avec le statut et le corps donnés, plus une page partout ailleurs
+type robotsOrigin struct {
+	robotsHits atomic.Int64
+	pageHits   atomic.Int64
+	status     atomic.Int64
+	body       []byte
+}
+
+func (o *robotsOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/robots.txt" {
+		o.robotsHits.Add(1)
+		w.WriteHeader(int(o.status.Load()))
+		w.Write(o.body)
+		return
+	}
+	o.pageHits.Add(1)
+	largePage(w, r)
+}
+
+func newRobotsOrigin(t *testing.T, status int, body []byte) (*robotsOrigin, *httptest.Server) {
+	t.Helper()
+	o := &robotsOrigin{body: body}
+	o.status.Store(int64(status))
+	ts := httptest.NewServer(o)
+	t.Cleanup(ts.Close)
+	return o, ts
+}
+
+func TestRestrictiveRobotsEndToEnd(t *testing.T) {
+	origin, originTS := newRobotsOrigin(t, http.StatusOK, readFixture(t, "robots/restrictive.txt"))
+	_, ts := newTestServer(t, map[string]string{"RESPECT_ROBOTS": "true"})
+	extract := func(path, params string) (int, []byte) {
+		return apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(originTS.URL+path)+params, "", "")
+	}
+
+	status, body := extract("/private/notes", "")
+	if status != http.StatusForbidden || errorCode(t, body) != codeRobotsDisallowed {
+		t.Fatalf("disallowed path: got %d %s", status, body)
+	}
+	if status, body := extract("/news/story", ""); status != http.StatusOK {
+		t.Fatalf("allowed path: got %d %s", status, body)
+	}
+	// respect_robots=false passe outre
+	if status, body := extract("/private/notes", "&respect_robots=false"); status != http.StatusOK {
+		t.Fatalf("respect_robots=false: got %d %s", status, body)
+	}
+	// user_agent ne contourne pas les règles du service, et doit lui-même
+	// être autorisé
+	for _, tt := range []struct {
+		path, userAgent string
+		want            int
+	}{
+		{"/private/notes", "FriendlyBot/1.0", http.StatusForbidden},
+		{"/news/story", "SomeCrawler/2.0", http.StatusForbidden},
+		{"/news/story", "FriendlyBot/1.0", http.StatusOK},
+	} {
+		status, body := extract(tt.path, "&nocache=true&user_agent="+url.QueryEscape(tt.userAgent))
+		if status != tt.want || (status == http.StatusForbidden && errorCode(t, body) != codeRobotsDisallowed) {
+			t.Errorf("%s as %s: got %d %s, want %d", tt.path, tt.userAgent, status, body, tt.want)
+		}
+	}
+	if got := origin.robotsHits.Load(); got != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1 (cached)", got)
+	}
+	if got := origin.pageHits.Load(); got != 3 {
+		t.Errorf("origin served %d pages, want 3", got)
+	}
+
+	// dans un lot, le refus ne touche que l'URL concernée
+	_, batch := postBatch(t, ts, []string{originTS.URL + "/private/other", originTS.URL + "/archive/2023"})
+	results := decodeBatch(t, batch)
+	if results[0].Error == nil || results[0].Error.Code != codeRobotsDisallowed || results[1].Error != nil {
+		t.Errorf("batch results: %+v", results)
+	}
+}
+
+func TestRobotsStatusHandling(t *testing.T) {
+	e := newTestEngine(t, map[string]string{"ROBOTS_ERROR_TTL": "50ms"})
+	ctx := context.Background()
+
+	// 404 : tout autorisé
+	_, missing := newRobotsOrigin(t, http.StatusNotFound, nil)
+	u, _ := url.Parse(missing.URL + "/anything")
+	if err := e.checkRobots(ctx, u, e.cfg.DefaultUserAgent); err != nil {
+		t.Errorf("404 robots.txt: %v", err)
+	}
+
+	// 5xx : tout refusé jusqu'à ROBOTS_ERROR_TTL, puis nouvelle lecture
+	origin, failing := newRobotsOrigin(t, http.StatusServiceUnavailable, []byte("User-agent: *\nDisallow: /private\n"))
+	u, _ = url.Parse(failing.URL + "/anything")
+	if err := e.checkRobots(ctx, u, e.cfg.DefaultUserAgent); toAPIError(err).Code != codeRobotsDisallowed {
+		t.Errorf("503 robots.txt: got %v", err)
+	}
+	origin.status.Store(http.StatusOK)
+	if err := e.checkRobots(ctx, u, e.cfg.DefaultUserAgent); err == nil {
+		t.Error("error state not cached")
+	}
+	time.Sleep(80 * time.Millisecond)
+	if err := e.checkRobots(ctx, u, e.cfg.DefaultUserAgent); err != nil {
+		t.Errorf("after ROBOTS_ERROR_TTL: %v", err)
+	}
+	if got := origin.robotsHits.Load(); got != 2 {
+		t.Errorf("robots.txt fetched %d times, want 2", got)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Liste blanche du HTML renvoyé (format=html, et base du Markdown).
+// Les éléments absents de la liste sont remplacés par leur contenu,
+// ceux de droppedTags retirés avec leur contenu. SANITIZE_ALLOW_TAGS
+// ("mark,abbr") et SANITIZE_ALLOW_ATTRS ("span:class,*:id") complètent
+// la liste ; droppedTags, les on* et les attributs de forbiddenAttrs
+// restent refusés quoi qu'il arrive.
+var defaultAllowedTags = map[string]bool{
+	"p": true, "br": true, "hr": true, "div": true, "span": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"table": true, "caption": true, "thead": true, "tbody": true, "tfoot": true,
+	"tr": true, "th": true, "td": true, "colgroup": true, "col": true,
+	"figure": true, "figcaption": true, "img": true, "a": true,
+	"blockquote": true, "q": true, "cite": true, "pre": true, "code": true,
+	"kbd": true, "samp": true, "var": true,
+	"em": true, "strong": true, "b": true, "i": true, "u": true, "s": true,
+	"del": true, "ins": true, "sub": true, "sup": true, "small": true,
+	"time": true, "section": true, "article": true, "header": true,
+	"footer": true, "aside": true, "details": true, "summary": true,
+}
+
+// éléments retirés avec leur contenu (code, contenus embarqués, formulaires,
+// espaces de noms SVG / MathML)
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"iframe": true, "frame": true, "frameset": true, "object": true,
+	"embed": true, "applet": true, "param": true, "form": true, "input": true,
+	"button": true, "select": true, "option": true, "textarea": true,
+	"link": true, "meta": true, "base": true, "head": true, "title": true,
+	"svg": true, "math": true, "audio": true, "video": true, "source": true,
+	"track": true, "canvas": true, "xmp": true, "plaintext": true,
+	"noembed": true, "noframes": true,
+}
+
+// attributs conservés, par élément ("*" = tous)
+var defaultAllowedAttrs = map[string][]string{
+	"*":    {"title", "lang", "dir"},
+	"a":    {"href"},
+	"img":  {"src", "alt", "width", "height"},
+	"td":   {"colspan", "rowspan"},
+	"th":   {"colspan", "rowspan", "scope"},
+	"ol":   {"start"},
+	"code": {"class"}, // langage pour le Markdown
+	"time": {"datetime"},
+}
+
+// attributs jamais conservés, même configurés
+var forbiddenAttrs = map[string]bool{
+	"srcdoc": true, "formaction": true, "action": true, "srcset": true,
+	"xmlns": true, "is": true,
+}
+
+// schémas acceptés dans href (src n'accepte que http et https)
+var linkSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "tel": true}
+
+// images data: sûres (pas de SVG, qui peut contenir du script), avec data_images
+var safeDataImage = regexp.MustCompile(`(?i)^data:image/(?:png|gif|jpe?g|webp|avif);base64,[a-z0-9+/=\s]*$`)
+
+// style exécutable ou qui charge une ressource
+var unsafeStyle = regexp.MustCompile(`(?i)expression|url\s*\(|javascript:|@import|behavior|binding|\\`)
+
+// sanitizePolicy : éléments et attributs conservés
+type sanitizePolicy struct {
+	tags  map[string]bool
+	attrs map[string][]string
+}
+
+// newSanitizePolicy complète la liste blanche par défaut avec
+// SANITIZE_ALLOW_TAGS et SANITIZE_ALLOW_ATTRS
+func newSanitizePolicy(tags, attrs []string) (*sanitizePolicy, error) {
+	p := &sanitizePolicy{tags: maps.Clone(defaultAllowedTags), attrs: map[string][]string{}}
+	for tag, keys := range defaultAllowedAttrs {
+		p.attrs[tag] = slices.Clone(keys)
+	}
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if droppedTags[tag] {
+			log.Printf("SANITIZE_ALLOW_TAGS: %s is never allowed, ignored", tag)
+			continue
+		}
+		p.tags[tag] = true
+	}
+	for _, pair := range attrs {
+		tag, key, ok := strings.Cut(strings.ToLower(pair), ":")
+		if !ok || tag == "" || key == "" {
+			return nil, fmt.Errorf("SANITIZE_ALLOW_ATTRS: %q is not tag:attribute", pair)
+		}
+		if strings.HasPrefix(key, "on") || forbiddenAttrs[key] {
+			log.Printf("SANITIZE_ALLOW_ATTRS: %s is never allowed, ignored", key)
+			continue
+		}
+		p.attrs[tag] = append(p.attrs[tag], key)
+	}
+	return p, nil
+}
+
+func (p *sanitizePolicy) attrAllowed(tag, key string) bool {
+	if strings.HasPrefix(key, "on") || forbiddenAttrs[key] {
+		return false
+	}
+	for _, k := range p.attrs["*"] {
+		if k == key {
+			return true
+		}
+	}
+	for _, k := range p.attrs[tag] {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeNode applique la liste blanche à n, qui reste en place : hors
+// liste, il devient un <div>
+func (p *sanitizePolicy) sanitizeNode(n *html.Node, base *url.URL, dataImages bool) {
+	if n.Type != html.ElementNode {
+		return
+	}
+	if n.Namespace != "" || !p.tags[n.Data] {
+		n.Namespace, n.Data, n.DataAtom = "", "div", atom.Div
+	}
+	p.sanitizeAttrs(n, base, dataImages)
+	p.sanitizeChildren(n, base, dataImages)
+}
+
+// sanitizeChildren nettoie les descendants de n : commentaires et éléments
+// interdits retirés, éléments inconnus remplacés par leur contenu
+func (p *sanitizePolicy) sanitizeChildren(n *html.Node, base *url.URL, dataImages bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.TextNode:
+		case c.Type != html.ElementNode, c.Namespace != "", droppedTags[c.Data]:
+			n.RemoveChild(c)
+		case !p.tags[c.Data]:
+			p.sanitizeChildren(c, base, dataImages)
+			for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+				c.RemoveChild(gc)
+				n.InsertBefore(gc, c)
+			}
+			n.RemoveChild(c)
+		default:
+			p.sanitizeAttrs(c, base, dataImages)
+			if c.Data == "img" && attr(c, "src") == "" {
+				n.RemoveChild(c)
+				break
+			}
+			p.sanitizeChildren(c, base, dataImages)
+		}
+		c = next
+	}
+}
+
+func (p *sanitizePolicy) sanitizeAttrs(n *html.Node, base *url.URL, dataImages bool) {
+	var kept []html.Attribute
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !p.attrAllowed(n.Data, a.Key) {
+			continue
+		}
+		switch a.Key {
+		case "href", "src":
+			if a.Key == "src" && dataImages && safeDataImage.MatchString(a.Val) {
+				break
+			}
+			a.Val = resolveAgainst(base, a.Val)
+			if !safeURL(a.Key, a.Val) {
+				continue
+			}
+		case "style":
+			if unsafeStyle.MatchString(a.Val) {
+				continue
+			}
+		}
+		kept = append(kept, a)
+	}
+	n.Attr = kept
+}
+
+// safeURL : http(s) ou lien relatif, mailto: et tel: aussi pour href. Les
+// navigateurs ignorent espaces et caractères de contrôle dans le schéma
+// ("java\tscript:") : ils sont retirés avant l'analyse.
+func safeURL(key, raw string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, raw)
+	u, err := url.Parse(cleaned)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" {
+		return true
+	}
+	if key == "src" {
+		return scheme == "http" || scheme == "https"
+	}
+	return linkSchemes[scheme]
+}
+
+// sanitizeFragment réanalyse le HTML produit et le nettoie de nouveau : ce
+// que le client obtiendra en l'analysant à son tour est aussi nettoyé, ce
+// qui neutralise les mutations à la sérialisation (mXSS)
+func (p *sanitizePolicy) sanitizeFragment(fragment string, dataImages bool) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), body)
+	if err != nil {
+		return ""
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	p.sanitizeChildren(body, nil, dataImages)
+
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return ""
+		}
+	}
+	return b.String()
+}
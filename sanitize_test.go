@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// sanitizeHTML passe page par le même chemin que format=html : nettoyage
+// du sous-arbre, sérialisation, nouvelle analyse et nouveau nettoyage
+func sanitizeHTML(t *testing.T, p *sanitizePolicy, page string, dataImages bool) string {
+	t.Helper()
+	base, _ := url.Parse("https://harbour.example/news/")
+	doc := mustParse(t, "<html><body>"+page+"</body></html>")
+	return p.renderHTML(p.cleanHTML(doc.Find("body"), base, dataImages), dataImages)
+}
+
+// checkSafe analyse out comme le ferait un navigateur et vérifie chaque
+// nœud : éléments de la liste blanche, ni on*, ni URL exécutable, ni
+// commentaire ; le HTML nettoyé doit aussi être stable au second passage
+func checkSafe(t *testing.T, p *sanitizePolicy, out string) {
+	t.Helper()
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(out), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var check func(n *html.Node)
+	check = func(n *html.Node) {
+		switch n.Type {
+		case html.CommentNode:
+			t.Errorf("comment left: %q", n.Data)
+		case html.ElementNode:
+			if n.Namespace != "" || !p.tags[n.Data] {
+				t.Errorf("element <%s> (namespace %q) left in %s", n.Data, n.Namespace, out)
+			}
+			for _, a := range n.Attr {
+				val := strings.ToLower(strings.Join(strings.Fields(a.Val), ""))
+				switch {
+				case strings.HasPrefix(a.Key, "on"), forbiddenAttrs[a.Key], !p.attrAllowed(n.Data, a.Key):
+					t.Errorf("attribute %s=%q left on <%s>", a.Key, a.Val, n.Data)
+				case a.Key == "href" || a.Key == "src":
+					if strings.Contains(val, "script:") || strings.HasPrefix(val, "data:image/svg") {
+						t.Errorf("%s=%q left on <%s>", a.Key, a.Val, n.Data)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			check(c)
+		}
+	}
+	for _, n := range nodes {
+		check(n)
+	}
+	if again := p.sanitizeFragment(out, true); again != out {
+		t.Errorf("not stable after a second pass:\n%s\n%s", out, again)
+	}
+}
+
+func TestSanitizeHostilePayloads(t *testing.T) {
+	p, err := newSanitizePolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := map[string]string{
+		"script":                `<p>a</p><script>alert(1)</script>`,
+		"svg onload":            `<svg onload=alert(1)><circle r=4 /></svg>`,
+		"svg script":            `<svg><script>alert(1)</script></svg>`,
+		"svg foreignObject":     `<svg><foreignObject><img src=x onerror=alert(1)></foreignObject></svg>`,
+		"math mglyph style":     `<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+		"form math style":       `<form><math><mtext></form><form><mglyph><style></math><img src onerror=alert(1)>`,
+		"noscript title":        `<noscript><p title="</noscript><img src=x onerror=alert(1)>"></noscript>`,
+		"nested noscript":       `<noscript><noscript></noscript><img src=x onerror=alert(1)></noscript>`,
+		"noscript in paragraph": `<p><noscript><style></noscript><img src=x onerror=alert(1)></style></p>`,
+		"template":              `<template><img src=x onerror=alert(1)></template>`,
+		"xmp":                   `<xmp><img src=x onerror=alert(1)></xmp>`,
+		"select style":          `<select><style><img src=x onerror=alert(1)></style></select>`,
+		"comment":               `<!--<img src=x onerror=alert(1)>--><p>b</p>`,
+		"conditional comment":   `<!--[if gte IE 4]><script>alert(1)</script><![endif]-->`,
+		"iframe srcdoc":         `<iframe srcdoc="<script>alert(1)</script>"></iframe>`,
+		"object and embed":      `<object data="javascript:alert(1)"></object><embed src="javascript:alert(1)">`,
+		"event handlers":        `<div onclick="alert(1)"><p onmouseover="alert(1)" ONFOCUS="alert(1)">text</p></div>`,
+		"javascript href":       `<a href="javascript:alert(1)">x</a>`,
+		"mixed case scheme":     `<a href="JaVaScRiPt:alert(1)">x</a>`,
+		"tab in scheme":         `<a href="java&#x09;script:alert(1)">x</a>`,
+		"newline in scheme":     "<a href=\"java\nscript:alert(1)\">x</a>",
+		"entity in scheme":      `<a href="&#106;avascript:alert(1)">x</a>`,
+		"leading spaces":        `<a href="  javascript:alert(1)">x</a>`,
+		"vbscript":              `<a href="vbscript:msgbox(1)">x</a>`,
+		"data html href":        `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+		"javascript img src":    `<img src="javascript:alert(1)" alt="x">`,
+		"svg data image":        `<img src="data:image/svg+xml;base64,PHN2ZyBvbmxvYWQ9ImFsZXJ0KDEpIi8+" alt="x">`,
+		"unclosed img":          `<img src=x onerror=alert(1)//`,
+		"base":                  `<base href="javascript:alert(1)//"><a href="page">x</a>`,
+		"srcset":                `<img src="/a.jpg" srcset="javascript:alert(1) 1x">`,
+		"attribute breakout":    `<a title="&quot;><img src=x onerror=alert(1)>">x</a>`,
+		"formaction":            `<button formaction="javascript:alert(1)">x</button>`,
+		"xlink href":            `<svg><a xlink:href="javascript:alert(1)"><text>x</text></a></svg>`,
+		"meta refresh":          `<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+		"link import":           `<link rel="import" href="https://evil.example/x.html">`,
+		"is attribute":          `<p is="evil-element">x</p>`,
+		"unknown element":       `<marquee onstart="alert(1)">x</marquee><custom-el onclick="alert(1)">y</custom-el>`,
+	}
+	for name, payload := range payloads {
+		t.Run(name, func(t *testing.T) {
+			out := sanitizeHTML(t, p, payload, false)
+			checkSafe(t, p, out)
+			if strings.Contains(strings.ToLower(out), "<script") {
+				t.Errorf("payload survived: %s", out)
+			}
+		})
+	}
+}
+
+func TestSanitizeHostileFixture(t *testing.T) {
+	e := newTestEngine(t, nil)
+	article := extractFixture(t, e, "sanitize/hostile.html", extractOptions{Format: formatHTML})
+	checkSafe(t, e.sanitizer, article.Content)
+	for _, marker := range []string{"base", "body", "click", "svg", "tab", "case", "noscript", "nested-noscript", "mxss", "hover", "img", "srcdoc", "object", "embed", "vb", "template", "comment"} {
+		// chaque charge utile porte son nom : alert('nom') ou msgbox('nom')
+		if strings.Contains(article.Content, "('"+marker+"')") || strings.Contains(article.Content, "(&#39;"+marker+"&#39;)") {
+			t.Errorf("payload %q left in html output", marker)
+		}
+	}
+	// le contenu de l'article est conservé
+	for _, want := range []string{"<h1>", "twenty thousand visitors", `<a href="https://harbour.example/map">north pier</a>`, `<img src="https://example.com/img/lanterns.jpg" alt="Lanterns on the quay"/>`, "<figcaption>"} {
+		if !strings.Contains(article.Content, want) {
+			t.Errorf("%q missing from html output:\n%s", want, article.Content)
+		}
+	}
+
+	// <base href="javascript:..."> ignoré : liens et images restent en https
+	for _, img := range article.Images {
+		if !strings.HasPrefix(img.URL, "https://example.com/") {
+			t.Errorf("image url %q", img.URL)
+		}
+	}
+
+	md := extractFixture(t, e, "sanitize/hostile.html", extractOptions{Format: formatMarkdown}).Content
+	if strings.Contains(strings.ToLower(md), "script:") || strings.Contains(md, "onerror") {
+		t.Errorf("payload left in markdown:\n%s", md)
+	}
+}
+
+func TestSanitizeKeepsArticleStructure(t *testing.T) {
+	p, _ := newSanitizePolicy(nil, nil)
+	page := `<h2 onclick="x()">Heading</h2><p>Text with <a href="/rel">a link</a>, <em>emphasis</em> and <code class="language-go">code</code>.</p>` +
+		`<ul><li>one</li></ul><ol start="3"><li>three</li></ol><blockquote><p>quote</p></blockquote><pre><code>x := 1</code></pre>` +
+		`<table><tr><th scope="col">h</th></tr><tr><td colspan="2">c</td></tr></table>` +
+		`<figure><img src="a.jpg" alt="A" width="10"><figcaption>cap</figcaption></figure><a href="mailto:desk@harbour.example">mail</a>`
+	out := sanitizeHTML(t, p, page, false)
+	checkSafe(t, p, out)
+	for _, want := range []string{
+		"<h2>Heading</h2>", `<a href="https://harbour.example/rel">a link</a>`, "<em>emphasis</em>", `<code class="language-go">`,
+		"<ul><li>one</li></ul>", `<ol start="3">`, "<blockquote><p>quote</p></blockquote>", "<pre><code>x := 1</code></pre>",
+		`<th scope="col">h</th>`, `<td colspan="2">c</td>`, `<img src="https://harbour.example/news/a.jpg" alt="A" width="10"/>`,
+		"<figcaption>cap</figcaption>", `href="mailto:desk@harbour.example"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q missing from\n%s", want, out)
+		}
+	}
+}
+
+func TestSanitizeDataImages(t *testing.T) {
+	p, _ := newSanitizePolicy(nil, nil)
+	png := `<img src="data:image/png;base64,iVBORw0KGgo=" alt="dot">`
+	if out := sanitizeHTML(t, p, png, false); strings.Contains(out, "data:") {
+		t.Errorf("data image kept without data_images: %s", out)
+	}
+	if out := sanitizeHTML(t, p, png, true); !strings.Contains(out, `src="data:image/png;base64,iVBORw0KGgo="`) {
+		t.Errorf("safe data image dropped with data_images: %s", out)
+	}
+	// jamais de SVG, ni de data: dans href, même avec data_images
+	for _, payload := range []string{
+		`<img src="data:image/svg+xml;base64,PHN2Zy8+" alt="x">`,
+		`<img src="data:image/png;base64,iVBO<script>" alt="x">`,
+		`<a href="data:image/png;base64,iVBORw0KGgo=">x</a>`,
+	} {
+		if out := sanitizeHTML(t, p, payload, true); strings.Contains(out, "data:") {
+			t.Errorf("%s: kept as %s", payload, out)
+		}
+	}
+}
+
+func TestSanitizePolicyConfig(t *testing.T) {
+	p, err := newSanitizePolicy([]string{"mark", "SCRIPT", "iframe"}, []string{"span:class", "p:style", "a:onclick", "img:srcset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := sanitizeHTML(t, p, `<p style="color: red"><mark>hi</mark><span class="note">n</span><a href="/x" onclick="alert(1)">x</a></p><script>alert(1)</script><iframe src="/f"></iframe><img src="/a.jpg" srcset="/b.jpg 2x">`, false)
+	checkSafe(t, p, out)
+	for _, want := range []string{`<p style="color: red">`, "<mark>hi</mark>", `<span class="note">`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q missing from %s", want, out)
+		}
+	}
+	// les éléments et attributs dangereux restent refusés, même configurés
+	for _, banned := range []string{"<script", "<iframe", "onclick", "srcset"} {
+		if strings.Contains(out, banned) {
+			t.Errorf("%q allowed by config: %s", banned, out)
+		}
+	}
+	// style autorisé, mais pas exécutable
+	for _, style := range []string{"width: expression(alert(1))", "background: url(javascript:alert(1))", "background:URL ('x')", `behavior: url(x.htc)`, `@import "x.css"`, `color: \65xpression(1)`} {
+		if out := sanitizeHTML(t, p, `<p style="`+html.EscapeString(style)+`">x</p>`, false); strings.Contains(out, "style=") {
+			t.Errorf("style %q kept: %s", style, out)
+		}
+	}
+
+	if _, err := newSanitizePolicy(nil, []string{"class"}); err == nil {
+		t.Error("attribute without tag accepted")
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		key, url string
+		want     bool
+	}{
+		{"href", "https://harbour.example/", true},
+		{"href", "/relative?x=1", true},
+		{"href", "mailto:a@b.example", true},
+		{"href", "tel:+331234", true},
+		{"href", "ftp://files.example/", false},
+		{"href", "java\x00script:alert(1)", false},
+		{"href", " \tjavascript:alert(1)", false},
+		{"src", "mailto:a@b.example", false},
+		{"src", "http://harbour.example/a.png", true},
+		{"src", "//cdn.example/a.png", true},
+	}
+	for _, tt := range tests {
+		if got := safeURL(tt.key, tt.url); got != tt.want {
+			t.Errorf("safeURL(%s, %q) = %v, want %v", tt.key, tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// server porte ce dont les handlers et middlewares ont besoin : la
+// configuration, les clés, le limiteur de débit et le moteur d'extraction.
+// Les handlers en sont des méthodes.
+type server struct {
+	cfg       *Config
+	engine    *engine
+	apiKeys   *keyStore
+	adminKeys *keyStore
+	limiter   *limiter
+
+	// passe à true dès le début de l'arrêt
+	shuttingDown atomic.Bool
+}
+
+func newServer(cfg *Config) (*server, error) {
+	e, err := newEngine(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &server{
+		cfg:       cfg,
+		engine:    e,
+		apiKeys:   &keyStore{},
+		adminKeys: &keyStore{},
+		limiter:   newLimiter(cfg.RateLimitRPM, cfg.RateLimitBurst),
+	}, nil
+}
+
+func (s *server) healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyzHandler renvoie 503 pendant l'arrêt pour que le load balancer nous retire
+func (s *server) readyzHandler(c *gin.Context) {
+	if s.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// serve démarre le serveur et le laisse terminer les requêtes en cours
+// à la réception de SIGTERM ou SIGINT
+func (s *server) serve(handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              ":" + s.cfg.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, draining requests for up to %s", s.cfg.ShutdownTimeout)
+	s.shuttingDown.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	// /readyz répond 503 pendant SHUTDOWN_READINESS_DELAY, le temps que le
+	// load balancer nous retire, avant la fermeture des listeners ; ce délai
+	// est pris sur SHUTDOWN_TIMEOUT
+	time.Sleep(s.cfg.ShutdownReadinessDelay)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	s.engine.jobs.drain(shutdownCtx)
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// freePort réserve puis libère un port local
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	s, ts := newTestServer(t, nil)
+	for path, want := range map[string]int{"/healthz": http.StatusOK, "/readyz": http.StatusOK} {
+		resp, err := ts.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: got %d", path, resp.StatusCode)
+		}
+	}
+	s.shuttingDown.Store(true)
+	resp, err := ts.Client().Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while shutting down: got %d", resp.StatusCode)
+	}
+}
+
+// serveClient, sans keep-alive : une connexion ouverte d'avance par le
+// transport puis jamais utilisée reste StateNew côté serveur, et Shutdown
+// l'attend jusqu'à 5 s
+var serveClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// startServe lance s.serve sur un port libre et attend qu'il écoute ; served
+// reçoit le retour de serve
+func startServe(t *testing.T, env map[string]string) (*server, string, chan error) {
+	t.Helper()
+	port := freePort(t)
+	values := map[string]string{"PORT": port}
+	for k, v := range env {
+		values[k] = v
+	}
+	s, err := newServer(testConfig(t, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.loadAPIKeys(); err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.serve(s.routes()) }()
+
+	base := "http://127.0.0.1:" + port
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := serveClient.Get(base + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+			return s, base, served
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server not listening: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// après SIGTERM, /readyz répond 503 sur le listener encore ouvert pendant
+// SHUTDOWN_READINESS_DELAY, puis les connexions sont refusées
+func TestReadinessFailsBeforeListenerCloses(t *testing.T) {
+	const delay = 400 * time.Millisecond
+	_, base, served := startServe(t, map[string]string{"SHUTDOWN_READINESS_DELAY": delay.String(), "SHUTDOWN_TIMEOUT": "5s"})
+
+	signaled := time.Now()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	var unready time.Duration
+	for unready == 0 {
+		resp, err := serveClient.Get(base + "/readyz")
+		if err != nil {
+			t.Fatalf("/readyz unreachable before reporting 503: %v", err)
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusServiceUnavailable:
+			unready = time.Since(signaled)
+		case resp.StatusCode != http.StatusOK:
+			t.Fatalf("/readyz: got %d", resp.StatusCode)
+		case time.Since(signaled) > delay:
+			t.Fatal("/readyz still 200 after the readiness delay")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if unready >= delay {
+		t.Errorf("503 only after %s", unready)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return")
+	}
+	if elapsed := time.Since(signaled); elapsed < delay {
+		t.Errorf("listener closed after %s, before the %s readiness delay", elapsed, delay)
+	}
+	if resp, err := serveClient.Get(base + "/readyz"); err == nil {
+		resp.Body.Close()
+		t.Errorf("connection accepted after shutdown: %d", resp.StatusCode)
+	}
+}
+
+// SIGTERM pendant une extraction lente : elle se termine avec 200, puis serve rend la main
+func TestGracefulShutdownDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+		largePage(w, r)
+	}))
+	defer origin.Close()
+
+	s, base, served := startServe(t, map[string]string{"SHUTDOWN_TIMEOUT": "5s"})
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, base+"/extract?url="+origin.URL, nil)
+		req.Header.Set("X-API-Key", testKey)
+		resp, err := serveClient.Do(req)
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		inFlight <- result{status: resp.StatusCode, body: string(body)}
+	}()
+	<-started
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-inFlight
+	if r.err != nil || r.status != http.StatusOK {
+		t.Fatalf("in-flight request: %d %v %s", r.status, r.err, r.body)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after draining")
+	}
+	if !s.shuttingDown.Load() {
+		t.Error("readiness not flipped")
+	}
+	// plus de nouvelles connexions
+	if resp, err := serveClient.Get(base + "/healthz"); err == nil {
+		resp.Body.Close()
+		t.Errorf("new connection accepted after shutdown: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// en dessous, trop peu de shingles pour que l'empreinte signifie quelque chose
+const minSimhashWords = 50
+
+// mots par shingle
+const shingleSize = 3
+
+// simhash calcule l'empreinte SimHash 64 bits du texte, en hexadécimal
+// (16 caractères). Le texte est mis en minuscules et découpé en mots
+// (lettres et chiffres) ; chaque shingle de 3 mots consécutifs est haché
+// en FNV-1a 64 et pèse son nombre d'occurrences. Un bit de l'empreinte vaut
+// 1 quand la somme pondérée des bits de même rang des hachés est positive.
+// Modifier l'une de ces étapes change toutes les empreintes déjà stockées
+// par les clients.
+func simhash(text string, words int) string {
+	if words < minSimhashWords {
+		return ""
+	}
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(tokens) < shingleSize {
+		return ""
+	}
+
+	weights := map[uint64]int{}
+	h := fnv.New64a()
+	for i := 0; i+shingleSize <= len(tokens); i++ {
+		h.Reset()
+		h.Write([]byte(strings.Join(tokens[i:i+shingleSize], " ")))
+		weights[h.Sum64()]++
+	}
+
+	var v [64]int
+	for sum, w := range weights {
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				v[bit] += w
+			} else {
+				v[bit] -= w
+			}
+		}
+	}
+	var fingerprint uint64
+	for bit := 0; bit < 64; bit++ {
+		if v[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fmt.Sprintf("%016x", fingerprint)
+}
+
+func parseSimhash(s string) (uint64, bool) {
+	if len(s) != 16 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
+	return v, err == nil
+}
+
+// similarityHandler compare deux simhash (GET /similarity?a=&b=)
+func (s *server) similarityHandler(c *gin.Context) {
+	a, okA := parseSimhash(c.Query("a"))
+	b, okB := parseSimhash(c.Query("b"))
+	if !okA || !okB {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "a and b must be 16-digit hexadecimal simhash values"))
+		return
+	}
+	distance := bits.OnesCount64(a ^ b)
+	c.JSON(http.StatusOK, gin.H{
+		"distance":         distance,
+		"threshold":        s.cfg.SimhashThreshold,
+		"likely_duplicate": distance <= s.cfg.SimhashThreshold,
+	})
+}
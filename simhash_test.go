@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"math/bits"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// empreintes de référence : si l'une change, l'algorithme a changé et les
+// simhash déjà stockés par les clients ne sont plus comparables
+func TestSimhashGolden(t *testing.T) {
+	e := newTestEngine(t, nil)
+	for _, tt := range []struct{ fixture, want string }{
+		{"wire.html", "7e693f368005b807"},
+		{"wire-edited.html", "7e613b368005b807"},
+		{"other.html", "9871e3486e34bf3c"},
+	} {
+		if got := extractFixture(t, e, "simhash/"+tt.fixture, extractOptions{}).SimHash; got != tt.want {
+			t.Errorf("%s: simhash %s, want %s", tt.fixture, got, tt.want)
+		}
+	}
+	// le texte seul, sans extraction : casse et ponctuation ignorées
+	for _, text := range []string{"the quick brown fox jumps over the lazy dog", "The quick, brown fox -- jumps over the LAZY dog!"} {
+		if got := simhash(text, minSimhashWords); got != "6f01620b0f6bf1cb" {
+			t.Errorf("simhash(%q) = %s", text, got)
+		}
+	}
+}
+
+func simhashDistance(t *testing.T, a, b string) int {
+	t.Helper()
+	x, okX := parseSimhash(a)
+	y, okY := parseSimhash(b)
+	if !okX || !okY {
+		t.Fatalf("invalid simhash %q or %q", a, b)
+	}
+	return bits.OnesCount64(x ^ y)
+}
+
+// même dépêche sur un autre site (habillage, titre, typographie, un mot
+// changé) : sous le seuil ; article différent : loin au-dessus
+func TestSimhashNearDuplicates(t *testing.T) {
+	e := newTestEngine(t, nil)
+	wire := extractFixture(t, e, "simhash/wire.html", extractOptions{})
+	edited := extractFixture(t, e, "simhash/wire-edited.html", extractOptions{})
+	other := extractFixture(t, e, "simhash/other.html", extractOptions{})
+
+	if wire.ContentHash == edited.ContentHash {
+		t.Fatal("edited fixture has the same content hash")
+	}
+	if d := simhashDistance(t, wire.SimHash, edited.SimHash); d > e.cfg.SimhashThreshold {
+		t.Errorf("edited version at distance %d, want at most %d", d, e.cfg.SimhashThreshold)
+	}
+	if d := simhashDistance(t, wire.SimHash, other.SimHash); d < 20 {
+		t.Errorf("unrelated article at distance %d, want well over the threshold", d)
+	}
+}
+
+func TestSimhashMinimumWords(t *testing.T) {
+	words := strings.Fields(strings.Repeat("alpha beta gamma delta epsilon ", 10))
+	if got := simhash(strings.Join(words[:minSimhashWords-1], " "), minSimhashWords-1); got != "" {
+		t.Errorf("%d words: simhash %q, want none", minSimhashWords-1, got)
+	}
+	if got := simhash(strings.Join(words[:minSimhashWords], " "), minSimhashWords); len(got) != 16 {
+		t.Errorf("%d words: simhash %q", minSimhashWords, got)
+	}
+
+	article := extractFixture(t, newTestEngine(t, nil), "simhash/short.html", extractOptions{})
+	if article.WordCount >= minSimhashWords || article.SimHash != "" {
+		t.Errorf("%d words: simhash %q, want none", article.WordCount, article.SimHash)
+	}
+}
+
+func TestSimilarityEndpoint(t *testing.T) {
+	e := newTestEngine(t, nil)
+	wire := extractFixture(t, e, "simhash/wire.html", extractOptions{}).SimHash
+	edited := extractFixture(t, e, "simhash/wire-edited.html", extractOptions{}).SimHash
+	other := extractFixture(t, e, "simhash/other.html", extractOptions{}).SimHash
+	_, ts := newTestServer(t, nil)
+	_, strict := newTestServer(t, map[string]string{"SIMHASH_THRESHOLD": "1"})
+
+	type similarity struct {
+		Distance        int  `json:"distance"`
+		Threshold       int  `json:"threshold"`
+		LikelyDuplicate bool `json:"likely_duplicate"`
+	}
+	tests := []struct {
+		name   string
+		a, b   string
+		strict bool
+		want   similarity
+	}{
+		{"near duplicate", wire, edited, false, similarity{2, 3, true}},
+		{"same", wire, wire, false, similarity{0, 3, true}},
+		{"unrelated", wire, other, false, similarity{35, 3, false}},
+		{"configured threshold", wire, edited, true, similarity{2, 1, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := ts
+			if tt.strict {
+				server = strict
+			}
+			var got similarity
+			if status := apiGet(t, server, "/similarity?a="+tt.a+"&b="+tt.b, &got); status != http.StatusOK || got != tt.want {
+				t.Errorf("got %d %+v, want %+v", status, got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{
+		"a=" + wire,
+		"a=" + wire + "&b=" + wire[:15],
+		"a=" + wire + "&b=" + wire + "0",
+		"a=" + wire + "&b=zz693f368005b807",
+		"a=&b=" + wire,
+	} {
+		status, body := apiRequest(t, ts, http.MethodGet, "/similarity?"+query, "", "")
+		var resp struct {
+			Error apiError `json:"error"`
+		}
+		if status != http.StatusBadRequest || json.Unmarshal(body, &resp) != nil || resp.Error.Code != codeInvalidRequest {
+			t.Errorf("%s: got %d %s, want 400", query, status, body)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+)
+
+// siteRule : sélecteurs propres à un site, prioritaires sur les heuristiques
+type siteRule struct {
+	Title  string   `json:"title,omitempty" yaml:"title"`
+	Body   string   `json:"body,omitempty" yaml:"body"`
+	Author string   `json:"author,omitempty" yaml:"author"`
+	Date   string   `json:"date,omitempty" yaml:"date"`
+	Remove []string `json:"remove,omitempty" yaml:"remove"`
+}
+
+// siteRuleSet est l'ensemble des règles chargées depuis SITE_RULES_FILE
+type siteRuleSet struct {
+	Path     string              `json:"file"`
+	LoadedAt time.Time           `json:"loaded_at"`
+	Rules    map[string]siteRule `json:"rules"` // motif d'hôte -> règle
+}
+
+// loadSiteRules lit SITE_RULES_FILE (JSON ou YAML selon l'extension) :
+// {"example.com": {...}, "*.example.org": {...}}. Tout sélecteur invalide
+// fait échouer le chargement, avec l'hôte et le champ en cause.
+func (e *engine) loadSiteRules() error {
+	path := e.cfg.SiteRulesFile
+	set := &siteRuleSet{Path: path, LoadedAt: time.Now(), Rules: map[string]siteRule{}}
+	if path == "" {
+		e.rules.Store(set)
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rules := map[string]siteRule{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &rules)
+	default:
+		err = json.Unmarshal(data, &rules)
+	}
+	if err != nil {
+		return fmt.Errorf("site rules %s: %w", path, err)
+	}
+
+	for pattern, rule := range rules {
+		key := strings.ToLower(strings.TrimSpace(pattern))
+		if key == "" || strings.Contains(strings.TrimPrefix(key, "*."), "*") {
+			return fmt.Errorf("site rules %s: invalid host pattern %q", path, pattern)
+		}
+		fields := map[string]string{"title": rule.Title, "body": rule.Body, "author": rule.Author, "date": rule.Date}
+		for i, sel := range rule.Remove {
+			fields[fmt.Sprintf("remove[%d]", i)] = sel
+		}
+		for field, sel := range fields {
+			if sel == "" {
+				continue
+			}
+			if _, err := cascadia.Compile(sel); err != nil {
+				return fmt.Errorf("site rules %s: %s: invalid %s selector %q", path, pattern, field, sel)
+			}
+		}
+		set.Rules[key] = rule
+	}
+	e.rules.Store(set)
+	return nil
+}
+
+// reloadSiteRulesOnSIGHUP recharge les règles à chaque SIGHUP
+func (e *engine) reloadSiteRulesOnSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := e.loadSiteRules(); err != nil {
+				log.Printf("site rules reload failed, keeping previous rules: %v", err)
+				continue
+			}
+			log.Println("site rules reloaded")
+		}
+	}()
+}
+
+// siteRuleFor retourne la règle de l'hôte de pageURL : correspondance exacte
+// (www. ignoré), sinon le motif *.domaine le plus long
+func (e *engine) siteRuleFor(pageURL string) (siteRule, bool) {
+	set := e.rules.Load()
+	if set == nil || len(set.Rules) == 0 {
+		return siteRule{}, false
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return siteRule{}, false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	if rule, ok := set.Rules[host]; ok {
+		return rule, true
+	}
+	if rule, ok := set.Rules[strings.TrimPrefix(host, "www.")]; ok {
+		return rule, true
+	}
+	for suffix := host; ; {
+		_, rest, found := strings.Cut(suffix, ".")
+		if !found {
+			return siteRule{}, false
+		}
+		if rule, ok := set.Rules["*."+rest]; ok {
+			return rule, true
+		}
+		suffix = rest
+	}
+}
+
+// ruleText lit le premier élément correspondant : attribut content ou datetime, sinon texte
+func ruleText(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	s := doc.Find(selector).First()
+	if s.Length() == 0 {
+		return ""
+	}
+	for _, attr := range []string{"content", "datetime"} {
+		if v := strings.TrimSpace(s.AttrOr(attr, "")); v != "" {
+			return v
+		}
+	}
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
+
+// applyRuleMetadata remplace les champs dont le sélecteur de r trouve une valeur
+func (e *engine) applyRuleMetadata(r siteRule, doc *goquery.Document, meta *Metadata) {
+	if title := ruleText(doc, r.Title); title != "" {
+		meta.Title = title
+	}
+	if authors := dedupeAuthors(namedAuthors(e.splitByline(ruleText(doc, r.Author)))); len(authors) > 0 {
+		meta.Authors, meta.Author = authors, authorNames(authors)
+	}
+	if date := ruleDate(ruleText(doc, r.Date)); date != "" {
+		meta.PublishedAt, meta.DateSource, meta.DateConfidence = date, dateSourceRule, dateHigh
+	}
+}
+
+// removeFrom retire les éléments listés dans remove
+func (r siteRule) removeFrom(doc *goquery.Document) {
+	for _, sel := range r.Remove {
+		doc.Find(sel).Remove()
+	}
+}
+
+// ruleMainContent retourne le corps désigné par body, ou nil s'il ne
+// correspond à rien (les heuristiques prennent alors le relais). Les éléments
+// parasites sont retirés autour et à l'intérieur, jamais le corps lui-même.
+func (e *engine) ruleMainContent(r siteRule, doc *goquery.Document, keep []string) *goquery.Selection {
+	if r.Body == "" || doc.Find(r.Body).Length() == 0 {
+		return nil
+	}
+	protected := e.keepFilter(keep)
+	e.removeBoilerplate(doc, func(s *goquery.Selection) bool {
+		return protected(s) || s.Is(r.Body) || s.Find(r.Body).Length() > 0
+	})
+	return doc.Find(r.Body).First()
+}
+
+// rulesHandler liste les règles chargées (GET /rules)
+func (s *server) rulesHandler(c *gin.Context) {
+	set := s.engine.rules.Load()
+	if set == nil {
+		set = &siteRuleSet{Rules: map[string]siteRule{}}
+	}
+	patterns := make([]string, 0, len(set.Rules))
+	for p := range set.Rules {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+
+	type entry struct {
+		Host string `json:"host"`
+		siteRule
+	}
+	list := make([]entry, 0, len(patterns))
+	for _, p := range patterns {
+		list = append(list, entry{Host: p, siteRule: set.Rules[p]})
+	}
+	c.JSON(http.StatusOK, gin.H{"file": set.Path, "loaded_at": set.LoadedAt, "rules": list})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func rulesEngine(t *testing.T) *engine {
+	t.Helper()
+	return newTestEngine(t, map[string]string{"SITE_RULES_FILE": "testdata/siterules/rules.yaml"})
+}
+
+// extractAs extrait testdata/name comme la page pageURL
+func extractAs(t *testing.T, e *engine, name, pageURL string) *Article {
+	t.Helper()
+	opts := extractOptions{Format: formatText}
+	if err := opts.validate(e.cfg); err != nil {
+		t.Fatal(err)
+	}
+	article, err := e.extractHTML(context.Background(), readFixture(t, name), "text/html; charset=utf-8", pageURL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return article
+}
+
+func TestSiteRuleMatching(t *testing.T) {
+	e := rulesEngine(t)
+	tests := []struct {
+		url, want string // want : sélecteur title de la règle retenue, "" si aucune
+	}{
+		{"https://news.example.com/a", "h1.headline"},
+		{"https://www.news.example.com/a", "h1.headline"},        // www. ignoré
+		{"https://sport.example.com/a", "h2.never-matches"},      // *.example.com
+		{"https://deep.sport.example.com/a", "h2.never-matches"}, // motif sur tout sous-domaine
+		{"https://ana.blogs.example.com/a", ".post-title"},       // le motif le plus long l'emporte
+		{"https://example.com/a", ""},                            // *.example.com ne couvre pas le domaine nu
+		{"https://example.org/a", ""},
+	}
+	for _, tt := range tests {
+		rule, ok := e.siteRuleFor(tt.url)
+		if ok != (tt.want != "") || rule.Title != tt.want {
+			t.Errorf("%s: rule %+v (matched=%v), want title %q", tt.url, rule, ok, tt.want)
+		}
+	}
+}
+
+func TestSiteRulePrecedence(t *testing.T) {
+	e := rulesEngine(t)
+	article := extractAs(t, e, "siterules/stream.html", "https://news.example.com/harbour")
+
+	if article.Title != "Harbour wall repairs begin" {
+		t.Errorf("title %q, want the h1.headline text", article.Title)
+	}
+	if article.Author != "Rosa Quay" {
+		t.Errorf("author %q, want the rule's byline", article.Author)
+	}
+	if article.PublishedAt != "2024-02-12T07:30:00Z" || article.DateSource != dateSourceRule {
+		t.Errorf("date %q from %q", article.PublishedAt, article.DateSource)
+	}
+	for _, want := range []string{"twenty metre breach", "closed to vehicles", "temporary pontoon"} {
+		if !strings.Contains(article.CleanText, want) {
+			t.Errorf("body misses %q", want)
+		}
+	}
+	for _, unwanted := range []string{"newsletter", "storm in pictures", "sidebar"} {
+		if strings.Contains(article.CleanText, unwanted) {
+			t.Errorf("body keeps %q", unwanted)
+		}
+	}
+}
+
+// un sélecteur qui ne trouve rien laisse la main aux heuristiques, champ par champ
+func TestSiteRuleFallsBackPerField(t *testing.T) {
+	e := rulesEngine(t)
+	heuristic := extractAs(t, newTestEngine(t, nil), "siterules/stream.html", "https://broken.example.org/harbour")
+	article := extractAs(t, e, "siterules/stream.html", "https://broken.example.org/harbour")
+
+	if article.Title == "" || article.Title != heuristic.Title {
+		t.Errorf("title %q, want the heuristic %q", article.Title, heuristic.Title)
+	}
+	if article.CleanText != heuristic.CleanText {
+		t.Error("body differs from the heuristic extraction")
+	}
+	if article.Author != heuristic.Author {
+		t.Errorf("author %q, want %q", article.Author, heuristic.Author)
+	}
+}
+
+func TestInvalidSiteRulesFailAtLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content, want string
+	}{
+		{"bad-selector.yaml", "news.example.com:\n  body: \"div[\"\n", `news.example.com: invalid body selector "div["`},
+		{"bad-remove.json", `{"a.example.com": {"remove": [".ok", "p:nope(1)"]}}`, `a.example.com: invalid remove[1] selector`},
+		{"bad-pattern.json", `{"news.*.example.com": {"title": "h1"}}`, `invalid host pattern "news.*.example.com"`},
+		{"bad-syntax.json", `{"a.example.com": `, "site rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := newEngine(testConfig(t, map[string]string{"SITE_RULES_FILE": path}))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// un rechargement invalide garde les règles précédentes
+func TestSiteRulesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	os.WriteFile(path, []byte(`{"a.example.com": {"title": "h1.first"}}`), 0o644)
+	e := newTestEngine(t, map[string]string{"SITE_RULES_FILE": path})
+
+	os.WriteFile(path, []byte(`{"a.example.com": {"title": "h1.second"}}`), 0o644)
+	if err := e.loadSiteRules(); err != nil {
+		t.Fatal(err)
+	}
+	if rule, _ := e.siteRuleFor("https://a.example.com/"); rule.Title != "h1.second" {
+		t.Errorf("after reload: %q", rule.Title)
+	}
+	os.WriteFile(path, []byte(`{"a.example.com": {"title": "h1["}}`), 0o644)
+	if err := e.loadSiteRules(); err == nil {
+		t.Fatal("invalid reload accepted")
+	}
+	if rule, _ := e.siteRuleFor("https://a.example.com/"); rule.Title != "h1.second" {
+		t.Errorf("after a failed reload: %q", rule.Title)
+	}
+}
+
+func TestRulesEndpoint(t *testing.T) {
+	_, ts := newTestServer(t, map[string]string{"SITE_RULES_FILE": "testdata/siterules/rules.yaml"})
+	var resp struct {
+		File  string `json:"file"`
+		Rules []struct {
+			Host string `json:"host"`
+			siteRule
+		} `json:"rules"`
+	}
+	status, body := adminRequest(t, ts, http.MethodGet, "/rules")
+	if status != http.StatusOK || json.Unmarshal(body, &resp) != nil {
+		t.Fatalf("status %d %s", status, body)
+	}
+	if resp.File != "testdata/siterules/rules.yaml" || len(resp.Rules) != 4 {
+		t.Fatalf("file %q, %d rules", resp.File, len(resp.Rules))
+	}
+	hosts := []string{}
+	for _, r := range resp.Rules {
+		hosts = append(hosts, r.Host)
+	}
+	if strings.Join(hosts, " ") != "*.blogs.example.com *.example.com broken.example.org news.example.com" {
+		t.Errorf("hosts %v", hosts)
+	}
+	if news := resp.Rules[3]; news.Body != "div#js-content-stream" || len(news.Remove) != 2 {
+		t.Errorf("news rule %+v", news.siteRule)
+	}
+
+	// sans fichier, la liste est vide
+	_, plain := newTestServer(t, nil)
+	resp.Rules = nil
+	if _, body := adminRequest(t, plain, http.MethodGet, "/rules"); json.Unmarshal(body, &resp) != nil || len(resp.Rules) != 0 {
+		t.Errorf("rules without a file: %s", body)
+	}
+
+	// route d'administration : une clé API ordinaire ne lit ni les règles ni
+	// le chemin du fichier
+	status, body = apiRequest(t, ts, http.MethodGet, "/rules", "", "")
+	if status != http.StatusUnauthorized || errorCode(t, body) != codeUnauthorized || strings.Contains(string(body), "rules.yaml") {
+		t.Errorf("/rules with an api key: got %d %s, want 401", status, body)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var (
+	errForbiddenAddress = errors.New("url points to a forbidden address")
+	errUnsupportedURL   = errors.New("unsupported url scheme")
+)
+
+// plages non couvertes par les méthodes de netip.Addr
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "ce réseau"
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmark
+	netip.MustParsePrefix("240.0.0.0/4"),   // réservé
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64
+}
+
+// isForbiddenIP couvre loopback, link-local, RFC1918, RFC4193 et multicast
+func isForbiddenIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, p := range forbiddenPrefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateURL vérifie le schéma puis résout l'hôte avant la requête ;
+// ALLOW_PRIVATE=true désactive la protection (wikis internes, etc.)
+func (e *engine) validateURL(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errUnsupportedURL
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errUnsupportedURL
+	}
+	if e.cfg.AllowPrivate {
+		return nil
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if isForbiddenIP(ip) {
+			return errForbiddenAddress
+		}
+		return nil
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		// l'erreur DNS sera remontée par le fetch lui-même
+		return nil
+	}
+	for _, ip := range ips {
+		if isForbiddenIP(ip) {
+			return errForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// dialControl revérifie l'IP réellement contactée (protection DNS rebinding)
+func (e *engine) dialControl(network, address string, c syscall.RawConn) error {
+	if e.cfg.AllowPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errForbiddenAddress
+	}
+	if isForbiddenIP(addrPort.Addr()) {
+		return errForbiddenAddress
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestIsForbiddenIP(t *testing.T) {
+	tests := []struct {
+		ip        string
+		forbidden bool
+	}{
+		{"127.0.0.1", true},             // loopback
+		{"127.8.9.10", true},            // loopback
+		{"::1", true},                   // loopback IPv6
+		{"169.254.169.254", true},       // link-local (métadonnées cloud)
+		{"fe80::1", true},               // link-local IPv6
+		{"10.0.0.5", true},              // RFC1918
+		{"172.16.3.4", true},            // RFC1918
+		{"172.31.255.255", true},        // RFC1918
+		{"192.168.1.1", true},           // RFC1918
+		{"fd12:3456::1", true},          // RFC4193
+		{"fc00::1", true},               // RFC4193
+		{"224.0.0.1", true},             // multicast
+		{"239.255.255.250", true},       // multicast
+		{"ff02::1", true},               // multicast IPv6
+		{"0.0.0.0", true},               // non spécifiée
+		{"100.64.0.1", true},            // CGNAT
+		{"::ffff:127.0.0.1", true},      // IPv4 mappée
+		{"::ffff:10.0.0.1", true},       // IPv4 mappée
+		{"93.184.215.14", false},        // publique
+		{"172.32.0.1", false},           // hors RFC1918
+		{"2606:4700::6810:85e5", false}, // publique IPv6
+	}
+	for _, tt := range tests {
+		if got := isForbiddenIP(netip.MustParseAddr(tt.ip)); got != tt.forbidden {
+			t.Errorf("isForbiddenIP(%s) = %v, want %v", tt.ip, got, tt.forbidden)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	e := newTestEngine(t, map[string]string{"ALLOW_PRIVATE": "false"})
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"http://169.254.169.254/latest/meta-data/", errForbiddenAddress},
+		{"http://localhost:8080/", errForbiddenAddress},
+		{"http://10.0.0.5/admin", errForbiddenAddress},
+		{"http://[::1]/", errForbiddenAddress},
+		{"ftp://example.com/file", errUnsupportedURL},
+		{"file:///etc/passwd", errUnsupportedURL},
+		{"gopher://example.com/", errUnsupportedURL},
+		{"http://93.184.215.14/", nil},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if err := e.validateURL(context.Background(), u); !errors.Is(err, tt.want) {
+			t.Errorf("validateURL(%s) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+
+	allowed := newTestEngine(t, map[string]string{"ALLOW_PRIVATE": "true"})
+	u, _ := url.Parse("http://10.0.0.5/admin")
+	if err := allowed.validateURL(context.Background(), u); err != nil {
+		t.Errorf("ALLOW_PRIVATE=true: %v", err)
+	}
+}
+
+// l'IP contactée est revérifiée à la connexion, après la résolution DNS
+func TestDialControlRejectsForbiddenAddress(t *testing.T) {
+	e := newTestEngine(t, map[string]string{"ALLOW_PRIVATE": "false"})
+	for _, addr := range []string{"127.0.0.1:80", "10.1.2.3:443", "[fd00::1]:80"} {
+		if err := e.dialControl("tcp", addr, nil); !errors.Is(err, errForbiddenAddress) {
+			t.Errorf("dialControl(%s) = %v", addr, err)
+		}
+	}
+	if err := e.dialControl("tcp", "93.184.215.14:443", nil); err != nil {
+		t.Errorf("public address: %v", err)
+	}
+}
+
+func TestExtractForbiddenAddressReturns400(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(largePage))
+	defer origin.Close()
+	_, ts := newTestServer(t, map[string]string{"ALLOW_PRIVATE": "false"})
+	for _, target := range []string{origin.URL, "http://169.254.169.254/", "http://10.0.0.5/admin"} {
+		status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape(target), "", "")
+		if status != http.StatusBadRequest || errorCode(t, body) != codeForbiddenAddress {
+			t.Errorf("%s: got %d %s", target, status, body)
+		}
+	}
+}
+
+// une URL publique redirigée vers 127.0.0.1 est refusée au saut. L'origine
+// « publique » est jouée par le proxy de l'opérateur, seul hôte local joignable.
+func TestRedirectToPrivateAddressIsBlocked(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(largePage))
+	defer internal.Close()
+	reached := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "93.184.215.14" {
+			http.Redirect(w, r, internal.URL+"/secret", http.StatusFound)
+			return
+		}
+		reached = true
+	}))
+	defer proxy.Close()
+	_, ts := newTestServer(t, map[string]string{"ALLOW_PRIVATE": "false", "OUTBOUND_PROXY": proxy.URL})
+
+	status, body := apiRequest(t, ts, http.MethodGet, "/extract?url="+url.QueryEscape("http://93.184.215.14/story"), "", "")
+	if status != http.StatusBadRequest || errorCode(t, body) != codeForbiddenAddress {
+		t.Fatalf("got %d %s, want 400 %s", status, body, codeForbiddenAddress)
+	}
+	if reached {
+		t.Error("the redirect target was fetched")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store conserve les extractions sérialisées ; une clé absente ou expirée
+// donne ok == false sans erreur. Les erreurs sont réservées aux pannes du
+// backend, que le cache traite comme des absences.
+type Store interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix supprime les clés commençant par prefix et retourne leur nombre
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+	// Stats compte les entrées de préfixe prefix et liste au plus recent clés
+	// parmi les plus récemment utilisées (si le backend le sait)
+	Stats(ctx context.Context, prefix string, recent int) (storeStats, error)
+}
+
+// storeStats : nombre d'entrées, mémoire estimée et clés récentes
+type storeStats struct {
+	Entries int
+	Bytes   int64
+	Recent  []string
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// memoryStore est un Store LRU en mémoire, borné à max entrées
+type memoryStore struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // le plus récent en tête
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+func newMemoryStore(max int) *memoryStore {
+	return &memoryStore{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if s.now().After(entry.expiresAt) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set ajoute ou remplace une entrée et évince la plus ancienne au-delà de max
+func (s *memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := s.now().Add(ttl)
+	if el, ok := s.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		s.order.MoveToFront(el)
+		return nil
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.order.Remove(el)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *memoryStore) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for key, el := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.order.Remove(el)
+			delete(s.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Stats estime la mémoire par la taille des clés et des valeurs
+func (s *memoryStore) Stats(ctx context.Context, prefix string, recent int) (storeStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats storeStats
+	now := s.now()
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*memoryEntry)
+		if !strings.HasPrefix(entry.key, prefix) || now.After(entry.expiresAt) {
+			continue
+		}
+		stats.Entries++
+		stats.Bytes += int64(len(entry.key) + len(entry.value))
+		if len(stats.Recent) < recent {
+			stats.Recent = append(stats.Recent, entry.key)
+		}
+	}
+	return stats, nil
+}
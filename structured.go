@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// StructuredData regroupe les données structurées brutes de la page (include_structured=true)
+type StructuredData struct {
+	JSONLD    []any             `json:"json_ld"`
+	Meta      map[string]string `json:"meta"` // og:* et twitter:*
+	Microdata []any             `json:"microdata"`
+	Warnings  []string          `json:"warnings"`
+}
+
+// extractStructuredData lit JSON-LD, balises og:/twitter: et microdonnées.
+// À appeler avant extractMainContent, qui retire les <script> du document.
+func extractStructuredData(doc *goquery.Document, base *url.URL) *StructuredData {
+	data := &StructuredData{
+		JSONLD:    []any{},
+		Meta:      map[string]string{},
+		Microdata: []any{},
+		Warnings:  []string{},
+	}
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		block, err := parseJSONLD(s.Text())
+		if err != nil {
+			data.Warnings = append(data.Warnings, fmt.Sprintf("json-ld block %d skipped: %v", i+1, err))
+			return
+		}
+		data.JSONLD = append(data.JSONLD, flattenGraph(block)...)
+	})
+
+	doc.Find("meta[property], meta[name]").Each(func(i int, s *goquery.Selection) {
+		key := strings.ToLower(strings.TrimSpace(s.AttrOr("property", s.AttrOr("name", ""))))
+		if !strings.HasPrefix(key, "og:") && !strings.HasPrefix(key, "twitter:") {
+			return
+		}
+		// une propriété répétée (og:image...) garde sa première valeur
+		if _, seen := data.Meta[key]; !seen {
+			data.Meta[key] = strings.TrimSpace(s.AttrOr("content", ""))
+		}
+	})
+
+	// éléments de premier niveau : itemscope qui ne sont pas la propriété d'un autre
+	doc.Find("[itemscope]").Not("[itemprop]").Each(func(i int, s *goquery.Selection) {
+		data.Microdata = append(data.Microdata, microdataItem(s, base))
+	})
+	return data
+}
+
+// parseJSONLD décode un bloc JSON-LD ; les blocs échappés en entités HTML
+// (&quot;...) ou enveloppés de CDATA / commentaires sont aussi acceptés
+func parseJSONLD(text string) (any, error) {
+	text = strings.TrimSpace(text)
+	for _, wrapper := range [][2]string{{"<!--", "-->"}, {"//<![CDATA[", "//]]>"}, {"<![CDATA[", "]]>"}} {
+		if strings.HasPrefix(text, wrapper[0]) && strings.HasSuffix(text, wrapper[1]) {
+			text = strings.TrimSpace(text[len(wrapper[0]) : len(text)-len(wrapper[1])])
+		}
+	}
+
+	var data any
+	err := json.Unmarshal([]byte(text), &data)
+	if err == nil {
+		return data, nil
+	}
+	if unescaped := html.UnescapeString(text); unescaped != text {
+		if json.Unmarshal([]byte(unescaped), &data) == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid json: %v", err)
+}
+
+// flattenGraph déplie les tableaux et @graph en une liste de nœuds,
+// en reportant le @context du bloc sur chacun
+func flattenGraph(block any) []any {
+	switch v := block.(type) {
+	case []any:
+		var out []any
+		for _, item := range v {
+			out = append(out, flattenGraph(item)...)
+		}
+		return out
+	case map[string]any:
+		graph, ok := v["@graph"].([]any)
+		if !ok {
+			return []any{v}
+		}
+		var out []any
+		for _, item := range graph {
+			if node, ok := item.(map[string]any); ok {
+				if _, has := node["@context"]; !has && v["@context"] != nil {
+					node["@context"] = v["@context"]
+				}
+			}
+			out = append(out, flattenGraph(item)...)
+		}
+		return out
+	}
+	return []any{block}
+}
+
+// microdataItem convertit un élément itemscope au format JSON du WHATWG :
+// {"type": [...], "id": ..., "properties": {"nom": [valeurs]}}
+func microdataItem(s *goquery.Selection, base *url.URL) map[string]any {
+	item := map[string]any{}
+	if types := strings.Fields(s.AttrOr("itemtype", "")); len(types) > 0 {
+		item["type"] = types
+	}
+	if id := strings.TrimSpace(s.AttrOr("itemid", "")); id != "" {
+		item["id"] = resolveAgainst(base, id)
+	}
+
+	properties := map[string][]any{}
+	var walk func(*goquery.Selection)
+	walk = func(parent *goquery.Selection) {
+		parent.Children().Each(func(i int, child *goquery.Selection) {
+			_, scoped := child.Attr("itemscope")
+			if names, ok := child.Attr("itemprop"); ok {
+				var value any
+				if scoped {
+					value = microdataItem(child, base)
+				} else {
+					value = microdataValue(child, base)
+				}
+				for _, name := range strings.Fields(names) {
+					properties[name] = append(properties[name], value)
+				}
+			}
+			// un itemscope imbriqué porte ses propres propriétés
+			if !scoped {
+				walk(child)
+			}
+		})
+	}
+	walk(s)
+	item["properties"] = properties
+	return item
+}
+
+// microdataValue : valeur d'une propriété selon l'élément qui la porte
+func microdataValue(s *goquery.Selection, base *url.URL) string {
+	switch goquery.NodeName(s) {
+	case "meta":
+		return strings.TrimSpace(s.AttrOr("content", ""))
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return resolveAgainst(base, s.AttrOr("src", ""))
+	case "a", "area", "link":
+		return resolveAgainst(base, s.AttrOr("href", ""))
+	case "object":
+		return resolveAgainst(base, s.AttrOr("data", ""))
+	case "data", "meter":
+		return strings.TrimSpace(s.AttrOr("value", ""))
+	case "time":
+		if dt, ok := s.Attr("datetime"); ok {
+			return strings.TrimSpace(dt)
+		}
+	}
+	if content, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
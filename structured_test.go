@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lookup suit un chemin "a.0.b" dans du JSON décodé
+func lookup(t *testing.T, v any, path string) any {
+	t.Helper()
+	for _, step := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[step]
+		case []any:
+			i, err := strconv.Atoi(step)
+			if err != nil || i >= len(node) {
+				t.Fatalf("%s: no index %s", path, step)
+			}
+			v = node[i]
+		default:
+			t.Fatalf("%s: cannot descend into %T at %s", path, v, step)
+		}
+	}
+	return v
+}
+
+// extractStructured passe par l'API : les valeurs doivent survivre à l'encodage JSON
+func extractStructured(t *testing.T, name string) map[string]any {
+	t.Helper()
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	var resp map[string]any
+	if status := apiGet(t, ts, "/extract?include_structured=true&url="+url.QueryEscape(fixtures.URL+"/structured/"+name), &resp); status != http.StatusOK {
+		t.Fatalf("status %d: %v", status, resp)
+	}
+	data, ok := resp["structured_data"].(map[string]any)
+	if !ok {
+		t.Fatalf("no structured_data in %v", resp)
+	}
+	return data
+}
+
+func TestStructuredDataRecipe(t *testing.T) {
+	data := extractStructured(t, "recipe.html")
+
+	// @graph déplié : WebSite, Recipe, puis le bloc échappé en entités
+	if got := len(data["json_ld"].([]any)); got != 3 {
+		t.Fatalf("%d json-ld nodes, want 3: %v", got, data["json_ld"])
+	}
+	for path, want := range map[string]any{
+		"json_ld.0.@type":                       "WebSite",
+		"json_ld.1.@type":                       "Recipe",
+		"json_ld.1.@context":                    "https://schema.org",
+		"json_ld.1.author.name":                 "Ada Baker",
+		"json_ld.1.recipeIngredient.1":          "1 1/2 cups flour",
+		"json_ld.1.recipeInstructions.1.text":   "Fold in the flour and cook on a hot pan.",
+		"json_ld.1.nutrition.calories":          "250 kcal",
+		"json_ld.1.aggregateRating.ratingValue": 4.8,
+		"json_ld.1.aggregateRating.ratingCount": 312.0,
+		"json_ld.2.@type":                       "BreadcrumbList",
+		"json_ld.2.itemListElement.0.name":      "Breakfast & brunch",
+		"meta.og:title":                         "Fluffy buttermilk pancakes",
+		"meta.og:image":                         "https://cook.example/img/pancakes.jpg",
+		"meta.twitter:card":                     "summary_large_image",
+	} {
+		if got := lookup(t, data, path); got != want {
+			t.Errorf("%s = %v, want %v", path, got, want)
+		}
+	}
+	if _, ok := data["meta"].(map[string]any)["description"]; ok {
+		t.Error("plain meta description included")
+	}
+
+	// le bloc invalide est signalé sans faire échouer la requête
+	warnings := data["warnings"].([]any)
+	if len(warnings) != 1 || !strings.Contains(warnings[0].(string), "json-ld block 3") {
+		t.Errorf("warnings %v", warnings)
+	}
+}
+
+func TestStructuredDataProductMicrodata(t *testing.T) {
+	data := extractStructured(t, "product.html")
+	if got := len(data["microdata"].([]any)); got != 1 {
+		t.Fatalf("%d top-level items, want 1", got)
+	}
+	for path, want := range map[string]any{
+		"microdata.0.type.0":                                                                 "https://schema.org/Product",
+		"microdata.0.properties.name.0":                                                      "Widget Pro",
+		"microdata.0.properties.brand.0.properties.name.0":                                   "Acme",
+		"microdata.0.properties.offers.0.type.0":                                             "https://schema.org/Offer",
+		"microdata.0.properties.offers.0.properties.price.0":                                 "19.99",
+		"microdata.0.properties.offers.0.properties.priceCurrency.0":                         "EUR",
+		"microdata.0.properties.offers.0.properties.availability.0":                          "https://schema.org/InStock",
+		"microdata.0.properties.review.0.properties.author.0.properties.name.0":              "Ann Critic",
+		"microdata.0.properties.review.0.properties.datePublished.0":                         "2024-01-02",
+		"microdata.0.properties.review.0.properties.reviewRating.0.properties.ratingValue.0": "4",
+		"meta.og:price:amount":                                                               "19.99",
+	} {
+		if got := lookup(t, data, path); got != want {
+			t.Errorf("%s = %v, want %v", path, got, want)
+		}
+	}
+	// URLs résolues par rapport à la page
+	for _, path := range []string{"microdata.0.id", "microdata.0.properties.image.0"} {
+		if got, _ := lookup(t, data, path).(string); !strings.HasPrefix(got, "http://127.0.0.1:") {
+			t.Errorf("%s = %q, want an absolute url", path, got)
+		}
+	}
+	// les propriétés d'un item imbriqué ne remontent pas dans le parent
+	if _, ok := lookup(t, data, "microdata.0.properties").(map[string]any)["price"]; ok {
+		t.Error("nested offer price leaked into the product")
+	}
+	if len(data["json_ld"].([]any)) != 0 || len(data["warnings"].([]any)) != 0 {
+		t.Errorf("json_ld %v, warnings %v", data["json_ld"], data["warnings"])
+	}
+}
+
+func TestStructuredDataIsOptIn(t *testing.T) {
+	e := newTestEngine(t, nil)
+	if article := extractFixture(t, e, "structured/recipe.html", extractOptions{}); article.StructuredData != nil {
+		t.Error("structured_data returned without include_structured")
+	}
+}
+
+func TestParseJSONLDWrappers(t *testing.T) {
+	for _, text := range []string{
+		`{"@type":"Thing"}`,
+		`<!-- {"@type":"Thing"} -->`,
+		`//<![CDATA[
+{"@type":"Thing"}
+//]]>`,
+		`{&quot;@type&quot;:&quot;Thing&quot;}`,
+	} {
+		block, err := parseJSONLD(text)
+		if err != nil {
+			t.Errorf("%q: %v", text, err)
+			continue
+		}
+		if block.(map[string]any)["@type"] != "Thing" {
+			t.Errorf("%q decoded to %v", text, block)
+		}
+	}
+	if _, err := parseJSONLD(`{"@type": }`); err == nil {
+		t.Error("invalid json accepted")
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bornes du paramètre sentences de /summarize
+const (
+	defaultSummarySentences = 3
+	maxSummarySentences     = 20
+)
+
+// phrases trop courtes pour un résumé (intertitres, légendes)
+const minSummaryTokens = 4
+
+// abréviations suivies d'un point qui ne terminent pas une phrase
+var abbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "etc": true, "vs": true, "cf": true, "al": true,
+	"dr": true, "mr": true, "mrs": true, "ms": true, "prof": true, "st": true,
+	"jr": true, "sr": true, "fig": true, "p": true, "pp": true,
+	"vol": true, "jan": true, "feb": true, "apr": true, "jun": true, "jul": true,
+	"aug": true, "sept": true, "oct": true, "nov": true, "dec": true,
+	"mme": true, "mlle": true, "env": true, "sra": true, "bzw": true,
+	"z.b": true, "u.a": true, "d.h": true,
+}
+
+// summaryOptions : nombre de phrases et longueur maximale (0 : sans limite)
+type summaryOptions struct {
+	Sentences int
+	MaxChars  int
+}
+
+func querySummaryOptions(c *gin.Context) (summaryOptions, error) {
+	opts := summaryOptions{Sentences: defaultSummarySentences}
+	if v := c.Query("sentences"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSummarySentences {
+			return opts, newAPIError(http.StatusBadRequest, codeInvalidRequest, "sentences must be between 1 and "+strconv.Itoa(maxSummarySentences))
+		}
+		opts.Sentences = n
+	}
+	if v := c.Query("max_chars"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return opts, newAPIError(http.StatusBadRequest, codeInvalidRequest, "max_chars must be a positive integer")
+		}
+		opts.MaxChars = n
+	}
+	return opts, nil
+}
+
+// summarizeHandler extrait la page comme GET /extract et ajoute un résumé (GET /summarize)
+func (s *server) summarizeHandler(c *gin.Context) {
+	pageURL := c.Query("url")
+	if pageURL == "" {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing url parameter"))
+		return
+	}
+	opts, err := s.queryOptions(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sum, err := querySummaryOptions(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	article, _, err := s.engine.extractCached(c.Request.Context(), pageURL, opts, c.Query("nocache") == "true")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respondSummary(c, article, sum, fields)
+}
+
+// summarizePostHandler résume un document envoyé comme pour POST /extract (POST /summarize)
+func (s *server) summarizePostHandler(c *gin.Context) {
+	opts, err := s.queryOptions(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sum, err := querySummaryOptions(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	body, contentType, pageURL, ok := s.readUpload(c)
+	if !ok {
+		return
+	}
+
+	article, err := s.engine.extractUploaded(c.Request.Context(), body, contentType, pageURL, opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	respondSummary(c, article, sum, fields)
+}
+
+func respondSummary(c *gin.Context, article *Article, opts summaryOptions, fields *fieldSelection) {
+	if article.feed != nil {
+		respondError(c, newAPIError(http.StatusUnprocessableEntity, codeUnsupportedContentType, "feeds cannot be summarized"))
+		return
+	}
+	article.Summary = summarize(article.CleanText, opts)
+	body, err := fields.project(article)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// summarize retient les phrases les plus proches du document (TF-IDF, les
+// phrases servant de documents), dans leur ordre d'origine. Les mots
+// présents dans presque toutes les phrases pèsent peu : pas besoin de
+// liste de mots outils, quelle que soit la langue. Les phrases ne sont
+// jamais coupées : celles qui dépassent max_chars sont écartées.
+func summarize(text string, opts summaryOptions) string {
+	sentences := splitSentences(text)
+	if len(sentences) == 0 {
+		return ""
+	}
+
+	tokens := make([][]string, len(sentences))
+	df := make(map[string]int)
+	for i, s := range sentences {
+		tokens[i] = summaryTokens(s)
+		seen := make(map[string]bool)
+		for _, t := range tokens[i] {
+			if !seen[t] {
+				seen[t] = true
+				df[t]++
+			}
+		}
+	}
+
+	n := float64(len(sentences))
+	idf := func(t string) float64 { return math.Log(1 + n/float64(df[t])) }
+	vector := func(toks []string) map[string]float64 {
+		v := make(map[string]float64)
+		for _, t := range toks {
+			v[t]++
+		}
+		for t := range v {
+			v[t] *= idf(t)
+		}
+		return v
+	}
+	var all []string
+	for _, toks := range tokens {
+		all = append(all, toks...)
+	}
+	centroid := vector(all)
+
+	type candidate struct {
+		index int
+		score float64
+	}
+	var candidates []candidate
+	for i, toks := range tokens {
+		if len(toks) < minSummaryTokens {
+			continue
+		}
+		candidates = append(candidates, candidate{i, cosine(vector(toks), centroid)})
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].score > candidates[b].score })
+
+	var picked []int
+	length := 0
+	for _, cand := range candidates {
+		if len(picked) == opts.Sentences {
+			break
+		}
+		size := utf8.RuneCountInString(sentences[cand.index])
+		if len(picked) > 0 {
+			size++ // séparateur
+		}
+		if opts.MaxChars > 0 && length+size > opts.MaxChars {
+			continue
+		}
+		picked = append(picked, cand.index)
+		length += size
+	}
+	sort.Ints(picked)
+
+	var b strings.Builder
+	for i, idx := range picked {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(sentences[idx])
+	}
+	return b.String()
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, na, nb float64
+	for t, w := range a {
+		dot += w * b[t]
+		na += w * w
+	}
+	for _, w := range b {
+		nb += w * w
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// summaryTokens : mots en minuscules ; chaque caractère CJK compte pour un mot
+func summaryTokens(sentence string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(sentence) {
+		switch {
+		case isCJK(r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// splitSentences découpe le texte en phrases : chaque ligne en est au moins
+// une, puis coupure après . ! ? … suivis d'un espace et d'une majuscule, d'un
+// chiffre ou d'un guillemet, et toujours après 。！？. Les abréviations
+// connues et les initiales ("J. Smith") ne coupent pas.
+func splitSentences(text string) []string {
+	var sentences []string
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		runes := []rune(line)
+		start := 0
+		for i := 0; i < len(runes); i++ {
+			r := runes[i]
+			if strings.ContainsRune("。！？", r) {
+				end := closingPunctuation(runes, i+1)
+				add(string(runes[start:end]))
+				start, i = end, end-1
+				continue
+			}
+			if !strings.ContainsRune(".!?…", r) {
+				continue
+			}
+			end := closingPunctuation(runes, i+1)
+			if end >= len(runes) || !unicode.IsSpace(runes[end]) {
+				continue
+			}
+			if !startsSentence(runes, end) {
+				continue
+			}
+			if r == '.' && isAbbreviation(runes[start:i]) {
+				continue
+			}
+			add(string(runes[start:end]))
+			start, i = end, end-1
+		}
+		add(string(runes[start:]))
+	}
+	return sentences
+}
+
+// closingPunctuation saute les guillemets et parenthèses fermants après la ponctuation finale
+func closingPunctuation(runes []rune, i int) int {
+	for i < len(runes) && strings.ContainsRune(`"'»”’)]`, runes[i]) {
+		i++
+	}
+	return i
+}
+
+// startsSentence : après les espaces, une lettre qui n'est pas une minuscule, un chiffre ou une ponctuation ouvrante
+func startsSentence(runes []rune, i int) bool {
+	for i < len(runes) && unicode.IsSpace(runes[i]) {
+		i++
+	}
+	if i >= len(runes) {
+		return false
+	}
+	r := runes[i]
+	// toute lettre non minuscule, pour les écritures sans casse
+	return (unicode.IsLetter(r) && !unicode.IsLower(r)) || unicode.IsNumber(r) || strings.ContainsRune(`"'«“‘(¿¡-—`, r)
+}
+
+// isAbbreviation examine le mot qui précède le point
+func isAbbreviation(before []rune) bool {
+	i := len(before)
+	for i > 0 && !unicode.IsSpace(before[i-1]) && before[i-1] != '(' {
+		i--
+	}
+	word := strings.ToLower(string(before[i:]))
+	if word == "" {
+		return false
+	}
+	if abbreviations[word] {
+		return true
+	}
+	// initiale : une seule lettre majuscule
+	if w := []rune(string(before[i:])); len(w) == 1 && unicode.IsUpper(w[0]) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// summarizeFixture appelle GET /summarize sur testdata/summarize/name
+func summarizeFixture(t *testing.T, name, params string) Article {
+	t.Helper()
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	var article Article
+	if status := apiGet(t, ts, "/summarize?url="+url.QueryEscape(fixtures.URL+"/summarize/"+name)+params, &article); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	return article
+}
+
+// summaryIndexes retrouve chaque phrase du résumé parmi celles du texte et
+// retourne leurs positions ; échoue sur toute phrase tronquée ou inventée
+func summaryIndexes(t *testing.T, article Article) []int {
+	t.Helper()
+	sentences := splitSentences(article.CleanText)
+	var indexes []int
+	for _, s := range splitSentences(article.Summary) {
+		found := -1
+		for i, candidate := range sentences {
+			if candidate == s {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			t.Fatalf("summary sentence %q is not a sentence of the text", s)
+		}
+		indexes = append(indexes, found)
+	}
+	return indexes
+}
+
+func TestSummaryDefaults(t *testing.T) {
+	article := summarizeFixture(t, "water-plan.html", "")
+	// les champs de l'extraction accompagnent le résumé
+	if article.Title != "City council approves the water plan" || article.WordCount < 250 || article.CleanText == "" {
+		t.Errorf("extraction fields missing: title %q, %d words", article.Title, article.WordCount)
+	}
+	indexes := summaryIndexes(t, article)
+	if len(indexes) != defaultSummarySentences {
+		t.Fatalf("%d sentences, want %d: %q", len(indexes), defaultSummarySentences, article.Summary)
+	}
+	for i := 1; i < len(indexes); i++ {
+		if indexes[i] <= indexes[i-1] {
+			t.Errorf("sentences out of order: %v", indexes)
+		}
+	}
+	for _, offTopic := range []string{"weather", "Lunch", "vote was"} {
+		if strings.Contains(article.Summary, offTopic) {
+			t.Errorf("summary picked an off-topic sentence (%s): %q", offTopic, article.Summary)
+		}
+	}
+}
+
+func TestSummaryBounds(t *testing.T) {
+	for _, tt := range []struct {
+		params       string
+		maxSentences int
+		maxChars     int
+	}{
+		{"&sentences=1", 1, 0},
+		{"&sentences=5", 5, 0},
+		{"&sentences=10&max_chars=300", 10, 300},
+		{"&max_chars=150", 3, 150},
+	} {
+		t.Run(tt.params, func(t *testing.T) {
+			article := summarizeFixture(t, "water-plan.html", tt.params)
+			indexes := summaryIndexes(t, article)
+			if len(indexes) == 0 || len(indexes) > tt.maxSentences {
+				t.Errorf("%d sentences, want 1 to %d", len(indexes), tt.maxSentences)
+			}
+			if tt.maxChars > 0 && utf8.RuneCountInString(article.Summary) > tt.maxChars {
+				t.Errorf("%d chars, want at most %d", utf8.RuneCountInString(article.Summary), tt.maxChars)
+			}
+			if tt.maxChars == 0 && len(indexes) != tt.maxSentences {
+				t.Errorf("%d sentences, want %d", len(indexes), tt.maxSentences)
+			}
+		})
+	}
+}
+
+// aucune liste de mots outils : le français se résume aussi
+func TestSummaryFrench(t *testing.T) {
+	article := summarizeFixture(t, "plan-fr.html", "&sentences=2")
+	if indexes := summaryIndexes(t, article); len(indexes) != 2 || indexes[0] >= indexes[1] {
+		t.Errorf("indexes %v for %q", indexes, article.Summary)
+	}
+	if strings.Contains(article.Summary, "beau") || strings.Contains(article.Summary, "musiciens") {
+		t.Errorf("off-topic sentence picked: %q", article.Summary)
+	}
+}
+
+func TestSummarizeParamsAreValidated(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	for _, params := range []string{"sentences=0", "sentences=21", "sentences=three", "max_chars=0", "max_chars=-4"} {
+		status, body := apiRequest(t, ts, http.MethodGet, "/summarize?url=https://example.com/&"+params, "", "")
+		if status != http.StatusBadRequest || errorCode(t, body) != codeInvalidRequest {
+			t.Errorf("%s: got %d %s", params, status, body)
+		}
+	}
+}
+
+func TestSummarizePost(t *testing.T) {
+	_, ts := newTestServer(t, nil)
+	page := string(readFixture(t, "summarize/water-plan.html"))
+	status, body := apiRequest(t, ts, http.MethodPost, "/summarize?sentences=2", "text/html", page)
+	if status != http.StatusOK || !strings.Contains(string(body), `"summary":"`) {
+		t.Errorf("got %d %.200s", status, body)
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Dr. Moreno led the study. The vote was 7 to 2.", []string{"Dr. Moreno led the study.", "The vote was 7 to 2."}},
+		{"Pipes leak, e.g. in the harbour. Mr. J. Smith agreed.", []string{"Pipes leak, e.g. in the harbour.", "Mr. J. Smith agreed."}},
+		{`"We cannot wait," she said. "Every year we lose water." Works start soon!`, []string{`"We cannot wait," she said.`, `"Every year we lose water."`, "Works start soon!"}},
+		{"It costs 1.5 million. Really? Yes.", []string{"It costs 1.5 million.", "Really?", "Yes."}},
+		{"First line without a stop\nSecond line.", []string{"First line without a stop", "Second line."}},
+		{"M. Dupont a parlé. Le vote a eu lieu.", []string{"M. Dupont a parlé.", "Le vote a eu lieu."}},
+		{"東京は晴れでした。明日は雨です。", []string{"東京は晴れでした。", "明日は雨です。"}},
+		{"Он пришёл домой. Было поздно.", []string{"Он пришёл домой.", "Было поздно."}},
+		{"see the file.txt for details", []string{"see the file.txt for details"}},
+	}
+	for _, tt := range tests {
+		if got := splitSentences(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitSentences(%q)\n got %q\nwant %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// bornes de colspan / rowspan : au-delà, la valeur est ramenée à la borne
+const maxTableSpan = 100
+
+// au-delà, une cellule contient un texte d'article et non une donnée
+const maxDataCellWords = 80
+
+// éléments qui signalent un tableau de mise en page lorsqu'une cellule en contient
+const layoutContentSelector = "h1, h2, h3, h4, h5, h6, article, section, blockquote, form, nav, header, footer, aside"
+
+// Table est un tableau de données du contenu principal ; chaque ligne a la
+// largeur du tableau, fusions (colspan / rowspan) répétées dans chaque case
+type Table struct {
+	Caption   string     `json:"caption"`
+	Headers   []string   `json:"headers"`
+	Rows      [][]string `json:"rows"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// collectTables retourne les tableaux de données du contenu principal, dans
+// l'ordre du document. Un tableau imbriqué dans un tableau retenu est ignoré,
+// ou, avec flatten, son texte est versé dans la cellule qui le contient ;
+// celui d'un tableau de mise en page est examiné pour lui-même. Au plus
+// maxTables tableaux (MAX_TABLES) de maxCells cellules chacun, après expansion
+// des fusions (MAX_TABLE_CELLS).
+func collectTables(main *goquery.Selection, flatten bool, maxTables, maxCells int) []Table {
+	tables := []Table{}
+	var kept []*html.Node
+	main.Find("table").AddBackFiltered("table").Each(func(i int, s *goquery.Selection) {
+		if len(tables) >= maxTables {
+			return
+		}
+		n := s.Nodes[0]
+		for _, k := range kept {
+			if isAncestor(k, n) {
+				return
+			}
+		}
+		if isLayoutTable(s) {
+			return
+		}
+		table, ok := parseTable(n, flatten, maxCells)
+		if !ok {
+			return
+		}
+		kept = append(kept, n)
+		tables = append(tables, table)
+	})
+	return tables
+}
+
+func isAncestor(ancestor, n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// isLayoutTable : rôle de présentation, ou cellules contenant des intertitres,
+// plusieurs paragraphes ou un long texte
+func isLayoutTable(s *goquery.Selection) bool {
+	switch strings.ToLower(s.AttrOr("role", "")) {
+	case "presentation", "none":
+		return true
+	}
+	layout := false
+	s.Find("td, th").EachWithBreak(func(i int, cell *goquery.Selection) bool {
+		if cell.Closest("table").Get(0) != s.Get(0) {
+			return true // cellule d'un tableau imbriqué
+		}
+		switch {
+		case cell.Find(layoutContentSelector).Length() > 0,
+			cell.Find("p").Length() > 1,
+			len(strings.Fields(cell.Text())) > maxDataCellWords:
+			layout = true
+		}
+		return !layout
+	})
+	return layout
+}
+
+// parseTable construit la grille du tableau ; ok est faux pour un tableau
+// d'une seule ligne ou d'une seule colonne (mise en page, encadré)
+func parseTable(n *html.Node, flatten bool, maxCells int) (Table, bool) {
+	var caption string
+	var headRows, bodyRows []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.Data {
+		case "caption":
+			caption = cellText(c, flatten)
+		case "tr":
+			bodyRows = append(bodyRows, c)
+		case "thead":
+			headRows = append(headRows, childRows(c)...)
+		case "tbody", "tfoot":
+			bodyRows = append(bodyRows, childRows(c)...)
+		}
+	}
+
+	grid, truncated := expandRows(append(headRows, bodyRows...), flatten, maxCells)
+	width := 0
+	for _, row := range grid {
+		width = max(width, len(row))
+	}
+	if len(grid) < 2 || width < 2 {
+		return Table{}, false
+	}
+	for i := range grid {
+		for len(grid[i]) < width {
+			grid[i] = append(grid[i], "")
+		}
+	}
+
+	table := Table{Caption: caption, Truncated: truncated}
+	headerCount := min(len(headRows), len(grid))
+	if headerCount == 0 && allHeaderCells(bodyRows[0]) {
+		headerCount = 1
+	}
+	if headerCount > 0 {
+		table.Headers = mergeHeaderRows(grid[:headerCount])
+	} else {
+		table.Headers = []string{}
+	}
+	table.Rows = grid[headerCount:]
+	if table.Rows == nil {
+		table.Rows = [][]string{}
+	}
+	return table, true
+}
+
+func childRows(n *html.Node) []*html.Node {
+	var rows []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "tr" {
+			rows = append(rows, c)
+		}
+	}
+	return rows
+}
+
+func rowCells(tr *html.Node) []*html.Node {
+	var cells []*html.Node
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (c.Data == "td" || c.Data == "th") {
+			cells = append(cells, c)
+		}
+	}
+	return cells
+}
+
+func allHeaderCells(tr *html.Node) bool {
+	cells := rowCells(tr)
+	for _, c := range cells {
+		if c.Data != "th" {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
+
+// expandRows répète le texte des cellules fusionnées dans chaque case
+// couverte ; s'arrête à maxCells cellules
+func expandRows(rows []*html.Node, flatten bool, maxCells int) ([][]string, bool) {
+	type pending struct {
+		text string
+		left int // lignes restant à couvrir
+	}
+	var carry []pending
+	var grid [][]string
+	cells := 0
+
+	for r, tr := range rows {
+		var row []string
+		col := 0
+		// cases encore couvertes par le rowspan d'une ligne précédente
+		skipCarried := func() {
+			for col < len(carry) && carry[col].left > 0 {
+				row = append(row, carry[col].text)
+				carry[col].left--
+				col++
+			}
+		}
+		for _, cell := range rowCells(tr) {
+			skipCarried()
+			text := cellText(cell, flatten)
+			colspan := spanAttr(cell, "colspan", 1)
+			rowspan := spanAttr(cell, "rowspan", 1)
+			if rowspan == 0 { // jusqu'à la fin du tableau
+				rowspan = len(rows) - r
+			}
+			for i := 0; i < colspan; i++ {
+				row = append(row, text)
+				if col == len(carry) {
+					carry = append(carry, pending{})
+				}
+				carry[col] = pending{text: text, left: rowspan - 1}
+				col++
+			}
+		}
+		for ; col < len(carry); col++ {
+			if carry[col].left > 0 {
+				row = append(row, carry[col].text)
+				carry[col].left--
+			} else {
+				row = append(row, "")
+			}
+		}
+
+		if cells+len(row) > maxCells {
+			return grid, true
+		}
+		cells += len(row)
+		grid = append(grid, row)
+	}
+	return grid, false
+}
+
+// spanAttr lit colspan ou rowspan, ramené entre 0 (rowspan seulement) et maxTableSpan
+func spanAttr(n *html.Node, key string, def int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(attr(n, key)))
+	if err != nil || v < 0 || (v == 0 && key == "colspan") {
+		return def
+	}
+	return min(v, maxTableSpan)
+}
+
+// mergeHeaderRows réunit plusieurs lignes d'en-tête colonne par colonne
+// ("2024" au-dessus de "T1" donne "2024 T1")
+func mergeHeaderRows(rows [][]string) []string {
+	headers := make([]string, len(rows[0]))
+	for col := range headers {
+		var parts []string
+		for _, row := range rows {
+			if text := row[col]; text != "" && (len(parts) == 0 || parts[len(parts)-1] != text) {
+				parts = append(parts, text)
+			}
+		}
+		headers[col] = strings.Join(parts, " ")
+	}
+	return headers
+}
+
+// cellText : texte brut de la cellule, espaces fusionnés ; le texte des
+// tableaux imbriqués n'est gardé qu'avec flatten
+func cellText(n *html.Node, flatten bool) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case n.Type != html.ElementNode:
+		case skippedTextTags[n.Data] && n.Data != "h1":
+			return
+		case n.Data == "table" && !flatten:
+			return
+		case n.Data == "br", n.Data == "td", n.Data == "th", n.Data == "p", n.Data == "li":
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return normalizeText(strings.ReplaceAll(b.String(), "\n", " "))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func extractTables(t *testing.T, e *engine, name string) []Table {
+	t.Helper()
+	return extractFixture(t, e, "tables/"+name, extractOptions{IncludeTables: true}).Tables
+}
+
+func TestTableSpansExpanded(t *testing.T) {
+	tables := extractTables(t, newTestEngine(t, nil), "results.html")
+	if len(tables) != 1 {
+		t.Fatalf("got %d tables, want 1", len(tables))
+	}
+	got := tables[0]
+	want := Table{
+		Caption: "Seats by ward, 2020 and 2024",
+		// deux lignes d'en-tête réunies, rowspan de "Ward" compris
+		Headers: []string{"Ward", "2020 Seats", "2020 Share", "2024 Seats", "2024 Share"},
+		Rows: [][]string{
+			{"Riverside", "4", "38%", "5", "41%"},
+			{"Riverside", "no contest", "no contest", "2", "12%"},
+			{"Hillside", "3", "29%", "3", "30%"},
+			{"Old Town", "1", "29%", "2", "18%"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestHeaderlessTable(t *testing.T) {
+	tables := extractTables(t, newTestEngine(t, nil), "headerless.html")
+	if len(tables) != 1 {
+		t.Fatalf("got %d tables, want 1", len(tables))
+	}
+	want := [][]string{
+		{"Monday", "06:12", "18:40"},
+		{"Tuesday", "06:58", "19:25"},
+		{"Wednesday", "07:41", "20:07"},
+	}
+	if got := tables[0]; got.Headers == nil || len(got.Headers) != 0 || !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("headers %q rows %q, want no headers and %q", got.Headers, got.Rows, want)
+	}
+}
+
+// la page entière est un tableau de mise en page : seul le tableau de
+// données qu'il contient est retenu
+func TestLayoutTableExcluded(t *testing.T) {
+	tables := extractTables(t, newTestEngine(t, nil), "layout.html")
+	want := []Table{{
+		Caption: "",
+		Headers: []string{"Plot size", "Old rent", "New rent"},
+		Rows:    [][]string{{"Half plot", "£32", "£36"}, {"Full plot", "£58", "£65"}},
+	}}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("got %+v\nwant %+v", tables, want)
+	}
+}
+
+func TestLayoutTableHeuristic(t *testing.T) {
+	tests := []struct {
+		name, html string
+		layout     bool
+	}{
+		{"data", `<table><tr><td>a</td><td>1</td></tr><tr><td>b</td><td>2</td></tr></table>`, false},
+		{"short paragraph in a cell", `<table><tr><td><p>a</p></td><td>1</td></tr><tr><td>b</td><td>2</td></tr></table>`, false},
+		{"presentation role", `<table role="presentation"><tr><td>a</td><td>1</td></tr></table>`, true},
+		{"heading in a cell", `<table><tr><td><h2>Title</h2></td><td>1</td></tr></table>`, true},
+		{"paragraphs in a cell", `<table><tr><td><p>one</p><p>two</p></td><td>1</td></tr></table>`, true},
+		{"long text", `<table><tr><td>` + strings.Repeat("word ", maxDataCellWords+1) + `</td><td>1</td></tr></table>`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := mustParse(t, "<html><body>"+tt.html+"</body></html>")
+			if got := isLayoutTable(doc.Find("table").First()); got != tt.layout {
+				t.Errorf("layout = %v, want %v", got, tt.layout)
+			}
+		})
+	}
+}
+
+func TestParseTableShapes(t *testing.T) {
+	tests := []struct {
+		name, html string
+		ok         bool
+		rows       [][]string
+	}{
+		{"single row", `<table><tr><td>a</td><td>b</td></tr></table>`, false, nil},
+		{"single column", `<table><tr><td>a</td></tr><tr><td>b</td></tr></table>`, false, nil},
+		{"short rows padded", `<table><tr><td>a</td><td>b</td><td>c</td></tr><tr><td>d</td></tr></table>`, true,
+			[][]string{{"a", "b", "c"}, {"d", "", ""}}},
+		{"rowspan=0 to the end", `<table><tr><td rowspan="0">a</td><td>b</td></tr><tr><td>c</td></tr><tr><td>d</td></tr></table>`, true,
+			[][]string{{"a", "b"}, {"a", "c"}, {"a", "d"}}},
+		{"invalid spans ignored", `<table><tr><td colspan="0">a</td><td colspan="x">b</td></tr><tr><td>c</td><td>d</td></tr></table>`, true,
+			[][]string{{"a", "b"}, {"c", "d"}}},
+		{"inline markup", `<table><tr><td><b>bold</b> <i>it</i></td><td>x<br>y</td></tr><tr><td>c</td><td>d</td></tr></table>`, true,
+			[][]string{{"bold it", "x y"}, {"c", "d"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := mustParse(t, "<html><body>"+tt.html+"</body></html>")
+			table, ok := parseTable(doc.Find("table").Get(0), false, 1000)
+			if ok != tt.ok || (ok && !reflect.DeepEqual(table.Rows, tt.rows)) {
+				t.Errorf("ok=%v rows %q, want ok=%v %q", ok, table.Rows, tt.ok, tt.rows)
+			}
+		})
+	}
+}
+
+func TestNestedTables(t *testing.T) {
+	page := `<html><body><table>
+<tr><th>Team</th><th>Scorers</th></tr>
+<tr><td>Rovers</td><td><table><tr><td>Ames</td><td>12'</td></tr><tr><td>Boyd</td><td>80'</td></tr></table></td></tr>
+<tr><td>United</td><td>none</td></tr>
+</table></body></html>`
+	doc := mustParse(t, page)
+
+	tables := collectTables(doc.Find("body"), false, 10, 1000)
+	if len(tables) != 1 || tables[0].Rows[0][1] != "" {
+		t.Errorf("nested table not skipped: %+v", tables)
+	}
+	tables = collectTables(doc.Find("body"), true, 10, 1000)
+	if len(tables) != 1 || tables[0].Rows[0][1] != "Ames 12' Boyd 80'" {
+		t.Errorf("nested table not flattened: %+v", tables)
+	}
+}
+
+func TestTableCaps(t *testing.T) {
+	table := `<table><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr><tr><td>e</td><td>f</td></tr></table>`
+	doc := mustParse(t, "<html><body>"+table+table+table+"</body></html>")
+
+	if tables := collectTables(doc.Find("body"), false, 2, 1000); len(tables) != 2 {
+		t.Errorf("MAX_TABLES=2: got %d tables", len(tables))
+	}
+	tables := collectTables(doc.Find("body"), false, 10, 4)
+	if len(tables) != 3 || len(tables[0].Rows) != 2 || !tables[0].Truncated {
+		t.Errorf("MAX_TABLE_CELLS=4: %+v", tables)
+	}
+}
+
+func TestIncludeTablesParam(t *testing.T) {
+	origin := fixtureServer(t)
+	_, ts := newTestServer(t, map[string]string{"MAX_TABLE_CELLS": "10"})
+	page := url.QueryEscape(origin.URL + "/tables/results.html")
+
+	var article Article
+	if status := apiGet(t, ts, "/extract?url="+page, &article); status != http.StatusOK || article.Tables != nil {
+		t.Errorf("tables without include_tables: %d %+v", status, article.Tables)
+	}
+	article = Article{}
+	if status := apiGet(t, ts, "/extract?url="+page+"&include_tables=true", &article); status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	// 5 cellules par ligne : les deux lignes d'en-tête seulement
+	if len(article.Tables) != 1 || len(article.Tables[0].Rows) != 0 || !article.Tables[0].Truncated {
+		t.Errorf("capped tables %+v", article.Tables)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// nature d'un bloc de texte
+const (
+	blockParagraph = iota
+	blockHeading
+	blockItem
+	blockPre
+)
+
+// textBlock est un bloc du texte brut : paragraphe, intertitre, élément de liste ou <pre>
+type textBlock struct {
+	kind  int
+	level int  // niveau d'un intertitre (2 à 6, <h1> étant le titre)
+	quote bool // dans un <blockquote>
+	text  string
+}
+
+// éléments qui ouvrent un nouveau bloc
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "aside": true, "nav": true, "blockquote": true,
+	"figure": true, "figcaption": true, "ul": true, "ol": true, "dl": true,
+	"dt": true, "dd": true, "table": true, "tr": true, "td": true, "th": true,
+	"caption": true, "hr": true, "address": true, "details": true, "summary": true,
+	"form": true, "fieldset": true,
+}
+
+var headingTags = map[string]bool{"h2": true, "h3": true, "h4": true, "h5": true, "h6": true}
+
+// éléments dont le texte n'est pas du contenu
+var skippedTextTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "head": true,
+	"svg": true, "math": true, "iframe": true, "object": true, "button": true, "select": true,
+	"h1": true, // le titre, renvoyé à part
+}
+
+// caractères invisibles retirés du texte : espaces sans chasse, BOM, trait d'union conditionnel
+var invisibleChars = strings.NewReplacer(
+	"\u200b", "", "\u200c", "", "\u200d", "", "\u2060", "", "\ufeff", "", "\u00ad", "",
+)
+
+// contentBlocks parcourt le sous-arbre et découpe son texte en blocs :
+// chaque élément de bloc en commence un, <br> devient un saut de ligne,
+// les espaces sont fusionnés (sauf dans <pre>) et les blocs vides ignorés
+func contentBlocks(s *goquery.Selection) []textBlock {
+	w := &textWalker{}
+	for _, n := range s.Nodes {
+		w.walk(n)
+	}
+	w.flush()
+	return w.blocks
+}
+
+type textWalker struct {
+	blocks []textBlock
+	cur    strings.Builder
+	kind   int
+	level  int
+	pre    int
+	quote  int
+}
+
+func (w *textWalker) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre > 0 {
+			w.cur.WriteString(n.Data)
+		} else {
+			// les retours à la ligne du source ne sont que des espaces
+			w.cur.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+		}
+		return
+	case html.ElementNode:
+	case html.DocumentNode:
+		w.children(n)
+		return
+	default:
+		return
+	}
+
+	tag := n.Data
+	switch {
+	case skippedTextTags[tag]:
+	case tag == "br":
+		w.cur.WriteByte('\n')
+	case headingTags[tag]:
+		w.level = int(tag[1] - '0')
+		w.block(n, blockHeading)
+	case tag == "li":
+		w.block(n, blockItem)
+	case tag == "pre":
+		w.pre++
+		w.block(n, blockPre)
+		w.pre--
+	case tag == "blockquote":
+		w.quote++
+		w.block(n, w.kind)
+		w.quote--
+	case blockTags[tag]:
+		w.block(n, w.kind)
+	default:
+		w.children(n)
+	}
+}
+
+func (w *textWalker) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+}
+
+// block isole le texte de n dans un bloc de nature kind ; le texte qui suit
+// reprend la nature du bloc englobant
+func (w *textWalker) block(n *html.Node, kind int) {
+	w.flush()
+	outer := w.kind
+	w.kind = kind
+	w.children(n)
+	w.flush()
+	w.kind = outer
+}
+
+func (w *textWalker) flush() {
+	text := w.cur.String()
+	w.cur.Reset()
+	if w.kind == blockPre {
+		text = strings.Trim(invisibleChars.Replace(text), "\n")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+	} else if text = normalizeText(text); text == "" {
+		return
+	}
+	b := textBlock{kind: w.kind, quote: w.quote > 0, text: text}
+	if w.kind == blockHeading {
+		b.level = w.level
+	}
+	w.blocks = append(w.blocks, b)
+}
+
+// normalizeText fusionne espaces, tabulations et espaces insécables, retire
+// les caractères invisibles et les lignes vides
+func normalizeText(text string) string {
+	text = invisibleChars.Replace(text)
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.FieldsFunc(line, unicode.IsSpace), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// texte brut de testdata/text/*.html, comparé à testdata/golden/text-*.txt
+func TestCleanTextGolden(t *testing.T) {
+	e := newTestEngine(t, nil)
+	for _, name := range []string{"nested-divs", "poetry", "lists"} {
+		t.Run(name, func(t *testing.T) {
+			article := extractFixture(t, e, "text/"+name+".html", extractOptions{})
+			checkGolden(t, "text-"+name+".txt", article.CleanText)
+
+			// paragraphs découpe le même texte
+			if got := strings.Join(article.Paragraphs, "\n\n"); got != article.CleanText {
+				t.Errorf("paragraphs joined differ from clean_text:\n%s", got)
+			}
+			for i, p := range article.Paragraphs {
+				if strings.TrimSpace(p) == "" {
+					t.Errorf("paragraph %d is empty", i)
+				}
+			}
+		})
+	}
+}
+
+func TestContentBlocks(t *testing.T) {
+	tests := []struct {
+		name, html string
+		want       []string
+	}{
+		{"adjacent paragraphs", `<p>End of sentence.</p><p>Start of next.</p>`, []string{"End of sentence.", "Start of next."}},
+		{"inline elements joined", `<p>One <em>two</em><a href="#"> three</a></p>`, []string{"One two three"}},
+		{"whitespace collapsed", "<p>a \t  b\n\nc</p>", []string{"a b c"}},
+		{"invisible characters", "<p>zero\u200bwidth soft\u00adhyphen\ufeff</p>", []string{"zerowidth softhyphen"}},
+		{"empty paragraphs dropped", `<p> </p><p>kept</p><div><p>&nbsp;</p></div>`, []string{"kept"}},
+		{"br as newline", `<p>line one<br>line two<br><br>line three</p>`, []string{"line one\nline two\nline three"}},
+		{"text around nested divs", `<div>before<div>inner</div>after</div>`, []string{"before", "inner", "after"}},
+		{"skipped elements", `<p>shown<script>hidden()</script><button>Share</button></p>`, []string{"shown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := mustParse(t, "<html><body>"+tt.html+"</body></html>")
+			var got []string
+			for _, b := range contentBlocks(doc.Find("body")) {
+				got = append(got, b.text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// en dessous, la détection de langue n'est pas fiable
+const minWordsForLanguage = 40
+
+// isCJK : caractères comptés un par un (pas d'espaces entre les mots)
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// countWords retourne le nombre de mots et, parmi eux, les caractères CJK
+func countWords(text string) (words, cjk int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			words++
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		case inWord && (r == '\'' || r == '’' || r == '-'):
+			// l'apostrophe et le trait d'union ne coupent pas le mot
+		default:
+			inWord = false
+		}
+	}
+	return words, cjk
+}
+
+// readingTime en secondes, arrondi à la seconde supérieure, à wpm mots par
+// minute et cpm caractères par minute pour le chinois et le japonais
+func readingTime(words, cjk, wpm, cpm int) int {
+	seconds := float64(words-cjk)/float64(wpm)*60 + float64(cjk)/float64(cpm)*60
+	return int(math.Ceil(seconds))
+}
+
+// mots très fréquents par langue à écriture latine
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "was", "for", "with", "as", "on", "are", "this", "be", "by", "have", "not", "from"},
+	"fr": {"le", "la", "les", "et", "des", "est", "un", "une", "du", "que", "en", "pour", "dans", "qui", "pas", "sur", "au", "avec", "ce", "sont"},
+	"es": {"el", "la", "los", "las", "y", "de", "que", "en", "un", "una", "es", "por", "con", "para", "del", "se", "no", "como", "su", "al"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "auf", "für", "dem", "auch", "es", "im", "sie"},
+	"it": {"il", "di", "che", "e", "la", "per", "un", "una", "non", "sono", "gli", "con", "del", "della", "le", "si", "al", "nel", "come", "anche"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "por", "mais", "dos", "das", "se"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "op", "te", "in", "niet", "zijn", "met", "voor", "ook", "maar", "aan", "er", "als", "bij"},
+}
+
+var stopwordSets = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool)
+	for lang, words := range stopwords {
+		set := make(map[string]bool)
+		for _, w := range words {
+			set[w] = true
+		}
+		sets[lang] = set
+	}
+	return sets
+}()
+
+// detectLanguage retourne un code ISO 639-1, ou "" si le texte est trop court
+// ou ambigu. L'écriture suffit pour les langues non latines ; sinon on compte
+// les mots outils de chaque langue.
+func detectLanguage(text string, words int) string {
+	if words < minWordsForLanguage {
+		return ""
+	}
+	if lang := detectByScript(text); lang != "" {
+		return lang
+	}
+
+	scores := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for lang, set := range stopwordSets {
+			if set[w] {
+				scores[lang]++
+			}
+		}
+	}
+
+	best, bestScore, second := "", 0, 0
+	for lang, score := range scores {
+		if score > bestScore {
+			best, bestScore, second = lang, score, bestScore
+		} else if score > second {
+			second = score
+		}
+	}
+	// au moins 5 % de mots outils et une avance nette
+	if bestScore*20 < words || bestScore == second {
+		return ""
+	}
+	return best
+}
+
+// detectByScript reconnaît les langues à partir de leur écriture dominante
+func detectByScript(text string) string {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			counts["kana"]++
+		case unicode.Is(unicode.Han, r):
+			counts["han"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["cyrillic"]++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				counts["uk"]++
+			}
+		case unicode.Is(unicode.Greek, r):
+			counts["el"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["he"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// le japonais mélange kanji et kana
+	if counts["kana"]*10 >= letters {
+		return "ja"
+	}
+	if (counts["han"]+counts["kana"])*2 >= letters {
+		return "zh"
+	}
+	if counts["cyrillic"]*2 >= letters {
+		if counts["uk"] > 0 {
+			return "uk"
+		}
+		return "ru"
+	}
+	for _, lang := range []string{"ko", "el", "ar", "he", "th", "hi"} {
+		if counts[lang]*2 >= letters {
+			return lang
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWordCountAndLanguageFixtures(t *testing.T) {
+	tests := []struct {
+		fixture            string
+		minWords, maxWords int
+		cjk                bool
+		language           string
+	}{
+		{"lang/en.txt", 75, 75, false, "en"},
+		{"lang/fr.txt", 67, 67, false, "fr"},
+		{"lang/ru.txt", 55, 55, false, "ru"},
+		// un mot par caractère ; le signe de prolongation ー n'est ni kana ni kanji
+		{"lang/ja.txt", 115, 122, true, "ja"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			text := string(readFixture(t, tt.fixture))
+			words, cjk := countWords(text)
+			if words < tt.minWords || words > tt.maxWords {
+				t.Errorf("%d words, want %d-%d", words, tt.minWords, tt.maxWords)
+			}
+			if tt.cjk != (cjk > 0) || (tt.cjk && cjk < tt.minWords-10) {
+				t.Errorf("%d cjk characters", cjk)
+			}
+			if lang := detectLanguage(text, words); lang != tt.language {
+				t.Errorf("language %q, want %q", lang, tt.language)
+			}
+		})
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		text       string
+		words, cjk int
+	}{
+		{"", 0, 0},
+		{"one two  three\nfour", 4, 0},
+		{"l'été, rez-de-chaussée — aujourd’hui.", 3, 0},
+		{"3 apples, 12.5 kg", 5, 0},
+		{"東京都", 3, 3},
+		{"Go言語 is fun", 5, 2},
+	}
+	for _, tt := range tests {
+		if words, cjk := countWords(tt.text); words != tt.words || cjk != tt.cjk {
+			t.Errorf("countWords(%q) = %d, %d; want %d, %d", tt.text, words, cjk, tt.words, tt.cjk)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		words, cjk, wpm, cpm, want int
+	}{
+		{0, 0, 230, 500, 0},
+		{230, 0, 230, 500, 60},
+		{231, 0, 230, 500, 61},   // arrondi à la seconde supérieure
+		{500, 500, 230, 500, 60}, // chinois et japonais au caractère
+		{330, 100, 230, 500, 72},
+		{120, 0, 60, 500, 120},
+	}
+	for _, tt := range tests {
+		if got := readingTime(tt.words, tt.cjk, tt.wpm, tt.cpm); got != tt.want {
+			t.Errorf("readingTime(%d, %d, %d, %d) = %d, want %d", tt.words, tt.cjk, tt.wpm, tt.cpm, got, tt.want)
+		}
+	}
+}
+
+// sous 40 mots : pas de langue plutôt qu'une supposition
+func TestShortTextHasNoLanguage(t *testing.T) {
+	text := "The library reopened this week after two years of work."
+	words, _ := countWords(text)
+	if lang := detectLanguage(text, words); lang != "" {
+		t.Errorf("language %q for %d words", lang, words)
+	}
+	ja := strings.Repeat("図書館", 5)
+	words, _ = countWords(ja)
+	if lang := detectLanguage(ja, words); lang != "" {
+		t.Errorf("language %q for %d characters", lang, words)
+	}
+}
+
+func TestArticleStats(t *testing.T) {
+	e := newTestEngine(t, map[string]string{"READING_WPM": "75"})
+	article := &Article{CleanText: string(readFixture(t, "lang/en.txt"))}
+	article.computeStats(e.cfg)
+	if article.WordCount != 75 || article.ReadingTime != 60 || article.Language != "en" {
+		t.Errorf("words=%d reading=%ds language=%q", article.WordCount, article.ReadingTime, article.Language)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// séparateurs entre titre, rubrique et nom du site ; les tirets exigent des
+// espaces autour ("1990–2000" reste intact)
+var titleSeparator = regexp.MustCompile(`\s*\|\s*|\s+(?:–|—|-|::|»|·)\s+`)
+
+// segment de pagination : "Page 2", "page 2 of 5", "Seite 3"...
+var titlePageSegment = regexp.MustCompile(`(?i)^(?:page|p\.|pg\.?|seite|página|pagina|strona)\s*\d+(?:\s*(?:of|sur|de|von|/)\s*\d+)?$`)
+
+// même marqueur collé en fin de titre : "Titre (page 2)", "Titre, page 2"
+var titlePageSuffix = regexp.MustCompile(`(?i)[\s,(\[]+(?:page|seite|página|pagina)\s*\d+(?:\s*(?:of|sur|de|von|/)\s*\d+)?[)\]]?$`)
+
+// au-delà, un segment qui contient le nom de domaine est un titre, pas le site
+const maxSiteSegmentWords = 4
+
+// titleSegment : texte d'un segment et séparateur qui le précède
+type titleSegment struct {
+	sep  string
+	text string
+}
+
+// cleanTitle retire du titre brut le nom du site, la rubrique et la
+// pagination. Seuls les segments situés aux extrémités sont retirés ; parmi
+// ceux qui restent, la plus longue suite de segments identique au <h1>, à
+// og:title ou au headline JSON-LD est retenue. Sans indice, le titre reste
+// tel quel : "Punch | Judy: a history" n'est pas coupé sans raison.
+func cleanTitle(raw string, references []string, siteName, pageURL string) string {
+	title := strings.Join(strings.Fields(raw), " ")
+	segments := splitTitle(title)
+	if len(segments) == 1 {
+		return stripPageSuffix(title)
+	}
+
+	label := hostLabel(pageURL)
+	isNoise := func(seg titleSegment, others []titleSegment) bool {
+		if titlePageSegment.MatchString(seg.text) {
+			return true
+		}
+		return isSiteSegment(seg.text, siteName, label, others)
+	}
+	for len(segments) > 1 {
+		last := segments[len(segments)-1]
+		if isNoise(last, segments[:len(segments)-1]) {
+			segments = segments[:len(segments)-1]
+			continue
+		}
+		if isNoise(segments[0], segments[1:]) {
+			segments = segments[1:]
+			segments[0].sep = ""
+			continue
+		}
+		break
+	}
+
+	// plus longue suite de segments égale à une référence
+	refs := make(map[string]bool)
+	for _, ref := range references {
+		if ref = titleKey(ref); ref != "" {
+			refs[ref] = true
+		}
+	}
+	bestStart, bestEnd := 0, len(segments)
+	found := false
+	for size := len(segments); size >= 1 && !found; size-- {
+		for start := 0; start+size <= len(segments); start++ {
+			if refs[titleKey(joinTitle(segments[start:start+size]))] {
+				bestStart, bestEnd, found = start, start+size, true
+				break
+			}
+		}
+	}
+	segments = segments[bestStart:bestEnd]
+	segments[0].sep = ""
+
+	if cleaned := stripPageSuffix(joinTitle(segments)); cleaned != "" {
+		return cleaned
+	}
+	return title
+}
+
+func splitTitle(title string) []titleSegment {
+	var segments []titleSegment
+	prev, sep := 0, ""
+	for _, loc := range titleSeparator.FindAllStringIndex(title, -1) {
+		if text := title[prev:loc[0]]; strings.TrimSpace(text) != "" {
+			segments = append(segments, titleSegment{sep: sep, text: text})
+			sep = ""
+		}
+		sep += title[loc[0]:loc[1]]
+		prev = loc[1]
+	}
+	if text := title[prev:]; strings.TrimSpace(text) != "" {
+		segments = append(segments, titleSegment{sep: sep, text: text})
+	}
+	if len(segments) == 0 {
+		return []titleSegment{{text: title}}
+	}
+	segments[0].sep = ""
+	return segments
+}
+
+func joinTitle(segments []titleSegment) string {
+	var b strings.Builder
+	for _, seg := range segments {
+		b.WriteString(seg.sep)
+		b.WriteString(seg.text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func stripPageSuffix(title string) string {
+	return strings.TrimSpace(titlePageSuffix.ReplaceAllString(title, ""))
+}
+
+// isSiteSegment reconnaît le nom du site : og:site_name, ou un segment court
+// contenant le nom de domaine et pas plus long que le reste du titre
+func isSiteSegment(text, siteName, label string, others []titleSegment) bool {
+	key := titleKey(text)
+	if key == "" {
+		return false
+	}
+	if site := titleKey(siteName); site != "" && key == site {
+		return true
+	}
+	if label == "" || !strings.Contains(strings.ReplaceAll(key, " ", ""), label) {
+		return false
+	}
+	words := len(strings.Fields(key))
+	if words > maxSiteSegmentWords {
+		return false
+	}
+	for _, other := range others {
+		if len(strings.Fields(other.text)) >= words {
+			return true
+		}
+	}
+	return false
+}
+
+// hostLabel : nom du domaine enregistrable sans suffixe (blog.acme.co.uk -> acme)
+func hostLabel(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(strings.TrimPrefix(u.Hostname(), "www."))
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return ""
+	}
+	label, _, _ := strings.Cut(domain, ".")
+	if len(label) < 3 {
+		return ""
+	}
+	return strings.ReplaceAll(label, "-", "")
+}
+
+// titleKey : minuscules, lettres et chiffres seulement, pour comparer des titres
+func titleKey(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
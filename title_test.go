@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		refs     []string // <h1>, headline JSON-LD, og:title
+		siteName string
+		pageURL  string
+		want     string
+	}{
+		{"site and page", "How We Scaled Postgres | Acme Engineering Blog – Page 2", []string{"How We Scaled Postgres"}, "Acme Engineering Blog", "https://blog.acme.com/postgres", "How We Scaled Postgres"},
+		{"site from host", "Inside the new transit plan - The Metro Herald", nil, "", "https://www.metroherald.com/transit", "Inside the new transit plan"},
+		{"site first", "BBC News » Storm closes coastal roads", nil, "BBC News", "https://www.bbc.co.uk/news/1", "Storm closes coastal roads"},
+		{"section and site", "Budget vote delayed again | Politics | The Daily Ledger", []string{"Budget vote delayed again"}, "The Daily Ledger", "https://ledger.example/p", "Budget vote delayed again"},
+		{"separator in headline", "Punch | Judy: a history", nil, "", "https://puppets.example/punch", "Punch | Judy: a history"},
+		{"separator in headline with site", "Punch | Judy: a history – Puppet Weekly", []string{"Punch | Judy: a history"}, "Puppet Weekly", "https://puppetweekly.example/a", "Punch | Judy: a history"},
+		{"single segment", "Why the sea is salty", nil, "Ocean Facts", "https://oceanfacts.example/salt", "Why the sea is salty"},
+		{"single segment with page suffix", "Why the sea is salty (page 3)", nil, "", "https://oceanfacts.example/salt", "Why the sea is salty"},
+		{"page of", "Long read: the harbour years :: Page 2 of 5 :: Harbour Gazette", nil, "Harbour Gazette", "https://harbour.example/long", "Long read: the harbour years"},
+		{"en dash in dates", "The 1990–2000 decade in review | Archive Monthly", nil, "Archive Monthly", "https://archive.example/decade", "The 1990–2000 decade in review"},
+		{"og:title picks the segment", "Recipes · Lemon tart with almond crust · Cook&Co", []string{"", "", "Lemon tart with almond crust"}, "", "https://cookandco.example/tart", "Lemon tart with almond crust"},
+		{"headline spans segments", "Review — Dune — Part Two — Film Club", []string{"Dune — Part Two"}, "Film Club", "https://filmclub.example/dune", "Dune — Part Two"},
+		{"long segment with the host kept", "GitHub Copilot is now available to every student worldwide | GitHub", nil, "", "https://github.blog/news", "GitHub Copilot is now available to every student worldwide"},
+		{"whitespace collapsed", "  Night trains\n return   to Europe  |  Rail News ", nil, "Rail News", "https://railnews.example/n", "Night trains return to Europe"},
+		{"French pagination", "Le retour des trains de nuit - Page 2 - Le Rail", nil, "Le Rail", "https://lerail.example/nuit", "Le retour des trains de nuit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanTitle(tt.raw, tt.refs, tt.siteName, tt.pageURL); got != tt.want {
+				t.Errorf("cleanTitle(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanTitleKeepsRawTitle(t *testing.T) {
+	article := extractFixture(t, newTestEngine(t, nil), "title/blog.html", extractOptions{})
+	if article.Title != "How We Scaled Postgres" {
+		t.Errorf("title %q", article.Title)
+	}
+	if article.RawTitle != "How We Scaled Postgres | Acme Engineering Blog – Page 2" {
+		t.Errorf("raw_title %q", article.RawTitle)
+	}
+}
+
+func TestHostLabel(t *testing.T) {
+	tests := map[string]string{
+		"https://blog.acme.co.uk/post":  "acme",
+		"https://www.metro-herald.com/": "metroherald",
+		"https://bbc.co.uk/":            "bbc",
+		"https://x.io/":                 "", // trop court pour être reconnu
+		"not a url":                     "",
+	}
+	for pageURL, want := range tests {
+		if got := hostLabel(pageURL); got != want {
+			t.Errorf("hostLabel(%q) = %q, want %q", pageURL, got, want)
+		}
+	}
+}
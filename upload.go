@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type uploadRequest struct {
+	HTML string `json:"html"`
+	URL  string `json:"url"`
+}
+
+// extractPostHandler applique le même pipeline que GET /extract à un document
+// fourni par le client (text/html ou JSON {"html", "url"}), sans aucun fetch
+func (s *server) extractPostHandler(c *gin.Context) {
+	opts, err := s.queryOptions(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	body, contentType, pageURL, ok := s.readUpload(c)
+	if !ok {
+		return
+	}
+
+	extract := func(ctx context.Context) (any, error) {
+		article, err := s.engine.extractUploaded(ctx, body, contentType, pageURL, opts)
+		if err != nil {
+			return nil, err
+		}
+		return fields.project(article)
+	}
+	if c.Query("async") == "true" {
+		s.startJob(c, c.Query("callback_url"), extract)
+		return
+	}
+
+	article, err := extract(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, article)
+}
+
+// bindJSON décode le corps JSON dans dst, limité à MAX_UPLOAD_BYTES ; en cas
+// d'erreur, la réponse est déjà écrite et ok vaut false
+func (s *server) bindJSON(c *gin.Context, dst any) (ok bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.cfg.MaxUploadBytes)
+	if err := c.ShouldBindJSON(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, newAPIError(http.StatusRequestEntityTooLarge, codeBodyTooLarge, "request body too large"))
+			return false
+		}
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid json body"))
+		return false
+	}
+	return true
+}
+
+// readUpload lit le document envoyé (text/html ou JSON {"html", "url"}) ;
+// en cas d'erreur, la réponse est déjà écrite et ok vaut false
+func (s *server) readUpload(c *gin.Context) (body []byte, contentType, pageURL string, ok bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, s.cfg.MaxUploadBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, newAPIError(http.StatusRequestEntityTooLarge, codeBodyTooLarge, "request body too large"))
+			return nil, "", "", false
+		}
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "failed to read request body"))
+		return nil, "", "", false
+	}
+
+	contentType = c.GetHeader("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	pageURL = c.Query("url")
+
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+	case "application/json":
+		var req uploadRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid json body"))
+			return nil, "", "", false
+		}
+		body = []byte(req.HTML)
+		contentType = "text/html; charset=utf-8"
+		if req.URL != "" {
+			pageURL = req.URL
+		}
+	default:
+		respondError(c, newAPIError(http.StatusUnsupportedMediaType, codeUnsupportedContentType, "expected text/html or application/json"))
+		return nil, "", "", false
+	}
+	if len(body) == 0 {
+		respondError(c, newAPIError(http.StatusBadRequest, codeInvalidRequest, "missing html"))
+		return nil, "", "", false
+	}
+	return body, contentType, pageURL, true
+}
+
+// extractUploaded extrait l'article d'un document envoyé, pageURL servant de base
+func (e *engine) extractUploaded(ctx context.Context, body []byte, contentType, pageURL string, opts extractOptions) (*Article, error) {
+	article, err := e.extractHTML(ctx, body, contentType, pageURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	article.FinalURL = pageURL
+	article.RedirectChain = []string{}
+	article.PageURLs = []string{}
+	if article.CanonicalURL == "" {
+		article.CanonicalURL = pageURL
+	}
+	return article, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// champs produits par le pipeline, identiques quelle que soit la source du document
+func pipelineFields(a *Article) map[string]any {
+	return map[string]any{
+		"title": a.Title, "author": a.Author, "published_at": a.PublishedAt, "image": a.Image,
+		"description": a.Description, "site_name": a.SiteName, "clean_text": a.CleanText,
+		"content": a.Content, "format": a.Format, "word_count": a.WordCount, "language": a.Language,
+		"content_hash": a.ContentHash, "images": a.Images, "canonical_url": a.CanonicalURL,
+	}
+}
+
+func TestPostExtractMatchesGet(t *testing.T) {
+	fixtures := fixtureServer(t)
+	_, ts := newTestServer(t, nil)
+	pageURL := fixtures.URL + "/meta.html"
+	page := string(readFixture(t, "meta.html"))
+
+	for _, format := range []string{formatText, formatMarkdown} {
+		t.Run(format, func(t *testing.T) {
+			var fetched Article
+			if status := apiGet(t, ts, "/extract?format="+format+"&url="+url.QueryEscape(pageURL), &fetched); status != http.StatusOK {
+				t.Fatalf("GET: %d", status)
+			}
+			want := pipelineFields(&fetched)
+
+			status, body := apiRequest(t, ts, http.MethodPost, "/extract?format="+format+"&url="+url.QueryEscape(pageURL), "text/html; charset=utf-8", page)
+			if status != http.StatusOK {
+				t.Fatalf("POST text/html: %d %s", status, body)
+			}
+			var posted Article
+			json.Unmarshal(body, &posted)
+			if got := pipelineFields(&posted); !reflect.DeepEqual(got, want) {
+				t.Errorf("text/html upload differs:\n%+v\nwant:\n%+v", got, want)
+			}
+
+			payload, _ := json.Marshal(uploadRequest{HTML: page, URL: pageURL})
+			status, body = apiRequest(t, ts, http.MethodPost, "/extract?format="+format, "application/json", string(payload))
+			if status != http.StatusOK {
+				t.Fatalf("POST json: %d %s", status, body)
+			}
+			posted = Article{}
+			json.Unmarshal(body, &posted)
+			if got := pipelineFields(&posted); !reflect.DeepEqual(got, want) {
+				t.Errorf("json upload differs:\n%+v\nwant:\n%+v", got, want)
+			}
+		})
+	}
+}
+
+// l'URL fournie sert de base et de canonical_url, sans jamais être contactée
+func TestPostExtractNeverFetches(t *testing.T) {
+	origin, originServer := newCountingOrigin(t, false)
+	_, ts := newTestServer(t, nil)
+	page := `<html><head><title>Offline</title></head><body><article><p>An uploaded page with a <a href="/next">relative link</a> and enough words to be kept.</p><img src="pic.jpg" alt="Pic"></article></body></html>`
+	payload, _ := json.Marshal(uploadRequest{HTML: page, URL: originServer.URL + "/story/"})
+
+	status, body := apiRequest(t, ts, http.MethodPost, "/extract?include_links=true", "application/json", string(payload))
+	if status != http.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	var article Article
+	json.Unmarshal(body, &article)
+	if origin.hits.Load() != 0 {
+		t.Errorf("the origin was contacted %d times", origin.hits.Load())
+	}
+	if article.CanonicalURL != originServer.URL+"/story/" {
+		t.Errorf("canonical_url %q", article.CanonicalURL)
+	}
+	if len(article.Images) != 1 || article.Images[0].URL != originServer.URL+"/story/pic.jpg" {
+		t.Errorf("images %+v", article.Images)
+	}
+	if len(article.Links) != 1 || article.Links[0].URL != originServer.URL+"/next" {
+		t.Errorf("links %+v", article.Links)
+	}
+}
+
+func TestPostExtractErrors(t *testing.T) {
+	_, ts := newTestServer(t, map[string]string{"MAX_UPLOAD_BYTES": "100"})
+	tests := []struct {
+		name, contentType, body string
+		status                  int
+	}{
+		{"too large", "text/html", "<p>" + strings.Repeat("x", 200) + "</p>", http.StatusRequestEntityTooLarge},
+		{"empty", "text/html", "", http.StatusBadRequest},
+		{"missing html", "application/json", `{"url": "https://example.com/"}`, http.StatusBadRequest},
+		{"bad json", "application/json", `{"html": `, http.StatusBadRequest},
+		{"wrong type", "application/pdf", "%PDF", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := apiRequest(t, ts, http.MethodPost, "/extract", tt.contentType, tt.body)
+			if status != tt.status {
+				t.Errorf("got %d %s, want %d", status, body, tt.status)
+			}
+		})
+	}
+}